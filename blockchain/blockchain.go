@@ -134,14 +134,6 @@ func (self *Blockchain) RegisterDepositOperator(signer blockchain.Signer, nonceC
 	self.RegisterOperator(DEPOSIT_OP, blockchain.NewOperator(signer, nonceCorpus))
 }
 
-func readablePrint(data map[ethereum.Address]byte) string {
-	result := ""
-	for addr, b := range data {
-		result = result + "|" + fmt.Sprintf("%s-%d", addr.Hex(), b)
-	}
-	return result
-}
-
 //====================== Write calls ===============================
 
 // TODO: Need better test coverage
@@ -157,83 +149,75 @@ func (self *Blockchain) SetRates(
 	gasPrice *big.Int) (*types.Transaction, error) {
 
 	block.Add(block, big.NewInt(1))
-	copts := self.GetCallOpts(0)
-	baseBuys, baseSells, _, _, _, err := self.GeneratedGetTokenRates(
-		copts, self.pricingAddr, tokens,
-	)
+	plan, err := self.PlanRates(tokens, buys, sells, gasPrice)
 	if err != nil {
 		return nil, err
 	}
-	baseTokens := []ethereum.Address{}
-	newBSells := []*big.Int{}
-	newBBuys := []*big.Int{}
-	newCSells := map[ethereum.Address]byte{}
-	newCBuys := map[ethereum.Address]byte{}
-	for i, token := range tokens {
-		compactSell, overflow1 := BigIntToCompactRate(sells[i], baseSells[i])
-		compactBuy, overflow2 := BigIntToCompactRate(buys[i], baseBuys[i])
-		if overflow1 || overflow2 {
-			baseTokens = append(baseTokens, token)
-			newBSells = append(newBSells, sells[i])
-			newBBuys = append(newBBuys, buys[i])
-			newCSells[token] = 0
-			newCBuys[token] = 0
-		} else {
-			newCSells[token] = compactSell.Compact
-			newCBuys[token] = compactBuy.Compact
-		}
-	}
-	bbuys, bsells, indices := BuildCompactBulk(
-		newCBuys,
-		newCSells,
-		self.tokenIndices,
-	)
 	opts, err := self.GetTxOpts(PRICING_OP, nonce, gasPrice, nil)
 	if err != nil {
 		log.Printf("Getting transaction opts failed, err: %s", err)
 		return nil, err
 	} else {
 		var tx *types.Transaction
-		if len(baseTokens) > 0 {
+		if plan.Method == SET_BASE_RATE_METHOD {
 			// set base tx
 			tx, err = self.GeneratedSetBaseRate(
-				opts, baseTokens, newBBuys, newBSells,
-				bbuys, bsells, block, indices)
+				opts, plan.BaseTokens, plan.BaseBuys, plan.BaseSells,
+				plan.BuyBulks, plan.SellBulks, block, plan.Indices)
 			if tx != nil {
 				log.Printf(
-					"broadcasting setbase tx %s, target buys(%s), target sells(%s), old base buy(%s) || old base sell(%s) || new base buy(%s) || new base sell(%s) || new compact buy(%s) || new compact sell(%s) || new buy bulk(%v) || new sell bulk(%v) || indices(%v)",
+					"broadcasting setbase tx %s, target buys(%s), target sells(%s), overflowed tokens(%v) || rebased tokens(%v) || new base buy(%s) || new base sell(%s) || new buy bulk(%v) || new sell bulk(%v) || indices(%v)",
 					tx.Hash().Hex(),
 					buys, sells,
-					baseBuys, baseSells,
-					newBBuys, newBSells,
-					readablePrint(newCBuys), readablePrint(newCSells),
-					bbuys, bsells, indices,
+					plan.Overflowed, plan.Rebased,
+					plan.BaseBuys, plan.BaseSells,
+					plan.BuyBulks, plan.SellBulks, plan.Indices,
 				)
 			}
 		} else {
 			// update compact tx
 			tx, err = self.GeneratedSetCompactData(
-				opts, bbuys, bsells, block, indices)
+				opts, plan.BuyBulks, plan.SellBulks, block, plan.Indices)
 			if tx != nil {
 				log.Printf(
-					"broadcasting setcompact tx %s, target buys(%s), target sells(%s), old base buy(%s) || old base sell(%s) || new compact buy(%s) || new compact sell(%s) || new buy bulk(%v) || new sell bulk(%v) || indices(%v)",
+					"broadcasting setcompact tx %s, target buys(%s), target sells(%s), new buy bulk(%v) || new sell bulk(%v) || indices(%v)",
 					tx.Hash().Hex(),
 					buys, sells,
-					baseBuys, baseSells,
-					readablePrint(newCBuys), readablePrint(newCSells),
-					bbuys, bsells, indices,
+					plan.BuyBulks, plan.SellBulks, plan.Indices,
 				)
 			}
-			// log.Printf("Setting compact rates: tx(%s), err(%v) with basesells(%+v), buys(%+v), sells(%+v), block(%s), indices(%+v)",
-			// 	tx.Hash().Hex(), err, baseTokens, buys, sells, block.Text(10), indices,
-			// )
 		}
 		if err != nil {
+			self.ReleaseNonce(opts)
 			return nil, err
-		} else {
-			return self.SignAndBroadcast(tx, PRICING_OP)
 		}
+		signedTx, err := self.SignAndBroadcast(tx, PRICING_OP)
+		if signedTx == nil {
+			self.ReleaseNonce(opts)
+		}
+		return signedTx, err
+	}
+}
+
+// PlanRates plans setting buys and sells of tokens against their current
+// base rates without sending any transaction, see PlanCompactRates
+func (self *Blockchain) PlanRates(
+	tokens []ethereum.Address,
+	buys []*big.Int,
+	sells []*big.Int,
+	gasPrice *big.Int) (common.CompactRatePlan, error) {
+
+	copts := self.GetCallOpts(0)
+	baseBuys, baseSells, _, _, _, err := self.GeneratedGetTokenRates(
+		copts, self.pricingAddr, tokens,
+	)
+	if err != nil {
+		return common.CompactRatePlan{}, err
 	}
+	return PlanCompactRates(
+		tokens, buys, sells, baseBuys, baseSells,
+		self.tokenIndices, COMPACT_REBASE_THRESHOLD, gasPrice,
+	), nil
 }
 
 func (self *Blockchain) Send(
@@ -250,10 +234,14 @@ func (self *Blockchain) Send(
 			ethereum.HexToAddress(token.Address),
 			amount, dest)
 		if err != nil {
+			self.ReleaseNonce(opts)
 			return nil, err
-		} else {
-			return self.SignAndBroadcast(tx, DEPOSIT_OP)
 		}
+		signedTx, err := self.SignAndBroadcast(tx, DEPOSIT_OP)
+		if signedTx == nil {
+			self.ReleaseNonce(opts)
+		}
+		return signedTx, err
 	}
 }
 
@@ -265,9 +253,14 @@ func (self *Blockchain) SetImbalanceStepFunction(token ethereum.Address, xBuy []
 	} else {
 		tx, err := self.GeneratedSetImbalanceStepFunction(opts, token, xBuy, yBuy, xSell, ySell)
 		if err != nil {
+			self.ReleaseNonce(opts)
 			return nil, err
 		}
-		return self.SignAndBroadcast(tx, PRICING_OP)
+		signedTx, err := self.SignAndBroadcast(tx, PRICING_OP)
+		if signedTx == nil {
+			self.ReleaseNonce(opts)
+		}
+		return signedTx, err
 	}
 }
 
@@ -279,9 +272,14 @@ func (self *Blockchain) SetQtyStepFunction(token ethereum.Address, xBuy []*big.I
 	} else {
 		tx, err := self.GeneratedSetQtyStepFunction(opts, token, xBuy, yBuy, xSell, ySell)
 		if err != nil {
+			self.ReleaseNonce(opts)
 			return nil, err
 		}
-		return self.SignAndBroadcast(tx, PRICING_OP)
+		signedTx, err := self.SignAndBroadcast(tx, PRICING_OP)
+		if signedTx == nil {
+			self.ReleaseNonce(opts)
+		}
+		return signedTx, err
 	}
 }
 
@@ -330,6 +328,47 @@ func (self *Blockchain) FetchBalanceData(reserve ethereum.Address, atBlock uint6
 	return result, nil
 }
 
+// commands of getStepFunctionData of the pricing contract, each array of
+// a step function has a command for its length followed by a command for
+// its values, imbalance step function commands follow qty ones
+const (
+	QTY_STEP_FUNCTION_COMMAND       int64 = 0
+	IMBALANCE_STEP_FUNCTION_COMMAND int64 = 8
+)
+
+// GetStepFunction reads the qty or imbalance step function of token from
+// the pricing contract
+func (self *Blockchain) GetStepFunction(token ethereum.Address, kind string) (common.StepFunction, error) {
+	result := common.StepFunction{}
+	var command int64
+	switch kind {
+	case common.QTY_STEP_FUNCTION:
+		command = QTY_STEP_FUNCTION_COMMAND
+	case common.IMBALANCE_STEP_FUNCTION:
+		command = IMBALANCE_STEP_FUNCTION_COMMAND
+	default:
+		return result, fmt.Errorf("Step function type %s is not supported", kind)
+	}
+	opts := self.GetCallOpts(0)
+	arrays := []*[]*big.Int{&result.XBuy, &result.YBuy, &result.XSell, &result.YSell}
+	for _, array := range arrays {
+		length, err := self.GeneratedGetStepFunctionData(opts, token, big.NewInt(command), Big0)
+		if err != nil {
+			return result, err
+		}
+		*array = []*big.Int{}
+		for i := int64(0); i < length.Int64(); i++ {
+			value, err := self.GeneratedGetStepFunctionData(opts, token, big.NewInt(command+1), big.NewInt(i))
+			if err != nil {
+				return result, err
+			}
+			*array = append(*array, value)
+		}
+		command += 2
+	}
+	return result, nil
+}
+
 func (self *Blockchain) FetchRates(atBlock uint64, currentBlock uint64) (common.AllRateEntry, error) {
 	result := common.AllRateEntry{}
 	tokenAddrs := []ethereum.Address{}
@@ -474,6 +513,7 @@ func (self *Blockchain) GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLo
 					// start new TradeLog
 					tradeLog = &common.TradeLog{}
 					tradeLog.BlockNumber = l.BlockNumber
+					tradeLog.BlockHash = l.BlockHash
 					tradeLog.TransactionHash = l.TxHash
 					tradeLog.Index = l.Index
 					tradeLog.Timestamp, err = self.InterpretTimestamp(
@@ -506,6 +546,7 @@ func (self *Blockchain) GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLo
 					result = append(result, common.SetCatLog{
 						Timestamp:       t,
 						BlockNumber:     l.BlockNumber,
+						BlockHash:       l.BlockHash,
 						TransactionHash: l.TxHash,
 						Index:           l.Index,
 						Address:         addr,
@@ -529,7 +570,15 @@ func (self *Blockchain) GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLo
 					tradeLog.DestAmount = destAmount.Big()
 					tradeLog.UserAddress = ethereum.BytesToAddress(l.Topics[1].Bytes())
 
-					if ethRate := self.GetEthRate(tradeLog.Timestamp / 1000000); ethRate != 0 {
+					timepoint := tradeLog.Timestamp / 1000000
+					ethRate, exact := self.GetEthRate(timepoint)
+					if ethRate == 0 {
+						log.Printf("LogFetcher - No ETH-USD rate at %d, tx %s has no fiat amount", timepoint, l.TxHash.Hex())
+					} else {
+						if !exact {
+							log.Printf("LogFetcher - ETH-USD rate at %d of tx %s is not exact", timepoint, l.TxHash.Hex())
+						}
+						tradeLog.FiatRateExact = exact
 						// fiatAmount = amount * ethRate
 						eth := common.ETHToken()
 						f := new(big.Float)
@@ -562,6 +611,57 @@ func (self *Blockchain) SetRateMinedNonce() (uint64, error) {
 	return self.GetMinedNonce(PRICING_OP)
 }
 
+// activityOperator returns the operator sending txs of activities of
+// action
+func activityOperator(action string) (string, error) {
+	switch action {
+	case "set_rates", "set_step_function":
+		return PRICING_OP, nil
+	case "deposit":
+		return DEPOSIT_OP, nil
+	}
+	return "", fmt.Errorf("%s activities don't send txs of an operator", action)
+}
+
+// ActivityGasPrice returns the gas price of new txs of activities of action
+func (self *Blockchain) ActivityGasPrice(action string) (*big.Int, error) {
+	op, err := activityOperator(action)
+	if err != nil {
+		return nil, err
+	}
+	return self.SuggestGasPrice(op)
+}
+
+// ActivityReplacementGasPrice returns the gas price of a tx of activities
+// of action replacing a pending tx at oldPrice
+func (self *Blockchain) ActivityReplacementGasPrice(action string, oldPrice *big.Int) (*big.Int, error) {
+	op, err := activityOperator(action)
+	if err != nil {
+		return nil, err
+	}
+	return self.ReplacementGasPrice(op, oldPrice)
+}
+
+// ReplaceStuckTx replaces tx of an activity of action at a higher gas
+// price when it has been pending longer than the operator policy allows
+// since submittedAt (millisecond). It returns nil if the tx is not stuck
+// or no longer pending.
+func (self *Blockchain) ReplaceStuckTx(action string, tx ethereum.Hash, submittedAt uint64) (*types.Transaction, error) {
+	op, err := activityOperator(action)
+	if err != nil {
+		return nil, err
+	}
+	policy := self.GasPricePolicy(op)
+	if policy.StuckAfter == 0 || common.GetTimepoint() < submittedAt+policy.StuckAfter*1000 {
+		return nil, nil
+	}
+	newTx, err := self.ReplaceTx(op, tx)
+	if err == blockchain.ErrTxNotPending {
+		return nil, nil
+	}
+	return newTx, err
+}
+
 func NewBlockchain(
 	base *blockchain.BaseBlockchain,
 	wrapperAddr, pricingAddr, burnerAddr,
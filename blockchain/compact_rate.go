@@ -2,11 +2,29 @@ package blockchain
 
 import (
 	"math/big"
+	"sort"
 	"strconv"
 
+	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+const (
+	SET_BASE_RATE_METHOD    string = "setBaseRate"
+	SET_COMPACT_DATA_METHOD string = "setCompactData"
+
+	// COMPACT_REBASE_THRESHOLD is the absolute compact rate from which
+	// tokens are rebased when a setBaseRate transaction is sent anyway, so
+	// they don't need another one soon
+	COMPACT_REBASE_THRESHOLD int64 = 100
+
+	// rough gas estimates of set rates transactions: a fixed cost, a cost
+	// per compact bulk and a cost per token of setBaseRate
+	SET_RATES_TX_GAS    uint64 = 35000
+	COMPACT_BULK_GAS    uint64 = 7000
+	BASE_RATE_TOKEN_GAS uint64 = 13000
+)
+
 type CompactRate struct {
 	Base    *big.Int
 	Compact byte
@@ -73,10 +91,93 @@ func BuildCompactBulk(newBuys, newSells map[ethereum.Address]byte, indices map[s
 		b = sellBulks[index.BulkIndex]
 		b.data[index.IndexInBulk] = newSells[addr]
 	}
-	for index, buy := range buyBulks {
-		buyResults = append(buyResults, buy.data)
+	// bulks are ordered by index so the same rates give the same data
+	bulkIndices := []uint64{}
+	for index := range buyBulks {
+		bulkIndices = append(bulkIndices, index)
+	}
+	sort.Slice(bulkIndices, func(i, j int) bool { return bulkIndices[i] < bulkIndices[j] })
+	for _, index := range bulkIndices {
+		buyResults = append(buyResults, buyBulks[index].data)
 		sellResults = append(sellResults, sellBulks[index].data)
 		indexResults = append(indexResults, big.NewInt(int64(index)))
 	}
 	return buyResults, sellResults, indexResults
 }
+
+// compactValue returns the signed value of a compact rate
+func compactValue(compact byte) int64 {
+	return int64(int8(compact))
+}
+
+// PlanCompactRates decides how to set buys and sells of tokens given their
+// current base rates. Tokens whose compact rates overflow must be rebased
+// with setBaseRate. As setBaseRate is expensive, when it is needed anyway
+// tokens whose compact rates are at least threshold away from their base
+// are rebased too. Otherwise only compact bulks are set.
+func PlanCompactRates(
+	tokens []ethereum.Address,
+	buys, sells, baseBuys, baseSells []*big.Int,
+	indices map[string]tbindex,
+	threshold int64,
+	gasPrice *big.Int) common.CompactRatePlan {
+
+	plan := common.CompactRatePlan{
+		Method:     SET_COMPACT_DATA_METHOD,
+		BaseTokens: []ethereum.Address{},
+		BaseBuys:   []*big.Int{},
+		BaseSells:  []*big.Int{},
+		Overflowed: []ethereum.Address{},
+		Rebased:    []ethereum.Address{},
+		GasPrice:   gasPrice,
+	}
+	compactBuys := map[ethereum.Address]byte{}
+	compactSells := map[ethereum.Address]byte{}
+	drifting := []int{}
+	for i, token := range tokens {
+		compactSell, overflow1 := BigIntToCompactRate(sells[i], baseSells[i])
+		compactBuy, overflow2 := BigIntToCompactRate(buys[i], baseBuys[i])
+		if overflow1 || overflow2 {
+			plan.Overflowed = append(plan.Overflowed, token)
+			plan.BaseTokens = append(plan.BaseTokens, token)
+			plan.BaseBuys = append(plan.BaseBuys, buys[i])
+			plan.BaseSells = append(plan.BaseSells, sells[i])
+			compactBuys[token] = 0
+			compactSells[token] = 0
+			continue
+		}
+		compactBuys[token] = compactBuy.Compact
+		compactSells[token] = compactSell.Compact
+		if abs(compactValue(compactBuy.Compact)) >= threshold || abs(compactValue(compactSell.Compact)) >= threshold {
+			drifting = append(drifting, i)
+		}
+	}
+	if len(plan.Overflowed) > 0 {
+		plan.Method = SET_BASE_RATE_METHOD
+		for _, i := range drifting {
+			token := tokens[i]
+			plan.Rebased = append(plan.Rebased, token)
+			plan.BaseTokens = append(plan.BaseTokens, token)
+			plan.BaseBuys = append(plan.BaseBuys, buys[i])
+			plan.BaseSells = append(plan.BaseSells, sells[i])
+			compactBuys[token] = 0
+			compactSells[token] = 0
+		}
+	}
+	plan.BuyBulks, plan.SellBulks, plan.Indices = BuildCompactBulk(compactBuys, compactSells, indices)
+	plan.Gas = SET_RATES_TX_GAS +
+		uint64(len(plan.Indices))*COMPACT_BULK_GAS +
+		uint64(len(plan.BaseTokens))*BASE_RATE_TOKEN_GAS
+	plan.Cost = big.NewInt(0)
+	if gasPrice != nil {
+		plan.Cost.Mul(gasPrice, new(big.Int).SetUint64(plan.Gas))
+	}
+	return plan
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -159,3 +159,96 @@ func TestBuildCompactBulk(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildCompactBulkMultiBulks(t *testing.T) {
+	buys := map[ethereum.Address]byte{}
+	sells := map[ethereum.Address]byte{}
+	indices := map[string]tbindex{}
+	// a full bulk 1, the first and last slots of bulk 0 and one slot of bulk 4
+	layout := []tbindex{{0, 0}, {0, 13}, {4, 7}}
+	for i := uint64(0); i < 14; i++ {
+		layout = append(layout, tbindex{1, i})
+	}
+	for i, index := range layout {
+		addr := ethereum.BigToAddress(big.NewInt(int64(i + 1)))
+		buys[addr] = byte(i + 1)
+		sells[addr] = byte(100 + i)
+		indices[addr.Hex()] = index
+	}
+	buyBulk, sellBulk, bulkIndices := BuildCompactBulk(buys, sells, indices)
+	expectedBuys := [][14]byte{
+		{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+		{4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17},
+		{0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0},
+	}
+	expectedSells := [][14]byte{
+		{100, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 101},
+		{103, 104, 105, 106, 107, 108, 109, 110, 111, 112, 113, 114, 115, 116},
+		{0, 0, 0, 0, 0, 0, 0, 102, 0, 0, 0, 0, 0, 0},
+	}
+	expectedIndices := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(4)}
+	if !reflect.DeepEqual(expectedBuys, buyBulk) ||
+		!reflect.DeepEqual(expectedSells, sellBulk) ||
+		!reflect.DeepEqual(expectedIndices, bulkIndices) {
+		t.Fatalf("Expected buys(%v), sells(%v), indices(%v), got buys(%v), sells(%v), indices(%v)",
+			expectedBuys, expectedSells, expectedIndices,
+			buyBulk, sellBulk, bulkIndices,
+		)
+	}
+}
+
+func TestPlanCompactRates(t *testing.T) {
+	tokens := []ethereum.Address{
+		ethereum.HexToAddress("0x14535eE720e329f66071B86486763Da4637034aE"),
+		ethereum.HexToAddress("0x24535eE720e329F66071b86486763da4637034AE"),
+		ethereum.HexToAddress("0x34535ee720e329f66071B86486763Da4637034aE"),
+	}
+	indices := map[string]tbindex{
+		tokens[0].Hex(): {0, 1},
+		tokens[1].Hex(): {0, 2},
+		tokens[2].Hex(): {2, 0},
+	}
+	bases := []*big.Int{big.NewInt(1000), big.NewInt(1000), big.NewInt(1000)}
+	gasPrice := big.NewInt(10)
+
+	// compacts are 50, 110 (drifting) and -120 (drifting)
+	plan := PlanCompactRates(
+		tokens,
+		[]*big.Int{big.NewInt(1050), big.NewInt(1110), big.NewInt(880)},
+		[]*big.Int{big.NewInt(1000), big.NewInt(1000), big.NewInt(1000)},
+		bases, bases, indices, COMPACT_REBASE_THRESHOLD, gasPrice)
+	if plan.Method != SET_COMPACT_DATA_METHOD || len(plan.BaseTokens) != 0 || len(plan.Rebased) != 0 {
+		t.Fatalf("Expected drifting tokens not to be rebased without overflow, got %+v", plan)
+	}
+	if plan.BuyBulks[0][1] != 50 || plan.BuyBulks[0][2] != 110 || plan.BuyBulks[1][0] != 136 {
+		t.Fatalf("Unexpected compact bulks %v", plan.BuyBulks)
+	}
+	expectedGas := SET_RATES_TX_GAS + 2*COMPACT_BULK_GAS
+	if plan.Gas != expectedGas || plan.Cost.Cmp(big.NewInt(int64(expectedGas)*10)) != 0 {
+		t.Fatalf("Expected gas %d and cost %d, got %d and %s", expectedGas, expectedGas*10, plan.Gas, plan.Cost)
+	}
+
+	// the first token overflows (compact 200), the third one is rebased
+	// with it and the second one keeps its compact
+	plan = PlanCompactRates(
+		tokens,
+		[]*big.Int{big.NewInt(1200), big.NewInt(1050), big.NewInt(1000)},
+		[]*big.Int{big.NewInt(1000), big.NewInt(1000), big.NewInt(880)},
+		bases, bases, indices, COMPACT_REBASE_THRESHOLD, gasPrice)
+	if plan.Method != SET_BASE_RATE_METHOD ||
+		!reflect.DeepEqual(plan.Overflowed, []ethereum.Address{tokens[0]}) ||
+		!reflect.DeepEqual(plan.Rebased, []ethereum.Address{tokens[2]}) ||
+		!reflect.DeepEqual(plan.BaseTokens, []ethereum.Address{tokens[0], tokens[2]}) {
+		t.Fatalf("Expected first token to overflow and third to be rebased, got %+v", plan)
+	}
+	if plan.BaseBuys[0].Int64() != 1200 || plan.BaseSells[1].Int64() != 880 {
+		t.Fatalf("Expected target rates to be new base rates, got buys %v, sells %v", plan.BaseBuys, plan.BaseSells)
+	}
+	if plan.BuyBulks[0][1] != 0 || plan.BuyBulks[0][2] != 50 || plan.SellBulks[1][0] != 0 {
+		t.Fatalf("Expected rebased tokens to have zero compacts, got buys %v, sells %v", plan.BuyBulks, plan.SellBulks)
+	}
+	expectedGas = SET_RATES_TX_GAS + 2*COMPACT_BULK_GAS + 2*BASE_RATE_TOKEN_GAS
+	if plan.Gas != expectedGas {
+		t.Fatalf("Expected gas %d, got %d", expectedGas, plan.Gas)
+	}
+}
@@ -40,3 +40,10 @@ func (self *Blockchain) GeneratedGetRate(opts blockchain.CallOpts, token ethereu
 	err := self.Call(timeOut, opts, self.pricing, out, "getRate", token, currentBlockNumber, buy, qty)
 	return out, err
 }
+
+func (self *Blockchain) GeneratedGetStepFunctionData(opts blockchain.CallOpts, token ethereum.Address, command *big.Int, param *big.Int) (*big.Int, error) {
+	timeOut := 2 * time.Second
+	out := big.NewInt(0)
+	err := self.Call(timeOut, opts, self.pricing, out, "getStepFunctionData", token, command, param)
+	return out, err
+}
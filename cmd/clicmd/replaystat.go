@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/cmd/configuration"
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/stat"
+	"github.com/spf13/cobra"
+)
+
+var replayAggregations string
+var replayFromTime uint64
+var replayToTime uint64
+var replayBatch time.Duration
+
+func replayStat(cmd *cobra.Command, args []string) {
+	kyberENV := os.Getenv("KYBER_ENV")
+	if kyberENV == "" {
+		kyberENV = "dev"
+	}
+	aggregations := strings.Split(replayAggregations, ",")
+	for _, aggregation := range aggregations {
+		switch aggregation {
+		case stat.BURNFEE_AGGREGATION, stat.VOLUME_STAT_AGGREGATION, stat.TRADE_SUMMARY_AGGREGATION,
+			stat.WALLET_AGGREGATION, stat.COUNTRY_AGGREGATION, stat.USER_INFO_AGGREGATION, stat.RESERVE_STAT_AGGREGATION:
+		default:
+			log.Fatalf("Aggregation %s is not supported", aggregation)
+		}
+	}
+	toTime := replayToTime
+	if toTime == 0 {
+		toTime = common.GetTimepoint()
+	}
+	if replayFromTime > toTime {
+		log.Fatalf("from_time %d is after to_time %d", replayFromTime, toTime)
+	}
+	config := configuration.GetConfig(kyberENV, false, endpointOW, true, true)
+	statFetcher := stat.NewFetcher(
+		config.StatStorage,
+		config.LogStorage,
+		config.RateStorage,
+		config.UserStorage,
+		config.StatFetcherRunner,
+		0,
+		config.ReserveAddress,
+		config.ThirdPartyReserves,
+	)
+	for _, aggregation := range aggregations {
+		log.Printf("Replaying %s from %d to %d", aggregation, replayFromTime, toTime)
+		start := time.Now()
+		total := 0
+		err := statFetcher.ReplayAggregation(
+			aggregation,
+			replayFromTime*1000000,
+			toTime*1000000,
+			uint64(replayBatch),
+			func(batchTo, replayTo uint64, tradeCount int) {
+				total += tradeCount
+				log.Printf("%s: replayed %d trade logs up to %d/%d", aggregation, total, batchTo/1000000, replayTo/1000000)
+			},
+		)
+		if err != nil {
+			log.Fatalf("Replaying %s failed after %s: %s", aggregation, time.Since(start), err)
+		}
+		log.Printf("Replayed %s: %d trade logs in %s", aggregation, total, time.Since(start))
+	}
+}
+
+var replayStatCmd = &cobra.Command{
+	Use:   "replay-stat",
+	Short: "rebuild stat aggregations from stored trade logs",
+	Long: `Reset stats of the given aggregations in a time window and aggregate stored
+trade logs again, other aggregations are untouched. Bolt databases are locked
+by a running server, so the stat server must be stopped before replaying. A
+running stat server replays aggregations through its /replay-stat-aggregation
+endpoint instead.`,
+	Example: "KYBER_ENV=dev ./cmd replay-stat --aggregations volume_stat_aggregation,wallet_aggregation --from_time 1525132800000",
+	Run:     replayStat,
+}
+
+func init() {
+	replayStatCmd.Flags().StringVar(&replayAggregations, "aggregations", "", "comma separated aggregations to replay: burn_fee_aggregation, volume_stat_aggregation, trade_summary_aggregation, wallet_aggregation, country_aggregation, user_info_aggregation, reserve_stat_aggregation")
+	replayStatCmd.MarkFlagRequired("aggregations")
+	replayStatCmd.Flags().Uint64Var(&replayFromTime, "from_time", 0, "beginning of the replayed window in millisecond")
+	replayStatCmd.Flags().Uint64Var(&replayToTime, "to_time", 0, "end of the replayed window in millisecond, default to the last processed trade log")
+	replayStatCmd.Flags().DurationVar(&replayBatch, "batch", 24*time.Hour, "time range of trade logs aggregated in a batch")
+	replayStatCmd.Flags().StringVar(&endpointOW, "endpoint", "", "endpoint, default to configuration file")
+	RootCmd.AddCommand(replayStatCmd)
+}
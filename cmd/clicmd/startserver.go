@@ -8,16 +8,18 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data"
 	"github.com/KyberNetwork/reserve-data/blockchain"
 	"github.com/KyberNetwork/reserve-data/cmd/configuration"
 	"github.com/KyberNetwork/reserve-data/common"
-	"github.com/KyberNetwork/reserve-data/common/blockchain/nonce"
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/http"
+	"github.com/KyberNetwork/reserve-data/pricing"
+	"github.com/KyberNetwork/reserve-data/rebalancer"
 	"github.com/KyberNetwork/reserve-data/stat"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/robfig/cron"
@@ -31,6 +33,7 @@ var endpointOW string
 var base_url, auth_url string
 var enableStat bool
 var noCore bool
+var enablePricing bool
 var stdoutLog bool
 
 func loadTimestamp(path string) []uint64 {
@@ -125,6 +128,7 @@ func serverStart(cmd *cobra.Command, args []string) {
 		for _, ex := range config.FetcherExchanges {
 			dataFetcher.AddExchange(ex)
 		}
+		dataFetcher.SetCircuitBreaker(config.CircuitBreaker)
 	}
 
 	if enableStat {
@@ -159,10 +163,12 @@ func serverStart(cmd *cobra.Command, args []string) {
 	}
 
 	if !noCore {
-		nonceCorpus := nonce.NewTimeWindow(config.BlockchainSigner.GetAddress(), 2000)
-		nonceDeposit := nonce.NewTimeWindow(config.DepositSigner.GetAddress(), 10000)
+		// operators of the same address share its nonce corpus
+		nonceCorpus := config.NonceCorpora.Get(config.BlockchainSigner.GetAddress())
+		nonceDeposit := config.NonceCorpora.Get(config.DepositSigner.GetAddress())
 		bc.RegisterPricingOperator(config.BlockchainSigner, nonceCorpus)
 		bc.RegisterDepositOperator(config.DepositSigner, nonceDeposit)
+		bc.ReconcileNonces()
 	}
 
 	// we need to implicitly add old contract addresses to production
@@ -186,7 +192,36 @@ func serverStart(cmd *cobra.Command, args []string) {
 				dataFetcher,
 			)
 			rData.Run()
-			rCore = core.NewReserveCore(bc, config.ActivityStorage, config.ReserveAddress)
+			rCore = core.NewReserveCore(
+				bc,
+				config.ActivityStorage,
+				config.FetcherStorage,
+				config.DataStorage,
+				core.NewRiskChecker(config.DataStorage, config.RiskConfig),
+				config.ReserveAddress,
+			)
+			core.NewTxEscalator(
+				bc,
+				config.ActivityStorage,
+				30*time.Second,
+			).Run()
+			rebalancer.NewRebalancer(
+				config.DataStorage,
+				config.MetricStorage,
+				config.ActivityStorage,
+				rCore,
+				config.Exchanges,
+				config.RebalancerRunner,
+			).Run()
+			if enablePricing {
+				pricing.NewEngine(
+					config.DataStorage,
+					config.MetricStorage,
+					rCore,
+					config.PricingConfig,
+					config.PricingRunner,
+				).Run()
+			}
 		}
 		if enableStat {
 			statFetcher.SetBlockchain(bc)
@@ -208,9 +243,11 @@ func serverStart(cmd *cobra.Command, args []string) {
 		server := http.NewHTTPServer(
 			rData, rCore, rStat,
 			config.MetricStorage,
+			config.ProposalManager,
 			servPortStr,
 			config.EnableAuthentication,
 			config.AuthEngine,
+			config.AuditStorage,
 			kyberENV,
 		)
 
@@ -236,6 +273,7 @@ func init() {
 	startServer.PersistentFlags().StringVar(&base_url, "base_url", "http://127.0.0.1", "base_url for authenticated enpoint")
 	startServer.Flags().BoolVarP(&enableStat, "enable-stat", "", false, "enable stat related fetcher and api, event logs will not be fetched")
 	startServer.Flags().BoolVarP(&noCore, "no-core", "", false, "disable core related fetcher and api, this should be used only when we want to run an independent stat server")
+	startServer.Flags().BoolVarP(&enablePricing, "enable-pricing", "", false, "enable in-process pricing engine which sets rates from exchange orderbooks, it still respects the hold/enable setrate switch")
 	startServer.Flags().BoolVarP(&stdoutLog, "log-to-stdout", "", false, "send log to both log file and stdout terminal")
 	RootCmd.AddCommand(startServer)
 }
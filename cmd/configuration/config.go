@@ -1,22 +1,30 @@
 package configuration
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	"github.com/KyberNetwork/reserve-data/common/blockchain/nonce"
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/data/fetcher/http_runner"
 	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/exchange/binance"
+	"github.com/KyberNetwork/reserve-data/exchange/bitfinex"
 	"github.com/KyberNetwork/reserve-data/exchange/bittrex"
 	"github.com/KyberNetwork/reserve-data/exchange/huobi"
+	"github.com/KyberNetwork/reserve-data/exchange/liqui"
 	"github.com/KyberNetwork/reserve-data/http"
 	"github.com/KyberNetwork/reserve-data/metric"
+	"github.com/KyberNetwork/reserve-data/pricing"
+	"github.com/KyberNetwork/reserve-data/rebalancer"
 	"github.com/KyberNetwork/reserve-data/stat"
 	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
 	"github.com/KyberNetwork/reserve-data/world"
@@ -24,17 +32,18 @@ import (
 )
 
 type SettingPaths struct {
-	settingPath         string
-	feePath             string
-	dataStoragePath     string
-	analyticStoragePath string
-	statStoragePath     string
-	logStoragePath      string
-	rateStoragePath     string
-	userStoragePath     string
-	secretPath          string
-	endPoint            string
-	bkendpoints         []string
+	settingPath           string
+	feePath               string
+	dataStoragePath       string
+	analyticStoragePath   string
+	statStoragePath       string
+	logStoragePath        string
+	rateStoragePath       string
+	userStoragePath       string
+	ethUSDRateStoragePath string
+	secretPath            string
+	endPoint              string
+	bkendpoints           []string
 }
 
 type Config struct {
@@ -49,10 +58,19 @@ type Config struct {
 	FetcherStorage       fetcher.Storage
 	FetcherGlobalStorage fetcher.GlobalStorage
 	MetricStorage        metric.MetricStorage
+	ProposalManager      *metric.ProposalManager
+	KeyStorage           http.KeyStorage
+	AuditStorage         http.AuditStorage
+	NonceCorpora         *nonce.Corpora
+	PricingConfig        pricing.Config
+	RiskConfig           core.RiskConfig
 	//ExchangeStorage exchange.Storage
 
 	World                *world.TheWorld
 	FetcherRunner        fetcher.FetcherRunner
+	CircuitBreaker       *fetcher.CircuitBreaker
+	RebalancerRunner     rebalancer.Runner
+	PricingRunner        pricing.Runner
 	StatFetcherRunner    stat.FetcherRunner
 	StatControllerRunner stat.ControllerRunner
 	FetcherExchanges     []fetcher.Exchange
@@ -144,6 +162,97 @@ func (self *Config) AddStatConfig(settingPath SettingPaths, addressConfig common
 	self.WhitelistAddress = whitelistAddr
 }
 
+// DataStorage is implemented by every reserve data storage backend
+type DataStorage interface {
+	data.Storage
+	data.GlobalStorage
+	fetcher.Storage
+	fetcher.GlobalStorage
+	core.ActivityStorage
+	metric.MetricStorage
+	exchange.StableExStorage
+	http.KeyStorage
+	http.AuditStorage
+	nonce.Storage
+}
+
+// NewDataStorage creates the storage backend chosen by KYBER_STORAGE env,
+// it is "bolt" (default) or "postgres". Postgres is connected with the
+// data source name in KYBER_POSTGRES_DSN env.
+func NewDataStorage(settingPath SettingPaths) (DataStorage, error) {
+	switch os.Getenv("KYBER_STORAGE") {
+	case "", "bolt":
+		return storage.NewBoltStorage(settingPath.dataStoragePath)
+	case "postgres":
+		return storage.NewPostgresStorage(os.Getenv("KYBER_POSTGRES_DSN"))
+	default:
+		return nil, fmt.Errorf("Storage %s is not supported", os.Getenv("KYBER_STORAGE"))
+	}
+}
+
+// NewCircuitBreaker creates the exchange circuit breaker. An exchange is
+// disabled after KYBER_BREAKER_FAILURES (default 5) consecutive failures
+// and enabled after being healthy for KYBER_BREAKER_HEALTHY_PERIOD (default
+// 10m).
+func NewCircuitBreaker(storage fetcher.Storage) *fetcher.CircuitBreaker {
+	failures := 5
+	if value := os.Getenv("KYBER_BREAKER_FAILURES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Fatalf("KYBER_BREAKER_FAILURES %s is not a positive number", value)
+		}
+		failures = n
+	}
+	healthyPeriod := 10 * time.Minute
+	if value := os.Getenv("KYBER_BREAKER_HEALTHY_PERIOD"); value != "" {
+		period, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("KYBER_BREAKER_HEALTHY_PERIOD %s is not a duration: %s", value, err)
+		}
+		healthyPeriod = period
+	}
+	return fetcher.NewCircuitBreaker(storage, failures, uint64(healthyPeriod/time.Millisecond))
+}
+
+// NewProposalManager creates the manager of configuration proposals. A
+// proposal needs KYBER_PROPOSAL_APPROVALS (default 1) approvals by keys
+// other than the proposer and expires after KYBER_PROPOSAL_TTL (default
+// 24h), 0 means proposals never expire.
+func NewProposalManager(storage metric.MetricStorage) *metric.ProposalManager {
+	approvals := 1
+	if value := os.Getenv("KYBER_PROPOSAL_APPROVALS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Fatalf("KYBER_PROPOSAL_APPROVALS %s is not a positive number", value)
+		}
+		approvals = n
+	}
+	ttl := 24 * time.Hour
+	if value := os.Getenv("KYBER_PROPOSAL_TTL"); value != "" {
+		period, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("KYBER_PROPOSAL_TTL %s is not a duration: %s", value, err)
+		}
+		ttl = period
+	}
+	return metric.NewProposalManager(storage, approvals, uint64(ttl/time.Millisecond))
+}
+
+// NewNonceCorpora creates the persistent nonce corpora of operators, an
+// allocated nonce still unknown to the node KYBER_NONCE_GAP_TIMEOUT
+// (default 1m) after its allocation is refilled.
+func NewNonceCorpora(storage nonce.Storage) *nonce.Corpora {
+	gapTimeout := time.Minute
+	if value := os.Getenv("KYBER_NONCE_GAP_TIMEOUT"); value != "" {
+		period, err := time.ParseDuration(value)
+		if err != nil || period <= 0 {
+			log.Fatalf("KYBER_NONCE_GAP_TIMEOUT %s is not a positive duration", value)
+		}
+		gapTimeout = period
+	}
+	return nonce.NewCorpora(storage, uint64(gapTimeout/time.Millisecond))
+}
+
 func (self *Config) AddCoreConfig(settingPath SettingPaths, addressConfig common.AddressConfig, kyberENV string) {
 	networkAddr := ethereum.HexToAddress(addressConfig.Network)
 	burnerAddr := ethereum.HexToAddress(addressConfig.FeeBurner)
@@ -160,15 +269,31 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, addressConfig common
 		log.Fatalf("Fees file %s cannot found at: %s", minDepositPath, err.Error())
 	}
 
-	dataStorage, err := storage.NewBoltStorage(settingPath.dataStoragePath)
+	pricingConfigPath := "/go/src/github.com/KyberNetwork/reserve-data/cmd/pricing.json"
+	pricingConfig, err := pricing.GetConfigFromFile(pricingConfigPath)
+	if err != nil {
+		log.Printf("Pricing config %s cannot be loaded: %s", pricingConfigPath, err)
+	}
+
+	riskConfigPath := "/go/src/github.com/KyberNetwork/reserve-data/cmd/risk.json"
+	riskConfig, err := core.GetRiskConfigFromFile(riskConfigPath)
+	if err != nil {
+		log.Printf("Risk config %s cannot be loaded, only exchange limits and balances are checked: %s", riskConfigPath, err)
+	}
+
+	dataStorage, err := NewDataStorage(settingPath)
 	if err != nil {
 		panic(err)
 	}
 
 	var fetcherRunner fetcher.FetcherRunner
+	var rebalancerRunner rebalancer.Runner
+	var pricingRunner pricing.Runner
 
 	if os.Getenv("KYBER_ENV") == "simulation" {
 		fetcherRunner = http_runner.NewHttpRunner(8001)
+		rebalancerRunner = http_runner.NewHttpRunner(8003)
+		pricingRunner = http_runner.NewHttpRunner(8004)
 	} else {
 		fetcherRunner = fetcher.NewTickerRunner(
 			7*time.Second,  // orderbook fetching interval
@@ -177,7 +302,10 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, addressConfig common
 			5*time.Second,  // block fetching interval
 			10*time.Minute, // tradeHistory fetching interval
 			10*time.Second, // global data fetching interval
+			30*time.Second, // open orders fetching interval
 		)
+		rebalancerRunner = rebalancer.NewTickerRunner(1 * time.Minute)
+		pricingRunner = pricing.NewTickerRunner(10 * time.Second)
 	}
 
 	pricingSigner := PricingSignerFromConfigFile(settingPath.secretPath)
@@ -189,7 +317,16 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, addressConfig common
 	self.FetcherStorage = dataStorage
 	self.FetcherGlobalStorage = dataStorage
 	self.MetricStorage = dataStorage
+	self.ProposalManager = NewProposalManager(dataStorage)
+	self.KeyStorage = dataStorage
+	self.AuditStorage = dataStorage
+	self.NonceCorpora = NewNonceCorpora(dataStorage)
 	self.FetcherRunner = fetcherRunner
+	self.CircuitBreaker = NewCircuitBreaker(dataStorage)
+	self.RebalancerRunner = rebalancerRunner
+	self.PricingRunner = pricingRunner
+	self.PricingConfig = pricingConfig
+	self.RiskConfig = riskConfig
 	self.BlockchainSigner = pricingSigner
 	//self.IntermediatorSigner = huoBiintermediatorSigner
 	self.DepositSigner = depositSigner
@@ -210,6 +347,7 @@ func (self *Config) AddCoreConfig(settingPath SettingPaths, addressConfig common
 		settingPath,
 		self.Blockchain,
 		minDeposit,
+		dataStorage,
 		kyberENV)
 	self.FetcherExchanges = exchangePool.FetcherExchanges()
 	self.Exchanges = exchangePool.CoreExchanges()
@@ -233,6 +371,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/dev_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/dev_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/dev_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/dev_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json",
 		"https://semi-node.kyber.network",
 		[]string{
@@ -252,6 +391,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/kovan_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/kovan_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/kovan_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/kovan_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json",
 		"https://kovan.infura.io",
 		[]string{},
@@ -265,6 +405,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_config.json",
 		"https://mainnet.infura.io",
 		[]string{
@@ -283,6 +424,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/mainnet_config.json",
 		"https://mainnet.infura.io",
 		[]string{
@@ -302,6 +444,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/staging_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/staging_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/staging_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/staging_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/staging_config.json",
 		"https://mainnet.infura.io",
 		[]string{
@@ -321,6 +464,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json",
 		"http://blockchain:8545",
 		[]string{
@@ -336,6 +480,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/ropsten_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/ropsten_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/ropsten_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/ropsten_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json",
 		"https://ropsten.infura.io",
 		[]string{
@@ -351,6 +496,7 @@ var ConfigPaths = map[string]SettingPaths{
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_logs.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_users.db",
+		"/go/src/github.com/KyberNetwork/reserve-data/cmd/core_eth_usd_rates.db",
 		"/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json",
 		"http://blockchain:8545",
 		[]string{
@@ -364,6 +510,8 @@ var Baseurl string = "http://127.0.0.1"
 var BinanceInterfaces = make(map[string]binance.Interface)
 var HuobiInterfaces = make(map[string]huobi.Interface)
 var BittrexInterfaces = make(map[string]bittrex.Interface)
+var BitfinexInterfaces = make(map[string]bitfinex.Interface)
+var LiquiInterfaces = make(map[string]liqui.Interface)
 
 func SetInterface(base_url string) {
 
@@ -390,4 +538,20 @@ func SetInterface(base_url string) {
 	BinanceInterfaces["simulation"] = binance.NewSimulatedInterface(base_url)
 	BinanceInterfaces["ropsten"] = binance.NewRopstenInterface(base_url)
 	BinanceInterfaces["analytic_dev"] = binance.NewRopstenInterface(base_url)
+
+	BitfinexInterfaces["dev"] = bitfinex.NewDevInterface()
+	BitfinexInterfaces["kovan"] = bitfinex.NewKovanInterface(base_url)
+	BitfinexInterfaces["mainnet"] = bitfinex.NewRealInterface()
+	BitfinexInterfaces["staging"] = bitfinex.NewRealInterface()
+	BitfinexInterfaces["simulation"] = bitfinex.NewSimulatedInterface(base_url)
+	BitfinexInterfaces["ropsten"] = bitfinex.NewRopstenInterface(base_url)
+	BitfinexInterfaces["analytic_dev"] = bitfinex.NewRopstenInterface(base_url)
+
+	LiquiInterfaces["dev"] = liqui.NewDevInterface()
+	LiquiInterfaces["kovan"] = liqui.NewKovanInterface(base_url)
+	LiquiInterfaces["mainnet"] = liqui.NewRealInterface()
+	LiquiInterfaces["staging"] = liqui.NewRealInterface()
+	LiquiInterfaces["simulation"] = liqui.NewSimulatedInterface(base_url)
+	LiquiInterfaces["ropsten"] = liqui.NewRopstenInterface(base_url)
+	LiquiInterfaces["analytic_dev"] = liqui.NewRopstenInterface(base_url)
 }
@@ -1,6 +1,7 @@
 package configuration
 
 import (
+	"log"
 	"os"
 	"strings"
 	"sync"
@@ -11,8 +12,11 @@ import (
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/exchange/binance"
+	"github.com/KyberNetwork/reserve-data/exchange/bitfinex"
 	"github.com/KyberNetwork/reserve-data/exchange/bittrex"
 	"github.com/KyberNetwork/reserve-data/exchange/huobi"
+	"github.com/KyberNetwork/reserve-data/exchange/liqui"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 type ExchangePool struct {
@@ -47,12 +51,45 @@ func getHuobiInterface(kyberENV string) huobi.Interface {
 	return envInterface
 }
 
+func getBitfinexInterface(kyberENV string) bitfinex.Interface {
+	envInterface, err := BitfinexInterfaces[kyberENV]
+	if !err {
+		envInterface = BitfinexInterfaces["dev"]
+	}
+	return envInterface
+}
+
+func getLiquiInterface(kyberENV string) liqui.Interface {
+	envInterface, err := LiquiInterfaces[kyberENV]
+	if !err {
+		envInterface = LiquiInterfaces["dev"]
+	}
+	return envInterface
+}
+
+// orderbookStreamEnabled returns true if exchange is listed in
+// KYBER_ORDERBOOK_STREAMS, its orderbooks are then streamed instead of
+// being polled
+func orderbookStreamEnabled(exchange string) bool {
+	for _, name := range strings.Split(os.Getenv("KYBER_ORDERBOOK_STREAMS"), ",") {
+		if name == exchange {
+			return true
+		}
+	}
+	return false
+}
+
+func newHuobiStream(kyberENV string, pairs []common.TokenPair) *huobi.HuobiStream {
+	return huobi.NewHuobiStream(getHuobiInterface(kyberENV), pairs)
+}
+
 func NewExchangePool(
 	feeConfig common.ExchangeFeesConfig,
 	addressConfig common.AddressConfig,
 	settingPaths SettingPaths,
 	blockchain *blockchain.BaseBlockchain,
 	minDeposit common.ExchangesMinDepositConfig,
+	stableExStorage exchange.StableExStorage,
 	kyberENV string) *ExchangePool {
 
 	exchanges := map[common.ExchangeID]interface{}{}
@@ -61,11 +98,26 @@ func NewExchangePool(
 	for _, exparam := range exparams {
 		switch exparam {
 		case "stable_exchange":
-			stableEx := exchange.NewStableEx(
+			stableExSigner, err := StableExSignerFromFile(settingPaths.secretPath)
+			if err != nil {
+				log.Printf("Cannot load stable exchange signer, stable exchange is disabled: %s", err)
+				continue
+			}
+			stableExNonce := nonce.NewTimeWindow(stableExSigner.GetAddress(), 10000)
+			stableEx, err := exchange.NewStableEx(
 				addressConfig.Exchanges["stable_exchange"],
 				feeConfig.Exchanges["stable_exchange"],
+				blockchain,
+				ethereum.HexToAddress(addressConfig.StableContract),
+				stableExSigner,
+				stableExNonce,
+				stableExStorage,
 				minDeposit.Exchanges["stable_exchange"],
 			)
+			if err != nil {
+				log.Printf("Cannot create stable exchange, stable exchange is disabled: %s", err)
+				continue
+			}
 			exchanges[stableEx.ID()] = stableEx
 		case "bittrex":
 			bittrexSigner := bittrex.NewSignerFromFile(settingPaths.secretPath)
@@ -94,7 +146,36 @@ func NewExchangePool(
 			}
 			wait.Wait()
 			bin.UpdatePairsPrecision()
+			if orderbookStreamEnabled("binance") {
+				stream := binance.NewBinanceStream(endpoint, bin.TokenPairs())
+				stream.Start()
+				bin.SetOrderbookStream(stream)
+			}
 			exchanges[bin.ID()] = bin
+		case "bitfinex":
+			bitfinexSigner := bitfinex.NewSignerFromFile(settingPaths.secretPath)
+			endpoint := bitfinex.NewBitfinexEndpoint(bitfinexSigner, getBitfinexInterface(kyberENV))
+			bitf := exchange.NewBitfinex(addressConfig.Exchanges["bitfinex"], feeConfig.Exchanges["bitfinex"], endpoint, minDeposit.Exchanges["bitfinex"])
+			wait := sync.WaitGroup{}
+			for tokenID, addr := range addressConfig.Exchanges["bitfinex"] {
+				wait.Add(1)
+				go AsyncUpdateDepositAddress(bitf, tokenID, addr, &wait)
+			}
+			wait.Wait()
+			bitf.UpdatePairsPrecision()
+			exchanges[bitf.ID()] = bitf
+		case "liqui":
+			liquiSigner := liqui.NewSignerFromFile(settingPaths.secretPath)
+			endpoint := liqui.NewLiquiEndpoint(liquiSigner, getLiquiInterface(kyberENV))
+			liq := exchange.NewLiqui(addressConfig.Exchanges["liqui"], feeConfig.Exchanges["liqui"], endpoint, minDeposit.Exchanges["liqui"])
+			wait := sync.WaitGroup{}
+			for tokenID, addr := range addressConfig.Exchanges["liqui"] {
+				wait.Add(1)
+				go AsyncUpdateDepositAddress(liq, tokenID, addr, &wait)
+			}
+			wait.Wait()
+			liq.UpdatePairsPrecision()
+			exchanges[liq.ID()] = liq
 		case "huobi":
 			huobiSigner := huobi.NewSignerFromFile(settingPaths.secretPath)
 			endpoint := huobi.NewHuobiEndpoint(huobiSigner, getHuobiInterface(kyberENV))
@@ -121,6 +202,11 @@ func NewExchangePool(
 			}
 			wait.Wait()
 			huobi.UpdatePairsPrecision()
+			if orderbookStreamEnabled("huobi") {
+				stream := newHuobiStream(kyberENV, huobi.TokenPairs())
+				stream.Start()
+				huobi.SetOrderbookStream(stream)
+			}
 			exchanges[huobi.ID()] = huobi
 		}
 	}
@@ -2,10 +2,13 @@ package configuration
 
 import (
 	"log"
+	"os"
+	"strings"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
 	"github.com/KyberNetwork/reserve-data/http"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
 	"github.com/KyberNetwork/reserve-data/world"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -61,6 +64,45 @@ func GetConfigPaths(kyberENV string) SettingPaths {
 	}
 }
 
+// NewEthUSDRate creates ETH-USD rate from providers listed in
+// KYBER_ETH_USD_PROVIDERS env, default to "coinmarketcap,coincap". Rates
+// imported from a csv file are listed as "file:<path>". Fetched history is
+// persisted only when stat is enabled, otherwise it is kept in memory.
+func NewEthUSDRate(setPath SettingPaths, enableStat bool) *blockchain.HistoricalEthUSDRate {
+	names := os.Getenv("KYBER_ETH_USD_PROVIDERS")
+	if names == "" {
+		names = "coinmarketcap,coincap"
+	}
+	providers := []blockchain.EthUSDRateProvider{}
+	for _, name := range strings.Split(names, ",") {
+		switch {
+		case name == "coinmarketcap":
+			providers = append(providers, blockchain.NewCMCEthUSDRateProvider())
+		case name == "coincap":
+			providers = append(providers, blockchain.NewCoinCapEthUSDRateProvider())
+		case strings.HasPrefix(name, "file:"):
+			provider, err := blockchain.NewFileEthUSDRateProvider(strings.TrimPrefix(name, "file:"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			providers = append(providers, provider)
+		default:
+			log.Fatalf("ETH-USD rate provider %s is not supported", name)
+		}
+	}
+	var rateStorage blockchain.EthUSDRateStorage
+	if enableStat {
+		boltStorage, err := statstorage.NewBoltEthUSDRateStorage(setPath.ethUSDRateStoragePath)
+		if err != nil {
+			panic(err)
+		}
+		rateStorage = boltStorage
+	}
+	ethRate := blockchain.NewHistoricalEthUSDRate(rateStorage, providers...)
+	ethRate.Run()
+	return ethRate
+}
+
 func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enableStat bool) *Config {
 	setPath := GetConfigPaths(kyberENV)
 
@@ -70,7 +112,6 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 	}
 
 	addressConfig := GetAddressConfig(setPath.settingPath)
-	hmac512auth := http.NewKNAuthenticationFromFile(setPath.secretPath)
 
 	wrapperAddr := ethereum.HexToAddress(addressConfig.Wrapper)
 	pricingAddr := ethereum.HexToAddress(addressConfig.Pricing)
@@ -119,12 +160,19 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 		}
 	}
 
+	gasPriceConfigPath := "/go/src/github.com/KyberNetwork/reserve-data/cmd/gas_price.json"
+	gasPriceConfig, err := blockchain.GetGasPriceConfigFromFile(gasPriceConfigPath)
+	if err != nil {
+		log.Printf("Gas price config %s cannot be loaded, the default policy is used: %s", gasPriceConfigPath, err)
+	}
+
 	blockchain := blockchain.NewBaseBlockchain(
 		client, infura, map[string]*blockchain.Operator{},
 		blockchain.NewBroadcaster(bkclients),
-		blockchain.NewCMCEthUSDRate(),
+		NewEthUSDRate(setPath, enableStat),
 		chainType,
 		blockchain.NewContractCaller(callClients, setPath.bkendpoints),
+		blockchain.NewGasPriceOracle(blockchain.NewRPCGasPriceSource(client), gasPriceConfig),
 	)
 
 	if !authEnbl {
@@ -139,7 +187,6 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 		PricingAddress:          pricingAddr,
 		ReserveAddress:          reserveAddr,
 		ChainType:               chainType,
-		AuthEngine:              hmac512auth,
 		EnableAuthentication:    authEnbl,
 		World:                   world,
 	}
@@ -151,5 +198,8 @@ func GetConfig(kyberENV string, authEnbl bool, endpointOW string, noCore, enable
 	if !noCore {
 		config.AddCoreConfig(setPath, addressConfig, kyberENV)
 	}
+	// api keys are kept in the core storage, without core only the
+	// secrets of the config file are accepted
+	config.AuthEngine = http.NewKNAuthenticationFromFile(setPath.secretPath, config.KeyStorage)
 	return config
 }
@@ -51,4 +51,9 @@ func TestBoltLogForStatStorage(t *testing.T) {
 			t.Fatalf("Testing stat_bolt as a stat storage: Test Trade Log failed (%s)", err)
 		}
 	}, t)
+	doBoltLogTest(func(tester *stat.LogStorageTest, t *testing.T) {
+		if err := tester.TestRemoveLogsAfter(); err != nil {
+			t.Fatalf("Testing stat_bolt as a stat storage: Test Remove Logs After failed (%s)", err)
+		}
+	}, t)
 }
@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
@@ -60,3 +61,24 @@ func HuobiIntermediatorSignerFromFile(secretPath string) *blockchain.EthereumSig
 	}
 	return blockchain.NewEthereumSigner(detail.Keystore, detail.Passphrase)
 }
+
+type jsonStableExDetail struct {
+	Keystore   string `json:"keystore_stable_exchange_path"`
+	Passphrase string `json:"passphrase_stable_exchange"`
+}
+
+func StableExSignerFromFile(secretPath string) (*blockchain.EthereumSigner, error) {
+	raw, err := ioutil.ReadFile(secretPath)
+	if err != nil {
+		return nil, err
+	}
+	detail := jsonStableExDetail{}
+	err = json.Unmarshal(raw, &detail)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Keystore == "" {
+		return nil, fmt.Errorf("keystore_stable_exchange_path is missing in %s", secretPath)
+	}
+	return blockchain.LoadEthereumSigner(detail.Keystore, detail.Passphrase)
+}
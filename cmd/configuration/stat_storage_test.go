@@ -90,4 +90,19 @@ func TestBoltStatForStatStorage(t *testing.T) {
 
 		}
 	}, t)
+	doStatBoltTest(func(tester *stat.StatStorageTest, t *testing.T) {
+		if err := tester.TestRemoveFirstTrades(); err != nil {
+			t.Fatalf("Testing stat_bolt as a stat storage: Test remove first trades failed (%s)", err)
+		}
+	}, t)
+	doStatBoltTest(func(tester *stat.StatStorageTest, t *testing.T) {
+		if err := tester.TestResetAggregation(); err != nil {
+			t.Fatalf("Testing stat_bolt as a stat storage: Test reset aggregation failed (%s)", err)
+		}
+	}, t)
+	doStatBoltTest(func(tester *stat.StatStorageTest, t *testing.T) {
+		if err := tester.TestReserveStats(); err != nil {
+			t.Fatalf("Testing stat_bolt as a stat storage: Test reserve stats failed (%s)", err)
+		}
+	}, t)
 }
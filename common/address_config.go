@@ -34,6 +34,7 @@ type AddressConfig struct {
 	Whitelist          string              `json:"whitelist"`
 	ThirdPartyReserves []string            `json:"third_party_reserves"`
 	Intermediator      string              `json:"intermediator"`
+	StableContract     string              `json:"stable_contract"`
 }
 
 func GetAddressConfigFromFile(path string) (AddressConfig, error) {
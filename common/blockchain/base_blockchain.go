@@ -25,6 +25,8 @@ const (
 	ZeroAddress string = "0x0000000000000000000000000000000000000000"
 )
 
+var ErrTxNotPending = errors.New("tx is not pending")
+
 // BaseBlockchain interact with the blockchain in a way that eases
 // other blockchain types in KyberNetwork.
 // It manages multiple operators (address, signer and nonce)
@@ -43,6 +45,7 @@ type BaseBlockchain struct {
 	chainType      string
 	contractCaller *ContractCaller
 	erc20abi       abi.ABI
+	gasPriceOracle *GasPriceOracle
 }
 
 func (self *BaseBlockchain) OperatorAddresses() map[string]ethereum.Address {
@@ -90,6 +93,75 @@ func (self *BaseBlockchain) GetNextNonce(operator string) (*big.Int, error) {
 	return nonce, err
 }
 
+// ReconcileNonces reconciles persistent nonce corpora of operators with
+// the node
+func (self *BaseBlockchain) ReconcileNonces() {
+	for name, op := range self.operators {
+		if corpus, ok := op.NonceCorpus.(PersistentNonceCorpus); ok {
+			if err := corpus.Reconcile(self.client); err != nil {
+				log.Printf("Reconciling nonces of operator %s failed: %s", name, err)
+			}
+		}
+	}
+}
+
+// NonceStates returns nonce states of operators by name, only the mined
+// and pending nonces of the node are returned for operators without a
+// persistent nonce corpus
+func (self *BaseBlockchain) NonceStates() (map[string]common.NonceState, error) {
+	result := map[string]common.NonceState{}
+	for name, op := range self.operators {
+		if corpus, ok := op.NonceCorpus.(PersistentNonceCorpus); ok {
+			state, err := corpus.State()
+			if err != nil {
+				return result, err
+			}
+			result[name] = state
+			continue
+		}
+		timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		mined, err := self.client.NonceAt(timeout, op.Address, nil)
+		if err == nil {
+			var pending uint64
+			pending, err = self.client.PendingNonceAt(timeout, op.Address)
+			result[name] = common.NonceState{
+				Address: op.Address.Hex(),
+				Mined:   mined,
+				Pending: pending,
+			}
+		}
+		cancel()
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// ReleaseNonce releases the nonce allocated for opts, it is called when
+// the tx of opts couldn't be built or signed so the nonce isn't left as
+// a gap. A tx which no node accepted may still reach the network, e.g. on
+// a broadcast timeout, so its nonce is left to the gap detection. Given
+// nonces and nonces of non persistent corpora are untouched.
+func (self *BaseBlockchain) ReleaseNonce(opts TxOpts) {
+	if opts.NonceAllocated {
+		self.releaseNonce(opts.Operator, opts.Nonce)
+	}
+}
+
+func (self *BaseBlockchain) releaseNonce(op *Operator, nonce *big.Int) {
+	corpus, ok := op.NonceCorpus.(PersistentNonceCorpus)
+	if !ok {
+		return
+	}
+	if err := corpus.Release(nonce.Uint64()); err != nil {
+		log.Printf("Releasing nonce %s of %s failed: %s", nonce.Text(10), op.Address.Hex(), err)
+	}
+}
+
+// SignAndBroadcast signs tx with the signer of operator from and
+// broadcasts it to all nodes. The signed tx is returned even if no node
+// accepted it, it is nil only if tx couldn't be signed.
 func (self *BaseBlockchain) SignAndBroadcast(tx *types.Transaction, from string) (*types.Transaction, error) {
 	signer := self.GetOperator(from).Signer
 	if tx == nil {
@@ -218,24 +290,76 @@ func (self *BaseBlockchain) GetTxOpts(op string, nonce *big.Int, gasPrice *big.I
 	result := TxOpts{}
 	operator := self.GetOperator(op)
 	var err error
-	if nonce == nil {
+	allocated := nonce == nil
+	if allocated {
 		nonce, err = self.GetNextNonce(op)
 	}
 	if err != nil {
 		return result, err
 	}
 	if gasPrice == nil {
-		gasPrice = big.NewInt(50100000000)
+		gasPrice, err = self.gasPriceOracle.GasPrice(op)
+		if err != nil {
+			if allocated {
+				self.releaseNonce(operator, nonce)
+			}
+			return result, err
+		}
 	}
 	// timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	result.Operator = operator
 	result.Nonce = nonce
+	result.NonceAllocated = allocated
 	result.Value = value
 	result.GasPrice = gasPrice
 	result.GasLimit = nil
 	return result, nil
 }
 
+// GasPricePolicy returns the gas price policy of operator op
+func (self *BaseBlockchain) GasPricePolicy(op string) GasPricePolicy {
+	return self.gasPriceOracle.Policy(op)
+}
+
+// SuggestGasPrice returns the gas price of new txs of operator op
+func (self *BaseBlockchain) SuggestGasPrice(op string) (*big.Int, error) {
+	return self.gasPriceOracle.GasPrice(op)
+}
+
+// ReplacementGasPrice returns the gas price of a tx of operator op
+// replacing its pending tx at oldPrice
+func (self *BaseBlockchain) ReplacementGasPrice(op string, oldPrice *big.Int) (*big.Int, error) {
+	return self.gasPriceOracle.ReplacementGasPrice(op, oldPrice)
+}
+
+// ReplaceTx resends pending tx hash of operator op with the same nonce and
+// data at a replacement gas price, ErrTxNotPending is returned if the tx
+// is mined or dropped
+func (self *BaseBlockchain) ReplaceTx(op string, hash ethereum.Hash) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, pending, err := self.TransactionByHash(timeout, hash)
+	if err == ether.NotFound {
+		return nil, ErrTxNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !pending {
+		return nil, ErrTxNotPending
+	}
+	if tx.tx.To() == nil {
+		return nil, fmt.Errorf("tx %s creates a contract, it can't be replaced", hash.Hex())
+	}
+	gasPrice, err := self.ReplacementGasPrice(op, tx.tx.GasPrice())
+	if err != nil {
+		return nil, err
+	}
+	rawTx := types.NewTransaction(
+		tx.tx.Nonce(), *tx.tx.To(), tx.tx.Value(), tx.tx.Gas(), gasPrice, tx.tx.Data())
+	return self.SignAndBroadcast(rawTx, op)
+}
+
 func (self *BaseBlockchain) GetLogs(param ether.FilterQuery) ([]types.Log, error) {
 	result := []types.Log{}
 	// log.Printf("LogFetcher - fetching logs data from block %d, to block %d", opts.Block, to.Uint64())
@@ -253,10 +377,44 @@ func (self *BaseBlockchain) CurrentBlock() (uint64, error) {
 	return result, err
 }
 
+// GetBlockHash returns hash of the block at number block of the current
+// canonical chain
+func (self *BaseBlockchain) GetBlockHash(block uint64) (ethereum.Hash, error) {
+	var header struct {
+		Hash ethereum.Hash `json:"hash"`
+	}
+	err := self.rpcClient.Call(&header, "eth_getBlockByNumber", fmt.Sprintf("0x%x", block), false)
+	if err != nil {
+		return header.Hash, err
+	}
+	if header.Hash == (ethereum.Hash{}) {
+		return header.Hash, fmt.Errorf("Block %d is not found", block)
+	}
+	return header.Hash, nil
+}
+
 func (self *BaseBlockchain) PackERC20Data(method string, params ...interface{}) ([]byte, error) {
 	return self.erc20abi.Pack(method, params...)
 }
 
+// ERC20Allowance returns the amount of token spender can take from owner
+// in pending state
+func (self *BaseBlockchain) ERC20Allowance(tokenAddress ethereum.Address, owner ethereum.Address, spender ethereum.Address) (*big.Int, error) {
+	token := &Contract{Address: tokenAddress, ABI: self.erc20abi}
+	out := big.NewInt(0)
+	err := self.Call(2*time.Second, self.GetCallOpts(0), token, out, "allowance", owner, spender)
+	return out, err
+}
+
+// BuildApproveERC20Tx builds a tx allowing spender to take amount of token
+// from the operator of opts
+func (self *BaseBlockchain) BuildApproveERC20Tx(opts TxOpts, amount *big.Int, spender ethereum.Address, tokenAddress ethereum.Address) (*types.Transaction, error) {
+	token := &Contract{Address: tokenAddress, ABI: self.erc20abi}
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return self.BuildTx(timeout, opts, token, "approve", spender, amount)
+}
+
 func (self *BaseBlockchain) BuildSendERC20Tx(opts TxOpts, amount *big.Int, to ethereum.Address, tokenAddress ethereum.Address) (*types.Transaction, error) {
 	var err error
 	value := opts.Value
@@ -324,6 +482,18 @@ func (self *BaseBlockchain) TransactionByHash(ctx context.Context, hash ethereum
 	return json, json.BlockNumber().Cmp(ethereum.Big0) == 0, nil
 }
 
+// TransactionReceipt returns the receipt of mined tx hash
+func (self *BaseBlockchain) TransactionReceipt(hash ethereum.Hash) (*types.Receipt, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	receipt, err := self.client.TransactionReceipt(timeout, hash)
+	if err != nil && receipt == nil {
+		return nil, err
+	}
+	// parity receipts come with an error, see TxStatus
+	return receipt, nil
+}
+
 func (self *BaseBlockchain) TxStatus(hash ethereum.Hash) (string, uint64, error) {
 	option := context.Background()
 	tx, pending, err := self.TransactionByHash(option, hash)
@@ -377,10 +547,10 @@ func (self *BaseBlockchain) TxStatus(hash ethereum.Hash) (string, uint64, error)
 	}
 }
 
-func (self *BaseBlockchain) GetEthRate(timepoint uint64) float64 {
-	rate := self.ethRate.GetUSDRate(timepoint)
-	log.Printf("ETH-USD rate: %f", rate)
-	return rate
+// GetEthRate returns ETH-USD rate at timepoint in millisecond and true if
+// it is the rate of the minute of timepoint, the rate is 0 if it is unknown
+func (self *BaseBlockchain) GetEthRate(timepoint uint64) (float64, bool) {
+	return self.ethRate.GetUSDRate(timepoint)
 }
 
 func NewMinimalBaseBlockchain(
@@ -406,12 +576,15 @@ func NewMinimalBaseBlockchain(
 			callClients = append(callClients, bkclient)
 		}
 	}
+	ethRate := NewHistoricalEthUSDRate(nil, NewCMCEthUSDRateProvider(), NewCoinCapEthUSDRateProvider())
+	ethRate.Run()
 	return NewBaseBlockchain(
 		rpcClient, ethClient, operators,
 		NewBroadcaster(bkclients),
-		NewCMCEthUSDRate(),
+		ethRate,
 		chainType,
 		NewContractCaller(callClients, endpoints),
+		NewGasPriceOracle(NewRPCGasPriceSource(rpcClient), DefaultGasPriceConfig()),
 	), nil
 }
 
@@ -422,7 +595,8 @@ func NewBaseBlockchain(
 	broadcaster *Broadcaster,
 	ethRate EthUSDRate,
 	chainType string,
-	contractcaller *ContractCaller) *BaseBlockchain {
+	contractcaller *ContractCaller,
+	gasPriceOracle *GasPriceOracle) *BaseBlockchain {
 
 	file, err := os.Open(
 		filepath.Join(common.CurrentDir(), "ERC20.abi"))
@@ -443,5 +617,6 @@ func NewBaseBlockchain(
 		chainType:      chainType,
 		erc20abi:       packabi,
 		contractCaller: contractcaller,
+		gasPriceOracle: gasPriceOracle,
 	}
 }
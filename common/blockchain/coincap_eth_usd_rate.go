@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type CoinCapHistoryResponse struct {
+	Data []struct {
+		PriceUSD string `json:"priceUsd"`
+		Time     uint64 `json:"time"`
+	} `json:"data"`
+}
+
+type CoinCapAssetResponse struct {
+	Data struct {
+		PriceUSD string `json:"priceUsd"`
+	} `json:"data"`
+}
+
+// CoinCapEthUSDRateProvider gets minute ETH-USD rates from coincap
+type CoinCapEthUSDRateProvider struct {
+	baseURL string
+}
+
+func NewCoinCapEthUSDRateProvider() *CoinCapEthUSDRateProvider {
+	return &CoinCapEthUSDRateProvider{
+		baseURL: "https://api.coincap.io/v2/assets/ethereum",
+	}
+}
+
+func (self *CoinCapEthUSDRateProvider) Name() string {
+	return "coincap"
+}
+
+func (self *CoinCapEthUSDRateProvider) GetRates(fromTime, toTime uint64) ([]common.USDRate, error) {
+	api := fmt.Sprintf("%s/history?interval=m1&start=%d&end=%d", self.baseURL, fromTime, toTime)
+	response := CoinCapHistoryResponse{}
+	if err := getJSON(api, &response); err != nil {
+		return nil, err
+	}
+	result := []common.USDRate{}
+	for _, e := range response.Data {
+		rate, err := strconv.ParseFloat(e.PriceUSD, 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, common.USDRate{Timepoint: e.Time, Rate: rate})
+	}
+	return result, nil
+}
+
+func (self *CoinCapEthUSDRateProvider) GetRealtimeRate() (float64, error) {
+	response := CoinCapAssetResponse{}
+	if err := getJSON(self.baseURL, &response); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(response.Data.PriceUSD, 64)
+}
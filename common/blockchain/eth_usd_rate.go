@@ -1,5 +1,33 @@
 package blockchain
 
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
 type EthUSDRate interface {
-	GetUSDRate(timepoint uint64) float64
+	// GetUSDRate returns ETH-USD rate at timepoint in millisecond and true
+	// if the rate is known at the minute of timepoint, false if it is
+	// interpolated or taken from the nearest known rate
+	GetUSDRate(timepoint uint64) (float64, bool)
+}
+
+// EthUSDRateProvider is a source of ETH-USD rates
+type EthUSDRateProvider interface {
+	Name() string
+	GetRealtimeRate() (float64, error)
+	// GetRates returns rates from fromTime to toTime in millisecond
+	GetRates(fromTime, toTime uint64) ([]common.USDRate, error)
+}
+
+// EthUSDRateStorage keeps the minute history of ETH-USD rates fetched from
+// providers and, apart from it, the realtime rates fetched periodically
+type EthUSDRateStorage interface {
+	StoreUSDRates(rates []common.USDRate) error
+	// GetUSDRatesAround returns the latest history rate at or before
+	// timepoint and the first one after it, missing rates have zero
+	// timepoint
+	GetUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error)
+	StoreRealtimeUSDRate(rate common.USDRate) error
+	// GetRealtimeUSDRatesAround is GetUSDRatesAround of realtime rates
+	GetRealtimeUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error)
 }
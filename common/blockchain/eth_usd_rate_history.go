@@ -0,0 +1,240 @@
+package blockchain
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	RATE_MINUTE uint64 = 60 * 1000
+	RATE_DAY    uint64 = 24 * 60 * RATE_MINUTE
+	// rates farther than MAX_RATE_GAP from a timepoint are not used for it
+	MAX_RATE_GAP uint64 = 60 * RATE_MINUTE
+	// history of a day which is not over is fetched again after
+	// RATE_FETCH_COOLDOWN
+	RATE_FETCH_COOLDOWN uint64 = 10 * RATE_MINUTE
+)
+
+// HistoricalEthUSDRate returns ETH-USD rates of the minute history in its
+// storage, missing days are fetched from the providers in order. Realtime
+// rates are stored apart from the history and used only when the history
+// has no rate around a timepoint.
+type HistoricalEthUSDRate struct {
+	mu           sync.Mutex
+	providers    []EthUSDRateProvider
+	storage      EthUSDRateStorage
+	fetchedDays  map[uint64]uint64
+	realtimeRate float64
+}
+
+// NewHistoricalEthUSDRate returns a rate keeping history in storage, the
+// history is kept in memory if storage is nil
+func NewHistoricalEthUSDRate(storage EthUSDRateStorage, providers ...EthUSDRateProvider) *HistoricalEthUSDRate {
+	if storage == nil {
+		storage = &ramEthUSDRateStorage{}
+	}
+	return &HistoricalEthUSDRate{
+		providers:   providers,
+		storage:     storage,
+		fetchedDays: map[uint64]uint64{},
+	}
+}
+
+// GetUSDRate returns the history rate nearest to timepoint in its minute,
+// the history of the day is fetched when the minute is missing. Otherwise
+// the rate is interpolated from the history, or from the realtime rates,
+// within MAX_RATE_GAP, or it is the latest realtime rate, and it is not
+// exact. It is 0 when no rate is known.
+func (self *HistoricalEthUSDRate) GetUSDRate(timepoint uint64) (float64, bool) {
+	before, after, err := self.storage.GetUSDRatesAround(timepoint)
+	if err == nil {
+		if _, exact := minuteRate(timepoint, before, after); !exact && self.fetchHistory(timepoint) {
+			before, after, err = self.storage.GetUSDRatesAround(timepoint)
+		}
+	}
+	if err != nil {
+		log.Printf("Getting ETH-USD rate history around %d failed: %s", timepoint, err)
+	} else {
+		if rate, exact := minuteRate(timepoint, before, after); exact {
+			return rate, true
+		}
+		if rate, found := rateAround(timepoint, before, after); found {
+			return rate, false
+		}
+	}
+	before, after, err = self.storage.GetRealtimeUSDRatesAround(timepoint)
+	if err != nil {
+		log.Printf("Getting realtime ETH-USD rates around %d failed: %s", timepoint, err)
+	} else if rate, found := rateAround(timepoint, before, after); found {
+		return rate, false
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	log.Printf("There is no ETH-USD rate around %d, using latest realtime rate %f", timepoint, self.realtimeRate)
+	return self.realtimeRate, false
+}
+
+// minuteRate returns the rate of before or after which is in the minute of
+// timepoint and nearer to it, false if none of them is
+func minuteRate(timepoint uint64, before, after common.USDRate) (float64, bool) {
+	minute := timepoint / RATE_MINUTE
+	hasBefore := before.Timepoint != 0 && before.Timepoint/RATE_MINUTE == minute
+	hasAfter := after.Timepoint != 0 && after.Timepoint/RATE_MINUTE == minute
+	switch {
+	case hasBefore && hasAfter:
+		if after.Timepoint-timepoint < timepoint-before.Timepoint {
+			return after.Rate, true
+		}
+		return before.Rate, true
+	case hasBefore:
+		return before.Rate, true
+	case hasAfter:
+		return after.Rate, true
+	}
+	return 0, false
+}
+
+// rateAround interpolates the rate at timepoint from before and after, or
+// takes the one of them within MAX_RATE_GAP, false if none of them is
+func rateAround(timepoint uint64, before, after common.USDRate) (float64, bool) {
+	hasBefore := before.Timepoint != 0 && timepoint-before.Timepoint <= MAX_RATE_GAP
+	hasAfter := after.Timepoint != 0 && after.Timepoint-timepoint <= MAX_RATE_GAP
+	switch {
+	case hasBefore && hasAfter:
+		ratio := float64(timepoint-before.Timepoint) / float64(after.Timepoint-before.Timepoint)
+		return before.Rate + (after.Rate-before.Rate)*ratio, true
+	case hasBefore:
+		return before.Rate, true
+	case hasAfter:
+		return after.Rate, true
+	}
+	return 0, false
+}
+
+// fetchHistory fetches rates of the day of timepoint, it returns true if
+// new rates are stored
+func (self *HistoricalEthUSDRate) fetchHistory(timepoint uint64) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	day := timepoint / RATE_DAY * RATE_DAY
+	now := common.GetTimepoint()
+	if last, fetched := self.fetchedDays[day]; fetched && (last >= day+RATE_DAY || now-last < RATE_FETCH_COOLDOWN) {
+		return false
+	}
+	self.fetchedDays[day] = now
+	for _, provider := range self.providers {
+		rates, err := provider.GetRates(day, day+RATE_DAY-1)
+		if err != nil {
+			log.Printf("Fetching ETH-USD rates of day %d from %s failed: %s", day, provider.Name(), err)
+			continue
+		}
+		if len(rates) == 0 {
+			continue
+		}
+		if err = self.storage.StoreUSDRates(rates); err != nil {
+			log.Printf("Storing ETH-USD rates failed: %s", err)
+			return false
+		}
+		log.Printf("Fetched %d ETH-USD rates of day %d from %s", len(rates), day, provider.Name())
+		return true
+	}
+	return false
+}
+
+// FetchRealtimeRate gets the current rate from the first provider having
+// it and stores it to the realtime rates
+func (self *HistoricalEthUSDRate) FetchRealtimeRate() error {
+	for _, provider := range self.providers {
+		rate, err := provider.GetRealtimeRate()
+		if err != nil {
+			log.Printf("Getting realtime ETH-USD rate from %s failed: %s", provider.Name(), err)
+			continue
+		}
+		self.mu.Lock()
+		self.realtimeRate = rate
+		self.mu.Unlock()
+		return self.storage.StoreRealtimeUSDRate(common.USDRate{Timepoint: common.GetTimepoint(), Rate: rate})
+	}
+	return errors.New("No provider returns realtime ETH-USD rate")
+}
+
+func (self *HistoricalEthUSDRate) Run() {
+	tick := time.NewTicker(10 * time.Minute)
+	go func() {
+		for {
+			if err := self.FetchRealtimeRate(); err != nil {
+				log.Println(err)
+			}
+			<-tick.C
+		}
+	}()
+}
+
+// ramEthUSDRateStorage keeps history and realtime rates ordered by
+// timepoint in memory
+type ramEthUSDRateStorage struct {
+	mu       sync.RWMutex
+	rates    []common.USDRate
+	realtime []common.USDRate
+}
+
+func (self *ramEthUSDRateStorage) StoreUSDRates(rates []common.USDRate) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.rates = mergeUSDRates(self.rates, rates)
+	return nil
+}
+
+func (self *ramEthUSDRateStorage) GetUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	before, after := usdRatesAround(self.rates, timepoint)
+	return before, after, nil
+}
+
+func (self *ramEthUSDRateStorage) StoreRealtimeUSDRate(rate common.USDRate) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.realtime = mergeUSDRates(self.realtime, []common.USDRate{rate})
+	return nil
+}
+
+func (self *ramEthUSDRateStorage) GetRealtimeUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	before, after := usdRatesAround(self.realtime, timepoint)
+	return before, after, nil
+}
+
+func mergeUSDRates(current, rates []common.USDRate) []common.USDRate {
+	byTimepoint := map[uint64]float64{}
+	for _, rate := range current {
+		byTimepoint[rate.Timepoint] = rate.Rate
+	}
+	for _, rate := range rates {
+		byTimepoint[rate.Timepoint] = rate.Rate
+	}
+	result := []common.USDRate{}
+	for timepoint, rate := range byTimepoint {
+		result = append(result, common.USDRate{Timepoint: timepoint, Rate: rate})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timepoint < result[j].Timepoint })
+	return result
+}
+
+func usdRatesAround(rates []common.USDRate, timepoint uint64) (common.USDRate, common.USDRate) {
+	var before, after common.USDRate
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Timepoint > timepoint })
+	if i > 0 {
+		before = rates[i-1]
+	}
+	if i < len(rates) {
+		after = rates[i]
+	}
+	return before, after
+}
@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const testDay uint64 = 1525132800000
+
+type testEthUSDRateProvider struct {
+	rates    []common.USDRate
+	realtime float64
+	fetched  int
+}
+
+func (self *testEthUSDRateProvider) Name() string {
+	return "test"
+}
+
+func (self *testEthUSDRateProvider) GetRealtimeRate() (float64, error) {
+	if self.realtime == 0 {
+		return 0, errors.New("no realtime rate")
+	}
+	return self.realtime, nil
+}
+
+func (self *testEthUSDRateProvider) GetRates(fromTime, toTime uint64) ([]common.USDRate, error) {
+	self.fetched++
+	result := []common.USDRate{}
+	for _, rate := range self.rates {
+		if rate.Timepoint >= fromTime && rate.Timepoint <= toTime {
+			result = append(result, rate)
+		}
+	}
+	return result, nil
+}
+
+func TestHistoricalEthUSDRate(t *testing.T) {
+	provider := &testEthUSDRateProvider{
+		rates: []common.USDRate{
+			{Timepoint: testDay + 10*RATE_MINUTE, Rate: 600},
+			{Timepoint: testDay + 20*RATE_MINUTE, Rate: 700},
+		},
+		realtime: 800,
+	}
+	ethRate := NewHistoricalEthUSDRate(nil, provider)
+
+	rate, exact := ethRate.GetUSDRate(testDay + 10*RATE_MINUTE + 30000)
+	if rate != 600 || !exact {
+		t.Fatalf("Expected exact rate 600 in the same minute, got %f (exact: %t)", rate, exact)
+	}
+	if provider.fetched != 1 {
+		t.Fatalf("Expected history of the day fetched once, fetched %d times", provider.fetched)
+	}
+	rate, exact = ethRate.GetUSDRate(testDay + 15*RATE_MINUTE)
+	if rate != 650 || exact {
+		t.Fatalf("Expected interpolated rate 650, got %f (exact: %t)", rate, exact)
+	}
+	rate, exact = ethRate.GetUSDRate(testDay + 50*RATE_MINUTE)
+	if rate != 700 || exact {
+		t.Fatalf("Expected nearest rate 700, got %f (exact: %t)", rate, exact)
+	}
+	if provider.fetched != 1 {
+		t.Fatalf("Expected stored history to be used, fetched %d times", provider.fetched)
+	}
+
+	if err := ethRate.FetchRealtimeRate(); err != nil {
+		t.Fatalf("Fetching realtime rate failed: %s", err)
+	}
+	rate, exact = ethRate.GetUSDRate(testDay + 5*RATE_DAY)
+	if rate != 800 || exact {
+		t.Fatalf("Expected realtime rate 800 without history, got %f (exact: %t)", rate, exact)
+	}
+	if rate, exact = NewHistoricalEthUSDRate(nil).GetUSDRate(testDay); rate != 0 || exact {
+		t.Fatalf("Expected rate 0 without any rate, got %f (exact: %t)", rate, exact)
+	}
+	fetched := provider.fetched
+	ethRate.GetUSDRate(testDay + 5*RATE_DAY + RATE_MINUTE)
+	if provider.fetched != fetched {
+		t.Fatalf("Expected a day without history not to be fetched again before cooldown")
+	}
+}
+
+func TestFileEthUSDRateProvider(t *testing.T) {
+	rates, err := readUSDRates(strings.NewReader("# timepoint,rate\n1525133400000,700.5\n1525132800000, 600\n"))
+	if err != nil {
+		t.Fatalf("Reading rates failed: %s", err)
+	}
+	if len(rates) != 2 || rates[0].Timepoint != testDay || rates[0].Rate != 600 || rates[1].Rate != 700.5 {
+		t.Fatalf("Expected rates ordered by timepoint, got %+v", rates)
+	}
+	if _, err = readUSDRates(strings.NewReader("1525132800000\n")); err == nil {
+		t.Fatalf("Expected error for a line without rate")
+	}
+}
+
+func TestHistoricalEthUSDRateWithRealtimeRates(t *testing.T) {
+	provider := &testEthUSDRateProvider{
+		rates: []common.USDRate{
+			{Timepoint: testDay + 10*RATE_MINUTE + 5000, Rate: 600},
+			{Timepoint: testDay + 10*RATE_MINUTE + 50000, Rate: 610},
+		},
+	}
+	storage := &ramEthUSDRateStorage{}
+	ethRate := NewHistoricalEthUSDRate(storage, provider)
+	// a realtime rate in the minute doesn't stop the history from being fetched
+	if err := storage.StoreRealtimeUSDRate(common.USDRate{Timepoint: testDay + 10*RATE_MINUTE + 30000, Rate: 800}); err != nil {
+		t.Fatalf("Storing realtime rate failed: %s", err)
+	}
+
+	rate, exact := ethRate.GetUSDRate(testDay + 10*RATE_MINUTE + 40000)
+	if rate != 610 || !exact || provider.fetched != 1 {
+		t.Fatalf("Expected fetched history rate 610 nearest in the minute, got %f (exact: %t, fetched: %d)", rate, exact, provider.fetched)
+	}
+	rate, exact = ethRate.GetUSDRate(testDay + 10*RATE_MINUTE + 10000)
+	if rate != 600 || !exact {
+		t.Fatalf("Expected history rate 600 nearest in the minute, got %f (exact: %t)", rate, exact)
+	}
+	rate, exact = ethRate.GetUSDRate(testDay + 3*RATE_DAY)
+	if rate != 0 || exact {
+		t.Fatalf("Expected rate 0 far from any rate, got %f (exact: %t)", rate, exact)
+	}
+}
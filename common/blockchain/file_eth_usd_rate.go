@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// FileEthUSDRateProvider serves ETH-USD rates imported from a csv file,
+// each line of the file is a timepoint in millisecond and its rate
+type FileEthUSDRateProvider struct {
+	path  string
+	rates []common.USDRate
+}
+
+func NewFileEthUSDRateProvider(path string) (*FileEthUSDRateProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rates, err := readUSDRates(file)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read ETH-USD rates from %s: %s", path, err)
+	}
+	return &FileEthUSDRateProvider{path, rates}, nil
+}
+
+func readUSDRates(r io.Reader) ([]common.USDRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+	result := []common.USDRate{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		timepoint, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, common.USDRate{Timepoint: timepoint, Rate: rate})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timepoint < result[j].Timepoint })
+	return result, nil
+}
+
+func (self *FileEthUSDRateProvider) Name() string {
+	return "file " + self.path
+}
+
+func (self *FileEthUSDRateProvider) GetRates(fromTime, toTime uint64) ([]common.USDRate, error) {
+	result := []common.USDRate{}
+	for _, rate := range self.rates {
+		if rate.Timepoint >= fromTime && rate.Timepoint <= toTime {
+			result = append(result, rate)
+		}
+	}
+	return result, nil
+}
+
+func (self *FileEthUSDRateProvider) GetRealtimeRate() (float64, error) {
+	return 0, errors.New("Imported rates have no realtime rate")
+}
@@ -0,0 +1,280 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DEFAULT_GAS_PRICE_POLICY is the policy of operators without their own
+	// policy
+	DEFAULT_GAS_PRICE_POLICY string = "default"
+	// DEFAULT_GAS_PRICE_BLOCKS is the number of latest blocks sampled for
+	// the gas price percentile
+	DEFAULT_GAS_PRICE_BLOCKS int = 20
+	// nodes reject replacement txs paying less than 10% more
+	MIN_GAS_PRICE_BUMP_PERCENT float64 = 10
+)
+
+// GasPricePolicy bounds and escalates gas prices of txs of an operator
+type GasPricePolicy struct {
+	// MinGwei and MaxGwei cap gas prices, replacement txs included
+	MinGwei float64 `json:"min_gwei"`
+	MaxGwei float64 `json:"max_gwei"`
+	// Percentile of gas prices of txs in the latest blocks, the node
+	// eth_gasPrice is used when it is 0 or there is no recent tx
+	Percentile float64 `json:"percentile"`
+	// BumpPercent is the min increase of the gas price of a replacement tx
+	BumpPercent float64 `json:"bump_percent"`
+	// StuckAfter is the number of seconds a tx is pending before it is
+	// replaced, 0 disables automatic replacement
+	StuckAfter uint64 `json:"stuck_after"`
+}
+
+func (self GasPricePolicy) Validate() error {
+	if self.MaxGwei <= 0 || self.MinGwei < 0 || self.MinGwei > self.MaxGwei {
+		return fmt.Errorf("min and max gas prices must be 0 <= min <= max and max > 0, got %f and %f", self.MinGwei, self.MaxGwei)
+	}
+	if self.Percentile < 0 || self.Percentile > 100 {
+		return fmt.Errorf("percentile must be in [0, 100], got %f", self.Percentile)
+	}
+	if self.BumpPercent < MIN_GAS_PRICE_BUMP_PERCENT {
+		return fmt.Errorf("bump percent must be at least %f, got %f", MIN_GAS_PRICE_BUMP_PERCENT, self.BumpPercent)
+	}
+	return nil
+}
+
+// GasPriceConfig is the gas price policies by operator name, the
+// DEFAULT_GAS_PRICE_POLICY policy applies to other operators
+type GasPriceConfig struct {
+	Blocks   int                       `json:"blocks"`
+	Policies map[string]GasPricePolicy `json:"policies"`
+}
+
+func DefaultGasPriceConfig() GasPriceConfig {
+	return GasPriceConfig{
+		Blocks: DEFAULT_GAS_PRICE_BLOCKS,
+		Policies: map[string]GasPricePolicy{
+			DEFAULT_GAS_PRICE_POLICY: {
+				MinGwei:     1,
+				MaxGwei:     100,
+				Percentile:  60,
+				BumpPercent: 20,
+				StuckAfter:  300,
+			},
+		},
+	}
+}
+
+func (self GasPriceConfig) Validate() error {
+	if self.Blocks <= 0 {
+		return fmt.Errorf("number of sampled blocks must be positive, got %d", self.Blocks)
+	}
+	if _, found := self.Policies[DEFAULT_GAS_PRICE_POLICY]; !found {
+		return fmt.Errorf("%s gas price policy is required", DEFAULT_GAS_PRICE_POLICY)
+	}
+	for name, policy := range self.Policies {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("gas price policy %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// GetGasPriceConfigFromFile reads gas price policies from path, fields
+// which are not set keep the default config values
+func GetGasPriceConfigFromFile(path string) (GasPriceConfig, error) {
+	result := DefaultGasPriceConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	config := GasPriceConfig{}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return result, err
+	}
+	if config.Blocks != 0 {
+		result.Blocks = config.Blocks
+	}
+	for name, policy := range config.Policies {
+		result.Policies[name] = policy
+	}
+	if err = result.Validate(); err != nil {
+		return DefaultGasPriceConfig(), err
+	}
+	return result, nil
+}
+
+// GasPriceSource is where the oracle reads gas prices from
+type GasPriceSource interface {
+	// NodeGasPrice is the eth_gasPrice suggestion of the node
+	NodeGasPrice() (*big.Int, error)
+	// BlockGasPrices returns gas prices of txs in the latest blocks
+	BlockGasPrices(blocks int) ([]*big.Int, error)
+}
+
+// RPCGasPriceSource reads gas prices from a node, gas prices of blocks
+// are cached as they never change
+type RPCGasPriceSource struct {
+	client *rpc.Client
+	mu     sync.Mutex
+	blocks map[uint64][]*big.Int
+}
+
+func NewRPCGasPriceSource(client *rpc.Client) *RPCGasPriceSource {
+	return &RPCGasPriceSource{
+		client: client,
+		blocks: map[uint64][]*big.Int{},
+	}
+}
+
+func (self *RPCGasPriceSource) NodeGasPrice() (*big.Int, error) {
+	var result hexutil.Big
+	if err := self.client.Call(&result, "eth_gasPrice"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
+func (self *RPCGasPriceSource) BlockGasPrices(blocks int) ([]*big.Int, error) {
+	var blockno string
+	if err := self.client.Call(&blockno, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+	current, err := strconv.ParseUint(blockno, 0, 64)
+	if err != nil {
+		return nil, err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []*big.Int{}
+	for i := uint64(0); i < uint64(blocks) && i <= current; i++ {
+		prices, found := self.blocks[current-i]
+		if !found {
+			var block struct {
+				Transactions []struct {
+					GasPrice *hexutil.Big `json:"gasPrice"`
+				} `json:"transactions"`
+			}
+			if err = self.client.Call(&block, "eth_getBlockByNumber", fmt.Sprintf("0x%x", current-i), true); err != nil {
+				return nil, err
+			}
+			prices = []*big.Int{}
+			for _, tx := range block.Transactions {
+				if tx.GasPrice != nil {
+					prices = append(prices, tx.GasPrice.ToInt())
+				}
+			}
+			self.blocks[current-i] = prices
+		}
+		result = append(result, prices...)
+	}
+	for number := range self.blocks {
+		if number+uint64(blocks) <= current {
+			delete(self.blocks, number)
+		}
+	}
+	return result, nil
+}
+
+// GasPriceOracle suggests gas prices of new and replacement txs by the
+// policy of their operator
+type GasPriceOracle struct {
+	source GasPriceSource
+	config GasPriceConfig
+}
+
+func NewGasPriceOracle(source GasPriceSource, config GasPriceConfig) *GasPriceOracle {
+	return &GasPriceOracle{source, config}
+}
+
+func (self *GasPriceOracle) Policy(operator string) GasPricePolicy {
+	if policy, found := self.config.Policies[operator]; found {
+		return policy
+	}
+	return self.config.Policies[DEFAULT_GAS_PRICE_POLICY]
+}
+
+// GasPrice returns the gas price of new txs of operator, it is the policy
+// percentile of gas prices in the latest blocks or the node suggestion,
+// within the policy caps
+func (self *GasPriceOracle) GasPrice(operator string) (*big.Int, error) {
+	policy := self.Policy(operator)
+	var price *big.Int
+	if policy.Percentile > 0 {
+		prices, err := self.source.BlockGasPrices(self.config.Blocks)
+		if err != nil {
+			log.Printf("Getting gas prices of the latest blocks failed, using the node gas price: %s", err)
+		} else {
+			price = percentile(prices, policy.Percentile)
+		}
+	}
+	if price == nil {
+		var err error
+		if price, err = self.source.NodeGasPrice(); err != nil {
+			return nil, err
+		}
+	}
+	return capGasPrice(price, policy), nil
+}
+
+// ReplacementGasPrice returns the gas price of a tx replacing a pending tx
+// of operator at oldPrice. It is the higher of the bumped old price and
+// the current gas price, an error is returned when the max cap doesn't
+// allow the bump.
+func (self *GasPriceOracle) ReplacementGasPrice(operator string, oldPrice *big.Int) (*big.Int, error) {
+	policy := self.Policy(operator)
+	bumped := big.NewInt(0).Mul(oldPrice, big.NewInt(int64(math.Ceil(10000+policy.BumpPercent*100))))
+	bumped.Add(bumped, big.NewInt(9999))
+	bumped.Div(bumped, big.NewInt(10000))
+	price := bumped
+	if current, err := self.GasPrice(operator); err == nil && current.Cmp(bumped) > 0 {
+		price = current
+	}
+	if price.Cmp(gweiToWei(policy.MaxGwei)) > 0 {
+		return nil, fmt.Errorf(
+			"gas price %s of the pending tx can't be raised by %.1f%% under the max %.1f gwei",
+			oldPrice.Text(10), policy.BumpPercent, policy.MaxGwei)
+	}
+	return price, nil
+}
+
+// percentile returns the nearest rank p percentile of prices, it is nil
+// if there is no price
+func percentile(prices []*big.Int, p float64) *big.Int {
+	if len(prices) == 0 {
+		return nil
+	}
+	sorted := append([]*big.Int{}, prices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return big.NewInt(0).Set(sorted[rank-1])
+}
+
+func capGasPrice(price *big.Int, policy GasPricePolicy) *big.Int {
+	if min := gweiToWei(policy.MinGwei); price.Cmp(min) < 0 {
+		return min
+	}
+	if max := gweiToWei(policy.MaxGwei); price.Cmp(max) > 0 {
+		return max
+	}
+	return big.NewInt(0).Set(price)
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	result, _ := big.NewFloat(0).Mul(big.NewFloat(gwei), big.NewFloat(1000000000)).Int(nil)
+	return result
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+type testGasPriceSource struct {
+	node   *big.Int
+	blocks []*big.Int
+}
+
+func (self *testGasPriceSource) NodeGasPrice() (*big.Int, error) {
+	return self.node, nil
+}
+
+func (self *testGasPriceSource) BlockGasPrices(blocks int) ([]*big.Int, error) {
+	if self.blocks == nil {
+		return nil, errors.New("node is unavailable")
+	}
+	return self.blocks, nil
+}
+
+func gwei(values ...float64) []*big.Int {
+	result := []*big.Int{}
+	for _, value := range values {
+		result = append(result, gweiToWei(value))
+	}
+	return result
+}
+
+func TestGasPriceOracle(t *testing.T) {
+	source := &testGasPriceSource{node: gweiToWei(1), blocks: gwei(100, 7, 1, 5, 3)}
+	oracle := NewGasPriceOracle(source, GasPriceConfig{
+		Blocks: 10,
+		Policies: map[string]GasPricePolicy{
+			DEFAULT_GAS_PRICE_POLICY: {MinGwei: 2, MaxGwei: 50, Percentile: 50, BumpPercent: 20},
+			"nodeOP":                 {MinGwei: 2, MaxGwei: 50, BumpPercent: 20},
+		},
+	})
+	expect := func(name string, got *big.Int, err error, gweiPrice float64) {
+		if err != nil || got.Cmp(gweiToWei(gweiPrice)) != 0 {
+			t.Fatalf("%s: expected %f gwei, got %v, error: %v", name, gweiPrice, got, err)
+		}
+	}
+
+	price, err := oracle.GasPrice("pricingOP")
+	expect("percentile of block gas prices", price, err, 5)
+	price, err = oracle.GasPrice("nodeOP")
+	expect("node gas price under the min cap", price, err, 2)
+	source.blocks = nil
+	source.node = gweiToWei(8)
+	price, err = oracle.GasPrice("pricingOP")
+	expect("node gas price when blocks are unavailable", price, err, 8)
+	source.blocks = gwei(200, 300)
+	price, err = oracle.GasPrice("pricingOP")
+	expect("block gas prices over the max cap", price, err, 50)
+
+	source.blocks = gwei(5)
+	price, err = oracle.ReplacementGasPrice("pricingOP", gweiToWei(10))
+	expect("bumped replacement gas price", price, err, 12)
+	source.blocks = gwei(30)
+	price, err = oracle.ReplacementGasPrice("pricingOP", gweiToWei(10))
+	expect("current gas price over the bumped price", price, err, 30)
+	if price, err = oracle.ReplacementGasPrice("pricingOP", gweiToWei(45)); err == nil {
+		t.Fatalf("Expected bumping over the max cap to fail, got %v", price)
+	}
+}
+
+func TestGasPriceConfigValidate(t *testing.T) {
+	config := DefaultGasPriceConfig()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected default config to be valid: %s", err)
+	}
+	config.Policies["depositOP"] = GasPricePolicy{MinGwei: 10, MaxGwei: 5, BumpPercent: 20}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("Expected min over max to be rejected")
+	}
+	config.Policies["depositOP"] = GasPricePolicy{MinGwei: 1, MaxGwei: 5, BumpPercent: 5}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("Expected bumps under %f%% to be rejected", MIN_GAS_PRICE_BUMP_PERCENT)
+	}
+}
@@ -26,7 +26,7 @@ func NewAutoIncreasing(
 }
 
 func (self *AutoIncreasing) GetAddress() ethereum.Address {
-	return self.GetAddress()
+	return self.address
 }
 
 func (self *AutoIncreasing) getNonceFromNode(ethclient *ethclient.Client) (*big.Int, error) {
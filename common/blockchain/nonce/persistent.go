@@ -0,0 +1,253 @@
+package nonce
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Storage persists nonce states of operator addresses
+type Storage interface {
+	StoreNonceState(state common.NonceState) error
+	// GetNonceState returns the stored state of address, it is empty if
+	// there is none
+	GetNonceState(address ethereum.Address) (common.NonceState, error)
+}
+
+// Persistent allocates nonces of an address and stores every allocation
+// before returning it, so nonces are neither reused nor skipped across
+// restarts and concurrent broadcasts.
+// Before each allocation it reconciles with the node: nonces below the
+// mined nonce are released and new nonces start from the node pending
+// nonce if it is ahead. An allocated nonce which is still unknown to the
+// node gapTimeout after its allocation is a gap left by a dropped tx, the
+// lowest one is allocated again before any new nonce.
+type Persistent struct {
+	address    ethereum.Address
+	storage    Storage
+	gapTimeout uint64 // millisecond
+	mu         sync.Mutex
+	state      *common.NonceState
+}
+
+func NewPersistent(address ethereum.Address, storage Storage, gapTimeout uint64) *Persistent {
+	return &Persistent{
+		address:    address,
+		storage:    storage,
+		gapTimeout: gapTimeout,
+	}
+}
+
+func (self *Persistent) GetAddress() ethereum.Address {
+	return self.address
+}
+
+func (self *Persistent) MinedNonce(ethclient *ethclient.Client) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	nonce, err := ethclient.NonceAt(ctx, self.GetAddress(), nil)
+	return big.NewInt(int64(nonce)), err
+}
+
+func (self *Persistent) nodeNonces(ethclient *ethclient.Client) (uint64, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	mined, err := ethclient.NonceAt(ctx, self.GetAddress(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	pending, err := ethclient.PendingNonceAt(ctx, self.GetAddress())
+	return mined, pending, err
+}
+
+// Reconcile updates the stored state with the mined and pending nonces of
+// the node, it is done on startup to report gaps before the first
+// allocation
+func (self *Persistent) Reconcile(ethclient *ethclient.Client) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	mined, pending, err := self.nodeNonces(ethclient)
+	if err != nil {
+		return err
+	}
+	timepoint := common.GetTimepoint()
+	if err = self.reconcile(mined, pending, timepoint); err != nil {
+		return err
+	}
+	return self.store()
+}
+
+func (self *Persistent) GetNextNonce(ethclient *ethclient.Client) (*big.Int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	mined, pending, err := self.nodeNonces(ethclient)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+	nonce, err := self.next(mined, pending, common.GetTimepoint())
+	if err != nil {
+		return big.NewInt(0), err
+	}
+	return big.NewInt(int64(nonce)), nil
+}
+
+// next reconciles with the mined and pending nonces of the node, then
+// allocates and stores a nonce
+func (self *Persistent) next(mined, pending, timepoint uint64) (uint64, error) {
+	if err := self.reconcile(mined, pending, timepoint); err != nil {
+		return 0, err
+	}
+	nonce := self.allocate(timepoint)
+	return nonce, self.store()
+}
+
+// Release gives back an allocated nonce whose tx couldn't be built or
+// signed. The nonce is allocated again before any new nonce, nonces on
+// top of the allocated ones are not needed anymore.
+func (self *Persistent) Release(nonce uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.release(nonce, common.GetTimepoint())
+}
+
+func (self *Persistent) release(nonce, timepoint uint64) error {
+	if err := self.load(); err != nil {
+		return err
+	}
+	state := self.state
+	if _, found := state.Allocated[nonce]; !found {
+		return nil
+	}
+	delete(state.Allocated, nonce)
+	for state.Next > state.Pending {
+		if _, found := state.Allocated[state.Next-1]; found {
+			break
+		}
+		state.Next--
+	}
+	if err := self.reconcile(state.Mined, state.Pending, timepoint); err != nil {
+		return err
+	}
+	return self.store()
+}
+
+// State returns a copy of the nonce state
+func (self *Persistent) State() (common.NonceState, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.load(); err != nil {
+		return common.NonceState{}, err
+	}
+	result := *self.state
+	result.Allocated = map[uint64]uint64{}
+	for nonce, timepoint := range self.state.Allocated {
+		result.Allocated[nonce] = timepoint
+	}
+	result.Gaps = append([]uint64{}, self.state.Gaps...)
+	return result, nil
+}
+
+func (self *Persistent) load() error {
+	if self.state != nil {
+		return nil
+	}
+	state, err := self.storage.GetNonceState(self.address)
+	if err != nil {
+		return err
+	}
+	state.Address = self.address.Hex()
+	state.Persistent = true
+	if state.Allocated == nil {
+		state.Allocated = map[uint64]uint64{}
+	}
+	self.state = &state
+	return nil
+}
+
+// store persists the state, the state is reloaded from the storage on next
+// use if it fails so allocations which are not stored are discarded
+func (self *Persistent) store() error {
+	err := self.storage.StoreNonceState(*self.state)
+	if err != nil {
+		self.state = nil
+	}
+	return err
+}
+
+func (self *Persistent) reconcile(mined, pending, timepoint uint64) error {
+	if err := self.load(); err != nil {
+		return err
+	}
+	state := self.state
+	state.Mined = mined
+	state.Pending = pending
+	for nonce := range state.Allocated {
+		if nonce < mined {
+			delete(state.Allocated, nonce)
+		}
+	}
+	if state.Next < pending {
+		state.Next = pending
+	}
+	state.Gaps = []uint64{}
+	for nonce := pending; nonce < state.Next; nonce++ {
+		allocatedAt, found := state.Allocated[nonce]
+		if !found || timepoint >= allocatedAt+self.gapTimeout {
+			state.Gaps = append(state.Gaps, nonce)
+		}
+	}
+	state.UpdatedAt = timepoint
+	return nil
+}
+
+// allocate returns the lowest gap if the node is waiting for it, txs of
+// higher gaps may still be queued in the node, otherwise a new nonce
+func (self *Persistent) allocate(timepoint uint64) uint64 {
+	state := self.state
+	var nonce uint64
+	if len(state.Gaps) > 0 && state.Gaps[0] == state.Pending {
+		nonce = state.Gaps[0]
+		state.Gaps = state.Gaps[1:]
+		state.Filled++
+	} else {
+		nonce = state.Next
+		state.Next++
+	}
+	state.Allocated[nonce] = timepoint
+	return nonce
+}
+
+// Corpora creates the Persistent of each operator address, operators of
+// the same address get the same Persistent so their allocations don't
+// overlap
+type Corpora struct {
+	storage    Storage
+	gapTimeout uint64 // millisecond
+	mu         sync.Mutex
+	corpora    map[ethereum.Address]*Persistent
+}
+
+func NewCorpora(storage Storage, gapTimeout uint64) *Corpora {
+	return &Corpora{
+		storage:    storage,
+		gapTimeout: gapTimeout,
+		corpora:    map[ethereum.Address]*Persistent{},
+	}
+}
+
+// Get returns the Persistent of address
+func (self *Corpora) Get(address ethereum.Address) *Persistent {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	corpus, found := self.corpora[address]
+	if !found {
+		corpus = NewPersistent(address, self.storage, self.gapTimeout)
+		self.corpora[address] = corpus
+	}
+	return corpus
+}
@@ -0,0 +1,131 @@
+package nonce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type testNonceStorage struct {
+	states map[string][]byte
+	fail   bool
+}
+
+func (self *testNonceStorage) StoreNonceState(state common.NonceState) error {
+	if self.fail {
+		return errors.New("storage is unavailable")
+	}
+	data, err := json.Marshal(state)
+	self.states[state.Address] = data
+	return err
+}
+
+func (self *testNonceStorage) GetNonceState(address ethereum.Address) (common.NonceState, error) {
+	result := common.NonceState{}
+	data, found := self.states[address.Hex()]
+	if !found {
+		return result, nil
+	}
+	err := json.Unmarshal(data, &result)
+	return result, err
+}
+
+func TestPersistentNonce(t *testing.T) {
+	address := ethereum.HexToAddress("0x1111111111111111111111111111111111111111")
+	storage := &testNonceStorage{states: map[string][]byte{}}
+	corpus := NewPersistent(address, storage, 1000)
+	expect := func(name string, corpus *Persistent, mined, pending, timepoint, expected uint64) {
+		nonce, err := corpus.next(mined, pending, timepoint)
+		if err != nil || nonce != expected {
+			t.Fatalf("%s: expected nonce %d, got %d, error: %v", name, expected, nonce, err)
+		}
+	}
+
+	expect("node pending nonce", corpus, 5, 5, 100, 5)
+	// the node doesn't know the txs of nonce 5 and 6 yet
+	expect("next nonce before the node catches up", corpus, 5, 5, 200, 6)
+	expect("next nonce after the node catches up", corpus, 5, 7, 300, 7)
+
+	// a restart continues from the stored state
+	restarted := NewPersistent(address, storage, 1000)
+	expect("next nonce after restart", restarted, 6, 7, 400, 8)
+	state, err := restarted.State()
+	if err != nil || fmt.Sprint(state.Allocated) != "map[6:200 7:300 8:400]" {
+		t.Fatalf("Expected nonces under the mined nonce to be released, got %+v, error: %v", state, err)
+	}
+
+	// txs of nonce 8 and 9 are dropped, 8 is refilled once it is timed out
+	expect("next nonce while nonce 8 is not timed out", restarted, 8, 8, 1000, 9)
+	expect("gap of the dropped tx", restarted, 8, 8, 1500, 8)
+	expect("next nonce after filling the gap", restarted, 8, 9, 1600, 10)
+	if state, err = restarted.State(); err != nil || state.Filled != 1 || fmt.Sprint(state.Gaps) != "[]" {
+		t.Fatalf("Expected one filled gap, got %+v, error: %v", state, err)
+	}
+	expect("gap after a filled gap", restarted, 9, 9, 2500, 9)
+
+	// allocations which are not stored are discarded
+	storage.fail = true
+	if _, err = restarted.next(10, 11, 2600); err == nil {
+		t.Fatalf("Expected allocation to fail when the state can't be stored")
+	}
+	storage.fail = false
+	expect("nonce of the failed allocation", restarted, 10, 11, 2700, 11)
+}
+
+func TestPersistentNonceRelease(t *testing.T) {
+	address := ethereum.HexToAddress("0x1111111111111111111111111111111111111111")
+	storage := &testNonceStorage{states: map[string][]byte{}}
+	corpus := NewPersistent(address, storage, 1000)
+	expect := func(name string, mined, pending, timepoint, expected uint64) {
+		nonce, err := corpus.next(mined, pending, timepoint)
+		if err != nil || nonce != expected {
+			t.Fatalf("%s: expected nonce %d, got %d, error: %v", name, expected, nonce, err)
+		}
+	}
+
+	expect("first nonce", 5, 5, 100, 5)
+	expect("second nonce", 5, 5, 100, 6)
+	expect("third nonce", 5, 5, 100, 7)
+	// the tx of the last nonce is not broadcast
+	if err := corpus.release(7, 200); err != nil {
+		t.Fatalf("Expected release to succeed, got error: %s", err)
+	}
+	expect("released last nonce", 5, 5, 300, 7)
+	// the tx of nonce 5 is not broadcast, the node waits for it
+	if err := corpus.release(5, 400); err != nil {
+		t.Fatalf("Expected release to succeed, got error: %s", err)
+	}
+	state, err := corpus.State()
+	if err != nil || fmt.Sprint(state.Gaps) != "[5]" {
+		t.Fatalf("Expected the released nonce to be a gap, got %+v, error: %v", state, err)
+	}
+	expect("released nonce before its gap timeout", 5, 5, 500, 5)
+	expect("next nonce after the released one", 5, 5, 500, 8)
+	if err = corpus.release(3, 600); err != nil {
+		t.Fatalf("Expected releasing a mined nonce to be ignored, got error: %s", err)
+	}
+	expect("next nonce after releasing a mined nonce", 5, 5, 600, 9)
+}
+
+func TestCorpora(t *testing.T) {
+	storage := &testNonceStorage{states: map[string][]byte{}}
+	corpora := NewCorpora(storage, 1000)
+	pricing := corpora.Get(ethereum.HexToAddress("0x1111111111111111111111111111111111111111"))
+	deposit := corpora.Get(ethereum.HexToAddress("0x1111111111111111111111111111111111111111"))
+	other := corpora.Get(ethereum.HexToAddress("0x2222222222222222222222222222222222222222"))
+	if pricing != deposit || pricing == other {
+		t.Fatalf("Expected one corpus per address")
+	}
+	first, err := pricing.next(5, 5, 100)
+	if err != nil {
+		t.Fatalf("Expected allocation to succeed, got error: %s", err)
+	}
+	second, err := deposit.next(5, 5, 100)
+	if err != nil || second == first {
+		t.Fatalf("Expected operators of the same address not to share nonce %d, got %d, error: %v", first, second, err)
+	}
+}
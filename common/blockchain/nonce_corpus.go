@@ -1,9 +1,11 @@
 package blockchain
 
 import (
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"math/big"
 )
 
 type NonceCorpus interface {
@@ -11,3 +13,13 @@ type NonceCorpus interface {
 	GetNextNonce(ethclient *ethclient.Client) (*big.Int, error)
 	MinedNonce(ethclient *ethclient.Client) (*big.Int, error)
 }
+
+// PersistentNonceCorpus is a NonceCorpus keeping its state in a storage,
+// it is reconciled with the node on startup. Allocated nonces whose tx is
+// never signed are released to be allocated again.
+type PersistentNonceCorpus interface {
+	NonceCorpus
+	Reconcile(ethclient *ethclient.Client) error
+	Release(nonce uint64) error
+	State() (common.NonceState, error)
+}
@@ -26,13 +26,24 @@ func (self EthereumSigner) Sign(tx *types.Transaction) (*types.Transaction, erro
 }
 
 func NewEthereumSigner(keyPath string, passphrase string) *EthereumSigner {
-	key, err := os.Open(keyPath)
+	signer, err := LoadEthereumSigner(keyPath, passphrase)
 	if err != nil {
 		panic(err)
 	}
+	return signer
+}
+
+// LoadEthereumSigner is NewEthereumSigner returning an error when the
+// keystore can't be opened or decrypted
+func LoadEthereumSigner(keyPath string, passphrase string) (*EthereumSigner, error) {
+	key, err := os.Open(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
 	auth, err := bind.NewTransactor(key, passphrase)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return &EthereumSigner{opts: auth}
+	return &EthereumSigner{opts: auth}, nil
 }
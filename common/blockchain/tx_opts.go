@@ -7,6 +7,9 @@ import (
 type TxOpts struct {
 	Operator *Operator // Ethereum account to send the transaction from
 	Nonce    *big.Int  // Nonce to use for the transaction execution (nil = use pending state)
+	// NonceAllocated is true if Nonce is allocated by the operator nonce
+	// corpus, it is released if the transaction is not built or signed
+	NonceAllocated bool
 
 	Value    *big.Int // Funds to transfer along along the transaction (nil = 0 = no funds)
 	GasPrice *big.Int // Gas price to use for the transaction execution (nil = gas price oracle)
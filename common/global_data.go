@@ -1,5 +1,13 @@
 package common
 
+import (
+	"sort"
+)
+
+// GOLD_PAIR is the pair priced by the gold feed median, its rate is ETH per
+// troy ounce of gold
+const GOLD_PAIR string = "XAUETH"
+
 type GoldRate struct {
 	Symbol string  `json:"symbol"`
 	Price  float64 `json:"price"`
@@ -22,10 +30,52 @@ type OneForgeGoldData struct {
 	Message   string
 }
 
+// GoldQuote is a quote of a global data source. Rate is the price of one
+// base unit of Pair in its quote currency, Timestamp is when the source
+// priced it in millisecond.
+type GoldQuote struct {
+	Pair      string  `json:"pair"`
+	Rate      float64 `json:"rate"`
+	Timestamp uint64  `json:"timestamp"`
+	Valid     bool    `json:"valid"`
+	Stale     bool    `json:"stale"`
+	Error     string  `json:"error"`
+}
+
+// GoldData keeps quotes of all global data sources by source name, Median
+// is the median rate of valid and fresh GOLD_PAIR quotes
 type GoldData struct {
-	Timestamp uint64
-	DGX       DGXGoldData
-	OneForge  OneForgeGoldData
+	Timestamp uint64               `json:"timestamp"`
+	Sources   map[string]GoldQuote `json:"sources"`
+	Median    float64              `json:"median"`
+}
+
+func NewGoldData(timestamp uint64, sources map[string]GoldQuote) GoldData {
+	return GoldData{
+		Timestamp: timestamp,
+		Sources:   sources,
+		Median:    GoldMedian(sources),
+	}
+}
+
+// GoldMedian returns the median rate of valid and fresh GOLD_PAIR quotes,
+// it is 0 if there is no such quote
+func GoldMedian(sources map[string]GoldQuote) float64 {
+	rates := []float64{}
+	for _, quote := range sources {
+		if quote.Pair == GOLD_PAIR && quote.Valid && !quote.Stale && quote.Rate > 0 {
+			rates = append(rates, quote.Rate)
+		}
+	}
+	if len(rates) == 0 {
+		return 0
+	}
+	sort.Float64s(rates)
+	middle := len(rates) / 2
+	if len(rates)%2 == 0 {
+		return (rates[middle-1] + rates[middle]) / 2
+	}
+	return rates[middle]
 }
 
 // {"value":2.00591,"text":"1 XAU is worth 2.00591 ETH","timestamp":1524811993}
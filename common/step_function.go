@@ -0,0 +1,88 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	QTY_STEP_FUNCTION       string = "qty"
+	IMBALANCE_STEP_FUNCTION string = "imbalance"
+
+	// MAX_STEP_FUNCTION_STEPS is the max number of steps of each side the
+	// pricing contract accepts
+	MAX_STEP_FUNCTION_STEPS int = 10
+	// rate changes of steps are in bps, -10000 bps makes the rate 0
+	MIN_STEP_FUNCTION_BPS int64 = -10000
+	MAX_STEP_FUNCTION_BPS int64 = 10000
+)
+
+// StepFunction is a qty or imbalance step function of the pricing
+// contract. Rates are changed by Y[i] bps when the traded quantity or the
+// imbalance (in token wei) is up to X[i], the last step applies beyond
+// the last X.
+type StepFunction struct {
+	XBuy  []*big.Int `json:"x_buy"`
+	YBuy  []*big.Int `json:"y_buy"`
+	XSell []*big.Int `json:"x_sell"`
+	YSell []*big.Int `json:"y_sell"`
+}
+
+// TokenStepFunctions are the step functions of a token, nil functions are
+// not changed
+type TokenStepFunctions struct {
+	Qty       *StepFunction `json:"qty,omitempty"`
+	Imbalance *StepFunction `json:"imbalance,omitempty"`
+}
+
+// Validate checks that each side has 1 to MAX_STEP_FUNCTION_STEPS steps,
+// X is strictly increasing and Y is within the bps bounds
+func (self StepFunction) Validate() error {
+	if err := validateSteps("buy", self.XBuy, self.YBuy); err != nil {
+		return err
+	}
+	return validateSteps("sell", self.XSell, self.YSell)
+}
+
+func validateSteps(side string, x, y []*big.Int) error {
+	if len(x) != len(y) {
+		return fmt.Errorf("%s side has %d x and %d y values", side, len(x), len(y))
+	}
+	if len(x) == 0 || len(x) > MAX_STEP_FUNCTION_STEPS {
+		return fmt.Errorf("%s side must have 1 to %d steps", side, MAX_STEP_FUNCTION_STEPS)
+	}
+	minBps := big.NewInt(MIN_STEP_FUNCTION_BPS)
+	maxBps := big.NewInt(MAX_STEP_FUNCTION_BPS)
+	for i := range x {
+		if x[i] == nil || y[i] == nil {
+			return fmt.Errorf("%s side has an empty step", side)
+		}
+		if i > 0 && x[i].Cmp(x[i-1]) <= 0 {
+			return fmt.Errorf("x of %s side must be strictly increasing, %s follows %s", side, x[i].Text(10), x[i-1].Text(10))
+		}
+		if y[i].Cmp(minBps) < 0 || y[i].Cmp(maxBps) > 0 {
+			return fmt.Errorf("y of %s side must be in [%d, %d] bps, got %s", side, MIN_STEP_FUNCTION_BPS, MAX_STEP_FUNCTION_BPS, y[i].Text(10))
+		}
+	}
+	return nil
+}
+
+// Validate checks step functions of the token, at least one of them must
+// be set
+func (self TokenStepFunctions) Validate() error {
+	if self.Qty == nil && self.Imbalance == nil {
+		return errors.New("at least one of qty and imbalance step functions must be set")
+	}
+	if self.Qty != nil {
+		if err := self.Qty.Validate(); err != nil {
+			return fmt.Errorf("qty step function: %s", err)
+		}
+	}
+	if self.Imbalance != nil {
+		if err := self.Imbalance.Validate(); err != nil {
+			return fmt.Errorf("imbalance step function: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+)
+
+func bigs(values ...int64) []*big.Int {
+	result := []*big.Int{}
+	for _, value := range values {
+		result = append(result, big.NewInt(value))
+	}
+	return result
+}
+
+func TestStepFunctionValidate(t *testing.T) {
+	valid := StepFunction{
+		XBuy:  bigs(-100, 0, 100),
+		YBuy:  bigs(-50, 0, 30),
+		XSell: bigs(100),
+		YSell: bigs(-10000),
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected step function to be valid, got %s", err)
+	}
+	invalid := map[string]StepFunction{
+		"length mismatch": {XBuy: bigs(1, 2), YBuy: bigs(1), XSell: bigs(1), YSell: bigs(1)},
+		"empty side":      {XBuy: bigs(), YBuy: bigs(), XSell: bigs(1), YSell: bigs(1)},
+		"too many steps":  {XBuy: bigs(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11), YBuy: bigs(0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0), XSell: bigs(1), YSell: bigs(1)},
+		"not increasing":  {XBuy: bigs(1, 1), YBuy: bigs(0, 0), XSell: bigs(1), YSell: bigs(1)},
+		"below min bps":   {XBuy: bigs(1), YBuy: bigs(1), XSell: bigs(1), YSell: bigs(-10001)},
+		"above max bps":   {XBuy: bigs(1), YBuy: bigs(10001), XSell: bigs(1), YSell: bigs(1)},
+	}
+	for name, stepFunction := range invalid {
+		if err := stepFunction.Validate(); err == nil {
+			t.Fatalf("Expected step function with %s to be invalid", name)
+		}
+	}
+	if err := (TokenStepFunctions{}).Validate(); err == nil {
+		t.Fatalf("Expected token step functions without any function to be invalid")
+	}
+}
@@ -289,14 +289,18 @@ func (self ActivityRecord) IsExchangePending() bool {
 			self.MiningStatus != "failed"
 	case "trade":
 		return self.ExchangeStatus == "" || self.ExchangeStatus == "submitted"
+	case "rebalance":
+		return false
 	}
 	return true
 }
 
 func (self ActivityRecord) IsBlockchainPending() bool {
 	switch self.Action {
-	case "withdraw", "deposit", "set_rates":
+	case "withdraw", "deposit", "set_rates", "set_step_function":
 		return (self.MiningStatus == "" || self.MiningStatus == "submitted") && self.ExchangeStatus != "failed"
+	case "rebalance":
+		return false
 	}
 	return true
 }
@@ -314,13 +318,46 @@ func (self ActivityRecord) IsPending() bool {
 	case "trade":
 		return (self.ExchangeStatus == "" || self.ExchangeStatus == "submitted") &&
 			self.ExchangeStatus != "failed"
-	case "set_rates":
+	case "set_rates", "set_step_function":
 		return (self.MiningStatus == "" || self.MiningStatus == "submitted") &&
 			self.ExchangeStatus != "failed"
+	case "rebalance":
+		// rebalance decisions are only recorded for auditing, the actual
+		// deposit, withdraw and trade are tracked by their own activities
+		return false
 	}
 	return true
 }
 
+// ReplacedTxs returns hashes of txs of the activity which were replaced
+// at higher gas prices, oldest first
+func (self ActivityRecord) ReplacedTxs() []string {
+	result := []string{}
+	switch txs := self.Result["replaced_txs"].(type) {
+	case []string:
+		result = append(result, txs...)
+	case []interface{}:
+		for _, tx := range txs {
+			if hash, ok := tx.(string); ok {
+				result = append(result, hash)
+			}
+		}
+	}
+	return result
+}
+
+// SubmittedAt returns the time (millisecond) the current tx of the
+// activity was sent, it is the time of the last replacement if there is
+// one
+func (self ActivityRecord) SubmittedAt() uint64 {
+	if replacedAt, ok := self.Result["replaced_at"].(string); ok {
+		if result, err := strconv.ParseUint(replacedAt, 10, 64); err == nil {
+			return result
+		}
+	}
+	return self.Timestamp.ToUint64()
+}
+
 type ActivityStatus struct {
 	ExchangeStatus string
 	Tx             string
@@ -347,6 +384,49 @@ type AllPriceResponse struct {
 	Block      uint64
 }
 
+// SimulatedRate is the rate of the pricing contract for Quantity, it is in
+// token per ETH for buys (Quantity in ETH) and ETH per token for sells
+// (Quantity in token). Price is the rate in ETH per token and Spreads are
+// relative distances of Price from the orderbook mid of each exchange,
+// positive spreads are worse than the mid for users.
+type SimulatedRate struct {
+	Quantity float64                `json:"quantity"`
+	Rate     float64                `json:"rate"`
+	Price    float64                `json:"price"`
+	Spreads  map[ExchangeID]float64 `json:"spreads"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// RateSimulation is the preview of the rates users get for a token and
+// side at Block
+type RateSimulation struct {
+	Token string                 `json:"token"`
+	Side  string                 `json:"side"`
+	Block uint64                 `json:"block"`
+	Mids  map[ExchangeID]float64 `json:"mids"`
+	Rates []SimulatedRate        `json:"rates"`
+}
+
+// CompactRatePlan is how a set rates transaction updates token rates.
+// Method is setBaseRate if base rates of BaseTokens must be set, either
+// because their compact rates overflow or because they are rebased
+// proactively, otherwise it is setCompactData. Gas is an estimate and
+// Cost is Gas at GasPrice in wei.
+type CompactRatePlan struct {
+	Method     string             `json:"method"`
+	BaseTokens []ethereum.Address `json:"base_tokens"`
+	BaseBuys   []*big.Int         `json:"base_buys"`
+	BaseSells  []*big.Int         `json:"base_sells"`
+	Overflowed []ethereum.Address `json:"overflowed"`
+	Rebased    []ethereum.Address `json:"rebased"`
+	BuyBulks   [][14]byte         `json:"buy_bulks"`
+	SellBulks  [][14]byte         `json:"sell_bulks"`
+	Indices    []*big.Int         `json:"indices"`
+	Gas        uint64             `json:"gas"`
+	GasPrice   *big.Int           `json:"gas_price"`
+	Cost       *big.Int           `json:"cost"`
+}
+
 type OnePriceResponse struct {
 	Version    Version
 	Timestamp  Timestamp
@@ -577,6 +657,7 @@ type KNLog interface {
 type SetCatLog struct {
 	Timestamp       uint64
 	BlockNumber     uint64
+	BlockHash       ethereum.Hash
 	TransactionHash ethereum.Hash
 	Index           uint
 
@@ -591,6 +672,7 @@ func (self SetCatLog) TxHash() ethereum.Hash { return self.TransactionHash }
 type TradeLog struct {
 	Timestamp       uint64
 	BlockNumber     uint64
+	BlockHash       ethereum.Hash
 	TransactionHash ethereum.Hash
 	Index           uint
 
@@ -600,6 +682,9 @@ type TradeLog struct {
 	SrcAmount   *big.Int
 	DestAmount  *big.Int
 	FiatAmount  float64
+	// FiatRateExact is false if FiatAmount is priced with an interpolated
+	// or realtime ETH-USD rate, or is 0 since no rate is known
+	FiatRateExact bool
 
 	ReserveAddress ethereum.Address
 	WalletAddress  ethereum.Address
@@ -609,6 +694,14 @@ type TradeLog struct {
 	Country        string
 }
 
+// FirstTradeSnapshot records if a trade is the first trade of its user
+// ever and in the day of each timezone when it is first aggregated, so
+// unique address counts are rolled back as they were aggregated
+type FirstTradeSnapshot struct {
+	Ever  bool           `json:"ever"`
+	InDay map[int64]bool `json:"in_day"`
+}
+
 type ReserveRateEntry struct {
 	BuyReserveRate  float64
 	BuySanityRate   float64
@@ -630,6 +723,12 @@ func (self TradeLog) BlockNo() uint64       { return self.BlockNumber }
 func (self TradeLog) Type() string          { return "TradeLog" }
 func (self TradeLog) TxHash() ethereum.Hash { return self.TransactionHash }
 
+// USDRate is the ETH-USD rate at a timepoint in millisecond
+type USDRate struct {
+	Timepoint uint64  `json:"timepoint"`
+	Rate      float64 `json:"rate"`
+}
+
 type StatTicks map[uint64]interface{}
 
 type TradeStats map[string]float64
@@ -664,6 +763,24 @@ type MetricStats struct {
 
 type MetricStatsTimeZone map[int64]map[uint64]MetricStats
 
+// ReserveTradeStats is trade stats of a reserve in a time frame
+type ReserveTradeStats struct {
+	TradeCount int     `json:"total_trade"`
+	ETHVolume  float64 `json:"total_eth_volume"`
+	USDVolume  float64 `json:"total_usd_amount"`
+	BurnFee    float64 `json:"total_burn_fee"`
+	WalletFee  float64 `json:"total_wallet_fee"`
+}
+
+type ReserveTradeStatsFreq map[string]map[uint64]ReserveTradeStats
+
+// ReserveMarketShare is the share of a reserve in the ETH volume of all
+// configured reserves in a time frame
+type ReserveMarketShare struct {
+	ETHVolume float64 `json:"total_eth_volume"`
+	Share     float64 `json:"share"`
+}
+
 type UserInfo struct {
 	Addr      string  `json:"user_address"`
 	Email     string  `json:"email"`
@@ -697,9 +814,13 @@ type AllTradeHistory struct {
 	Data      map[ExchangeID]ExchangeTradeHistory
 }
 
+// ExStatus is the status of an exchange, Tripped is set when the exchange
+// is disabled by the circuit breaker rather than manually
 type ExStatus struct {
 	Timestamp uint64 `json:"timestamp"`
 	Status    bool   `json:"status"`
+	Tripped   bool   `json:"tripped,omitempty"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 type ExchangesStatus map[string]ExStatus
@@ -778,3 +899,74 @@ func (t TokenHeatmapResponse) Len() int      { return len(t) }
 func (t TokenHeatmapResponse) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
 
 type UsersVolume map[string]StatTicks
+
+// APIKeyScope grants a permission of the http api, it is restricted to
+// Exchanges and Tokens when they are not empty.
+type APIKeyScope struct {
+	Permission string   `json:"permission"`
+	Exchanges  []string `json:"exchanges,omitempty"`
+	Tokens     []string `json:"tokens,omitempty"`
+}
+
+// APIKey signs requests to the http api with its secret. ExpiresAt is a
+// timepoint in millisecond, 0 means the key never expires.
+type APIKey struct {
+	ID        string        `json:"id"`
+	Secret    string        `json:"secret,omitempty"`
+	Scopes    []APIKeyScope `json:"scopes"`
+	CreatedAt uint64        `json:"created_at"`
+	ExpiresAt uint64        `json:"expires_at"`
+	Revoked   bool          `json:"revoked"`
+}
+
+// AuditRecord is an authenticated api request. KeyID is the key verified
+// to sign the request, RequestedKey is the "apikey" header even if its
+// signature is invalid and CosignerKey is the key verified to co-sign it.
+// Success and Reason are read from the response.
+type AuditRecord struct {
+	Timestamp    uint64            `json:"timestamp"`
+	Method       string            `json:"method"`
+	Endpoint     string            `json:"endpoint"`
+	Permission   string            `json:"permission"`
+	KeyID        string            `json:"key_id"`
+	RequestedKey string            `json:"requested_key,omitempty"`
+	CosignerKey  string            `json:"cosigner_key,omitempty"`
+	Params       map[string]string `json:"params"`
+	Status       int               `json:"status"`
+	Success      bool              `json:"success"`
+	Reason       string            `json:"reason,omitempty"`
+}
+
+// NonceState is the nonce allocation state of an operator address
+type NonceState struct {
+	Address string `json:"address"`
+	// Persistent is false for operators whose nonces are only kept in
+	// memory, only Address, Mined and Pending are set for them
+	Persistent bool `json:"persistent"`
+	// Next is the next new nonce to allocate
+	Next uint64 `json:"next"`
+	// Mined and Pending are the mined and pending nonces of the node at
+	// the last reconciliation
+	Mined   uint64 `json:"mined"`
+	Pending uint64 `json:"pending"`
+	// Allocated is the allocation time (millisecond) of nonces which are
+	// not mined yet
+	Allocated map[uint64]uint64 `json:"allocated"`
+	// Gaps are allocated nonces which the node doesn't know long after
+	// their allocation, their txs are dropped
+	Gaps []uint64 `json:"gaps"`
+	// Filled is the number of gaps allocated again
+	Filled    uint64 `json:"filled"`
+	UpdatedAt uint64 `json:"updated_at"`
+}
+
+// StableExOrder is a trade order of the stable exchange, it is pending
+// until its tx to the stable token contract is mined or failed
+type StableExOrder struct {
+	Base      string  `json:"base"`
+	Quote     string  `json:"quote"`
+	Type      string  `json:"type"`
+	Rate      float64 `json:"rate"`
+	Amount    float64 `json:"amount"`
+	Timestamp uint64  `json:"timestamp"`
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"sync"
+)
+
+const (
+	PRICE_UPDATE     string = "prices"
+	RATE_UPDATE      string = "rates"
+	AUTH_DATA_UPDATE string = "authdata"
+	// number of updates buffered for each subscriber, updates to a
+	// subscriber which falls behind more than that are dropped
+	UPDATE_FEED_BUFFER int = 100
+)
+
+// DataUpdate notifies that a new version of a topic is stored
+type DataUpdate struct {
+	Topic   string
+	Version Version
+}
+
+// UpdateFeed fans data updates out to its subscribers, it is safe for
+// concurrent use.
+type UpdateFeed struct {
+	mu          sync.Mutex
+	subscribers map[<-chan DataUpdate]chan DataUpdate
+}
+
+func NewUpdateFeed() *UpdateFeed {
+	return &UpdateFeed{
+		subscribers: map[<-chan DataUpdate]chan DataUpdate{},
+	}
+}
+
+func (self *UpdateFeed) Subscribe() <-chan DataUpdate {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	ch := make(chan DataUpdate, UPDATE_FEED_BUFFER)
+	self.subscribers[ch] = ch
+	return ch
+}
+
+// Unsubscribe stops sending updates to ch and closes it
+func (self *UpdateFeed) Unsubscribe(ch <-chan DataUpdate) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if sub, found := self.subscribers[ch]; found {
+		delete(self.subscribers, ch)
+		close(sub)
+	}
+}
+
+// Publish never blocks, the update is dropped for subscribers whose
+// buffers are full
+func (self *UpdateFeed) Publish(topic string, version Version) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	update := DataUpdate{topic, version}
+	for _, sub := range self.subscribers {
+		select {
+		case sub <- update:
+		default:
+		}
+	}
+}
@@ -20,4 +20,8 @@ type ActivityStorage interface {
 	GetActivity(id common.ActivityID) (common.ActivityRecord, error)
 
 	PendingSetrate(minedNonce uint64) (*common.ActivityRecord, error)
+
+	GetPendingActivities() ([]common.ActivityRecord, error)
+
+	UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error
 }
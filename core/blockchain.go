@@ -20,6 +20,32 @@ type Blockchain interface {
 		block *big.Int,
 		nonce *big.Int,
 		gasPrice *big.Int) (*types.Transaction, error)
+	PlanRates(
+		tokens []ethereum.Address,
+		buys []*big.Int,
+		sells []*big.Int,
+		gasPrice *big.Int) (common.CompactRatePlan, error)
+	SetQtyStepFunction(
+		token ethereum.Address,
+		xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error)
+	SetImbalanceStepFunction(
+		token ethereum.Address,
+		xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error)
+	GetStepFunction(token ethereum.Address, kind string) (common.StepFunction, error)
+	GetPrice(token ethereum.Address, block *big.Int, priceType string, qty *big.Int, atBlock uint64) (*big.Int, error)
+	CurrentBlock() (uint64, error)
 	SetRateMinedNonce() (uint64, error)
+	// ActivityGasPrice returns the gas price of new txs of activities of
+	// action
+	ActivityGasPrice(action string) (*big.Int, error)
+	// ActivityReplacementGasPrice returns the gas price of a tx of
+	// activities of action replacing a pending tx at oldPrice
+	ActivityReplacementGasPrice(action string, oldPrice *big.Int) (*big.Int, error)
+	// ReplaceStuckTx replaces tx of an activity of action submitted at
+	// submittedAt (millisecond) when it is stuck, it returns nil if the tx
+	// is not stuck or no longer pending
+	ReplaceStuckTx(action string, tx ethereum.Hash, submittedAt uint64) (*types.Transaction, error)
+	// NonceStates returns nonce states of operators by name
+	NonceStates() (map[string]common.NonceState, error)
 	GetAddresses() *common.Addresses
 }
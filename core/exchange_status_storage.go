@@ -0,0 +1,9 @@
+package core
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type ExchangeStatusStorage interface {
+	GetExchangeStatus() (common.ExchangesStatus, error)
+}
@@ -0,0 +1,12 @@
+package core
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// PriceStorage gives the latest orderbooks of the exchanges to rate
+// simulations
+type PriceStorage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetOnePrice(common.TokenPairID, common.Version) (common.OnePrice, error)
+}
@@ -16,16 +16,25 @@ import (
 type ReserveCore struct {
 	blockchain      Blockchain
 	activityStorage ActivityStorage
+	statusStorage   ExchangeStatusStorage
+	priceStorage    PriceStorage
+	riskChecker     *RiskChecker
 	rm              ethereum.Address
 }
 
 func NewReserveCore(
 	blockchain Blockchain,
 	storage ActivityStorage,
+	statusStorage ExchangeStatusStorage,
+	priceStorage PriceStorage,
+	riskChecker *RiskChecker,
 	rm ethereum.Address) *ReserveCore {
 	return &ReserveCore{
 		blockchain,
 		storage,
+		statusStorage,
+		priceStorage,
+		riskChecker,
 		rm,
 	}
 }
@@ -65,8 +74,15 @@ func (self ReserveCore) Trade(
 	var done, remaining float64
 	var finished bool
 	var err error
+	rejections := []RiskRejection{}
 
-	err = sanityCheckTrading(exchange, base, quote, rate, amount)
+	err = self.checkExchangeStatus(exchange)
+	if err == nil {
+		rejections = self.riskChecker.CheckTrade(exchange, tradeType, base, quote, rate, amount, timepoint)
+		if len(rejections) > 0 {
+			err = RiskError{rejections}
+		}
+	}
 	if err == nil {
 		id, done, remaining, finished, err = exchange.Trade(tradeType, base, quote, rate, amount, timepoint)
 	}
@@ -95,11 +111,12 @@ func (self ReserveCore) Trade(
 			"amount":    strconv.FormatFloat(amount, 'f', -1, 64),
 			"timepoint": timepoint,
 		}, map[string]interface{}{
-			"id":        id,
-			"done":      done,
-			"remaining": remaining,
-			"finished":  finished,
-			"error":     common.ErrorToString(err),
+			"id":              id,
+			"done":            done,
+			"remaining":       remaining,
+			"finished":        finished,
+			"error":           common.ErrorToString(err),
+			"risk_rejections": rejections,
 		},
 		status,
 		"",
@@ -118,11 +135,14 @@ func (self ReserveCore) Trade(
 	return uid, done, remaining, finished, err
 }
 
+// Deposit sends amount of token to the exchange, cosigned deposits are
+// allowed to exceed transfer limits
 func (self ReserveCore) Deposit(
 	exchange common.Exchange,
 	token common.Token,
 	amount *big.Int,
-	timepoint uint64) (common.ActivityID, error) {
+	timepoint uint64,
+	cosigned bool) (common.ActivityID, error) {
 
 	address, supported := exchange.Address(token)
 
@@ -132,9 +152,17 @@ func (self ReserveCore) Deposit(
 	var txprice string = "0"
 	var err error
 	var status string
+	amountFloat := common.BigToFloat(amount, token.Decimal)
+	rejections := []RiskRejection{}
+	// the deposit is recorded before other transfers of the token are checked
+	defer self.riskChecker.LockTransfer(exchange, token)()
 
 	if !supported {
 		err = errors.New(fmt.Sprintf("Exchange %s doesn't support token %s", exchange.ID(), token.ID))
+	} else if serr := self.checkExchangeStatus(exchange); serr != nil {
+		err = serr
+	} else if rejections = self.riskChecker.CheckTransfer("deposit", exchange, token, amountFloat, cosigned); len(rejections) > 0 {
+		err = RiskError{rejections}
 	} else if ok, perr := self.activityStorage.HasPendingDeposit(token, exchange); ok {
 		if perr != nil {
 			err = perr
@@ -155,7 +183,6 @@ func (self ReserveCore) Deposit(
 		txnonce = strconv.FormatUint(tx.Nonce(), 10)
 		txprice = tx.GasPrice().Text(10)
 	}
-	amountFloat := common.BigToFloat(amount, token.Decimal)
 	uid := timebasedID(txhex + "|" + token.ID + "|" + strconv.FormatFloat(amountFloat, 'f', -1, 64))
 	self.activityStorage.Record(
 		"deposit",
@@ -166,11 +193,13 @@ func (self ReserveCore) Deposit(
 			"token":     token,
 			"amount":    strconv.FormatFloat(amountFloat, 'f', -1, 64),
 			"timepoint": timepoint,
+			"cosigned":  cosigned,
 		}, map[string]interface{}{
-			"tx":       txhex,
-			"nonce":    txnonce,
-			"gasPrice": txprice,
-			"error":    common.ErrorToString(err),
+			"tx":              txhex,
+			"nonce":           txnonce,
+			"gasPrice":        txprice,
+			"error":           common.ErrorToString(err),
+			"risk_rejections": rejections,
 		},
 		"",
 		status,
@@ -183,18 +212,27 @@ func (self ReserveCore) Deposit(
 	return uid, err
 }
 
+// Withdraw takes amount of token from the exchange to the reserve, cosigned
+// withdrawals are allowed to exceed transfer limits
 func (self ReserveCore) Withdraw(
 	exchange common.Exchange, token common.Token,
-	amount *big.Int, timepoint uint64) (common.ActivityID, error) {
+	amount *big.Int, timepoint uint64, cosigned bool) (common.ActivityID, error) {
 
 	_, supported := exchange.Address(token)
 	var err error
 	var id string
+	amountFloat := common.BigToFloat(amount, token.Decimal)
+	rejections := []RiskRejection{}
+	// the withdrawal is recorded before other transfers of the token are
+	// checked
+	defer self.riskChecker.LockTransfer(exchange, token)()
 	if !supported {
 		err = errors.New(fmt.Sprintf("Exchange %s doesn't support token %s", exchange.ID(), token.ID))
-	} else {
-		err = sanityCheckAmount(exchange, token, amount)
-		if err == nil {
+	} else if err = self.checkExchangeStatus(exchange); err == nil {
+		rejections = self.riskChecker.CheckTransfer("withdraw", exchange, token, amountFloat, cosigned)
+		if len(rejections) > 0 {
+			err = RiskError{rejections}
+		} else if err = sanityCheckAmount(exchange, token, amount); err == nil {
 			id, err = exchange.Withdraw(token, amount, self.rm, timepoint)
 		}
 	}
@@ -212,11 +250,13 @@ func (self ReserveCore) Withdraw(
 		map[string]interface{}{
 			"exchange":  exchange,
 			"token":     token,
-			"amount":    strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64),
+			"amount":    strconv.FormatFloat(amountFloat, 'f', -1, 64),
 			"timepoint": timepoint,
+			"cosigned":  cosigned,
 		}, map[string]interface{}{
-			"error": common.ErrorToString(err),
-			"id":    id,
+			"error":           common.ErrorToString(err),
+			"risk_rejections": rejections,
+			"id":              id,
 			// this field will be updated with real tx when data fetcher can fetch it
 			// from exchanges
 			"tx": "",
@@ -287,18 +327,22 @@ func (self ReserveCore) SetRates(
 					err = errors.New("Couldn't check pending set rate tx pool. Please try later")
 				} else {
 					if oldNonce != nil {
-						newPrice := big.NewInt(0).Add(oldPrice, big.NewInt(10000000000))
-						log.Printf("Trying to replace old tx with new price: %s", newPrice.Text(10))
-						tx, err = self.blockchain.SetRates(
-							tokenAddrs, buys, sells, block,
-							oldNonce,
-							newPrice,
-						)
+						var newPrice *big.Int
+						newPrice, err = self.blockchain.ActivityReplacementGasPrice("set_rates", oldPrice)
+						if err == nil {
+							log.Printf("Trying to replace old tx with new price: %s", newPrice.Text(10))
+							tx, err = self.blockchain.SetRates(
+								tokenAddrs, buys, sells, block,
+								oldNonce,
+								newPrice,
+							)
+						}
 					} else {
+						// the gas price is suggested by the gas price oracle
 						tx, err = self.blockchain.SetRates(
 							tokenAddrs, buys, sells, block,
 							nil,
-							big.NewInt(50100000000),
+							nil,
 						)
 					}
 				}
@@ -341,6 +385,118 @@ func (self ReserveCore) SetRates(
 	return uid, err
 }
 
+// SetStepFunction sends the qty or imbalance step function of token to
+// the pricing contract, the submission is recorded as a set_step_function
+// activity
+func (self ReserveCore) SetStepFunction(
+	token common.Token,
+	kind string,
+	stepFunction common.StepFunction) (common.ActivityID, error) {
+
+	var tx *types.Transaction
+	var txhex string = ethereum.Hash{}.Hex()
+	var txnonce string = "0"
+	var txprice string = "0"
+	var status string
+
+	err := stepFunction.Validate()
+	if err == nil {
+		tokenAddr := ethereum.HexToAddress(token.Address)
+		switch kind {
+		case common.QTY_STEP_FUNCTION:
+			tx, err = self.blockchain.SetQtyStepFunction(
+				tokenAddr, stepFunction.XBuy, stepFunction.YBuy,
+				stepFunction.XSell, stepFunction.YSell)
+		case common.IMBALANCE_STEP_FUNCTION:
+			tx, err = self.blockchain.SetImbalanceStepFunction(
+				tokenAddr, stepFunction.XBuy, stepFunction.YBuy,
+				stepFunction.XSell, stepFunction.YSell)
+		default:
+			err = fmt.Errorf("Step function type %s is not supported", kind)
+		}
+	}
+	if err != nil {
+		status = "failed"
+	} else {
+		status = "submitted"
+		txhex = tx.Hash().Hex()
+		txnonce = strconv.FormatUint(tx.Nonce(), 10)
+		txprice = tx.GasPrice().Text(10)
+	}
+	uid := timebasedID(txhex + "|" + token.ID + "|" + kind)
+	self.activityStorage.Record(
+		"set_step_function",
+		uid,
+		"blockchain",
+		map[string]interface{}{
+			"token":         token,
+			"type":          kind,
+			"step_function": stepFunction,
+		}, map[string]interface{}{
+			"tx":       txhex,
+			"nonce":    txnonce,
+			"gasPrice": txprice,
+			"error":    common.ErrorToString(err),
+		},
+		"",
+		status,
+		common.GetTimepoint(),
+	)
+	log.Printf(
+		"Core ----------> Set %s step function of %s: ==> Result: tx: %s, nonce: %s, price: %s, error: %s",
+		kind, token.ID, txhex, txnonce, txprice, err,
+	)
+	return uid, err
+}
+
+// GetStepFunctions reads the current qty and imbalance step functions of
+// token from the pricing contract
+func (self ReserveCore) GetStepFunctions(token common.Token) (common.TokenStepFunctions, error) {
+	tokenAddr := ethereum.HexToAddress(token.Address)
+	qty, err := self.blockchain.GetStepFunction(tokenAddr, common.QTY_STEP_FUNCTION)
+	if err != nil {
+		return common.TokenStepFunctions{}, err
+	}
+	imbalance, err := self.blockchain.GetStepFunction(tokenAddr, common.IMBALANCE_STEP_FUNCTION)
+	if err != nil {
+		return common.TokenStepFunctions{}, err
+	}
+	return common.TokenStepFunctions{Qty: &qty, Imbalance: &imbalance}, nil
+}
+
+func (self ReserveCore) GetNonceStates() (map[string]common.NonceState, error) {
+	return self.blockchain.NonceStates()
+}
+
+// PlanRates returns how SetRates would set buys and sells of tokens, at the
+// gas price SetRates would use, without sending any transaction
+func (self ReserveCore) PlanRates(
+	tokens []common.Token,
+	buys []*big.Int,
+	sells []*big.Int) (common.CompactRatePlan, error) {
+
+	if len(tokens) != len(buys) || len(tokens) != len(sells) {
+		return common.CompactRatePlan{}, errors.New("Tokens, buys and sells must have the same length")
+	}
+	tokenAddrs := []ethereum.Address{}
+	for _, token := range tokens {
+		tokenAddrs = append(tokenAddrs, ethereum.HexToAddress(token.Address))
+	}
+	gasPrice, err := self.blockchain.ActivityGasPrice("set_rates")
+	if err != nil {
+		return common.CompactRatePlan{}, err
+	}
+	if minedNonce, err := self.blockchain.SetRateMinedNonce(); err == nil {
+		// a pending set rate tx is replaced with a higher gas price
+		if _, oldPrice, err := self.pendingSetrateInfo(minedNonce); err == nil && oldPrice != nil {
+			if gasPrice, err = self.blockchain.ActivityReplacementGasPrice("set_rates", oldPrice); err != nil {
+				return common.CompactRatePlan{}, err
+			}
+		}
+	}
+	return self.blockchain.PlanRates(tokenAddrs, buys, sells, gasPrice)
+}
+
 func sanityCheck(buys, afpMid, sells []*big.Int) error {
 	eth := big.NewFloat(0).SetInt(big.NewInt(1000000000000000000))
 	for i, s := range sells {
@@ -365,20 +521,22 @@ func sanityCheck(buys, afpMid, sells []*big.Int) error {
 	return nil
 }
 
-func sanityCheckTrading(exchange common.Exchange, base, quote common.Token, rate, amount float64) error {
-	tokenPairID := makeTokenPair(base.ID, quote.ID)
-	exchangeInfo, err := exchange.GetExchangeInfo(tokenPairID)
+// checkExchangeStatus returns error if the exchange is disabled manually or
+// by the circuit breaker, or its status can't be read. Exchanges without
+// status are enabled.
+func (self ReserveCore) checkExchangeStatus(exchange common.Exchange) error {
+	status, err := self.statusStorage.GetExchangeStatus()
 	if err != nil {
-		return err
+		return fmt.Errorf("Getting status of exchange %s failed: %s", exchange.ID(), err)
 	}
-	currentNotional := rate * amount
-	minNotional := exchangeInfo.MinNotional
-	if minNotional != float64(0) {
-		if currentNotional < minNotional {
-			return errors.New("Notional must be bigger than exchange's MinNotional")
-		}
+	exStatus, found := status[string(exchange.ID())]
+	if !found || exStatus.Status {
+		return nil
 	}
-	return nil
+	if exStatus.Tripped {
+		return fmt.Errorf("Exchange %s is disabled by circuit breaker: %s", exchange.ID(), exStatus.Reason)
+	}
+	return fmt.Errorf("Exchange %s is disabled", exchange.ID())
 }
 
 func sanityCheckAmount(exchange common.Exchange, token common.Token, amount *big.Int) error {
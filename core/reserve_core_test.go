@@ -1,8 +1,12 @@
 package core
 
 import (
+	"errors"
+	"math"
 	"math/big"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -31,7 +35,11 @@ func (self testExchange) MarshalText() (text []byte, err error) {
 	return []byte("bittrex"), nil
 }
 func (self testExchange) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
-	return common.ExchangePrecisionLimit{}, nil
+	return common.ExchangePrecisionLimit{
+		Precision:   common.TokenPairPrecision{Amount: 2, Price: 6},
+		AmountLimit: common.TokenPairAmountLimit{Min: 1, Max: 1000},
+		MinNotional: 0.01,
+	}, nil
 }
 func (self testExchange) GetFee() common.ExchangeFees {
 	return common.ExchangeFees{}
@@ -48,6 +56,18 @@ func (self testExchange) TokenAddresses() map[string]ethereum.Address {
 func (self testExchange) UpdateDepositAddress(token common.Token, address string) {
 }
 
+// testNoPricePrecisionExchange rounds prices itself, like bitfinex does to
+// significant digits, so it has no price precision
+type testNoPricePrecisionExchange struct {
+	testExchange
+}
+
+func (self testNoPricePrecisionExchange) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	return common.ExchangePrecisionLimit{
+		Precision: common.TokenPairPrecision{Amount: 8},
+	}, nil
+}
+
 type testBlockchain struct {
 }
 
@@ -82,10 +102,69 @@ func (self testBlockchain) SetRates(
 	return tx, nil
 }
 
+func (self testBlockchain) SetQtyStepFunction(
+	token ethereum.Address,
+	xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.Send(common.Token{}, nil, token)
+}
+
+func (self testBlockchain) SetImbalanceStepFunction(
+	token ethereum.Address,
+	xBuy, yBuy, xSell, ySell []*big.Int) (*types.Transaction, error) {
+	return self.Send(common.Token{}, nil, token)
+}
+
+func (self testBlockchain) GetStepFunction(token ethereum.Address, kind string) (common.StepFunction, error) {
+	return common.StepFunction{}, nil
+}
+
+// GetPrice returns 500 token per ETH for buys and 0.0019 ETH per token for
+// sells, quantities over 1000 token or 10 ETH get rate 0
+func (self testBlockchain) GetPrice(token ethereum.Address, block *big.Int, priceType string, qty *big.Int, atBlock uint64) (*big.Int, error) {
+	if priceType == "buy" {
+		if qty.Cmp(common.FloatToBigInt(10, 18)) > 0 {
+			return big.NewInt(0), nil
+		}
+		return common.FloatToBigInt(500, 18), nil
+	}
+	if qty.Cmp(common.FloatToBigInt(1000, 18)) > 0 {
+		return big.NewInt(0), nil
+	}
+	return common.FloatToBigInt(0.0019, 18), nil
+}
+
+func (self testBlockchain) CurrentBlock() (uint64, error) {
+	return 100, nil
+}
+
+func (self testBlockchain) PlanRates(
+	tokens []ethereum.Address,
+	buys []*big.Int,
+	sells []*big.Int,
+	gasPrice *big.Int) (common.CompactRatePlan, error) {
+	return common.CompactRatePlan{GasPrice: gasPrice}, nil
+}
+
 func (self testBlockchain) SetRateMinedNonce() (uint64, error) {
 	return 0, nil
 }
 
+func (self testBlockchain) ActivityGasPrice(action string) (*big.Int, error) {
+	return big.NewInt(20000000000), nil
+}
+
+func (self testBlockchain) ActivityReplacementGasPrice(action string, oldPrice *big.Int) (*big.Int, error) {
+	return big.NewInt(0).Add(oldPrice, big.NewInt(10000000000)), nil
+}
+
+func (self testBlockchain) ReplaceStuckTx(action string, tx ethereum.Hash, submittedAt uint64) (*types.Transaction, error) {
+	return nil, nil
+}
+
+func (self testBlockchain) NonceStates() (map[string]common.NonceState, error) {
+	return map[string]common.NonceState{}, nil
+}
+
 func (self testBlockchain) GetAddresses() *common.Addresses {
 	return &common.Addresses{}
 }
@@ -114,6 +193,14 @@ func (self testActivityStorage) PendingSetrate(minedNonce uint64) (*common.Activ
 	return nil, nil
 }
 
+func (self testActivityStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return []common.ActivityRecord{}, nil
+}
+
+func (self testActivityStorage) UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error {
+	return nil
+}
+
 func (self testActivityStorage) HasPendingDeposit(
 	token common.Token, exchange common.Exchange) (bool, error) {
 	if token.ID == "OMG" && exchange.ID() == "bittrex" {
@@ -123,10 +210,50 @@ func (self testActivityStorage) HasPendingDeposit(
 	}
 }
 
+type testExchangeStatusStorage struct {
+	Status common.ExchangesStatus
+	Err    error
+}
+
+func (self testExchangeStatusStorage) GetExchangeStatus() (common.ExchangesStatus, error) {
+	return self.Status, self.Err
+}
+
+type testRiskStorage struct {
+	Balances common.EBalanceEntry
+	Price    common.ExchangePrice
+	Records  []common.ActivityRecord
+}
+
+func (self testRiskStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	return self.Records, nil
+}
+
+func (self testRiskStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(1), nil
+}
+
+func (self testRiskStorage) GetOnePrice(pair common.TokenPairID, version common.Version) (common.OnePrice, error) {
+	return common.OnePrice{"bittrex": self.Price}, nil
+}
+
+func (self testRiskStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(1), nil
+}
+
+func (self testRiskStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	return common.AuthDataSnapshot{
+		ExchangeBalances: map[common.ExchangeID]common.EBalanceEntry{"bittrex": self.Balances},
+	}, nil
+}
+
 func getTestCore(hasPendingDeposit bool) *ReserveCore {
 	return NewReserveCore(
 		testBlockchain{},
 		testActivityStorage{hasPendingDeposit},
+		testExchangeStatusStorage{},
+		testRiskStorage{},
+		NewRiskChecker(testRiskStorage{}, RiskConfig{}),
 		ethereum.Address{},
 	)
 }
@@ -139,6 +266,7 @@ func TestNotAllowDeposit(t *testing.T) {
 		common.Token{"OMG", "0x1111111111111111111111111111111111111111", 18},
 		big.NewInt(10),
 		common.GetTimepoint(),
+		false,
 	)
 	if err == nil {
 		t.Fatalf("Expected to return an error protecting user from deposit when there is another pending deposit")
@@ -148,8 +276,327 @@ func TestNotAllowDeposit(t *testing.T) {
 		common.Token{"KNC", "0x1111111111111111111111111111111111111111", 18},
 		big.NewInt(10),
 		common.GetTimepoint(),
+		false,
 	)
 	if err != nil {
 		t.Fatalf("Expected to be able to deposit different token")
 	}
 }
+
+func TestNotAllowDisabledExchange(t *testing.T) {
+	core := NewReserveCore(
+		testBlockchain{},
+		testActivityStorage{false},
+		testExchangeStatusStorage{Status: common.ExchangesStatus{
+			"bittrex": {Status: false, Tripped: true, Reason: "5 consecutive balance failures"},
+		}},
+		testRiskStorage{},
+		NewRiskChecker(testRiskStorage{}, RiskConfig{}),
+		ethereum.Address{},
+	)
+	token := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	if _, err := core.Deposit(testExchange{}, token, big.NewInt(10), common.GetTimepoint(), false); err == nil {
+		t.Fatalf("Expected deposit to a disabled exchange to fail")
+	}
+	if _, err := core.Withdraw(testExchange{}, token, big.NewInt(10), common.GetTimepoint(), false); err == nil {
+		t.Fatalf("Expected withdraw from a disabled exchange to fail")
+	}
+	eth := common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18}
+	if _, _, _, _, err := core.Trade(testExchange{}, "buy", token, eth, 0.001, 100, common.GetTimepoint()); err == nil {
+		t.Fatalf("Expected trade on a disabled exchange to fail")
+	}
+}
+
+func TestNotAllowUnknownExchangeStatus(t *testing.T) {
+	core := NewReserveCore(
+		testBlockchain{},
+		testActivityStorage{false},
+		testExchangeStatusStorage{Err: errors.New("storage is unavailable")},
+		testRiskStorage{},
+		NewRiskChecker(testRiskStorage{}, RiskConfig{}),
+		ethereum.Address{},
+	)
+	token := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	if _, err := core.Deposit(testExchange{}, token, big.NewInt(10), common.GetTimepoint(), false); err == nil {
+		t.Fatalf("Expected deposit to fail when the exchange status is unknown")
+	}
+	eth := common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18}
+	if _, _, _, _, err := core.Trade(testExchange{}, "buy", token, eth, 0.001, 100, common.GetTimepoint()); err == nil {
+		t.Fatalf("Expected trade to fail when the exchange status is unknown")
+	}
+}
+
+func TestRiskChecks(t *testing.T) {
+	storage := testRiskStorage{
+		Balances: common.EBalanceEntry{
+			Valid:            true,
+			AvailableBalance: map[string]float64{"KNC": 500, "ETH": 1},
+		},
+		Price: common.ExchangePrice{
+			Valid: true,
+			Bids:  []common.PriceEntry{{Quantity: 100, Rate: 0.0019}},
+			Asks:  []common.PriceEntry{{Quantity: 100, Rate: 0.002}},
+		},
+	}
+	checker := NewRiskChecker(storage, RiskConfig{
+		MaxOrderSize:      map[string]float64{"KNC": 300},
+		MaxPriceDeviation: 0.05,
+	})
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	eth := common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18}
+	checks := func(rejections []RiskRejection) []string {
+		result := []string{}
+		for _, rejection := range rejections {
+			result = append(result, rejection.Check)
+		}
+		return result
+	}
+
+	if rejections := checker.CheckTrade(testExchange{}, "buy", knc, eth, 0.002, 100, 0); len(rejections) != 0 {
+		t.Fatalf("Expected a valid order to pass, got %+v", rejections)
+	}
+	cases := []struct {
+		tradeType string
+		rate      float64
+		amount    float64
+		check     string
+	}{
+		{"buy", 0.002, 100.001, RISK_AMOUNT_PRECISION},
+		{"buy", 0.0020001, 100, RISK_PRICE_PRECISION},
+		{"sell", 0.0019, 0.5, RISK_AMOUNT_LIMIT},
+		{"sell", 0.0019, 400, RISK_MAX_ORDER_SIZE},
+		{"buy", 0.002, 600, RISK_BALANCE},
+		{"buy", 0.0022, 100, RISK_PRICE_DEVIATION},
+		{"sell", 0.0017, 100, RISK_PRICE_DEVIATION},
+	}
+	for _, c := range cases {
+		rejections := checker.CheckTrade(testExchange{}, c.tradeType, knc, eth, c.rate, c.amount, 0)
+		found := false
+		for _, rejection := range rejections {
+			found = found || rejection.Check == c.check
+		}
+		if !found {
+			t.Fatalf("Expected %s %f at %f to be rejected by %s, got %v", c.tradeType, c.amount, c.rate, c.check, checks(rejections))
+		}
+	}
+
+	for _, rejection := range checker.CheckTrade(testNoPricePrecisionExchange{}, "buy", knc, eth, 0.0020012345, 100.00000001, 0) {
+		if rejection.Check == RISK_AMOUNT_PRECISION || rejection.Check == RISK_PRICE_PRECISION {
+			t.Fatalf("Expected an exchange without price precision to only check amount decimals, got %+v", rejection)
+		}
+	}
+
+	core := NewReserveCore(testBlockchain{}, testActivityStorage{false}, testExchangeStatusStorage{}, testRiskStorage{}, checker, ethereum.Address{})
+	_, _, _, _, err := core.Trade(testExchange{}, "sell", knc, eth, 0.0019, 400, common.GetTimepoint())
+	if riskErr, ok := err.(RiskError); !ok || len(riskErr.Rejections) != 1 {
+		t.Fatalf("Expected trade to fail with one risk rejection, got %v", err)
+	}
+}
+
+func TestTransferLimits(t *testing.T) {
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	record := func(action, token, amount, status string) common.ActivityRecord {
+		return common.ActivityRecord{
+			Action:         action,
+			Destination:    "bittrex",
+			Params:         map[string]interface{}{"token": token, "amount": amount},
+			ExchangeStatus: status,
+		}
+	}
+	storage := testRiskStorage{
+		Records: []common.ActivityRecord{
+			record("deposit", "KNC", "600", "done"),
+			record("deposit", "KNC", "1000", "failed"),
+			record("deposit", "OMG", "1000", "done"),
+			record("withdraw", "KNC", "1000", "done"),
+		},
+	}
+	checker := NewRiskChecker(storage, RiskConfig{
+		TransferLimits: map[string]map[string]TransferLimit{
+			"bittrex": {"KNC": {MaxDeposit: 500, DailyDeposit: 1000}},
+		},
+	})
+	checks := func(rejections []RiskRejection) []string {
+		result := []string{}
+		for _, rejection := range rejections {
+			result = append(result, rejection.Check)
+		}
+		return result
+	}
+
+	if rejections := checker.CheckTransfer("deposit", testExchange{}, knc, 400, false); len(rejections) != 0 {
+		t.Fatalf("Expected a deposit within limits to pass, got %v", checks(rejections))
+	}
+	if rejections := checker.CheckTransfer("withdraw", testExchange{}, knc, 5000, false); len(rejections) != 0 {
+		t.Fatalf("Expected an unlimited withdraw to pass, got %v", checks(rejections))
+	}
+	if rejections := checker.CheckTransfer("deposit", testExchange{}, knc, 501, false); len(rejections) != 2 || rejections[0].Check != RISK_TRANSFER_CAP || rejections[1].Check != RISK_DAILY_QUOTA {
+		t.Fatalf("Expected a deposit to exceed cap and daily quota, got %v", checks(rejections))
+	}
+	if rejections := checker.CheckTransfer("deposit", testExchange{}, knc, 450, false); len(rejections) != 1 || rejections[0].Check != RISK_DAILY_QUOTA {
+		t.Fatalf("Expected a deposit to exceed daily quota, got %v", checks(rejections))
+	}
+	if rejections := checker.CheckTransfer("deposit", testExchange{}, knc, 501, true); len(rejections) != 0 {
+		t.Fatalf("Expected a cosigned deposit to pass, got %v", checks(rejections))
+	}
+	omg := common.Token{ID: "OMG", Address: "0x2222222222222222222222222222222222222222", Decimal: 18}
+	strict := NewRiskChecker(storage, RiskConfig{RejectUnlistedTransfers: true})
+	if rejections := strict.CheckTransfer("deposit", testExchange{}, omg, 1, false); len(rejections) != 1 || rejections[0].Check != RISK_TRANSFER_LIMIT {
+		t.Fatalf("Expected a deposit without limits to be rejected, got %v", checks(rejections))
+	}
+	if rejections := strict.CheckTransfer("deposit", testExchange{}, omg, 1, true); len(rejections) != 0 {
+		t.Fatalf("Expected a cosigned deposit without limits to pass, got %v", checks(rejections))
+	}
+
+	core := NewReserveCore(testBlockchain{}, testActivityStorage{false}, testExchangeStatusStorage{}, testRiskStorage{}, checker, ethereum.Address{})
+	amount := common.FloatToBigInt(450, knc.Decimal)
+	if _, err := core.Deposit(testExchange{}, knc, amount, common.GetTimepoint(), false); err == nil {
+		t.Fatalf("Expected deposit over daily quota to fail")
+	}
+	if _, err := core.Deposit(testExchange{}, knc, amount, common.GetTimepoint(), true); err != nil {
+		t.Fatalf("Expected cosigned deposit over daily quota to pass, got %s", err)
+	}
+}
+
+// testTransferStorage records activities which are then counted by risk
+// checks, its blockchain sends are slow to widen race windows
+type testTransferStorage struct {
+	testActivityStorage
+	testRiskStorage
+	mu      *sync.Mutex
+	records *[]common.ActivityRecord
+}
+
+func (self testTransferStorage) Record(
+	action string,
+	id common.ActivityID,
+	destination string,
+	params map[string]interface{},
+	result map[string]interface{},
+	estatus string,
+	mstatus string,
+	timepoint uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	*self.records = append(*self.records, common.ActivityRecord{
+		Action:         action,
+		ID:             id,
+		Destination:    destination,
+		Params:         params,
+		Result:         result,
+		ExchangeStatus: estatus,
+		MiningStatus:   mstatus,
+	})
+	return nil
+}
+
+func (self testTransferStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]common.ActivityRecord{}, *self.records...), nil
+}
+
+type testSlowBlockchain struct {
+	testBlockchain
+}
+
+func (self testSlowBlockchain) Send(token common.Token, amount *big.Int, address ethereum.Address) (*types.Transaction, error) {
+	time.Sleep(10 * time.Millisecond)
+	return self.testBlockchain.Send(token, amount, address)
+}
+
+func TestConcurrentTransfersWithinQuota(t *testing.T) {
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	storage := testTransferStorage{mu: &sync.Mutex{}, records: &[]common.ActivityRecord{}}
+	checker := NewRiskChecker(storage, RiskConfig{
+		TransferLimits: map[string]map[string]TransferLimit{
+			"bittrex": {"KNC": {DailyDeposit: 1000}},
+		},
+	})
+	core := NewReserveCore(testSlowBlockchain{}, storage, testExchangeStatusStorage{}, testRiskStorage{}, checker, ethereum.Address{})
+
+	amount := common.FloatToBigInt(600, knc.Decimal)
+	wait := sync.WaitGroup{}
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			_, err := core.Deposit(testExchange{}, knc, amount, common.GetTimepoint(), false)
+			errs <- err
+		}()
+	}
+	wait.Wait()
+	close(errs)
+	passed := 0
+	for err := range errs {
+		if err == nil {
+			passed++
+		}
+	}
+	if passed != 1 {
+		t.Fatalf("Expected one of the concurrent deposits to fit in the daily quota, %d passed", passed)
+	}
+}
+
+func TestSetStepFunction(t *testing.T) {
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	core := NewReserveCore(testBlockchain{}, testActivityStorage{false}, testExchangeStatusStorage{}, testRiskStorage{}, NewRiskChecker(testRiskStorage{}, RiskConfig{}), ethereum.Address{})
+	stepFunction := common.StepFunction{
+		XBuy:  []*big.Int{big.NewInt(0), big.NewInt(1000)},
+		YBuy:  []*big.Int{big.NewInt(0), big.NewInt(-10)},
+		XSell: []*big.Int{big.NewInt(1000)},
+		YSell: []*big.Int{big.NewInt(-20)},
+	}
+	if _, err := core.SetStepFunction(knc, common.QTY_STEP_FUNCTION, stepFunction); err != nil {
+		t.Fatalf("Expected qty step function to be submitted, got %s", err)
+	}
+	if _, err := core.SetStepFunction(knc, "volume", stepFunction); err == nil {
+		t.Fatalf("Expected unknown step function type to fail")
+	}
+	stepFunction.YSell = []*big.Int{big.NewInt(-20000)}
+	if _, err := core.SetStepFunction(knc, common.IMBALANCE_STEP_FUNCTION, stepFunction); err == nil {
+		t.Fatalf("Expected step function out of bps bounds to fail")
+	}
+}
+
+func TestSimulateRates(t *testing.T) {
+	storage := testRiskStorage{
+		Price: common.ExchangePrice{
+			Valid: true,
+			Bids:  []common.PriceEntry{{Quantity: 100, Rate: 0.0019}},
+			Asks:  []common.PriceEntry{{Quantity: 100, Rate: 0.0021}},
+		},
+	}
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	core := NewReserveCore(testBlockchain{}, testActivityStorage{false}, testExchangeStatusStorage{}, storage, NewRiskChecker(storage, RiskConfig{}), ethereum.Address{})
+
+	simulation, err := core.SimulateRates(knc, "sell", []float64{100, 2000}, 0)
+	if err != nil {
+		t.Fatalf("Couldn't simulate rates: %s", err)
+	}
+	if simulation.Block != 100 || simulation.Mids["bittrex"] != 0.002 || len(simulation.Rates) != 2 {
+		t.Fatalf("Unexpected simulation %+v", simulation)
+	}
+	if spread := simulation.Rates[0].Spreads["bittrex"]; math.Abs(spread-0.05) > 1e-9 {
+		t.Fatalf("Expected sell spread 0.05, got %f", spread)
+	}
+	if simulation.Rates[1].Error == "" {
+		t.Fatalf("Expected rate 0 of a big quantity to be reported as error")
+	}
+
+	simulation, err = core.SimulateRates(knc, "buy", []float64{1}, 200)
+	if err != nil || simulation.Block != 200 {
+		t.Fatalf("Unexpected simulation %+v, error: %v", simulation, err)
+	}
+	if rate := simulation.Rates[0]; rate.Rate != 500 || math.Abs(rate.Spreads["bittrex"]-0) > 1e-9 {
+		t.Fatalf("Expected buy price at the mid, got %+v", rate)
+	}
+	if _, err = core.SimulateRates(knc, "borrow", []float64{1}, 0); err == nil {
+		t.Fatalf("Expected unknown side to fail")
+	}
+	noPrices := NewReserveCore(testBlockchain{}, testActivityStorage{false}, testExchangeStatusStorage{}, nil, nil, ethereum.Address{})
+	if _, err = noPrices.SimulateRates(knc, "sell", []float64{100}, 0); err == nil {
+		t.Fatalf("Expected simulation without price storage to fail")
+	}
+}
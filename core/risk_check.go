@@ -0,0 +1,226 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	RISK_EXCHANGE_INFO    string = "exchange_info"
+	RISK_MIN_NOTIONAL     string = "min_notional"
+	RISK_AMOUNT_PRECISION string = "amount_precision"
+	RISK_PRICE_PRECISION  string = "price_precision"
+	RISK_AMOUNT_LIMIT     string = "amount_limit"
+	RISK_PRICE_LIMIT      string = "price_limit"
+	RISK_MAX_ORDER_SIZE   string = "max_order_size"
+	RISK_BALANCE          string = "balance"
+	RISK_PRICE_DEVIATION  string = "price_deviation"
+)
+
+type RiskConfig struct {
+	// MaxOrderSize is the max amount of an order by its base token, tokens
+	// without it are not limited
+	MaxOrderSize map[string]float64 `json:"max_order_size"`
+	// MaxPriceDeviation is the max relative distance of an order rate from
+	// the best ask (buy) or best bid (sell) of the exchange, 0 disables it
+	MaxPriceDeviation float64 `json:"max_price_deviation"`
+	// TransferLimits are deposit and withdrawal limits by exchange then token
+	TransferLimits map[string]map[string]TransferLimit `json:"transfer_limits"`
+	// RejectUnlistedTransfers rejects deposits and withdrawals of tokens
+	// without TransferLimits on the exchange, they are unlimited otherwise
+	RejectUnlistedTransfers bool `json:"reject_unlisted_transfers"`
+}
+
+func GetRiskConfigFromFile(path string) (RiskConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return RiskConfig{}, err
+	}
+	result := RiskConfig{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// RiskRejection is a failed risk check of an order
+type RiskRejection struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+// RiskError is returned when an order fails risk checks, Rejections are
+// recorded in the trade activity
+type RiskError struct {
+	Rejections []RiskRejection
+}
+
+func (self RiskError) Error() string {
+	reasons := []string{}
+	for _, rejection := range self.Rejections {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", rejection.Check, rejection.Reason))
+	}
+	return "Order is rejected by risk checks: " + strings.Join(reasons, "; ")
+}
+
+// RiskStorage gives the latest orderbooks, exchange balances and activities
+// to risk checks
+type RiskStorage interface {
+	GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error)
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetOnePrice(common.TokenPairID, common.Version) (common.OnePrice, error)
+	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
+	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
+}
+
+type RiskChecker struct {
+	storage RiskStorage
+	config  RiskConfig
+
+	transferMu    sync.Mutex
+	transferLocks map[string]*sync.Mutex
+}
+
+func NewRiskChecker(storage RiskStorage, config RiskConfig) *RiskChecker {
+	return &RiskChecker{
+		storage:       storage,
+		config:        config,
+		transferLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// CheckTrade validates an order against exchange precision and limits,
+// max order size, available balance and the current orderbook. It returns
+// all failed checks, an empty result means the order passes.
+func (self *RiskChecker) CheckTrade(
+	exchange common.Exchange,
+	tradeType string,
+	base, quote common.Token,
+	rate, amount float64,
+	timepoint uint64) []RiskRejection {
+
+	rejections := []RiskRejection{}
+	reject := func(check, format string, args ...interface{}) {
+		rejections = append(rejections, RiskRejection{check, fmt.Sprintf(format, args...)})
+	}
+	pair := makeTokenPair(base.ID, quote.ID)
+	info, err := exchange.GetExchangeInfo(pair)
+	if err != nil {
+		reject(RISK_EXCHANGE_INFO, "%s", err)
+	} else {
+		if info.MinNotional != 0 && rate*amount < info.MinNotional {
+			reject(RISK_MIN_NOTIONAL, "notional %f is smaller than %f", rate*amount, info.MinNotional)
+		}
+		// exchanges without precision info have both precisions zero,
+		// exchanges rounding prices to significant digits themselves
+		// (bitfinex) have no price precision
+		if info.Precision.Amount != 0 || info.Precision.Price != 0 {
+			if !hasPrecision(amount, info.Precision.Amount) {
+				reject(RISK_AMOUNT_PRECISION, "amount %s has more than %d decimals", formatFloat(amount), info.Precision.Amount)
+			}
+		}
+		if info.Precision.Price != 0 && !hasPrecision(rate, info.Precision.Price) {
+			reject(RISK_PRICE_PRECISION, "rate %s has more than %d decimals", formatFloat(rate), info.Precision.Price)
+		}
+		if outOfLimit(amount, info.AmountLimit.Min, info.AmountLimit.Max) {
+			reject(RISK_AMOUNT_LIMIT, "amount %s is out of [%s, %s]", formatFloat(amount), formatFloat(info.AmountLimit.Min), formatFloat(info.AmountLimit.Max))
+		}
+		if outOfLimit(rate, info.PriceLimit.Min, info.PriceLimit.Max) {
+			reject(RISK_PRICE_LIMIT, "rate %s is out of [%s, %s]", formatFloat(rate), formatFloat(info.PriceLimit.Min), formatFloat(info.PriceLimit.Max))
+		}
+	}
+	if maxSize := self.config.MaxOrderSize[base.ID]; maxSize > 0 && amount > maxSize {
+		reject(RISK_MAX_ORDER_SIZE, "amount %s is bigger than max order size %s of %s", formatFloat(amount), formatFloat(maxSize), base.ID)
+	}
+	if err := self.checkBalance(exchange, tradeType, base, quote, rate, amount, timepoint); err != nil {
+		reject(RISK_BALANCE, "%s", err)
+	}
+	if self.config.MaxPriceDeviation > 0 {
+		if err := self.checkDeviation(exchange, tradeType, pair, rate, timepoint); err != nil {
+			reject(RISK_PRICE_DEVIATION, "%s", err)
+		}
+	}
+	return rejections
+}
+
+func (self *RiskChecker) checkBalance(
+	exchange common.Exchange,
+	tradeType string,
+	base, quote common.Token,
+	rate, amount float64,
+	timepoint uint64) error {
+	version, err := self.storage.CurrentAuthDataVersion(timepoint)
+	if err != nil {
+		return err
+	}
+	authData, err := self.storage.GetAuthData(version)
+	if err != nil {
+		return err
+	}
+	balance, found := authData.ExchangeBalances[exchange.ID()]
+	if !found || !balance.Valid {
+		return fmt.Errorf("balances of %s are not available", exchange.ID())
+	}
+	token, required := base, amount
+	if tradeType == "buy" {
+		token, required = quote, rate*amount
+	}
+	if available := balance.AvailableBalance[token.ID]; available < required {
+		return fmt.Errorf("order needs %s %s but only %s is available", formatFloat(required), token.ID, formatFloat(available))
+	}
+	return nil
+}
+
+func (self *RiskChecker) checkDeviation(
+	exchange common.Exchange,
+	tradeType string,
+	pair common.TokenPairID,
+	rate float64,
+	timepoint uint64) error {
+	version, err := self.storage.CurrentPriceVersion(timepoint)
+	if err != nil {
+		return err
+	}
+	onePrice, err := self.storage.GetOnePrice(pair, version)
+	if err != nil {
+		return err
+	}
+	price, found := onePrice[exchange.ID()]
+	if !found || !price.Valid {
+		return fmt.Errorf("orderbook of %s on %s is not available", pair, exchange.ID())
+	}
+	side, entries := "bid", price.Bids
+	if tradeType == "buy" {
+		side, entries = "ask", price.Asks
+	}
+	if len(entries) == 0 || entries[0].Rate <= 0 {
+		return fmt.Errorf("there is no %s of %s on %s", side, pair, exchange.ID())
+	}
+	best := entries[0].Rate
+	deviation := math.Abs(rate-best) / best
+	if deviation > self.config.MaxPriceDeviation {
+		return fmt.Errorf("rate %s deviates %.4f from best %s %s, max is %.4f", formatFloat(rate), deviation, side, formatFloat(best), self.config.MaxPriceDeviation)
+	}
+	return nil
+}
+
+// hasPrecision returns true if value has at most precision decimals
+func hasPrecision(value float64, precision int) bool {
+	scaled := value * math.Pow10(precision)
+	return math.Abs(scaled-math.Round(scaled)) <= math.Max(1e-6, math.Abs(scaled)*1e-12)
+}
+
+// outOfLimit returns true if value is out of [min, max], zero bounds are
+// not checked
+func outOfLimit(value, min, max float64) bool {
+	return (min > 0 && value < min) || (max > 0 && value > max)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// SimulateRates calls getRate of the pricing contract for each quantity,
+// quantities are in ETH for buys and in token for sells. Rates are compared
+// with the mid of the latest orderbook of each exchange.
+func (self ReserveCore) SimulateRates(
+	token common.Token,
+	side string,
+	quantities []float64,
+	block uint64) (common.RateSimulation, error) {
+
+	result := common.RateSimulation{
+		Token: token.ID,
+		Side:  side,
+		Mids:  map[common.ExchangeID]float64{},
+		Rates: []common.SimulatedRate{},
+	}
+	if side != "buy" && side != "sell" {
+		return result, errors.New("Side must be buy or sell")
+	}
+	if len(quantities) == 0 {
+		return result, errors.New("There must be at least one quantity")
+	}
+	if block == 0 {
+		current, err := self.blockchain.CurrentBlock()
+		if err != nil {
+			return result, err
+		}
+		block = current
+	}
+	result.Block = block
+	mids, err := self.orderbookMids(makeTokenPair(token.ID, "ETH"))
+	if err != nil {
+		return result, err
+	}
+	result.Mids = mids
+	// quantities of buys are in ETH
+	decimals := token.Decimal
+	if side == "buy" {
+		decimals = 18
+	}
+	tokenAddr := ethereum.HexToAddress(token.Address)
+	for _, quantity := range quantities {
+		simulated := common.SimulatedRate{Quantity: quantity, Spreads: map[common.ExchangeID]float64{}}
+		rate, err := self.blockchain.GetPrice(
+			tokenAddr, big.NewInt(int64(block)), side,
+			common.FloatToBigInt(quantity, decimals), block)
+		if err != nil {
+			simulated.Error = err.Error()
+		} else if rate.Sign() == 0 {
+			simulated.Error = "pricing contract returns rate 0, the quantity may exceed the limits"
+		} else {
+			simulated.Rate = common.BigToFloat(rate, 18)
+			simulated.Price = simulated.Rate
+			if side == "buy" {
+				simulated.Price = 1 / simulated.Rate
+			}
+			for exchange, mid := range mids {
+				if side == "buy" {
+					simulated.Spreads[exchange] = (simulated.Price - mid) / mid
+				} else {
+					simulated.Spreads[exchange] = (mid - simulated.Price) / mid
+				}
+			}
+		}
+		result.Rates = append(result.Rates, simulated)
+	}
+	return result, nil
+}
+
+// orderbookMids returns the mid of best bid and best ask of pair on each
+// exchange with a valid orderbook
+func (self ReserveCore) orderbookMids(pair common.TokenPairID) (map[common.ExchangeID]float64, error) {
+	result := map[common.ExchangeID]float64{}
+	storage := self.priceStorage
+	if storage == nil {
+		return result, errors.New("There is no price storage to get orderbooks from")
+	}
+	version, err := storage.CurrentPriceVersion(common.GetTimepoint())
+	if err != nil {
+		return result, err
+	}
+	onePrice, err := storage.GetOnePrice(pair, version)
+	if err != nil {
+		return result, fmt.Errorf("orderbooks of %s are not available: %s", pair, err)
+	}
+	for exchange, price := range onePrice {
+		if !price.Valid || len(price.Bids) == 0 || len(price.Asks) == 0 {
+			continue
+		}
+		if mid := (price.Bids[0].Rate + price.Asks[0].Rate) / 2; mid > 0 {
+			result[exchange] = mid
+		}
+	}
+	return result, nil
+}
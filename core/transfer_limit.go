@@ -0,0 +1,141 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	RISK_TRANSFER_CAP   string = "transfer_cap"
+	RISK_DAILY_QUOTA    string = "daily_quota"
+	RISK_TRANSFER_QUOTA string = "transfer_quota"
+	RISK_TRANSFER_LIMIT string = "transfer_limit"
+
+	// deposits and withdrawals are summed up over a rolling day for quotas
+	QUOTA_PERIOD uint64 = 24 * 60 * 60 * 1000
+)
+
+// TransferLimit limits deposits and withdrawals of a token on an exchange,
+// amounts are in token unit and zero limits are not checked
+type TransferLimit struct {
+	MaxDeposit    float64 `json:"max_deposit"`
+	MaxWithdraw   float64 `json:"max_withdraw"`
+	DailyDeposit  float64 `json:"daily_deposit"`
+	DailyWithdraw float64 `json:"daily_withdraw"`
+}
+
+func (self TransferLimit) limits(action string) (float64, float64) {
+	if action == "deposit" {
+		return self.MaxDeposit, self.DailyDeposit
+	}
+	return self.MaxWithdraw, self.DailyWithdraw
+}
+
+// LockTransfer serializes transfers of token on exchange until the returned
+// func is called. A transfer holds it from its limit check until it is
+// recorded, so concurrent transfers are checked against the quota used by
+// each other.
+func (self *RiskChecker) LockTransfer(exchange common.Exchange, token common.Token) func() {
+	key := fmt.Sprintf("%s|%s", exchange.ID(), token.ID)
+	self.transferMu.Lock()
+	lock, found := self.transferLocks[key]
+	if !found {
+		lock = &sync.Mutex{}
+		self.transferLocks[key] = lock
+	}
+	self.transferMu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// CheckTransfer checks a deposit or withdrawal against the single transfer
+// cap and the rolling 24h quota of the token on the exchange. Tokens without
+// limits on the exchange are unlimited unless RejectUnlistedTransfers is
+// set. Transfers co-signed by a configuration confirming key are allowed to
+// exceed them. Callers hold LockTransfer until the transfer is recorded.
+func (self *RiskChecker) CheckTransfer(
+	action string,
+	exchange common.Exchange,
+	token common.Token,
+	amount float64,
+	cosigned bool) []RiskRejection {
+
+	rejections := []RiskRejection{}
+	limit, found := self.config.TransferLimits[string(exchange.ID())][token.ID]
+	if !found && !self.config.RejectUnlistedTransfers {
+		return rejections
+	}
+	if !found {
+		rejections = append(rejections, RiskRejection{
+			RISK_TRANSFER_LIMIT,
+			fmt.Sprintf("%s has no transfer limits on %s", token.ID, exchange.ID()),
+		})
+	}
+	maxAmount, dailyAmount := limit.limits(action)
+	if maxAmount > 0 && amount > maxAmount {
+		rejections = append(rejections, RiskRejection{
+			RISK_TRANSFER_CAP,
+			fmt.Sprintf("%s of %s %s is bigger than cap %s", action, formatFloat(amount), token.ID, formatFloat(maxAmount)),
+		})
+	}
+	if dailyAmount > 0 {
+		now := common.GetTimepoint()
+		transferred, err := self.transferredAmount(action, exchange, token, now-QUOTA_PERIOD, now)
+		if err != nil {
+			rejections = append(rejections, RiskRejection{RISK_TRANSFER_QUOTA, err.Error()})
+		} else if transferred+amount > dailyAmount {
+			rejections = append(rejections, RiskRejection{
+				RISK_DAILY_QUOTA,
+				fmt.Sprintf("%s of %s %s exceeds daily quota %s, %s was transferred in the last 24h", action, formatFloat(amount), token.ID, formatFloat(dailyAmount), formatFloat(transferred)),
+			})
+		}
+	}
+	if len(rejections) > 0 && cosigned {
+		log.Printf("Co-signed %s of %s %s on %s exceeds limits: %+v", action, formatFloat(amount), token.ID, exchange.ID(), rejections)
+		return []RiskRejection{}
+	}
+	return rejections
+}
+
+// transferredAmount sums up amounts of action records of the token on the
+// exchange in [fromTime, toTime] millisecond, failed records are skipped
+func (self *RiskChecker) transferredAmount(action string, exchange common.Exchange, token common.Token, fromTime, toTime uint64) (float64, error) {
+	records, err := self.storage.GetAllRecords(fromTime*1000000, toTime*1000000)
+	if err != nil {
+		return 0, err
+	}
+	result := 0.0
+	for _, record := range records {
+		if record.Action != action || record.Destination != string(exchange.ID()) {
+			continue
+		}
+		if record.ExchangeStatus == "failed" || record.MiningStatus == "failed" {
+			continue
+		}
+		if recordToken(record.Params["token"]) != token.ID {
+			continue
+		}
+		amount, err := strconv.ParseFloat(fmt.Sprintf("%v", record.Params["amount"]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("Amount of activity %s is invalid: %s", record.ID, err)
+		}
+		result += amount
+	}
+	return result, nil
+}
+
+// recordToken returns the token id of an activity param, it is a token
+// before the activity is stored and its id after
+func recordToken(param interface{}) string {
+	switch token := param.(type) {
+	case common.Token:
+		return token.ID
+	case string:
+		return token
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// TxEscalator replaces blockchain txs of pending set step function and
+// deposit activities which are stuck longer than the gas price policy of
+// their operator allows. Replacements keep the nonce and data of the stuck
+// tx at a higher gas price, the activity is updated to track the new tx
+// and keeps the replaced ones.
+// Set rates txs are not escalated, ReserveCore.SetRates already replaces
+// the pending set rates tx on its nonce with every rate update.
+type TxEscalator struct {
+	blockchain      Blockchain
+	activityStorage ActivityStorage
+	interval        time.Duration
+}
+
+func NewTxEscalator(blockchain Blockchain, activityStorage ActivityStorage, interval time.Duration) *TxEscalator {
+	return &TxEscalator{blockchain, activityStorage, interval}
+}
+
+func (self *TxEscalator) Run() {
+	go func() {
+		ticker := time.NewTicker(self.interval)
+		for range ticker.C {
+			self.Escalate()
+		}
+	}()
+}
+
+// Escalate replaces stuck txs of pending activities, it returns ids of
+// the activities whose tx is replaced
+func (self *TxEscalator) Escalate() []common.ActivityID {
+	result := []common.ActivityID{}
+	pendings, err := self.activityStorage.GetPendingActivities()
+	if err != nil {
+		log.Printf("Escalator: getting pending activities failed: %s", err)
+		return result
+	}
+	for _, activity := range pendings {
+		if !activity.IsBlockchainPending() ||
+			(activity.Action != "set_step_function" && activity.Action != "deposit") {
+			continue
+		}
+		txhex, ok := activity.Result["tx"].(string)
+		if !ok {
+			continue
+		}
+		tx := ethereum.HexToHash(txhex)
+		if tx.Big().Sign() == 0 {
+			continue
+		}
+		newTx, err := self.blockchain.ReplaceStuckTx(activity.Action, tx, activity.SubmittedAt())
+		if err != nil {
+			log.Printf("Escalator: replacing tx %s of %s activity %s failed: %s", txhex, activity.Action, activity.ID, err)
+			continue
+		}
+		if newTx == nil {
+			continue
+		}
+		log.Printf(
+			"Escalator: replaced tx %s of %s activity %s with tx %s at gas price %s",
+			txhex, activity.Action, activity.ID, newTx.Hash().Hex(), newTx.GasPrice().Text(10))
+		// the activity may have been updated while the tx was being replaced
+		current, err := self.activityStorage.GetActivity(activity.ID)
+		if err != nil {
+			log.Printf("Escalator: getting activity %s failed: %s", activity.ID, err)
+			continue
+		}
+		if !current.IsBlockchainPending() || current.Result["tx"] != activity.Result["tx"] {
+			log.Printf("Escalator: activity %s is updated while its tx was being replaced, tx %s is not recorded", activity.ID, newTx.Hash().Hex())
+			continue
+		}
+		current.Result["replaced_txs"] = append(current.ReplacedTxs(), txhex)
+		current.Result["replaced_at"] = strconv.FormatUint(common.GetTimepoint(), 10)
+		current.Result["tx"] = newTx.Hash().Hex()
+		current.Result["gasPrice"] = newTx.GasPrice().Text(10)
+		if err = self.activityStorage.UpdateActivity(current.ID, current); err != nil {
+			log.Printf("Escalator: updating activity %s failed: %s", activity.ID, err)
+			continue
+		}
+		result = append(result, activity.ID)
+	}
+	return result
+}
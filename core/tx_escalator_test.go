@@ -0,0 +1,156 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// testEscalationBlockchain replaces txs in stuck at their nonce and counts
+// the txs sent at each nonce
+type testEscalationBlockchain struct {
+	testBlockchain
+	stuck map[ethereum.Hash]uint64
+	sent  map[uint64]int
+}
+
+func (self testEscalationBlockchain) ReplaceStuckTx(action string, tx ethereum.Hash, submittedAt uint64) (*types.Transaction, error) {
+	nonce, stuck := self.stuck[tx]
+	if !stuck {
+		return nil, nil
+	}
+	self.sent[nonce]++
+	return types.NewTransaction(nonce, ethereum.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(24000000000), nil), nil
+}
+
+func (self testEscalationBlockchain) SetRates(
+	tokens []ethereum.Address,
+	buys []*big.Int,
+	sells []*big.Int,
+	block *big.Int,
+	nonce *big.Int,
+	gasPrice *big.Int) (*types.Transaction, error) {
+	self.sent[nonce.Uint64()]++
+	return types.NewTransaction(nonce.Uint64(), ethereum.Address{}, big.NewInt(0), big.NewInt(300000), gasPrice, nil), nil
+}
+
+type testEscalationStorage struct {
+	testActivityStorage
+	activities map[common.ActivityID]common.ActivityRecord
+}
+
+func (self testEscalationStorage) GetActivity(id common.ActivityID) (common.ActivityRecord, error) {
+	return self.activities[id], nil
+}
+
+func (self testEscalationStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	result := []common.ActivityRecord{}
+	for _, activity := range self.activities {
+		if activity.IsPending() {
+			result = append(result, activity)
+		}
+	}
+	return result, nil
+}
+
+func (self testEscalationStorage) PendingSetrate(minedNonce uint64) (*common.ActivityRecord, error) {
+	for _, activity := range self.activities {
+		if activity.Action == "set_rates" && activity.IsBlockchainPending() {
+			return &activity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (self testEscalationStorage) UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error {
+	self.activities[id] = activity
+	return nil
+}
+
+func TestTxEscalator(t *testing.T) {
+	stuckTx := "0x0000000000000000000000000000000000000000000000000000000000000001"
+	freshTx := "0x0000000000000000000000000000000000000000000000000000000000000002"
+	minedTx := "0x0000000000000000000000000000000000000000000000000000000000000003"
+	activity := func(id uint64, action, tx, mstatus string) common.ActivityRecord {
+		return common.ActivityRecord{
+			Action:       action,
+			ID:           common.ActivityID{Timepoint: id, EID: tx},
+			Destination:  "blockchain",
+			Result:       map[string]interface{}{"tx": tx, "nonce": "1", "gasPrice": "20000000000"},
+			MiningStatus: mstatus,
+			Timestamp:    "1000",
+		}
+	}
+	storage := testEscalationStorage{activities: map[common.ActivityID]common.ActivityRecord{}}
+	for _, record := range []common.ActivityRecord{
+		activity(1, "deposit", stuckTx, "submitted"),
+		activity(2, "deposit", freshTx, "submitted"),
+		activity(3, "set_step_function", minedTx, "mined"),
+		activity(4, "withdraw", stuckTx, "submitted"),
+	} {
+		storage.activities[record.ID] = record
+	}
+	escalator := NewTxEscalator(
+		testEscalationBlockchain{
+			stuck: map[ethereum.Hash]uint64{
+				ethereum.HexToHash(stuckTx): 1,
+				ethereum.HexToHash(minedTx): 1,
+			},
+			sent: map[uint64]int{},
+		},
+		storage, 0)
+
+	replaced := escalator.Escalate()
+	id := common.ActivityID{Timepoint: 1, EID: stuckTx}
+	if len(replaced) != 1 || replaced[0] != id {
+		t.Fatalf("Expected only the stuck deposit tx to be replaced, got %v", replaced)
+	}
+	record := storage.activities[id]
+	if record.Result["tx"] == stuckTx || record.Result["gasPrice"] != "24000000000" {
+		t.Fatalf("Expected the activity to track the replacement tx, got %+v", record.Result)
+	}
+	if txs := record.ReplacedTxs(); len(txs) != 1 || txs[0] != stuckTx {
+		t.Fatalf("Expected the stuck tx to be kept as replaced, got %v", txs)
+	}
+	if record.SubmittedAt() == 1000 {
+		t.Fatalf("Expected the submission time to be the replacement time")
+	}
+}
+
+func TestTxEscalatorSkipsSetRates(t *testing.T) {
+	stuckTx := "0x0000000000000000000000000000000000000000000000000000000000000001"
+	id := common.ActivityID{Timepoint: 1, EID: stuckTx}
+	storage := testEscalationStorage{activities: map[common.ActivityID]common.ActivityRecord{
+		id: {
+			Action:       "set_rates",
+			ID:           id,
+			Destination:  "blockchain",
+			Result:       map[string]interface{}{"tx": stuckTx, "nonce": "7", "gasPrice": "20000000000"},
+			MiningStatus: "submitted",
+			Timestamp:    "1000",
+		},
+	}}
+	blockchain := testEscalationBlockchain{
+		stuck: map[ethereum.Hash]uint64{ethereum.HexToHash(stuckTx): 7},
+		sent:  map[uint64]int{},
+	}
+	reserveCore := NewReserveCore(blockchain, storage, testExchangeStatusStorage{}, testRiskStorage{}, NewRiskChecker(testRiskStorage{}, RiskConfig{}), ethereum.Address{})
+	escalator := NewTxEscalator(blockchain, storage, 0)
+
+	// both paths see the stuck set rates tx at nonce 7
+	if _, err := reserveCore.SetRates(
+		[]common.Token{{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}},
+		[]*big.Int{big.NewInt(1)}, []*big.Int{big.NewInt(1)}, big.NewInt(100), []*big.Int{big.NewInt(1)},
+	); err != nil {
+		t.Fatalf("Expected set rates to replace the pending tx, got error: %s", err)
+	}
+	if replaced := escalator.Escalate(); len(replaced) != 0 {
+		t.Fatalf("Expected set rates txs not to be escalated, got %v", replaced)
+	}
+	if blockchain.sent[7] != 1 {
+		t.Fatalf("Expected one replacement at nonce 7, got %d", blockchain.sent[7])
+	}
+}
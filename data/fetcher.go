@@ -3,4 +3,5 @@ package data
 type Fetcher interface {
 	Run() error
 	Stop() error
+	UpdateExchangeNotification(exchange string, isWarning bool, msg string)
 }
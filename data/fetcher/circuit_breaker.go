@@ -0,0 +1,145 @@
+package fetcher
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	BALANCE_SOURCE      string = "balance"
+	PRICE_SOURCE        string = "price"
+	NOTIFICATION_SOURCE string = "notification"
+)
+
+type breakerState struct {
+	// consecutive failures by source
+	failures map[string]int
+	// time of the last failure of any source
+	lastFailure uint64
+	// first success after the last failure, 0 if the last result is a failure
+	healthySince uint64
+}
+
+// CircuitBreaker disables an exchange in exchange status after
+// failureThreshold consecutive failures of one of its sources (balances,
+// prices or warning notifications) and enables it again when it has been
+// healthy for healthyPeriod milliseconds. Warning notifications expire
+// when no source fails for healthyPeriod. Exchanges disabled manually are
+// never enabled by the breaker. Statuses are checked and updated in one
+// storage transaction so manual updates in between are not overwritten.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	storage          Storage
+	failureThreshold int
+	healthyPeriod    uint64
+	states           map[string]*breakerState
+}
+
+func NewCircuitBreaker(storage Storage, failureThreshold int, healthyPeriod uint64) *CircuitBreaker {
+	return &CircuitBreaker{
+		storage:          storage,
+		failureThreshold: failureThreshold,
+		healthyPeriod:    healthyPeriod,
+		states:           map[string]*breakerState{},
+	}
+}
+
+func (self *CircuitBreaker) state(exchange string) *breakerState {
+	state, found := self.states[exchange]
+	if !found {
+		state = &breakerState{failures: map[string]int{}}
+		self.states[exchange] = state
+	}
+	return state
+}
+
+// RecordFailure counts a failure of source, the exchange is disabled when
+// the source fails failureThreshold times in a row
+func (self *CircuitBreaker) RecordFailure(exchange, source, reason string, timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	state := self.state(exchange)
+	state.failures[source]++
+	state.lastFailure = timepoint
+	state.healthySince = 0
+	if state.failures[source] < self.failureThreshold {
+		return
+	}
+	tripped := common.ExStatus{
+		Timestamp: timepoint,
+		Status:    false,
+		Tripped:   true,
+		Reason:    fmt.Sprintf("%d consecutive %s failures, last: %s", state.failures[source], source, reason),
+	}
+	disabled := false
+	err := self.storage.UpdateOneExchangeStatus(exchange, func(current common.ExStatus, found bool) (common.ExStatus, bool) {
+		disabled = !found || current.Status
+		return tripped, disabled
+	})
+	if err != nil {
+		log.Printf("Circuit breaker: disabling %s failed: %s", exchange, err)
+		return
+	}
+	if disabled {
+		log.Printf("Circuit breaker: disabled %s: %s", exchange, tripped.Reason)
+	}
+}
+
+// RecordSuccess resets failures of source, an exchange disabled by the
+// breaker is enabled when it has been healthy for healthyPeriod
+func (self *CircuitBreaker) RecordSuccess(exchange, source string, timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	state := self.state(exchange)
+	state.failures[source] = 0
+	// warnings are not always followed by a non warning notification,
+	// they are forgotten once the exchange has no failure for healthyPeriod
+	warningsExpired := false
+	if state.failures[NOTIFICATION_SOURCE] > 0 && timepoint >= state.lastFailure+self.healthyPeriod {
+		state.failures[NOTIFICATION_SOURCE] = 0
+		warningsExpired = true
+	}
+	for _, failures := range state.failures {
+		if failures > 0 {
+			return
+		}
+	}
+	if state.healthySince == 0 {
+		state.healthySince = timepoint
+		if warningsExpired {
+			state.healthySince = state.lastFailure
+		}
+	}
+	if timepoint < state.healthySince+self.healthyPeriod {
+		return
+	}
+	enabled := false
+	err := self.storage.UpdateOneExchangeStatus(exchange, func(current common.ExStatus, found bool) (common.ExStatus, bool) {
+		// exchanges disabled manually in the meantime are not tripped
+		enabled = found && !current.Status && current.Tripped
+		return common.ExStatus{
+			Timestamp: timepoint,
+			Status:    true,
+		}, enabled
+	})
+	if err != nil {
+		log.Printf("Circuit breaker: enabling %s failed: %s", exchange, err)
+		return
+	}
+	if enabled {
+		log.Printf("Circuit breaker: enabled %s after being healthy since %d", exchange, state.healthySince)
+	}
+}
+
+// RecordNotification counts a warning notification as a failure, other
+// notifications reset warning failures
+func (self *CircuitBreaker) RecordNotification(exchange string, isWarning bool, msg string, timepoint uint64) {
+	if isWarning {
+		self.RecordFailure(exchange, NOTIFICATION_SOURCE, msg, timepoint)
+	} else {
+		self.RecordSuccess(exchange, NOTIFICATION_SOURCE, timepoint)
+	}
+}
@@ -0,0 +1,86 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testStatusStorage struct {
+	Storage
+	status common.ExchangesStatus
+}
+
+func (self *testStatusStorage) GetExchangeStatus() (common.ExchangesStatus, error) {
+	result := common.ExchangesStatus{}
+	for k, v := range self.status {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (self *testStatusStorage) UpdateExchangeStatus(data common.ExchangesStatus) error {
+	self.status = data
+	return nil
+}
+
+func (self *testStatusStorage) UpdateOneExchangeStatus(exchange string, update func(current common.ExStatus, found bool) (common.ExStatus, bool)) error {
+	current, found := self.status[exchange]
+	if status, changed := update(current, found); changed {
+		self.status[exchange] = status
+	}
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	storage := &testStatusStorage{status: common.ExchangesStatus{
+		"binance": {Status: true},
+		"huobi":   {Status: false},
+	}}
+	breaker := NewCircuitBreaker(storage, 3, 1000)
+
+	breaker.RecordFailure("binance", BALANCE_SOURCE, "timeout", 1)
+	breaker.RecordFailure("binance", BALANCE_SOURCE, "timeout", 2)
+	breaker.RecordSuccess("binance", PRICE_SOURCE, 3)
+	if !storage.status["binance"].Status {
+		t.Fatalf("Expected binance to be enabled before %d consecutive failures", 3)
+	}
+	breaker.RecordFailure("binance", BALANCE_SOURCE, "timeout", 4)
+	if status := storage.status["binance"]; status.Status || !status.Tripped {
+		t.Fatalf("Expected binance to be disabled by circuit breaker, got %+v", status)
+	}
+
+	breaker.RecordSuccess("binance", BALANCE_SOURCE, 10)
+	breaker.RecordFailure("binance", PRICE_SOURCE, "invalid", 20)
+	breaker.RecordSuccess("binance", PRICE_SOURCE, 30)
+	breaker.RecordSuccess("binance", PRICE_SOURCE, 1020)
+	if storage.status["binance"].Status {
+		t.Fatalf("Expected binance to stay disabled before being healthy for the healthy period")
+	}
+	breaker.RecordSuccess("binance", BALANCE_SOURCE, 1030)
+	if status := storage.status["binance"]; !status.Status || status.Tripped {
+		t.Fatalf("Expected binance to be enabled after the healthy period, got %+v", status)
+	}
+
+	for i := uint64(0); i < 3; i++ {
+		breaker.RecordNotification("bittrex", true, "withdraw suspended", i)
+	}
+	if status := storage.status["bittrex"]; status.Status || !status.Tripped {
+		t.Fatalf("Expected bittrex to be disabled by warning notifications, got %+v", status)
+	}
+	breaker.RecordSuccess("bittrex", BALANCE_SOURCE, 500)
+	breaker.RecordSuccess("bittrex", PRICE_SOURCE, 1000)
+	if storage.status["bittrex"].Status {
+		t.Fatalf("Expected bittrex to stay disabled before the warnings expire")
+	}
+	breaker.RecordSuccess("bittrex", BALANCE_SOURCE, 1002)
+	if status := storage.status["bittrex"]; !status.Status || status.Tripped {
+		t.Fatalf("Expected bittrex to be enabled once balances and prices are healthy for the healthy period after the last warning, got %+v", status)
+	}
+
+	breaker.RecordSuccess("huobi", BALANCE_SOURCE, 1)
+	breaker.RecordSuccess("huobi", BALANCE_SOURCE, 5000)
+	if storage.status["huobi"].Status {
+		t.Fatalf("Expected manually disabled huobi not to be enabled by circuit breaker")
+	}
+}
@@ -1,6 +1,8 @@
 package fetcher
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"sync"
@@ -21,6 +23,7 @@ type Fetcher struct {
 	currentBlock           uint64
 	currentBlockUpdateTime uint64
 	simulationMode         bool
+	breaker                *CircuitBreaker
 }
 
 func NewFetcher(
@@ -47,22 +50,45 @@ func (self *Fetcher) SetBlockchain(blockchain Blockchain) {
 	self.FetchCurrentBlock(common.GetTimepoint())
 }
 
+// SetCircuitBreaker makes fetching results of exchanges drive their status
+func (self *Fetcher) SetCircuitBreaker(breaker *CircuitBreaker) {
+	self.breaker = breaker
+}
+
+func (self *Fetcher) recordFetch(exchange Exchange, source string, err error, timepoint uint64) {
+	if self.breaker == nil {
+		return
+	}
+	if err != nil {
+		self.breaker.RecordFailure(string(exchange.ID()), source, err.Error(), timepoint)
+	} else {
+		self.breaker.RecordSuccess(string(exchange.ID()), source, timepoint)
+	}
+}
+
+// UpdateExchangeNotification lets warning notifications of an exchange
+// trip its circuit breaker
+func (self *Fetcher) UpdateExchangeNotification(exchange string, isWarning bool, msg string) {
+	if self.breaker != nil {
+		self.breaker.RecordNotification(exchange, isWarning, msg, common.GetTimepoint())
+	}
+}
+
 func (self *Fetcher) AddExchange(exchange Exchange) {
 	self.exchanges = append(self.exchanges, exchange)
 	// initiate exchange status as up
-	exchangeStatus, _ := self.storage.GetExchangeStatus()
-	if exchangeStatus == nil {
-		exchangeStatus = map[string]common.ExStatus{}
-	}
-	exchangeID := string(exchange.ID())
-	_, exist := exchangeStatus[exchangeID]
-	if !exist {
-		exchangeStatus[exchangeID] = common.ExStatus{
+	err := self.storage.UpdateOneExchangeStatus(string(exchange.ID()), func(current common.ExStatus, found bool) (common.ExStatus, bool) {
+		if found {
+			return current, false
+		}
+		return common.ExStatus{
 			Timestamp: common.GetTimepoint(),
 			Status:    true,
-		}
+		}, true
+	})
+	if err != nil {
+		log.Printf("Initiating status of %s failed: %s", exchange.ID(), err)
 	}
-	self.storage.UpdateExchangeStatus(exchangeStatus)
 }
 
 func (self *Fetcher) Stop() error {
@@ -78,6 +104,7 @@ func (self *Fetcher) Run() error {
 	go self.RunBlockFetcher()
 	go self.RunTradeHistoryFetcher()
 	go self.RunGlobalDataFetcher()
+	go self.RunOrderFetcher()
 	log.Printf("Fetcher runner is running...")
 	return nil
 }
@@ -260,6 +287,53 @@ func (self *Fetcher) RunTradeHistoryFetcher() {
 	}
 }
 
+func (self *Fetcher) FetchOrderFromExchange(
+	wait *sync.WaitGroup,
+	exchange Exchange,
+	data *sync.Map,
+	timepoint uint64) {
+
+	defer wait.Done()
+	orders, err := exchange.FetchOrderData(timepoint)
+	if err != nil {
+		log.Printf("Fetch open orders from exchange failed: %s", err.Error())
+		orders.Valid = false
+		orders.Error = err.Error()
+	}
+	data.Store(exchange.ID(), orders)
+}
+
+func (self *Fetcher) FetchAllOrders(timepoint uint64) {
+	orders := common.AllOrderEntry{}
+	wait := sync.WaitGroup{}
+	data := sync.Map{}
+	for _, exchange := range self.exchanges {
+		wait.Add(1)
+		go self.FetchOrderFromExchange(&wait, exchange, &data, timepoint)
+	}
+
+	wait.Wait()
+	data.Range(func(key, value interface{}) bool {
+		orders[key.(common.ExchangeID)] = value.(common.OrderEntry)
+		return true
+	})
+
+	err := self.storage.StoreOrder(orders, timepoint)
+	if err != nil {
+		log.Printf("Store open orders failed: %s", err.Error())
+	}
+}
+
+func (self *Fetcher) RunOrderFetcher() {
+	for {
+		log.Printf("waiting for signal from runner order channel")
+		t := <-self.runner.GetOrderTicker()
+		log.Printf("got signal in order channel with timestamp %d", common.TimeToTimepoint(t))
+		self.FetchAllOrders(common.TimeToTimepoint(t))
+		log.Printf("fetched open orders from exchanges")
+	}
+}
+
 func (self *Fetcher) FetchAuthDataFromBlockchain(
 	allBalances map[string]common.BalanceEntry,
 	allStatuses *sync.Map,
@@ -317,7 +391,7 @@ func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord)
 		log.Printf("Getting mined nonce failed: %s", nerr)
 	}
 	for _, activity := range pendings {
-		if activity.IsBlockchainPending() && (activity.Action == "set_rates" || activity.Action == "deposit" || activity.Action == "withdraw") {
+		if activity.IsBlockchainPending() && (activity.Action == "set_rates" || activity.Action == "set_step_function" || activity.Action == "deposit" || activity.Action == "withdraw") {
 			var blockNum uint64
 			var status string
 			var err error
@@ -363,7 +437,18 @@ func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord)
 					err,
 				}
 			case "lost":
-				elapsed := common.GetTimepoint() - activity.Timestamp.ToUint64()
+				// a replaced tx may be mined before its replacement
+				if replaced, replacedStatus, replacedBlock := self.minedReplacedTx(activity); replacedStatus != "" {
+					log.Printf("Fetcher tx status: tx(%s) is lost, replaced tx(%s) is %s", activity.Result["tx"].(string), replaced, replacedStatus)
+					result[activity.ID] = common.ActivityStatus{
+						ExchangeStatus: activity.ExchangeStatus,
+						Tx:             replaced,
+						BlockNumber:    replacedBlock,
+						MiningStatus:   replacedStatus,
+					}
+					break
+				}
+				elapsed := common.GetTimepoint() - activity.SubmittedAt()
 				if elapsed > uint64(15*time.Minute/time.Millisecond) {
 					log.Printf("Fetcher tx status: tx(%s) is lost, elapsed time: %d", activity.Result["tx"].(string), elapsed)
 					result[activity.ID] = common.ActivityStatus{
@@ -380,6 +465,18 @@ func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord)
 	return result
 }
 
+// minedReplacedTx returns the hash, status and block of a replaced tx of
+// activity which is mined instead of its current tx
+func (self *Fetcher) minedReplacedTx(activity common.ActivityRecord) (string, string, uint64) {
+	for _, hash := range activity.ReplacedTxs() {
+		status, blockNum, err := self.blockchain.TxStatus(ethereum.HexToHash(hash))
+		if err == nil && (status == "mined" || status == "failed") {
+			return hash, status, blockNum
+		}
+	}
+	return "", "", 0
+}
+
 func unchanged(pre, post map[common.ActivityID]common.ActivityStatus) bool {
 	if len(pre) != len(post) {
 		return false
@@ -522,7 +619,11 @@ func (self *Fetcher) FetchAuthDataFromExchange(
 		for id, activityStatus := range statuses {
 			allStatuses.Store(id, activityStatus)
 		}
+		if !balances.Valid {
+			err = errors.New(balances.Error)
+		}
 	}
+	self.recordFetch(exchange, BALANCE_SOURCE, err, timepoint)
 }
 
 func (self *Fetcher) FetchStatusFromExchange(exchange Exchange, pendings []common.ActivityRecord, timepoint uint64) map[common.ActivityID]common.ActivityStatus {
@@ -599,5 +700,9 @@ func (self *Fetcher) fetchPriceFromExchange(wg *sync.WaitGroup, exchange Exchang
 	}
 	for pair, exchangeData := range exdata {
 		data.SetOnePrice(exchange.ID(), pair, exchangeData)
+		if err == nil && !exchangeData.Valid {
+			err = fmt.Errorf("%s price is invalid: %s", pair, exchangeData.Error)
+		}
 	}
+	self.recordFetch(exchange, PRICE_SOURCE, err, timepoint)
 }
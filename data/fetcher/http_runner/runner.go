@@ -19,6 +19,9 @@ type HttpRunner struct {
 	tradeLogProcessorTicker chan time.Time
 	catLogProcessorTicker   chan time.Time
 	globalDataTicker        chan time.Time
+	rebalanceTicker         chan time.Time
+	pricingTicker           chan time.Time
+	orderTicker             chan time.Time
 	server                  *HttpRunnerServer
 }
 
@@ -26,6 +29,14 @@ func (self *HttpRunner) GetGlobalDataTicker() <-chan time.Time {
 	return self.globalDataTicker
 }
 
+func (self *HttpRunner) GetRebalanceTicker() <-chan time.Time {
+	return self.rebalanceTicker
+}
+
+func (self *HttpRunner) GetPricingTicker() <-chan time.Time {
+	return self.pricingTicker
+}
+
 func (self *HttpRunner) GetTradeLogProcessorTicker() <-chan time.Time {
 	return self.tradeLogProcessorTicker
 }
@@ -57,6 +68,10 @@ func (self *HttpRunner) GetTradeHistoryTicker() <-chan time.Time {
 	return self.tticker
 }
 
+func (self *HttpRunner) GetOrderTicker() <-chan time.Time {
+	return self.orderTicker
+}
+
 func (self *HttpRunner) GetReserveRatesTicker() <-chan time.Time {
 	return self.rsticker
 }
@@ -97,6 +112,9 @@ func NewHttpRunner(port int) *HttpRunner {
 	tradeLogProcessorChan := make(chan time.Time)
 	catLogProcessorChan := make(chan time.Time)
 	globalDataChan := make(chan time.Time)
+	rebalanceChan := make(chan time.Time)
+	pricingChan := make(chan time.Time)
+	orderChan := make(chan time.Time)
 	runner := HttpRunner{
 		port,
 		ochan,
@@ -109,6 +127,9 @@ func NewHttpRunner(port int) *HttpRunner {
 		tradeLogProcessorChan,
 		catLogProcessorChan,
 		globalDataChan,
+		rebalanceChan,
+		pricingChan,
+		orderChan,
 		nil,
 	}
 	runner.Start()
@@ -104,6 +104,39 @@ func (self *HttpRunnerServer) gtick(c *gin.Context) {
 	)
 }
 
+func (self *HttpRunnerServer) rbtick(c *gin.Context) {
+	timepoint := getTimePoint(c)
+	self.runner.rebalanceTicker <- common.TimepointToTime(timepoint)
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+		},
+	)
+}
+
+func (self *HttpRunnerServer) ptick(c *gin.Context) {
+	timepoint := getTimePoint(c)
+	self.runner.pricingTicker <- common.TimepointToTime(timepoint)
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+		},
+	)
+}
+
+func (self *HttpRunnerServer) ordertick(c *gin.Context) {
+	timepoint := getTimePoint(c)
+	self.runner.orderTicker <- common.TimepointToTime(timepoint)
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+		},
+	)
+}
+
 func (self *HttpRunnerServer) init() {
 	self.r.GET("/otick", self.otick)
 	self.r.GET("/atick", self.atick)
@@ -111,6 +144,9 @@ func (self *HttpRunnerServer) init() {
 	self.r.GET("/btick", self.btick)
 	self.r.GET("/ttick", self.ttick)
 	self.r.GET("/gtick", self.gtick)
+	self.r.GET("/rbtick", self.rbtick)
+	self.r.GET("/ptick", self.ptick)
+	self.r.GET("/ordertick", self.ordertick)
 }
 
 func (self *HttpRunnerServer) Start() error {
@@ -12,6 +12,7 @@ type FetcherRunner interface {
 	GetRateTicker() <-chan time.Time
 	GetBlockTicker() <-chan time.Time
 	GetTradeHistoryTicker() <-chan time.Time
+	GetOrderTicker() <-chan time.Time
 	// Start must be non-blocking and must only return after runner
 	// gets to ready state before GetOrderbookTicker() and
 	// GetAuthDataTicker() get called
@@ -27,12 +28,14 @@ type TickerRunner struct {
 	bduration          time.Duration
 	tduration          time.Duration
 	globalDataDuration time.Duration
+	orderDuration      time.Duration
 	oclock             *time.Ticker
 	aclock             *time.Ticker
 	rclock             *time.Ticker
 	bclock             *time.Ticker
 	tclock             *time.Ticker
 	globalDataClock    *time.Ticker
+	orderClock         *time.Ticker
 	signal             chan bool
 }
 
@@ -74,6 +77,13 @@ func (self *TickerRunner) GetTradeHistoryTicker() <-chan time.Time {
 	return self.tclock.C
 }
 
+func (self *TickerRunner) GetOrderTicker() <-chan time.Time {
+	if self.orderClock == nil {
+		<-self.signal
+	}
+	return self.orderClock.C
+}
+
 // func (self *TickerRunner) GetReserveRatesTicker() <-chan time.Time {
 // 	if self.rsclock == nil {
 // 		<-self.signal
@@ -94,6 +104,8 @@ func (self *TickerRunner) Start() error {
 	self.signal <- true
 	self.globalDataClock = time.NewTicker(self.globalDataDuration)
 	self.signal <- true
+	self.orderClock = time.NewTicker(self.orderDuration)
+	self.signal <- true
 	return nil
 }
 
@@ -104,12 +116,14 @@ func (self *TickerRunner) Stop() error {
 	self.bclock.Stop()
 	self.tclock.Stop()
 	self.globalDataClock.Stop()
+	self.orderClock.Stop()
 	return nil
 }
 
 func NewTickerRunner(
 	oduration, aduration, rduration,
-	bduration, tduration, globalDataDuration time.Duration) *TickerRunner {
+	bduration, tduration, globalDataDuration,
+	orderDuration time.Duration) *TickerRunner {
 	return &TickerRunner{
 		oduration,
 		aduration,
@@ -117,12 +131,14 @@ func NewTickerRunner(
 		bduration,
 		tduration,
 		globalDataDuration,
+		orderDuration,
+		nil,
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
-		make(chan bool, 6),
+		make(chan bool, 7),
 	}
 }
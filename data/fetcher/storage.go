@@ -9,12 +9,17 @@ type Storage interface {
 	StoreRate(data common.AllRateEntry, timepoint uint64) error
 	StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error
 	StoreTradeHistory(data common.AllTradeHistory, timepoint uint64) error
+	StoreOrder(data common.AllOrderEntry, timepoint uint64) error
 
 	GetPendingActivities() ([]common.ActivityRecord, error)
 	UpdateActivity(id common.ActivityID, act common.ActivityRecord) error
 
 	GetExchangeStatus() (common.ExchangesStatus, error)
 	UpdateExchangeStatus(data common.ExchangesStatus) error
+	// UpdateOneExchangeStatus reads and updates status of exchange in one
+	// transaction, update gets the current status and returns the new one
+	// and false if nothing is changed
+	UpdateOneExchangeStatus(exchange string, update func(current common.ExStatus, found bool) (common.ExStatus, bool)) error
 
 	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
 	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
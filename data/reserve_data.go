@@ -94,6 +94,35 @@ func (self ReserveData) GetAuthData(timepoint uint64) (common.AuthDataResponse,
 	}
 }
 
+func (self ReserveData) CurrentOrderVersion(timepoint uint64) (common.Version, error) {
+	return self.storage.CurrentOrderVersion(timepoint)
+}
+
+func (self ReserveData) GetOpenOrders(timepoint uint64) (common.AllOrderResponse, error) {
+	timestamp := common.GetTimestamp()
+	version, err := self.storage.CurrentOrderVersion(timepoint)
+	if err != nil {
+		return common.AllOrderResponse{}, err
+	} else {
+		result := common.AllOrderResponse{}
+		data, err := self.storage.GetOrders(version)
+		returnTime := common.GetTimestamp()
+		result.Version = version
+		result.Timestamp = timestamp
+		result.ReturnTime = returnTime
+		result.Data = data
+		return result, err
+	}
+}
+
+func (self ReserveData) SubscribeUpdates() <-chan common.DataUpdate {
+	return self.storage.Subscribe()
+}
+
+func (self ReserveData) UnsubscribeUpdates(ch <-chan common.DataUpdate) {
+	self.storage.Unsubscribe(ch)
+}
+
 func (self ReserveData) CurrentRateVersion(timepoint uint64) (common.Version, error) {
 	return self.storage.CurrentRateVersion(timepoint)
 }
@@ -211,21 +240,22 @@ func (self ReserveData) GetExchangeStatus() (common.ExchangesStatus, error) {
 }
 
 func (self ReserveData) UpdateExchangeStatus(exchange string, status bool, timestamp uint64) error {
-	currentExchangeStatus, err := self.storage.GetExchangeStatus()
-	if err != nil {
-		return err
-	}
-	currentExchangeStatus[exchange] = common.ExStatus{
-		Timestamp: timestamp,
-		Status:    status,
-	}
-	return self.storage.UpdateExchangeStatus(currentExchangeStatus)
+	return self.storage.UpdateOneExchangeStatus(exchange, func(current common.ExStatus, found bool) (common.ExStatus, bool) {
+		return common.ExStatus{
+			Timestamp: timestamp,
+			Status:    status,
+		}, true
+	})
 }
 
 func (self ReserveData) UpdateExchangeNotification(
 	exchange, action, tokenPair string, fromTime, toTime uint64, isWarning bool, msg string) error {
 	err := self.storage.UpdateExchangeNotification(exchange, action, tokenPair, fromTime, toTime, isWarning, msg)
-	return err
+	if err != nil {
+		return err
+	}
+	self.fetcher.UpdateExchangeNotification(exchange, isWarning, msg)
+	return nil
 }
 
 func (self ReserveData) GetRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
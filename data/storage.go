@@ -19,10 +19,20 @@ type Storage interface {
 	GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error)
 	GetPendingActivities() ([]common.ActivityRecord, error)
 
+	CurrentOrderVersion(timepoint uint64) (common.Version, error)
+	GetOrders(common.Version) (common.AllOrderEntry, error)
+
 	GetTradeHistory(timepoint uint64) (common.AllTradeHistory, error)
 	GetExchangeStatus() (common.ExchangesStatus, error)
 	UpdateExchangeStatus(data common.ExchangesStatus) error
+	// UpdateOneExchangeStatus reads and updates status of exchange in one
+	// transaction, update gets the current status and returns the new one
+	// and false if nothing is changed
+	UpdateOneExchangeStatus(exchange string, update func(current common.ExStatus, found bool) (common.ExStatus, bool)) error
 
 	UpdateExchangeNotification(exchange, action, tokenPair string, fromTime, toTime uint64, isWarning bool, msg string) error
 	GetExchangeNotifications() (common.ExchangeNotifications, error)
+
+	Subscribe() <-chan common.DataUpdate
+	Unsubscribe(ch <-chan common.DataUpdate)
 }
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,37 +14,47 @@ import (
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/metric"
 	"github.com/boltdb/bolt"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 const (
 	GOLD_BUCKET string = "gold_feeds"
 
-	PRICE_BUCKET                       string = "prices"
-	RATE_BUCKET                        string = "rates"
-	ORDER_BUCKET                       string = "orders"
-	ACTIVITY_BUCKET                    string = "activities"
-	AUTH_DATA_BUCKET                   string = "auth_data"
-	PENDING_ACTIVITY_BUCKET            string = "pending_activities"
-	METRIC_BUCKET                      string = "metrics"
-	METRIC_TARGET_QUANTITY             string = "target_quantity"
+	PRICE_BUCKET               string = "prices"
+	RATE_BUCKET                string = "rates"
+	ORDER_BUCKET               string = "orders"
+	ACTIVITY_BUCKET            string = "activities"
+	AUTH_DATA_BUCKET           string = "auth_data"
+	PENDING_ACTIVITY_BUCKET    string = "pending_activities"
+	METRIC_BUCKET              string = "metrics"
+	METRIC_TARGET_QUANTITY     string = "target_quantity"
+	TRADE_HISTORY              string = "trade_history"
+	ENABLE_REBALANCE           string = "enable_rebalance"
+	SETRATE_CONTROL            string = "setrate_control"
+	PWI_EQUATION               string = "pwi_equation"
+	INTERMEDIATE_TX            string = "intermediate_tx"
+	EXCHANGE_STATUS            string = "exchange_status"
+	EXCHANGE_NOTIFICATIONS     string = "exchange_notifications"
+	MAX_NUMBER_VERSION         int    = 1000
+	MAX_GET_RATES_PERIOD       uint64 = 86400000 //1 days in milisec
+	STABLE_TOKEN_PARAMS_BUCKET string = "stable-token-params"
+	API_KEYS_BUCKET            string = "api_keys"
+	PROPOSAL_BUCKET            string = "proposals"
+	AUDIT_LOG_BUCKET           string = "audit_logs"
+	NONCE_BUCKET               string = "nonces"
+	STABLE_EX_ORDER_BUCKET     string = "stable_exchange_orders"
+
+	// buckets of pending configurations replaced by proposals, their
+	// contents are migrated to PROPOSAL_BUCKET by NewBoltStorage
 	PENDING_TARGET_QUANTITY            string = "pending_target_quantity"
-	TRADE_HISTORY                      string = "trade_history"
-	ENABLE_REBALANCE                   string = "enable_rebalance"
-	SETRATE_CONTROL                    string = "setrate_control"
 	PENDING_PWI_EQUATION               string = "pending_pwi_equation"
-	PWI_EQUATION                       string = "pwi_equation"
-	INTERMEDIATE_TX                    string = "intermediate_tx"
-	EXCHANGE_STATUS                    string = "exchange_status"
-	EXCHANGE_NOTIFICATIONS             string = "exchange_notifications"
-	MAX_NUMBER_VERSION                 int    = 1000
-	MAX_GET_RATES_PERIOD               uint64 = 86400000 //1 days in milisec
-	STABLE_TOKEN_PARAMS_BUCKET         string = "stable-token-params"
 	PENDING_STABLE_TOKEN_PARAMS_BUCKET string = "pending-stable-token-params"
 )
 
 type BoltStorage struct {
-	mu sync.RWMutex
-	db *bolt.DB
+	mu   sync.RWMutex
+	db   *bolt.DB
+	feed *common.UpdateFeed
 }
 
 func NewBoltStorage(path string) (*BoltStorage, error) {
@@ -95,10 +104,6 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucketIfNotExists([]byte(PENDING_TARGET_QUANTITY))
-		if err != nil {
-			return err
-		}
 		_, err = tx.CreateBucketIfNotExists([]byte(TRADE_HISTORY))
 		if err != nil {
 			return err
@@ -111,10 +116,6 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucketIfNotExists([]byte(PENDING_PWI_EQUATION))
-		if err != nil {
-			return err
-		}
 		_, err = tx.CreateBucketIfNotExists([]byte(PWI_EQUATION))
 		if err != nil {
 			return err
@@ -131,23 +132,117 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucketIfNotExists([]byte(PENDING_STABLE_TOKEN_PARAMS_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(STABLE_TOKEN_PARAMS_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucketIfNotExists([]byte(STABLE_TOKEN_PARAMS_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(API_KEYS_BUCKET))
 		if err != nil {
 			return err
 		}
-		return nil
+		_, err = tx.CreateBucketIfNotExists([]byte(PROPOSAL_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(AUDIT_LOG_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(NONCE_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(STABLE_EX_ORDER_BUCKET))
+		if err != nil {
+			return err
+		}
+		err = migratePendingConfigs(tx)
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	storage := &BoltStorage{sync.RWMutex{}, db}
+	storage := &BoltStorage{sync.RWMutex{}, db, common.NewUpdateFeed()}
 	return storage, nil
 }
 
+// migratePendingConfigs converts pending target quantities, PWI equations
+// and stable token params of the buckets used before proposals to pending
+// proposals and deletes the old buckets. Migrated proposals need one
+// approval as the pending configurations needed one confirmation.
+func migratePendingConfigs(tx *bolt.Tx) error {
+	proposals := tx.Bucket([]byte(PROPOSAL_BUCKET))
+	timepoint := common.GetTimepoint()
+	store := func(bucket string, id uint64, proposalType, data string, params map[string]string) error {
+		for proposals.Get(uint64ToBytes(id)) != nil {
+			id++
+		}
+		proposal := metric.Proposal{
+			ID:                id,
+			Type:              proposalType,
+			Data:              data,
+			Params:            params,
+			Approvers:         []string{},
+			RequiredApprovals: 1,
+			CreatedAt:         id,
+			Status:            metric.PROPOSAL_PENDING,
+			History: []metric.ProposalEvent{
+				{Action: "propose", Timestamp: id, Note: "migrated from " + bucket},
+			},
+		}
+		dataJSON, err := json.Marshal(proposal)
+		if err != nil {
+			return err
+		}
+		log.Printf("Migrating pending %s of %s to proposal %d", proposalType, bucket, id)
+		return proposals.Put(uint64ToBytes(id), dataJSON)
+	}
+	migrations := map[string]func(k, v []byte) error{
+		PENDING_TARGET_QUANTITY: func(k, v []byte) error {
+			targetQty := metric.TokenTargetQty{}
+			if err := json.Unmarshal(v, &targetQty); err != nil {
+				return err
+			}
+			params := map[string]string{"type": strconv.FormatInt(targetQty.Type, 10)}
+			return store(PENDING_TARGET_QUANTITY, targetQty.ID, metric.TARGET_QTY_PROPOSAL, targetQty.Data, params)
+		},
+		PENDING_PWI_EQUATION: func(k, v []byte) error {
+			equation := metric.PWIEquation{}
+			if err := json.Unmarshal(v, &equation); err != nil {
+				return err
+			}
+			return store(PENDING_PWI_EQUATION, equation.ID, metric.PWI_EQUATION_PROPOSAL, equation.Data, nil)
+		},
+		// stable token params are kept under key 1 without a timepoint
+		PENDING_STABLE_TOKEN_PARAMS_BUCKET: func(k, v []byte) error {
+			return store(PENDING_STABLE_TOKEN_PARAMS_BUCKET, timepoint, metric.STABLE_TOKEN_PARAMS_PROPOSAL, string(v), nil)
+		},
+	}
+	for bucket, migrate := range migrations {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			continue
+		}
+		if err := b.ForEach(migrate); err != nil {
+			return fmt.Errorf("Cannot migrate %s: %s", bucket, err)
+		}
+		if err := tx.DeleteBucket([]byte(bucket)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel notifying every new price, rate and auth data
+// version once it is committed
+func (self *BoltStorage) Subscribe() <-chan common.DataUpdate {
+	return self.feed.Subscribe()
+}
+
+func (self *BoltStorage) Unsubscribe(ch <-chan common.DataUpdate) {
+	self.feed.Unsubscribe(ch)
+}
+
 func uint64ToBytes(u uint64) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, u)
@@ -403,6 +498,54 @@ func (self *BoltStorage) StorePrice(data common.AllPriceEntry, timepoint uint64)
 		}
 		return b.Put(uint64ToBytes(timepoint), dataJson)
 	})
+	if err == nil {
+		self.feed.Publish(common.PRICE_UPDATE, common.Version(timepoint))
+	}
+	return err
+}
+
+func (self *BoltStorage) CurrentOrderVersion(timepoint uint64) (common.Version, error) {
+	var result uint64
+	var err error
+	err = self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(ORDER_BUCKET)).Cursor()
+		result, err = reverseSeek(timepoint, c)
+		return nil
+	})
+	return common.Version(result), err
+}
+
+func (self *BoltStorage) GetOrders(version common.Version) (common.AllOrderEntry, error) {
+	result := common.AllOrderEntry{}
+	var err error
+	err = self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ORDER_BUCKET))
+		data := b.Get(uint64ToBytes(uint64(version)))
+		if data == nil {
+			err = fmt.Errorf("version %d doesn't exist", version)
+		} else {
+			err = json.Unmarshal(data, &result)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (self *BoltStorage) StoreOrder(data common.AllOrderEntry, timepoint uint64) error {
+	var err error
+	err = self.db.Update(func(tx *bolt.Tx) error {
+		var dataJson []byte
+		b := tx.Bucket([]byte(ORDER_BUCKET))
+
+		// remove outdated data from bucket
+		self.PruneOutdatedData(tx, ORDER_BUCKET)
+
+		dataJson, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return b.Put(uint64ToBytes(timepoint), dataJson)
+	})
 	return err
 }
 
@@ -420,6 +563,9 @@ func (self *BoltStorage) StoreAuthSnapshot(
 		err = b.Put(uint64ToBytes(timepoint), dataJson)
 		return err
 	})
+	if err == nil {
+		self.feed.Publish(common.AUTH_DATA_UPDATE, common.Version(timepoint))
+	}
 	return err
 }
 
@@ -427,6 +573,7 @@ func (self *BoltStorage) StoreRate(data common.AllRateEntry, timepoint uint64) e
 	log.Printf("Storing rate data to bolt: data(%v), timespoint(%v)", data, timepoint)
 	var err error
 	var lastEntryjson common.AllRateEntry
+	stored := false
 	err = self.db.Update(func(tx *bolt.Tx) error {
 		var dataJson []byte
 		b := tx.Bucket([]byte(RATE_BUCKET))
@@ -438,10 +585,14 @@ func (self *BoltStorage) StoreRate(data common.AllRateEntry, timepoint uint64) e
 			if err != nil {
 				return err
 			}
+			stored = true
 			return b.Put(uint64ToBytes(timepoint), dataJson)
 		}
 		return err
 	})
+	if err == nil && stored {
+		self.feed.Publish(common.RATE_UPDATE, common.Version(timepoint))
+	}
 	return err
 }
 
@@ -749,69 +900,6 @@ func (self *BoltStorage) GetMetric(tokens []common.Token, fromTime, toTime uint6
 	return result, err
 }
 
-func (self *BoltStorage) GetPendingTargetQty() (metric.TokenTargetQty, error) {
-	var err error
-	var tokenTargetQty metric.TokenTargetQty
-	err = self.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_TARGET_QUANTITY))
-		_, data := b.Cursor().Last()
-		if data == nil {
-			err = errors.New("There no pending target quantity")
-		} else {
-			err = json.Unmarshal(data, &tokenTargetQty)
-			if err != nil {
-				log.Printf("Cannot unmarshal: %s", err.Error())
-			}
-		}
-		return nil
-	})
-	return tokenTargetQty, err
-}
-
-func (self *BoltStorage) StorePendingTargetQty(data, dataType string) error {
-	var err error
-	timepoint := common.GetTimepoint()
-	tokenTargetQty := metric.TokenTargetQty{}
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_TARGET_QUANTITY))
-		_, lastPending := b.Cursor().Last()
-		if lastPending != nil {
-			err = errors.New("There is another pending target quantity. Please confirm or cancel it before setting new target.")
-			return err
-		}
-
-		tokenTargetQty.ID = timepoint
-		tokenTargetQty.Status = "unconfirmed"
-		tokenTargetQty.Data = data
-		tokenTargetQty.Type, _ = strconv.ParseInt(dataType, 10, 64)
-		idByte := uint64ToBytes(timepoint)
-		var dataJson []byte
-		dataJson, err = json.Marshal(tokenTargetQty)
-		if err != nil {
-			return err
-		}
-		log.Printf("Target to save: %v", dataJson)
-		return b.Put(idByte, dataJson)
-	})
-	return err
-}
-
-func (self *BoltStorage) RemovePendingTargetQty() error {
-	var err error
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_TARGET_QUANTITY))
-		k, lastPending := b.Cursor().Last()
-		log.Printf("Last key: %s", k)
-		if lastPending == nil {
-			return errors.New("There is no pending target quantity.")
-		}
-
-		b.Delete([]byte(k))
-		return nil
-	})
-	return err
-}
-
 func (self *BoltStorage) CurrentTargetQtyVersion(timepoint uint64) (common.Version, error) {
 	var result uint64
 	var err error
@@ -845,47 +933,15 @@ func (self *BoltStorage) GetTokenTargetQty() (metric.TokenTargetQty, error) {
 	return tokenTargetQty, err
 }
 
-func (self *BoltStorage) StoreTokenTargetQty(id, data string) error {
-	var err error
-	var tokenTargetQty metric.TokenTargetQty
-	var dataJson []byte
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		pending := tx.Bucket([]byte(PENDING_TARGET_QUANTITY))
-		_, pendingTargetQty := pending.Cursor().Last()
-
-		if pendingTargetQty == nil {
-			err = errors.New("There is no pending target activity to confirm.")
+func (self *BoltStorage) StoreTokenTargetQty(targetQty metric.TokenTargetQty) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(METRIC_TARGET_QUANTITY))
+		dataJson, err := json.Marshal(targetQty)
+		if err != nil {
 			return err
-		} else {
-			// verify confirm data
-			json.Unmarshal(pendingTargetQty, &tokenTargetQty)
-			pendingData := tokenTargetQty.Data
-			idInt, _ := strconv.ParseUint(id, 10, 64)
-			if tokenTargetQty.ID != idInt {
-				err = errors.New("Pending target quantity ID does not match")
-				return err
-			}
-			if data != pendingData {
-				err = errors.New("Pending target quantity data does not match")
-				return err
-			}
-
-			// Save to confirmed target quantity
-			tokenTargetQty.Status = "confirmed"
-			b := tx.Bucket([]byte(METRIC_TARGET_QUANTITY))
-			dataJson, err = json.Marshal(tokenTargetQty)
-			if err != nil {
-				return err
-			}
-			idByte := uint64ToBytes(common.GetTimepoint())
-			return b.Put(idByte, dataJson)
 		}
+		return b.Put(uint64ToBytes(common.GetTimepoint()), dataJson)
 	})
-	if err == nil {
-		// remove pending target qty
-		return self.RemovePendingTargetQty()
-	}
-	return err
 }
 
 func (self *BoltStorage) GetTradeHistory(timepoint uint64) (common.AllTradeHistory, error) {
@@ -1039,82 +1095,15 @@ func (self *BoltStorage) StoreSetrateControl(status bool) error {
 	return err
 }
 
-func (self *BoltStorage) StorePendingPWIEquation(data string) error {
-	var err error
-	timepoint := common.GetTimepoint()
-	saveData := metric.PWIEquation{}
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_PWI_EQUATION))
-		c := b.Cursor()
-		_, v := c.First()
-		if v != nil {
-			err = errors.New("There is another pending equation, please confirm or reject to set new equation")
-			return err
-		} else {
-			idByte := uint64ToBytes(timepoint)
-			saveData.ID = timepoint
-			saveData.Data = data
-			if err != nil {
-				return err
-			}
-			dataJson, err := json.Marshal(saveData)
-			if err != nil {
-				return err
-			}
-			err = b.Put(idByte, dataJson)
-		}
-		return err
-	})
-	return err
-}
-
-func (self *BoltStorage) GetPendingPWIEquation() (metric.PWIEquation, error) {
-	var err error
-	var result metric.PWIEquation
-	err = self.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_PWI_EQUATION))
-		c := b.Cursor()
-		_, v := c.First()
-		if v == nil {
-			return errors.New("There no pending equation")
-		} else {
-			json.Unmarshal(v, &result)
-		}
-		return nil
-	})
-	return result, err
-}
-
-func (self *BoltStorage) StorePWIEquation(data string) error {
-	var err error
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_PWI_EQUATION))
-		c := b.Cursor()
-		_, v := c.First()
-		if v == nil {
-			err = errors.New("There no pending equation")
+func (self *BoltStorage) StorePWIEquation(equation metric.PWIEquation) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PWI_EQUATION))
+		dataJson, err := json.Marshal(equation)
+		if err != nil {
 			return err
-		} else {
-			p := tx.Bucket([]byte(PWI_EQUATION))
-			idByte := uint64ToBytes(common.GetTimepoint())
-			pending := metric.PWIEquation{}
-			json.Unmarshal(v, &pending)
-			if pending.Data != data {
-				err = errors.New("Confirm data does not match pending data")
-				return err
-			}
-			saveData, err := json.Marshal(pending)
-			if err != nil {
-				return err
-			}
-			err = p.Put(idByte, saveData)
 		}
-		return err
+		return b.Put(uint64ToBytes(common.GetTimepoint()), dataJson)
 	})
-	if err == nil {
-		self.RemovePendingPWIEquation()
-	}
-	return err
 }
 
 func (self *BoltStorage) GetPWIEquation() (metric.PWIEquation, error) {
@@ -1135,25 +1124,6 @@ func (self *BoltStorage) GetPWIEquation() (metric.PWIEquation, error) {
 	return result, err
 }
 
-func (self *BoltStorage) RemovePendingPWIEquation() error {
-	var err error
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_PWI_EQUATION))
-		c := b.Cursor()
-		k, _ := c.First()
-		if k != nil {
-			err = b.Delete([]byte(k))
-			if err != nil {
-				return err
-			}
-		} else {
-			err = errors.New("There is no pending data")
-		}
-		return err
-	})
-	return err
-}
-
 func (self *BoltStorage) GetExchangeStatus() (common.ExchangesStatus, error) {
 	var result common.ExchangesStatus
 	var err error
@@ -1185,6 +1155,29 @@ func (self *BoltStorage) UpdateExchangeStatus(data common.ExchangesStatus) error
 	return err
 }
 
+func (self *BoltStorage) UpdateOneExchangeStatus(exchange string, update func(current common.ExStatus, found bool) (common.ExStatus, bool)) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(EXCHANGE_STATUS))
+		data := common.ExchangesStatus{}
+		if _, v := b.Cursor().Last(); v != nil {
+			if err := json.Unmarshal(v, &data); err != nil {
+				return err
+			}
+		}
+		current, found := data[exchange]
+		status, changed := update(current, found)
+		if !changed {
+			return nil
+		}
+		data[exchange] = status
+		dataJson, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return b.Put(uint64ToBytes(common.GetTimepoint()), dataJson)
+	})
+}
+
 func (self *BoltStorage) UpdateExchangeNotification(
 	exchange, action, token string, fromTime, toTime uint64, isWarning bool, msg string) error {
 	var err error
@@ -1246,52 +1239,20 @@ func (self *BoltStorage) GetExchangeNotifications() (common.ExchangeNotification
 	return result, err
 }
 
-func (self *BoltStorage) SetStableTokenParams(value []byte) error {
-	var err error
-	k := uint64ToBytes(1)
-	temp := make(map[string]interface{})
-	vErr := json.Unmarshal(value, &temp)
-	if vErr != nil {
-		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", vErr)
-	}
-	err = self.db.Update(func(tx *bolt.Tx) error {
-		b, uErr := tx.CreateBucketIfNotExists([]byte(PENDING_STABLE_TOKEN_PARAMS_BUCKET))
-		if uErr != nil {
-			return uErr
-		}
-		if b.Get(k) != nil {
-			return fmt.Errorf("Currently there is a pending record")
-		}
-		return b.Put(k, value)
-	})
-	return err
-}
-
-func (self *BoltStorage) ConfirmStableTokenParams(value []byte) error {
-	var err error
+func (self *BoltStorage) StoreStableTokenParams(value []byte) error {
 	k := uint64ToBytes(1)
 	temp := make(map[string]interface{})
 	vErr := json.Unmarshal(value, &temp)
 	if vErr != nil {
 		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", vErr)
 	}
-	pending, err := self.GetPendingStableTokenParams()
-	if eq := reflect.DeepEqual(pending, temp); !eq {
-		return fmt.Errorf("Rejected: confiming data isn't consistent")
-	}
-
-	err = self.db.Update(func(tx *bolt.Tx) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
 		b, uErr := tx.CreateBucketIfNotExists([]byte(STABLE_TOKEN_PARAMS_BUCKET))
 		if uErr != nil {
 			return uErr
 		}
 		return b.Put(k, value)
 	})
-	if err != nil {
-		return err
-	}
-	err = self.RemovePendingStableTokenParams()
-	return err
 }
 
 func (self *BoltStorage) GetStableTokenParams() (map[string]interface{}, error) {
@@ -1315,39 +1276,174 @@ func (self *BoltStorage) GetStableTokenParams() (map[string]interface{}, error)
 	return result, err
 }
 
-func (self *BoltStorage) GetPendingStableTokenParams() (map[string]interface{}, error) {
-	k := uint64ToBytes(1)
-	result := make(map[string]interface{})
+func (self *BoltStorage) StoreProposal(proposal metric.Proposal) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PROPOSAL_BUCKET))
+		dataJSON, err := json.Marshal(proposal)
+		if err != nil {
+			return err
+		}
+		return b.Put(uint64ToBytes(proposal.ID), dataJSON)
+	})
+}
+
+func (self *BoltStorage) GetProposal(id uint64) (metric.Proposal, error) {
+	result := metric.Proposal{}
 	err := self.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_STABLE_TOKEN_PARAMS_BUCKET))
-		if b == nil {
-			return fmt.Errorf("Bucket hasn't exist yet")
+		b := tx.Bucket([]byte(PROPOSAL_BUCKET))
+		data := b.Get(uint64ToBytes(id))
+		if data == nil {
+			return fmt.Errorf("Proposal %d is not found", id)
 		}
-		record := b.Get(k)
-		if record == nil {
+		return json.Unmarshal(data, &result)
+	})
+	return result, err
+}
+
+// GetProposals returns all proposals ordered by id
+func (self *BoltStorage) GetProposals() ([]metric.Proposal, error) {
+	result := []metric.Proposal{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PROPOSAL_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			proposal := metric.Proposal{}
+			if err := json.Unmarshal(v, &proposal); err != nil {
+				return err
+			}
+			result = append(result, proposal)
 			return nil
+		})
+	})
+	return result, err
+}
+
+// StoreAuditRecord appends record, keys are its timestamp followed by a
+// sequence so records of the same millisecond are kept in order
+func (self *BoltStorage) StoreAuditRecord(record common.AuditRecord) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(AUDIT_LOG_BUCKET))
+		dataJSON, err := json.Marshal(record)
+		if err != nil {
+			return err
 		}
-		vErr := json.Unmarshal(record, &result)
-		if vErr != nil {
-			return vErr
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := append(uint64ToBytes(record.Timestamp), uint64ToBytes(seq)...)
+		return b.Put(key, dataJSON)
+	})
+}
+
+func (self *BoltStorage) GetAuditRecords(fromTime, toTime uint64) ([]common.AuditRecord, error) {
+	result := []common.AuditRecord{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(AUDIT_LOG_BUCKET)).Cursor()
+		for k, v := c.Seek(uint64ToBytes(fromTime)); k != nil && bytesToUint64(k[:8]) <= toTime; k, v = c.Next() {
+			record := common.AuditRecord{}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			result = append(result, record)
 		}
 		return nil
 	})
 	return result, err
 }
 
-func (self *BoltStorage) RemovePendingStableTokenParams() error {
-	k := uint64ToBytes(1)
-	err := self.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(PENDING_STABLE_TOKEN_PARAMS_BUCKET))
-		if b == nil {
-			return fmt.Errorf("Bucket hasn't existed yet")
+func (self *BoltStorage) StoreAPIKey(key common.APIKey) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(API_KEYS_BUCKET))
+		dataJSON, err := json.Marshal(key)
+		if err != nil {
+			return err
 		}
-		record := b.Get(k)
-		if record == nil {
-			return fmt.Errorf("Bucket is empty")
+		return b.Put([]byte(key.ID), dataJSON)
+	})
+}
+
+func (self *BoltStorage) GetAPIKey(id string) (common.APIKey, error) {
+	var result common.APIKey
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(API_KEYS_BUCKET))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("Key %s is not found", id)
 		}
-		return b.Delete(k)
+		return json.Unmarshal(data, &result)
+	})
+	return result, err
+}
+
+func (self *BoltStorage) GetAPIKeys() ([]common.APIKey, error) {
+	result := []common.APIKey{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(API_KEYS_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			key := common.APIKey{}
+			if err := json.Unmarshal(v, &key); err != nil {
+				return err
+			}
+			result = append(result, key)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (self *BoltStorage) StoreNonceState(state common.NonceState) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(NONCE_BUCKET))
+		dataJSON, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(strings.ToLower(state.Address)), dataJSON)
+	})
+}
+
+func (self *BoltStorage) GetNonceState(address ethereum.Address) (common.NonceState, error) {
+	result := common.NonceState{Address: address.Hex(), Allocated: map[uint64]uint64{}}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(NONCE_BUCKET))
+		data := b.Get([]byte(strings.ToLower(address.Hex())))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &result)
+	})
+	return result, err
+}
+
+func (self *BoltStorage) StoreStableExOrder(id string, order common.StableExOrder) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(STABLE_EX_ORDER_BUCKET))
+		dataJSON, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), dataJSON)
+	})
+}
+
+func (self *BoltStorage) GetStableExOrders() (map[string]common.StableExOrder, error) {
+	result := map[string]common.StableExOrder{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(STABLE_EX_ORDER_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			order := common.StableExOrder{}
+			if err := json.Unmarshal(v, &order); err != nil {
+				return err
+			}
+			result[string(k)] = order
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (self *BoltStorage) RemoveStableExOrder(id string) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(STABLE_EX_ORDER_BUCKET)).Delete([]byte(id))
 	})
-	return err
 }
@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+	"github.com/boltdb/bolt"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 func TestHasPendingDepositBoltStorage(t *testing.T) {
@@ -49,3 +54,263 @@ func TestHasPendingDepositBoltStorage(t *testing.T) {
 		t.Fatalf("Expected ram storage to return true when there is pending deposit")
 	}
 }
+
+func TestStoreOrderBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	orders := common.AllOrderEntry{
+		common.ExchangeID("binance"): common.OrderEntry{
+			Valid: true,
+			Data: []common.Order{
+				{ID: "1_OMGETH", Base: "OMG", Quote: "ETH", OrderId: "1", Price: 0.02, OrigQty: 10, Side: "BUY"},
+			},
+		},
+	}
+	if err = storage.StoreOrder(orders, 1000); err != nil {
+		t.Fatalf("Couldn't store orders: %v", err)
+	}
+	version, err := storage.CurrentOrderVersion(2000)
+	if err != nil || version != common.Version(1000) {
+		t.Fatalf("Expected order version 1000, got %d, error: %v", version, err)
+	}
+	stored, err := storage.GetOrders(version)
+	if err != nil {
+		t.Fatalf("Couldn't get orders: %v", err)
+	}
+	if len(stored["binance"].Data) != 1 || stored["binance"].Data[0].OrderId != "1" {
+		t.Fatalf("Expected stored orders to be returned, got %+v", stored)
+	}
+}
+
+func TestSubscribeBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	updates := storage.Subscribe()
+	if err = storage.StorePrice(common.AllPriceEntry{}, 1000); err != nil {
+		t.Fatalf("Couldn't store price: %v", err)
+	}
+	if err = storage.StoreRate(common.AllRateEntry{BlockNumber: 10}, 2000); err != nil {
+		t.Fatalf("Couldn't store rate: %v", err)
+	}
+	// rate of an older block is not stored so it must not be notified
+	if err = storage.StoreRate(common.AllRateEntry{BlockNumber: 5}, 3000); err != nil {
+		t.Fatalf("Couldn't store rate: %v", err)
+	}
+	if err = storage.StoreAuthSnapshot(&common.AuthDataSnapshot{}, 4000); err != nil {
+		t.Fatalf("Couldn't store auth data: %v", err)
+	}
+	storage.Unsubscribe(updates)
+	expected := []common.DataUpdate{
+		{Topic: common.PRICE_UPDATE, Version: 1000},
+		{Topic: common.RATE_UPDATE, Version: 2000},
+		{Topic: common.AUTH_DATA_UPDATE, Version: 4000},
+	}
+	received := []common.DataUpdate{}
+	for update := range updates {
+		received = append(received, update)
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected updates %+v, got %+v", expected, received)
+	}
+	for i, update := range received {
+		if update != expected[i] {
+			t.Fatalf("Expected updates %+v, got %+v", expected, received)
+		}
+	}
+}
+
+func TestAPIKeysBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	defer os.Remove(boltFile)
+	if _, err = storage.GetAPIKey("1"); err == nil {
+		t.Fatalf("Expected error getting a key which doesn't exist")
+	}
+	key := common.APIKey{ID: "1", Secret: "secret", Scopes: []common.APIKeyScope{{Permission: "trade"}}}
+	if err = storage.StoreAPIKey(key); err != nil {
+		t.Fatalf("Couldn't store key: %v", err)
+	}
+	key.Revoked = true
+	if err = storage.StoreAPIKey(key); err != nil {
+		t.Fatalf("Couldn't store key: %v", err)
+	}
+	keys, err := storage.GetAPIKeys()
+	if err != nil || len(keys) != 1 || !keys[0].Revoked || keys[0].Secret != "secret" {
+		t.Fatalf("Expected the revoked key, got %+v, error: %v", keys, err)
+	}
+}
+
+func TestAuditRecordsBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	defer os.Remove(boltFile)
+	for i, timestamp := range []uint64{100, 200, 200, 300} {
+		record := common.AuditRecord{Timestamp: timestamp, Endpoint: fmt.Sprintf("/%d", i)}
+		if err = storage.StoreAuditRecord(record); err != nil {
+			t.Fatalf("Couldn't store audit record: %v", err)
+		}
+	}
+	records, err := storage.GetAuditRecords(150, 250)
+	if err != nil || len(records) != 2 || records[0].Endpoint != "/1" || records[1].Endpoint != "/2" {
+		t.Fatalf("Expected the 2 records at 200 in order, got %+v, error: %v", records, err)
+	}
+}
+
+func TestNonceStateBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	defer os.Remove(boltFile)
+	address := ethereum.HexToAddress("0x1111111111111111111111111111111111111111")
+	state, err := storage.GetNonceState(address)
+	if err != nil || state.Next != 0 || len(state.Allocated) != 0 {
+		t.Fatalf("Expected an empty state, got %+v, error: %v", state, err)
+	}
+	state = common.NonceState{Address: address.Hex(), Next: 8, Allocated: map[uint64]uint64{7: 100}, Gaps: []uint64{7}}
+	if err = storage.StoreNonceState(state); err != nil {
+		t.Fatalf("Couldn't store nonce state: %v", err)
+	}
+	state, err = storage.GetNonceState(address)
+	if err != nil || state.Next != 8 || state.Allocated[7] != 100 || len(state.Gaps) != 1 {
+		t.Fatalf("Expected the stored state, got %+v, error: %v", state, err)
+	}
+}
+
+func TestStableExOrderBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	defer os.Remove(boltFile)
+	order := common.StableExOrder{Base: "DGX", Quote: "ETH", Type: "buy", Rate: 0.1, Amount: 10, Timestamp: 100}
+	if err = storage.StoreStableExOrder("0x1", order); err != nil {
+		t.Fatalf("Couldn't store stable exchange order: %v", err)
+	}
+	orders, err := storage.GetStableExOrders()
+	if err != nil || len(orders) != 1 || orders["0x1"] != order {
+		t.Fatalf("Expected the stored order, got %+v, error: %v", orders, err)
+	}
+	if err = storage.RemoveStableExOrder("0x1"); err != nil {
+		t.Fatalf("Couldn't remove stable exchange order: %v", err)
+	}
+	if orders, err = storage.GetStableExOrders(); err != nil || len(orders) != 0 {
+		t.Fatalf("Expected no order after removal, got %+v, error: %v", orders, err)
+	}
+}
+
+func TestUpdateOneExchangeStatusBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	defer os.Remove(boltFile)
+	wait := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func(exchange string) {
+			defer wait.Done()
+			err := storage.UpdateOneExchangeStatus(exchange, func(current common.ExStatus, found bool) (common.ExStatus, bool) {
+				return common.ExStatus{Status: true}, !found
+			})
+			if err != nil {
+				t.Errorf("Couldn't update status of %s: %v", exchange, err)
+			}
+		}(fmt.Sprintf("exchange%d", i))
+	}
+	wait.Wait()
+	status, err := storage.GetExchangeStatus()
+	if err != nil || len(status) != 10 {
+		t.Fatalf("Expected statuses of all concurrently updated exchanges, got %+v, error: %v", status, err)
+	}
+}
+
+func TestMigratePendingConfigsBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	os.Remove(boltFile)
+	defer os.Remove(boltFile)
+	// a database of the version before proposals
+	db, err := bolt.Open(boltFile, 0600, nil)
+	if err != nil {
+		t.Fatalf("Couldn't open bolt db %v", err)
+	}
+	pending := map[string][]byte{
+		PENDING_TARGET_QUANTITY:            []byte(`{"ID":1000,"Timestamp":0,"Data":"KNC_1_2_3_4","Status":"unconfirmed","Type":1}`),
+		PENDING_PWI_EQUATION:               []byte(`{"id":2000,"data":"KNC_1_2_3"}`),
+		PENDING_STABLE_TOKEN_PARAMS_BUCKET: []byte(`{"DGX":{"AskSpread":50}}`),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for bucket, data := range pending {
+			b, err := tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			if err = b.Put(uint64ToBytes(1), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Couldn't store pending configs %v", err)
+	}
+	db.Close()
+
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't init bolt storage %v", err)
+	}
+	proposals, err := storage.GetProposals()
+	if err != nil || len(proposals) != 3 {
+		t.Fatalf("Expected 3 migrated proposals, got %+v, error: %v", proposals, err)
+	}
+	byType := map[string]metric.Proposal{}
+	for _, proposal := range proposals {
+		if proposal.Status != metric.PROPOSAL_PENDING || proposal.RequiredApprovals != 1 {
+			t.Fatalf("Expected migrated proposals to be pending with one required approval, got %+v", proposal)
+		}
+		byType[proposal.Type] = proposal
+	}
+	if targetQty := byType[metric.TARGET_QTY_PROPOSAL]; targetQty.ID != 1000 || targetQty.Data != "KNC_1_2_3_4" || targetQty.Params["type"] != "1" {
+		t.Fatalf("Expected the pending target quantity to be migrated, got %+v", targetQty)
+	}
+	if equation := byType[metric.PWI_EQUATION_PROPOSAL]; equation.ID != 2000 || equation.Data != "KNC_1_2_3" {
+		t.Fatalf("Expected the pending PWI equation to be migrated, got %+v", equation)
+	}
+	if params := byType[metric.STABLE_TOKEN_PARAMS_PROPOSAL]; params.Data != `{"DGX":{"AskSpread":50}}` {
+		t.Fatalf("Expected the pending stable token params to be migrated, got %+v", params)
+	}
+	err = storage.db.View(func(tx *bolt.Tx) error {
+		for bucket := range pending {
+			if tx.Bucket([]byte(bucket)) != nil {
+				return fmt.Errorf("bucket %s is not deleted", bucket)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected old pending buckets to be deleted: %v", err)
+	}
+}
@@ -0,0 +1,932 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	_ "github.com/lib/pq"
+)
+
+// PostgresStorage is a PostgreSQL backed storage implementing the same
+// interfaces as BoltStorage. Unlike BoltStorage the database can be shared
+// by several core replicas.
+// Updates are notified to subscribers of the replica storing them only.
+type PostgresStorage struct {
+	db   *sql.DB
+	feed *common.UpdateFeed
+}
+
+func NewPostgresStorage(dataSource string) (*PostgresStorage, error) {
+	db, err := sql.Open("postgres", dataSource)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	if err = migratePostgres(db); err != nil {
+		return nil, err
+	}
+	return &PostgresStorage{db, common.NewUpdateFeed()}, nil
+}
+
+// bigint converts a timepoint to a BIGINT column value, timepoints beyond
+// BIGINT range (eg. MAX_TIMESPOT) mean the latest data
+func bigint(timepoint uint64) int64 {
+	if timepoint > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(timepoint)
+}
+
+func (self *PostgresStorage) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (self *PostgresStorage) currentVersion(table string, timepoint uint64) (common.Version, error) {
+	var version int64
+	err := self.db.QueryRow(
+		fmt.Sprintf(`SELECT timepoint FROM %s WHERE timepoint <= $1 ORDER BY timepoint DESC LIMIT 1`, table),
+		bigint(timepoint),
+	).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("There is no data before timepoint %d", timepoint)
+	}
+	return common.Version(version), err
+}
+
+func (self *PostgresStorage) getVersion(table string, version common.Version, result interface{}) error {
+	var data []byte
+	err := self.db.QueryRow(
+		fmt.Sprintf(`SELECT data FROM %s WHERE timepoint = $1`, table),
+		bigint(uint64(version)),
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("version %d doesn't exist", version)
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// getLatest reads the latest version of table, it returns sql.ErrNoRows if
+// the table is empty
+func (self *PostgresStorage) getLatest(table string, result interface{}) error {
+	var data []byte
+	err := self.db.QueryRow(
+		fmt.Sprintf(`SELECT data FROM %s ORDER BY timepoint DESC LIMIT 1`, table),
+	).Scan(&data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func insertVersion(tx *sql.Tx, table string, timepoint uint64, data interface{}) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		fmt.Sprintf(`INSERT INTO %s (timepoint, data) VALUES ($1, $2)
+			ON CONFLICT (timepoint) DO UPDATE SET data = EXCLUDED.data`, table),
+		bigint(timepoint), string(dataJSON),
+	)
+	return err
+}
+
+// storeVersion stores data as version timepoint of table, only the latest
+// MAX_NUMBER_VERSION versions are kept if prune is true
+func (self *PostgresStorage) storeVersion(table string, timepoint uint64, data interface{}, prune bool) error {
+	return self.inTx(func(tx *sql.Tx) error {
+		if err := insertVersion(tx, table, timepoint, data); err != nil {
+			return err
+		}
+		if !prune {
+			return nil
+		}
+		_, err := tx.Exec(
+			fmt.Sprintf(`DELETE FROM %s WHERE timepoint <= (
+				SELECT timepoint FROM %s ORDER BY timepoint DESC OFFSET $1 LIMIT 1)`, table, table),
+			MAX_NUMBER_VERSION,
+		)
+		return err
+	})
+}
+
+// Subscribe returns a channel notifying every new price, rate and auth data
+// version once it is committed
+func (self *PostgresStorage) Subscribe() <-chan common.DataUpdate {
+	return self.feed.Subscribe()
+}
+
+func (self *PostgresStorage) Unsubscribe(ch <-chan common.DataUpdate) {
+	self.feed.Unsubscribe(ch)
+}
+
+func (self *PostgresStorage) CurrentGoldInfoVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("gold_feeds", timepoint)
+}
+
+func (self *PostgresStorage) GetGoldInfo(version common.Version) (common.GoldData, error) {
+	result := common.GoldData{}
+	err := self.getVersion("gold_feeds", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreGoldInfo(data common.GoldData) error {
+	return self.storeVersion("gold_feeds", data.Timestamp, data, false)
+}
+
+func (self *PostgresStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("prices", timepoint)
+}
+
+func (self *PostgresStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	result := common.AllPriceEntry{}
+	err := self.getVersion("prices", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetOnePrice(pair common.TokenPairID, version common.Version) (common.OnePrice, error) {
+	prices, err := self.GetAllPrices(version)
+	if err != nil {
+		return common.OnePrice{}, err
+	}
+	price, exist := prices.Data[pair]
+	if !exist {
+		return common.OnePrice{}, errors.New("Pair of token is not supported")
+	}
+	return price, nil
+}
+
+func (self *PostgresStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
+	err := self.storeVersion("prices", timepoint, data, true)
+	if err == nil {
+		self.feed.Publish(common.PRICE_UPDATE, common.Version(timepoint))
+	}
+	return err
+}
+
+func (self *PostgresStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("auth_data", timepoint)
+}
+
+func (self *PostgresStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	result := common.AuthDataSnapshot{}
+	err := self.getVersion("auth_data", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error {
+	err := self.storeVersion("auth_data", timepoint, data, false)
+	if err == nil {
+		self.feed.Publish(common.AUTH_DATA_UPDATE, common.Version(timepoint))
+	}
+	return err
+}
+
+func (self *PostgresStorage) CurrentRateVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("rates", timepoint)
+}
+
+func (self *PostgresStorage) GetRate(version common.Version) (common.AllRateEntry, error) {
+	result := common.AllRateEntry{}
+	err := self.getVersion("rates", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetRates(fromTime, toTime uint64) ([]common.AllRateEntry, error) {
+	result := []common.AllRateEntry{}
+	if toTime-fromTime > MAX_GET_RATES_PERIOD {
+		return result, fmt.Errorf("Time range is too broad, it must be smaller or equal to %d miliseconds", MAX_GET_RATES_PERIOD)
+	}
+	rows, err := self.db.Query(
+		`SELECT data FROM rates WHERE timepoint >= $1 AND timepoint <= $2 ORDER BY timepoint DESC`,
+		bigint(fromTime), bigint(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		rate := common.AllRateEntry{}
+		if err = json.Unmarshal(data, &rate); err != nil {
+			return result, err
+		}
+		result = append(result, rate)
+	}
+	return result, rows.Err()
+}
+
+// StoreRate stores rates only if they are of a newer block than the latest
+// stored rates
+func (self *PostgresStorage) StoreRate(data common.AllRateEntry, timepoint uint64) error {
+	stored := false
+	err := self.inTx(func(tx *sql.Tx) error {
+		// serialize rate writers so two replicas cannot both pass the
+		// block check
+		if _, err := tx.Exec(`LOCK TABLE rates IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var lastBlock int64
+		err := tx.QueryRow(`SELECT block_number FROM rates ORDER BY timepoint DESC LIMIT 1`).Scan(&lastBlock)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if uint64(lastBlock) >= data.BlockNumber {
+			return nil
+		}
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO rates (timepoint, block_number, data) VALUES ($1, $2, $3)
+			ON CONFLICT (timepoint) DO UPDATE SET block_number = EXCLUDED.block_number, data = EXCLUDED.data`,
+			bigint(timepoint), bigint(data.BlockNumber), string(dataJSON),
+		)
+		stored = err == nil
+		return err
+	})
+	if err == nil && stored {
+		self.feed.Publish(common.RATE_UPDATE, common.Version(timepoint))
+	}
+	return err
+}
+
+func (self *PostgresStorage) CurrentOrderVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("orders", timepoint)
+}
+
+func (self *PostgresStorage) GetOrders(version common.Version) (common.AllOrderEntry, error) {
+	result := common.AllOrderEntry{}
+	err := self.getVersion("orders", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreOrder(data common.AllOrderEntry, timepoint uint64) error {
+	return self.storeVersion("orders", timepoint, data, true)
+}
+
+func (self *PostgresStorage) Record(
+	action string,
+	id common.ActivityID,
+	destination string,
+	params map[string]interface{}, result map[string]interface{},
+	estatus string,
+	mstatus string,
+	timepoint uint64) error {
+
+	record := common.ActivityRecord{
+		Action:         action,
+		ID:             id,
+		Destination:    destination,
+		Params:         params,
+		Result:         result,
+		ExchangeStatus: estatus,
+		MiningStatus:   mstatus,
+		Timestamp:      common.Timestamp(strconv.FormatUint(timepoint, 10)),
+	}
+	dataJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// an activity stays pending until it is updated to a non pending
+	// state, recording it again does not change that
+	_, err = self.db.Exec(
+		`INSERT INTO activities (timepoint, eid, action, destination, pending, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (timepoint, eid) DO UPDATE SET
+			action = EXCLUDED.action,
+			destination = EXCLUDED.destination,
+			pending = activities.pending OR EXCLUDED.pending,
+			data = EXCLUDED.data`,
+		bigint(id.Timepoint), id.EID, action, destination, record.IsPending(), string(dataJSON),
+	)
+	return err
+}
+
+func scanActivities(rows *sql.Rows) ([]common.ActivityRecord, error) {
+	defer rows.Close()
+	result := []common.ActivityRecord{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return result, err
+		}
+		record := common.ActivityRecord{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) GetActivity(id common.ActivityID) (common.ActivityRecord, error) {
+	result := common.ActivityRecord{}
+	var data []byte
+	err := self.db.QueryRow(
+		`SELECT data FROM activities WHERE timepoint = $1 AND eid = $2`,
+		bigint(id.Timepoint), id.EID,
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, errors.New("Cannot find that activity")
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	if (toTime-fromTime)/1000000 > MAX_GET_RATES_PERIOD {
+		return []common.ActivityRecord{}, fmt.Errorf("Time range is too broad, it must be smaller or equal to %d miliseconds", MAX_GET_RATES_PERIOD)
+	}
+	// zero fromTime or toTime leaves that side of the range open
+	if toTime == 0 {
+		toTime = math.MaxInt64
+	}
+	rows, err := self.db.Query(
+		`SELECT data FROM activities WHERE timepoint >= $1 AND timepoint <= $2
+		ORDER BY timepoint DESC, eid DESC`,
+		bigint(fromTime), bigint(toTime),
+	)
+	if err != nil {
+		return []common.ActivityRecord{}, err
+	}
+	return scanActivities(rows)
+}
+
+func (self *PostgresStorage) PendingSetrate(minedNonce uint64) (*common.ActivityRecord, error) {
+	pendings, err := self.GetPendingActivities()
+	if err != nil {
+		return nil, err
+	}
+	return getLastPendingSetrate(pendings, minedNonce)
+}
+
+func (self *PostgresStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	rows, err := self.db.Query(
+		`SELECT data FROM activities WHERE pending ORDER BY timepoint DESC, eid DESC`,
+	)
+	if err != nil {
+		return []common.ActivityRecord{}, err
+	}
+	return scanActivities(rows)
+}
+
+func (self *PostgresStorage) UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error {
+	dataJSON, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	// an activity which is not pending anymore never becomes pending
+	// again, it might have been replaced by another activity
+	_, err = self.db.Exec(
+		`INSERT INTO activities (timepoint, eid, action, destination, pending, data)
+		VALUES ($1, $2, $3, $4, FALSE, $5)
+		ON CONFLICT (timepoint, eid) DO UPDATE SET
+			action = EXCLUDED.action,
+			destination = EXCLUDED.destination,
+			pending = activities.pending AND $6,
+			data = EXCLUDED.data`,
+		bigint(id.Timepoint), id.EID, activity.Action, activity.Destination, string(dataJSON), activity.IsPending(),
+	)
+	return err
+}
+
+func (self *PostgresStorage) HasPendingDeposit(token common.Token, exchange common.Exchange) (bool, error) {
+	rows, err := self.db.Query(
+		`SELECT data FROM activities WHERE pending AND action = 'deposit' AND destination = $1`,
+		string(exchange.ID()),
+	)
+	if err != nil {
+		return false, err
+	}
+	deposits, err := scanActivities(rows)
+	if err != nil {
+		return false, err
+	}
+	for _, deposit := range deposits {
+		if tokenID, ok := deposit.Params["token"].(string); ok && tokenID == token.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (self *PostgresStorage) StoreMetric(data *metric.MetricEntry, timepoint uint64) error {
+	return self.storeVersion("metrics", data.Timestamp, data, false)
+}
+
+func (self *PostgresStorage) GetMetric(tokens []common.Token, fromTime, toTime uint64) (map[string]metric.MetricList, error) {
+	result := map[string]metric.MetricList{}
+	for _, tok := range tokens {
+		result[tok.ID] = metric.MetricList{}
+	}
+	rows, err := self.db.Query(
+		`SELECT data FROM metrics WHERE timepoint >= $1 AND timepoint <= $2 ORDER BY timepoint`,
+		bigint(fromTime), bigint(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		entry := metric.MetricEntry{}
+		if err = json.Unmarshal(data, &entry); err != nil {
+			return result, err
+		}
+		for tok, m := range entry.Data {
+			metricList, found := result[tok]
+			if found {
+				result[tok] = append(metricList, metric.TokenMetricResponse{
+					Timestamp: entry.Timestamp,
+					AfpMid:    m.AfpMid,
+					Spread:    m.Spread,
+				})
+			}
+		}
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) CurrentTargetQtyVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("target_quantity", timepoint)
+}
+
+func (self *PostgresStorage) GetTokenTargetQty() (metric.TokenTargetQty, error) {
+	result := metric.TokenTargetQty{}
+	version, err := self.CurrentTargetQtyVersion(common.GetTimepoint())
+	if err != nil {
+		return result, err
+	}
+	err = self.getVersion("target_quantity", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreTokenTargetQty(targetQty metric.TokenTargetQty) error {
+	return self.storeVersion("target_quantity", common.GetTimepoint(), targetQty, false)
+}
+
+func (self *PostgresStorage) GetTradeHistory(timepoint uint64) (common.AllTradeHistory, error) {
+	result := common.AllTradeHistory{}
+	var data []byte
+	err := self.db.QueryRow(`SELECT data FROM trade_history WHERE id = 1`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, fmt.Errorf("There no data before timepoint %d", timepoint)
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// StoreTradeHistory merges data into the stored trade history, histories of
+// pairs in data replace the stored ones
+func (self *PostgresStorage) StoreTradeHistory(data common.AllTradeHistory, timepoint uint64) error {
+	return self.inTx(func(tx *sql.Tx) error {
+		currentData := common.AllTradeHistory{
+			Timestamp: common.GetTimestamp(),
+			Data:      map[common.ExchangeID]common.ExchangeTradeHistory{},
+		}
+		var current []byte
+		err := tx.QueryRow(`SELECT data FROM trade_history WHERE id = 1 FOR UPDATE`).Scan(&current)
+		if err == nil {
+			if err = json.Unmarshal(current, &currentData); err != nil {
+				return err
+			}
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		for exchange, dataHistory := range data.Data {
+			currentDataHistory, exist := currentData.Data[exchange]
+			if !exist {
+				currentData.Data[exchange] = dataHistory
+			} else {
+				for pair, pairHistory := range dataHistory {
+					currentDataHistory[pair] = pairHistory
+				}
+			}
+		}
+		dataJSON, err := json.Marshal(currentData)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO trade_history (id, timepoint, data) VALUES (1, $1, $2)
+			ON CONFLICT (id) DO UPDATE SET timepoint = EXCLUDED.timepoint, data = EXCLUDED.data`,
+			bigint(timepoint), string(dataJSON),
+		)
+		return err
+	})
+}
+
+func (self *PostgresStorage) getControl(name string, result interface{}) (bool, error) {
+	var data []byte
+	err := self.db.QueryRow(`SELECT data FROM controls WHERE name = $1`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, result)
+}
+
+func (self *PostgresStorage) storeControl(name string, data interface{}) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO controls (name, data) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`,
+		name, string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetRebalanceControl() (metric.RebalanceControl, error) {
+	result := metric.RebalanceControl{}
+	found, err := self.getControl(ENABLE_REBALANCE, &result)
+	if err == nil && !found {
+		result.Status = false
+		err = self.StoreRebalanceControl(false)
+	}
+	return result, err
+}
+
+func (self *PostgresStorage) StoreRebalanceControl(status bool) error {
+	return self.storeControl(ENABLE_REBALANCE, metric.RebalanceControl{Status: status})
+}
+
+func (self *PostgresStorage) GetSetrateControl() (metric.SetrateControl, error) {
+	result := metric.SetrateControl{}
+	found, err := self.getControl(SETRATE_CONTROL, &result)
+	if err == nil && !found {
+		result.Status = false
+		err = self.StoreSetrateControl(false)
+	}
+	return result, err
+}
+
+func (self *PostgresStorage) StoreSetrateControl(status bool) error {
+	return self.storeControl(SETRATE_CONTROL, metric.SetrateControl{Status: status})
+}
+
+func (self *PostgresStorage) StorePWIEquation(equation metric.PWIEquation) error {
+	return self.storeVersion("pwi_equation", common.GetTimepoint(), equation, false)
+}
+
+func (self *PostgresStorage) GetPWIEquation() (metric.PWIEquation, error) {
+	result := metric.PWIEquation{}
+	err := self.getLatest("pwi_equation", &result)
+	if err == sql.ErrNoRows {
+		return result, errors.New("There is no equation")
+	}
+	return result, err
+}
+
+func (self *PostgresStorage) GetExchangeStatus() (common.ExchangesStatus, error) {
+	result := common.ExchangesStatus{}
+	err := self.getLatest("exchange_status", &result)
+	if err == sql.ErrNoRows {
+		return result, errors.New("There is no data yet.")
+	}
+	return result, err
+}
+
+func (self *PostgresStorage) UpdateExchangeStatus(data common.ExchangesStatus) error {
+	return self.storeVersion("exchange_status", common.GetTimepoint(), data, false)
+}
+
+func (self *PostgresStorage) UpdateOneExchangeStatus(exchange string, update func(current common.ExStatus, found bool) (common.ExStatus, bool)) error {
+	return self.inTx(func(tx *sql.Tx) error {
+		// concurrent updates of other exchanges wait for this one so none
+		// of them is lost
+		if _, err := tx.Exec(`LOCK TABLE exchange_status IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		data := common.ExchangesStatus{}
+		var current []byte
+		err := tx.QueryRow(`SELECT data FROM exchange_status ORDER BY timepoint DESC LIMIT 1`).Scan(&current)
+		if err == nil {
+			if err = json.Unmarshal(current, &data); err != nil {
+				return err
+			}
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		status, found := data[exchange]
+		status, changed := update(status, found)
+		if !changed {
+			return nil
+		}
+		data[exchange] = status
+		return insertVersion(tx, "exchange_status", common.GetTimepoint(), data)
+	})
+}
+
+func (self *PostgresStorage) UpdateExchangeNotification(
+	exchange, action, token string, fromTime, toTime uint64, isWarning bool, msg string) error {
+	noti := common.ExchangeNotiContent{
+		FromTime:  fromTime,
+		ToTime:    toTime,
+		IsWarning: isWarning,
+		Message:   msg,
+	}
+	dataJSON, err := json.Marshal(noti)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO exchange_notifications (exchange, action, token, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (exchange, action, token) DO UPDATE SET data = EXCLUDED.data`,
+		exchange, action, token, string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetExchangeNotifications() (common.ExchangeNotifications, error) {
+	result := common.ExchangeNotifications{}
+	rows, err := self.db.Query(`SELECT exchange, action, token, data FROM exchange_notifications`)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var exchange, action, token string
+		var data []byte
+		if err = rows.Scan(&exchange, &action, &token, &data); err != nil {
+			return result, err
+		}
+		notiContent := common.ExchangeNotiContent{}
+		if err = json.Unmarshal(data, &notiContent); err != nil {
+			return result, err
+		}
+		actionContent, exist := result[exchange]
+		if !exist {
+			actionContent = common.ExchangeActionNoti{}
+			result[exchange] = actionContent
+		}
+		tokenContent, exist := actionContent[action]
+		if !exist {
+			tokenContent = common.ExchangeTokenNoti{}
+			actionContent[action] = tokenContent
+		}
+		tokenContent[token] = notiContent
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreStableTokenParams(value []byte) error {
+	temp := make(map[string]interface{})
+	if vErr := json.Unmarshal(value, &temp); vErr != nil {
+		return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", vErr)
+	}
+	_, err := self.db.Exec(
+		`INSERT INTO stable_token_params (pending, data) VALUES (FALSE, $1)
+		ON CONFLICT (pending) DO UPDATE SET data = EXCLUDED.data`,
+		string(value),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetStableTokenParams() (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	var data []byte
+	err := self.db.QueryRow(`SELECT data FROM stable_token_params WHERE NOT pending`).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreProposal(proposal metric.Proposal) error {
+	dataJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO proposals (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`,
+		bigint(proposal.ID), string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetProposal(id uint64) (metric.Proposal, error) {
+	result := metric.Proposal{}
+	var data []byte
+	err := self.db.QueryRow(`SELECT data FROM proposals WHERE id = $1`, bigint(id)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, fmt.Errorf("Proposal %d is not found", id)
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// GetProposals returns all proposals ordered by id
+func (self *PostgresStorage) GetProposals() ([]metric.Proposal, error) {
+	result := []metric.Proposal{}
+	rows, err := self.db.Query(`SELECT data FROM proposals ORDER BY id`)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		proposal := metric.Proposal{}
+		if err = json.Unmarshal(data, &proposal); err != nil {
+			return result, err
+		}
+		result = append(result, proposal)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreAuditRecord(record common.AuditRecord) error {
+	dataJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO audit_logs (timepoint, data) VALUES ($1, $2)`,
+		bigint(record.Timestamp), string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetAuditRecords(fromTime, toTime uint64) ([]common.AuditRecord, error) {
+	result := []common.AuditRecord{}
+	rows, err := self.db.Query(
+		`SELECT data FROM audit_logs WHERE timepoint BETWEEN $1 AND $2 ORDER BY timepoint, id`,
+		bigint(fromTime), bigint(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		record := common.AuditRecord{}
+		if err = json.Unmarshal(data, &record); err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreAPIKey(key common.APIKey) error {
+	dataJSON, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO api_keys (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`,
+		key.ID, string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetAPIKey(id string) (common.APIKey, error) {
+	result := common.APIKey{}
+	var data []byte
+	err := self.db.QueryRow(`SELECT data FROM api_keys WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, fmt.Errorf("Key %s is not found", id)
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetAPIKeys() ([]common.APIKey, error) {
+	result := []common.APIKey{}
+	rows, err := self.db.Query(`SELECT data FROM api_keys ORDER BY id`)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		key := common.APIKey{}
+		if err = json.Unmarshal(data, &key); err != nil {
+			return result, err
+		}
+		result = append(result, key)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreNonceState(state common.NonceState) error {
+	dataJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO nonce_states (address, data) VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET data = EXCLUDED.data`,
+		strings.ToLower(state.Address), string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetNonceState(address ethereum.Address) (common.NonceState, error) {
+	result := common.NonceState{Address: address.Hex(), Allocated: map[uint64]uint64{}}
+	var data []byte
+	err := self.db.QueryRow(
+		`SELECT data FROM nonce_states WHERE address = $1`,
+		strings.ToLower(address.Hex()),
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StoreStableExOrder(id string, order common.StableExOrder) error {
+	dataJSON, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO stable_exchange_orders (id, data) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`,
+		id, string(dataJSON),
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetStableExOrders() (map[string]common.StableExOrder, error) {
+	result := map[string]common.StableExOrder{}
+	rows, err := self.db.Query(`SELECT id, data FROM stable_exchange_orders`)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err = rows.Scan(&id, &data); err != nil {
+			return result, err
+		}
+		order := common.StableExOrder{}
+		if err = json.Unmarshal(data, &order); err != nil {
+			return result, err
+		}
+		result[id] = order
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) RemoveStableExOrder(id string) error {
+	_, err := self.db.Exec(`DELETE FROM stable_exchange_orders WHERE id = $1`, id)
+	return err
+}
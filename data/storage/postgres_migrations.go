@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// postgresMigrations are applied in order and each of them is applied only
+// once, the version of a database is the number of applied migrations.
+// Applied migrations must never be changed, schema changes go to new
+// migrations appended to the list.
+var postgresMigrations = []string{
+	// 1: initial schema
+	`
+CREATE TABLE gold_feeds (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE prices (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE rates (
+	timepoint    BIGINT PRIMARY KEY,
+	block_number BIGINT NOT NULL,
+	data         TEXT NOT NULL
+);
+CREATE TABLE orders (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE auth_data (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE activities (
+	timepoint   BIGINT NOT NULL,
+	eid         TEXT NOT NULL,
+	action      TEXT NOT NULL,
+	destination TEXT NOT NULL,
+	pending     BOOLEAN NOT NULL,
+	data        TEXT NOT NULL,
+	PRIMARY KEY (timepoint, eid)
+);
+CREATE INDEX activities_pending_idx ON activities (pending) WHERE pending;
+CREATE TABLE metrics (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE target_quantity (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE pending_target_quantity (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE trade_history (
+	id        INTEGER PRIMARY KEY CHECK (id = 1),
+	timepoint BIGINT NOT NULL,
+	data      TEXT NOT NULL
+);
+CREATE TABLE controls (
+	name TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE pwi_equation (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE pending_pwi_equation (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE exchange_status (
+	timepoint BIGINT PRIMARY KEY,
+	data      TEXT NOT NULL
+);
+CREATE TABLE exchange_notifications (
+	exchange TEXT NOT NULL,
+	action   TEXT NOT NULL,
+	token    TEXT NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (exchange, action, token)
+);
+CREATE TABLE stable_token_params (
+	pending BOOLEAN PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+`,
+	// 2: api keys
+	`
+CREATE TABLE api_keys (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+`,
+	// 3: configuration proposals replace pending configurations
+	`
+CREATE TABLE proposals (
+	id   BIGINT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+DROP TABLE pending_target_quantity;
+DROP TABLE pending_pwi_equation;
+DELETE FROM stable_token_params WHERE pending;
+`,
+	// 4: audit log of authenticated requests
+	`
+CREATE TABLE audit_logs (
+	id        BIGSERIAL PRIMARY KEY,
+	timepoint BIGINT NOT NULL,
+	data      TEXT NOT NULL
+);
+CREATE INDEX audit_logs_timepoint_idx ON audit_logs (timepoint);
+`,
+	// 5: nonces allocated to operators
+	`
+CREATE TABLE nonce_states (
+	address TEXT PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+`,
+	// 6: pending orders of the stable exchange
+	`
+CREATE TABLE stable_exchange_orders (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+`,
+}
+
+// migratePostgres brings the database schema up to date. The migration
+// table is locked during the migration so replicas starting at the same
+// time do not apply a migration twice.
+func migratePostgres(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	var version int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	for ; version < len(postgresMigrations); version++ {
+		if _, err = tx.Exec(postgresMigrations[version]); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
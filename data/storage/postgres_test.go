@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+// Postgres tests run against the database in KYBER_TEST_POSTGRES_DSN env,
+// eg. "postgres://postgres@localhost/reserve_test?sslmode=disable".
+// All tables of the database are dropped.
+func newTestPostgresStorage(t *testing.T) *PostgresStorage {
+	dataSource := os.Getenv("KYBER_TEST_POSTGRES_DSN")
+	if dataSource == "" {
+		t.Skip("KYBER_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dataSource)
+	if err != nil {
+		t.Fatalf("Couldn't connect to postgres: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`)
+	if err != nil {
+		t.Fatalf("Couldn't reset postgres database: %v", err)
+	}
+	storage, err := NewPostgresStorage(dataSource)
+	if err != nil {
+		t.Fatalf("Couldn't init postgres storage: %v", err)
+	}
+	return storage
+}
+
+func TestPostgresStorageMigration(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	// migrating an up to date database does nothing
+	if err := migratePostgres(storage.db); err != nil {
+		t.Fatalf("Couldn't migrate again: %v", err)
+	}
+	var version int
+	if err := storage.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(postgresMigrations) {
+		t.Fatalf("Expected schema version %d, got %d", len(postgresMigrations), version)
+	}
+}
+
+func TestPostgresStorageVersions(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	updates := storage.Subscribe()
+	defer storage.Unsubscribe(updates)
+
+	if _, err := storage.CurrentPriceVersion(1000); err == nil {
+		t.Fatalf("Expected error when there is no price")
+	}
+	pair := common.NewTokenPairID("OMG", "ETH")
+	prices := common.AllPriceEntry{
+		Block: 1,
+		Data:  map[common.TokenPairID]common.OnePrice{pair: {}},
+	}
+	if err := storage.StorePrice(prices, 1000); err != nil {
+		t.Fatalf("Couldn't store price: %v", err)
+	}
+	if err := storage.StorePrice(prices, 2000); err != nil {
+		t.Fatalf("Couldn't store price: %v", err)
+	}
+	version, err := storage.CurrentPriceVersion(1500)
+	if err != nil || version != 1000 {
+		t.Fatalf("Expected price version 1000, got %d, error: %v", version, err)
+	}
+	version, err = storage.CurrentPriceVersion(18446744073709551615)
+	if err != nil || version != 2000 {
+		t.Fatalf("Expected latest price version 2000, got %d, error: %v", version, err)
+	}
+	if _, err = storage.GetOnePrice(pair, version); err != nil {
+		t.Fatalf("Couldn't get price of %s: %v", pair, err)
+	}
+	if _, err = storage.GetAllPrices(1500); err == nil {
+		t.Fatalf("Expected error getting a version which doesn't exist")
+	}
+
+	if err = storage.StoreRate(common.AllRateEntry{BlockNumber: 10}, 1000); err != nil {
+		t.Fatalf("Couldn't store rate: %v", err)
+	}
+	// rates of an older block are ignored
+	if err = storage.StoreRate(common.AllRateEntry{BlockNumber: 9}, 2000); err != nil {
+		t.Fatalf("Couldn't store rate: %v", err)
+	}
+	rates, err := storage.GetRates(0, 3000)
+	if err != nil || len(rates) != 1 || rates[0].BlockNumber != 10 {
+		t.Fatalf("Expected rates of block 10 only, got %+v, error: %v", rates, err)
+	}
+
+	expected := []common.DataUpdate{
+		{Topic: common.PRICE_UPDATE, Version: 1000},
+		{Topic: common.PRICE_UPDATE, Version: 2000},
+		{Topic: common.RATE_UPDATE, Version: 1000},
+	}
+	for _, e := range expected {
+		if update := <-updates; update != e {
+			t.Fatalf("Expected update %+v, got %+v", e, update)
+		}
+	}
+}
+
+func TestPostgresStorageActivities(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	token := common.Token{ID: "OMG", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	exchange := common.TestExchange{}
+	id := common.NewActivityID(1000, "deposit_1")
+	err := storage.Record(
+		"deposit",
+		id,
+		string(exchange.ID()),
+		map[string]interface{}{"token": token.ID, "amount": "1.0"},
+		map[string]interface{}{"tx": "", "error": nil},
+		"",
+		"submitted",
+		1000,
+	)
+	if err != nil {
+		t.Fatalf("Couldn't record activity: %v", err)
+	}
+	pending, err := storage.HasPendingDeposit(token, exchange)
+	if err != nil || !pending {
+		t.Fatalf("Expected pending deposit, got %t, error: %v", pending, err)
+	}
+	activity, err := storage.GetActivity(id)
+	if err != nil {
+		t.Fatalf("Couldn't get activity: %v", err)
+	}
+	activity.MiningStatus = "mined"
+	activity.ExchangeStatus = "done"
+	if err = storage.UpdateActivity(id, activity); err != nil {
+		t.Fatalf("Couldn't update activity: %v", err)
+	}
+	pendings, err := storage.GetPendingActivities()
+	if err != nil || len(pendings) != 0 {
+		t.Fatalf("Expected no pending activity, got %+v, error: %v", pendings, err)
+	}
+	records, err := storage.GetAllRecords(0, 0)
+	if err != nil || len(records) != 1 || records[0].MiningStatus != "mined" {
+		t.Fatalf("Expected the updated activity, got %+v, error: %v", records, err)
+	}
+}
+
+func TestPostgresStorageConfigData(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	if err := storage.StoreTokenTargetQty(metric.TokenTargetQty{ID: 1, Data: "OMG_1_2_3", Status: "confirmed", Type: 1}); err != nil {
+		t.Fatalf("Couldn't store target quantity: %v", err)
+	}
+	target, err := storage.GetTokenTargetQty()
+	if err != nil || target.Status != "confirmed" || target.Data != "OMG_1_2_3" {
+		t.Fatalf("Expected confirmed target quantity, got %+v, error: %v", target, err)
+	}
+
+	proposal := metric.Proposal{ID: 10, Type: metric.PWI_EQUATION_PROPOSAL, Data: "OMG_1_2_3", Status: metric.PROPOSAL_PENDING}
+	if err = storage.StoreProposal(proposal); err != nil {
+		t.Fatalf("Couldn't store proposal: %v", err)
+	}
+	proposal.Status = metric.PROPOSAL_REJECTED
+	if err = storage.StoreProposal(proposal); err != nil {
+		t.Fatalf("Couldn't update proposal: %v", err)
+	}
+	if stored, err := storage.GetProposal(10); err != nil || stored.Status != metric.PROPOSAL_REJECTED {
+		t.Fatalf("Expected the updated proposal, got %+v, error: %v", stored, err)
+	}
+	if proposals, err := storage.GetProposals(); err != nil || len(proposals) != 1 {
+		t.Fatalf("Expected one proposal, got %+v, error: %v", proposals, err)
+	}
+
+	control, err := storage.GetRebalanceControl()
+	if err != nil || control.Status {
+		t.Fatalf("Expected rebalance to be disabled by default, got %+v, error: %v", control, err)
+	}
+	if err = storage.StoreRebalanceControl(true); err != nil {
+		t.Fatalf("Couldn't store rebalance control: %v", err)
+	}
+	if control, err = storage.GetRebalanceControl(); err != nil || !control.Status {
+		t.Fatalf("Expected rebalance to be enabled, got %+v, error: %v", control, err)
+	}
+
+	params := []byte(`{"DGX":{"AskSpread":10,"BidSpread":10}}`)
+	if err = storage.StoreStableTokenParams([]byte(`{"DGX":`)); err == nil {
+		t.Fatalf("Expected error storing malformed stable token params")
+	}
+	if err = storage.StoreStableTokenParams(params); err != nil {
+		t.Fatalf("Couldn't store stable token params: %v", err)
+	}
+	confirmed, err := storage.GetStableTokenParams()
+	if err != nil || len(confirmed) != 1 {
+		t.Fatalf("Expected confirmed stable token params, got %+v, error: %v", confirmed, err)
+	}
+
+	if err = storage.UpdateExchangeNotification("binance", "withdraw", "OMG", 1, 2, true, "failed"); err != nil {
+		t.Fatalf("Couldn't update exchange notification: %v", err)
+	}
+	notifications, err := storage.GetExchangeNotifications()
+	if err != nil || notifications["binance"]["withdraw"]["OMG"].Message != "failed" {
+		t.Fatalf("Expected exchange notification, got %+v, error: %v", notifications, err)
+	}
+
+	entry := &metric.MetricEntry{Timestamp: 1000, Data: map[string]metric.TokenMetric{"OMG": {AfpMid: 1, Spread: 2}}}
+	if err = storage.StoreMetric(entry, 1000); err != nil {
+		t.Fatalf("Couldn't store metric: %v", err)
+	}
+	metrics, err := storage.GetMetric([]common.Token{{ID: "OMG"}}, 0, 2000)
+	if err != nil || len(metrics["OMG"]) != 1 {
+		t.Fatalf("Expected one OMG metric, got %+v, error: %v", metrics, err)
+	}
+}
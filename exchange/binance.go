@@ -26,6 +26,13 @@ type Binance struct {
 	exchangeInfo *common.ExchangeInfo
 	fees         common.ExchangeFees
 	minDeposit   common.ExchangesMinDeposit
+	stream       OrderbookStream
+}
+
+// SetOrderbookStream makes FetchPriceData serve orderbooks from stream,
+// pairs whose local orderbooks are out of sync are still polled
+func (self *Binance) SetOrderbookStream(stream OrderbookStream) {
+	self.stream = stream
 }
 
 func (self *Binance) TokenAddresses() map[string]ethereum.Address {
@@ -245,7 +252,8 @@ func (self *Binance) FetchOnePairData(
 func (self *Binance) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
-	pairs := self.pairs
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	pairs := fetchStreamPriceData(self.stream, self.pairs, result, timepoint)
 	var i int = 0
 	var x int = 0
 	for i < len(pairs) {
@@ -257,7 +265,6 @@ func (self *Binance) FetchPriceData(timepoint uint64) (map[common.TokenPairID]co
 		wait.Wait()
 		i = x
 	}
-	result := map[common.TokenPairID]common.ExchangePrice{}
 	data.Range(func(key, value interface{}) bool {
 		result[key.(common.TokenPairID)] = value.(common.ExchangePrice)
 		return true
@@ -300,39 +307,50 @@ func (self *Binance) OpenOrdersForOnePair(
 		data.Store(pair.PairID(), orders)
 	} else {
 		log.Printf("Unsuccessful response from Binance: %s", err)
+		data.Store(pair.PairID(), err)
 	}
 }
 
-// func (self *Binance) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
-// 	result := common.OrderEntry{}
-// 	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
-// 	result.Valid = true
-// 	result.Data = []common.Order{}
-//
-// 	wait := sync.WaitGroup{}
-// 	data := sync.Map{}
-// 	pairs := self.pairs
-// 	var i int = 0
-// 	var x int = 0
-// 	for i < len(pairs) {
-// 		for x = i; x < len(pairs) && x < i+BATCH_SIZE; x++ {
-// 			wait.Add(1)
-// 			pair := pairs[x]
-// 			go self.OpenOrdersForOnePair(&wait, pair, &data, timepoint)
-// 		}
-// 		i = x
-// 		wait.Wait()
-// 	}
-//
-// 	result.ReturnTime = common.GetTimestamp()
-//
-// 	data.Range(func(key, value interface{}) bool {
-// 		orders := value.([]common.Order)
-// 		result.Data = append(result.Data, orders...)
-// 		return true
-// 	})
-// 	return result, nil
-// }
+func (self *Binance) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
+	result := common.OrderEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Data = []common.Order{}
+
+	wait := sync.WaitGroup{}
+	data := sync.Map{}
+	pairs := self.pairs
+	var i int = 0
+	var x int = 0
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+BATCH_SIZE; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.OpenOrdersForOnePair(&wait, pair, &data, timepoint)
+		}
+		i = x
+		wait.Wait()
+	}
+
+	result.ReturnTime = common.GetTimestamp()
+
+	// the snapshot misses open orders of pairs failed to be fetched
+	errs := []string{}
+	data.Range(func(key, value interface{}) bool {
+		switch value := value.(type) {
+		case error:
+			errs = append(errs, fmt.Sprintf("%s: %s", key, value))
+		case []common.Order:
+			result.Data = append(result.Data, value...)
+		}
+		return true
+	})
+	if len(errs) > 0 {
+		result.Valid = false
+		result.Error = strings.Join(errs, ", ")
+	}
+	return result, nil
+}
 
 func (self *Binance) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
@@ -496,5 +514,6 @@ func NewBinance(addressConfig map[string]string, feeConfig common.ExchangeFees,
 		common.NewExchangeInfo(),
 		fees,
 		minDeposit,
+		nil,
 	}
 }
@@ -97,12 +97,16 @@ func (self *BinanceEndpoint) GetResponse(
 }
 
 func (self *BinanceEndpoint) GetDepthOnePair(pair common.TokenPair) (exchange.Binaresp, error) {
+	return self.getDepth(pair, "50")
+}
+
+func (self *BinanceEndpoint) getDepth(pair common.TokenPair, limit string) (exchange.Binaresp, error) {
 
 	resp_body, err := self.GetResponse(
 		"GET", self.interf.PublicEndpoint()+"/api/v1/depth",
 		map[string]string{
 			"symbol": fmt.Sprintf("%s%s", pair.Base.ID, pair.Quote.ID),
-			"limit":  "50",
+			"limit":  limit,
 		},
 		false,
 		common.GetTimepoint(),
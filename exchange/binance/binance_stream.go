@@ -0,0 +1,143 @@
+package binance
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// number of levels of the snapshot a local orderbook is built from
+	STREAM_SNAPSHOT_LIMIT string = "1000"
+	// a connection without any message for this long is considered dead
+	STREAM_READ_TIMEOUT time.Duration = 60 * time.Second
+	// delay before reconnecting a dropped stream
+	STREAM_RECONNECT_DELAY time.Duration = 5 * time.Second
+)
+
+// BinanceStream maintains local orderbooks of binance pairs from binance
+// diff depth streams, one stream per pair.
+// The orderbook of a pair is built from a rest snapshot and then kept up
+// to date by the diffs, whenever a diff is missed the orderbook is resynced
+// from a new snapshot.
+type BinanceStream struct {
+	endpoint       *BinanceEndpoint
+	pairs          []common.TokenPair
+	books          map[common.TokenPairID]*exchange.Orderbook
+	reconnectDelay time.Duration
+}
+
+// Orderbook implements exchange.OrderbookStream
+func (self *BinanceStream) Orderbook(pair common.TokenPairID, timepoint uint64) (common.ExchangePrice, bool) {
+	book, found := self.books[pair]
+	if !found {
+		return common.ExchangePrice{}, false
+	}
+	return book.ExchangePrice(timepoint)
+}
+
+// Start connects streams of all pairs in background, dropped streams are
+// reconnected automatically
+func (self *BinanceStream) Start() {
+	for _, pair := range self.pairs {
+		go self.run(pair)
+	}
+}
+
+func (self *BinanceStream) run(pair common.TokenPair) {
+	book := self.books[pair.PairID()]
+	for {
+		err := self.stream(pair, book)
+		log.Printf("Binance orderbook stream of %s stopped: %s", pair.PairID(), err)
+		book.Invalidate()
+		time.Sleep(self.reconnectDelay)
+	}
+}
+
+func (self *BinanceStream) resync(pair common.TokenPair, book *exchange.Orderbook) error {
+	snapshot, err := self.endpoint.getDepth(pair, STREAM_SNAPSHOT_LIMIT)
+	if err != nil {
+		return err
+	}
+	book.Reset(
+		toPriceEntries(snapshot.Bids),
+		toPriceEntries(snapshot.Asks),
+		snapshot.LastUpdatedId,
+		common.GetTimepoint(),
+	)
+	return nil
+}
+
+// stream keeps book in sync until the connection is dropped. Diffs are
+// buffered by the connection while the snapshot is being fetched so none
+// of them is lost.
+func (self *BinanceStream) stream(pair common.TokenPair, book *exchange.Orderbook) error {
+	url := fmt.Sprintf(
+		"%s/%s@depth",
+		self.endpoint.interf.StreamEndpoint(),
+		strings.ToLower(pair.Base.ID+pair.Quote.ID),
+	)
+	conn, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = self.resync(pair, book); err != nil {
+		return err
+	}
+	// the first diff applied after a snapshot may overlap it
+	first := true
+	for {
+		if err = conn.SetReadDeadline(time.Now().Add(STREAM_READ_TIMEOUT)); err != nil {
+			return err
+		}
+		event := exchange.BinanceDepthEvent{}
+		if err = websocket.JSON.Receive(conn, &event); err != nil {
+			return err
+		}
+		lastID := book.UpdateID()
+		if event.FinalUpdateID <= lastID {
+			continue
+		}
+		if event.FirstUpdateID > lastID+1 || (!first && event.FirstUpdateID != lastID+1) {
+			log.Printf("Binance orderbook of %s missed updates %d - %d, resync", pair.PairID(), lastID+1, event.FirstUpdateID-1)
+			book.Invalidate()
+			if err = self.resync(pair, book); err != nil {
+				return err
+			}
+			first = true
+			continue
+		}
+		book.Update(
+			toPriceEntries(event.Bids),
+			toPriceEntries(event.Asks),
+			event.FinalUpdateID,
+			common.GetTimepoint(),
+		)
+		first = false
+	}
+}
+
+func toPriceEntries(levels []exchange.Binaprice) []common.PriceEntry {
+	result := []common.PriceEntry{}
+	for _, level := range levels {
+		quantity, _ := strconv.ParseFloat(level.Quantity, 64)
+		rate, _ := strconv.ParseFloat(level.Rate, 64)
+		result = append(result, common.PriceEntry{Quantity: quantity, Rate: rate})
+	}
+	return result
+}
+
+func NewBinanceStream(endpoint *BinanceEndpoint, pairs []common.TokenPair) *BinanceStream {
+	books := map[common.TokenPairID]*exchange.Orderbook{}
+	for _, pair := range pairs {
+		books[pair.PairID()] = exchange.NewOrderbook()
+	}
+	return &BinanceStream{endpoint, pairs, books, STREAM_RECONNECT_DELAY}
+}
@@ -0,0 +1,122 @@
+package binance
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"golang.org/x/net/websocket"
+)
+
+type testStreamInterface struct {
+	url string
+}
+
+func (self testStreamInterface) PublicEndpoint() string {
+	return self.url
+}
+
+func (self testStreamInterface) AuthenticatedEndpoint() string {
+	return self.url
+}
+
+func (self testStreamInterface) StreamEndpoint() string {
+	return strings.Replace(self.url, "http", "ws", 1) + "/ws"
+}
+
+// testBinanceServer stands in for binance, it serves depth snapshots and
+// pushes events of its events channel to the depth stream
+type testBinanceServer struct {
+	mu        sync.Mutex
+	snapshot  string
+	snapshots int
+	events    chan string
+}
+
+func (self *testBinanceServer) setSnapshot(snapshot string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.snapshot = snapshot
+}
+
+func (self *testBinanceServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/depth", func(w http.ResponseWriter, r *http.Request) {
+		self.mu.Lock()
+		defer self.mu.Unlock()
+		self.snapshots++
+		fmt.Fprint(w, self.snapshot)
+	})
+	mux.Handle("/ws/ethbtc@depth", websocket.Handler(func(conn *websocket.Conn) {
+		for event := range self.events {
+			if err := websocket.Message.Send(conn, event); err != nil {
+				return
+			}
+		}
+	}))
+	return mux
+}
+
+func waitForOrderbook(t *testing.T, stream *BinanceStream, pair common.TokenPairID, check func(common.ExchangePrice) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		price, ok := stream.Orderbook(pair, common.GetTimepoint())
+		if ok && check(price) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	price, ok := stream.Orderbook(pair, common.GetTimepoint())
+	t.Fatalf("Orderbook is not as expected, ok: %t, orderbook: %+v", ok, price)
+}
+
+func TestBinanceStream(t *testing.T) {
+	server := &testBinanceServer{
+		snapshot: `{"lastUpdateId":100,"bids":[["0.05","10"]],"asks":[["0.06","5"]]}`,
+		events:   make(chan string, 10),
+	}
+	httpServer := httptest.NewServer(server.handler())
+	defer httpServer.Close()
+	defer close(server.events)
+
+	pair := common.TokenPair{Base: common.Token{ID: "ETH"}, Quote: common.Token{ID: "BTC"}}
+	endpoint := &BinanceEndpoint{Signer{}, testStreamInterface{httpServer.URL}, 0}
+	stream := NewBinanceStream(endpoint, []common.TokenPair{pair})
+	stream.reconnectDelay = 10 * time.Millisecond
+	if _, ok := stream.Orderbook(pair.PairID(), common.GetTimepoint()); ok {
+		t.Fatalf("Orderbook must not be in sync before the stream starts")
+	}
+	stream.Start()
+
+	// already included in the snapshot, must be dropped
+	server.events <- `{"e":"depthUpdate","s":"ETHBTC","U":95,"u":100,"b":[["0.05","0"]],"a":[]}`
+	// overlaps the snapshot
+	server.events <- `{"e":"depthUpdate","s":"ETHBTC","U":99,"u":102,"b":[["0.049","3"]],"a":[]}`
+	server.events <- `{"e":"depthUpdate","s":"ETHBTC","U":103,"u":103,"b":[],"a":[["0.06","0"],["0.061","1"]]}`
+	waitForOrderbook(t, stream, pair.PairID(), func(price common.ExchangePrice) bool {
+		return len(price.Bids) == 2 && len(price.Asks) == 1 &&
+			price.Bids[0].Rate == 0.05 && price.Bids[0].Quantity == 10 &&
+			price.Bids[1].Rate == 0.049 && price.Bids[1].Quantity == 3 &&
+			price.Asks[0].Rate == 0.061 && price.Asks[0].Quantity == 1
+	})
+
+	// updates 104 - 109 are missed, the orderbook must be resynced
+	server.setSnapshot(`{"lastUpdateId":200,"bids":[["0.04","1"]],"asks":[["0.07","2"]]}`)
+	server.events <- `{"e":"depthUpdate","s":"ETHBTC","U":110,"u":111,"b":[["0.03","1"]],"a":[]}`
+	server.events <- `{"e":"depthUpdate","s":"ETHBTC","U":195,"u":201,"b":[],"a":[["0.08","4"]]}`
+	waitForOrderbook(t, stream, pair.PairID(), func(price common.ExchangePrice) bool {
+		return len(price.Bids) == 1 && len(price.Asks) == 2 &&
+			price.Bids[0].Rate == 0.04 &&
+			price.Asks[0].Rate == 0.07 && price.Asks[1].Rate == 0.08
+	})
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.snapshots != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d", server.snapshots)
+	}
+}
@@ -25,6 +25,17 @@ type Binaresp struct {
 	Asks          []Binaprice `json:"asks"`
 }
 
+// BinanceDepthEvent is a diff depth event pushed by binance depth stream
+type BinanceDepthEvent struct {
+	EventType     string      `json:"e"`
+	EventTime     uint64      `json:"E"`
+	Symbol        string      `json:"s"`
+	FirstUpdateID int64       `json:"U"`
+	FinalUpdateID int64       `json:"u"`
+	Bids          []Binaprice `json:"b"`
+	Asks          []Binaprice `json:"a"`
+}
+
 type Binainfo struct {
 	Code             int    `json:"code"`
 	Msg              string `json:"msg"`
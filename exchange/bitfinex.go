@@ -1,17 +1,86 @@
 package exchange
 
 import (
+	"fmt"
+	"log"
+	"math"
 	"math/big"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+const (
+	BITFINEX_EPSILON float64 = 0.0000001 // 10e-7
+	// Bitfinex accepts at most 8 decimals for order amounts
+	BITFINEX_AMOUNT_PRECISION int = 8
+	// Bitfinex accepts at most 5 significant digits for order prices
+	BITFINEX_PRICE_SIGNIFICANT_DIGITS int = 5
+)
+
+// Bitfinex names some currencies differently from us
+var bitfinexCurrencies = map[string]string{
+	"MANA": "mna",
+	"QTUM": "qtm",
+	"IOST": "ios",
+	"DATA": "dat",
+}
+
+// Bitfinex identifies the deposit and withdraw methods of a currency
+// by the network name instead of its symbol
+var bitfinexMethods = map[string]string{
+	"ETH": "ethereum",
+	"OMG": "omisego",
+	"EOS": "eos",
+	"SNT": "status",
+}
+
+// BitfinexCurrency returns Bitfinex currency code of a token
+func BitfinexCurrency(tokenID string) string {
+	if currency, found := bitfinexCurrencies[strings.ToUpper(tokenID)]; found {
+		return currency
+	}
+	return strings.ToLower(tokenID)
+}
+
+// BitfinexSymbol returns Bitfinex symbol of a token pair, eg: omgeth
+func BitfinexSymbol(base, quote string) string {
+	return BitfinexCurrency(base) + BitfinexCurrency(quote)
+}
+
+// BitfinexMethod returns Bitfinex deposit/withdraw method of a token
+func BitfinexMethod(tokenID string) string {
+	if method, found := bitfinexMethods[strings.ToUpper(tokenID)]; found {
+		return method
+	}
+	return strings.ToLower(tokenID)
+}
+
+// bitfinexTokenID returns our token ID of a Bitfinex currency code
+func bitfinexTokenID(currency string) string {
+	for tokenID, c := range bitfinexCurrencies {
+		if c == strings.ToLower(currency) {
+			return tokenID
+		}
+	}
+	return strings.ToUpper(currency)
+}
+
 type Bitfinex struct {
-	interf    BitfinexInterface
-	pairs     []common.TokenPair
-	addresses map[string]ethereum.Address
+	interf       BitfinexInterface
+	pairs        []common.TokenPair
+	tokens       []common.Token
+	addresses    *common.ExchangeAddresses
+	exchangeInfo *common.ExchangeInfo
+	fees         common.ExchangeFees
+	minDeposit   common.ExchangesMinDeposit
+}
+
+func (self *Bitfinex) TokenAddresses() map[string]ethereum.Address {
+	return self.addresses.GetData()
 }
 
 func (self *Bitfinex) MarshalText() (text []byte, err error) {
@@ -19,18 +88,70 @@ func (self *Bitfinex) MarshalText() (text []byte, err error) {
 }
 
 func (self *Bitfinex) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
+	addr, supported := self.addresses.Get(token.ID)
 	return addr, supported
 }
 
 func (self *Bitfinex) UpdateAllDepositAddresses(address string) {
-	for k, _ := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
+	data := self.addresses.GetData()
+	for k, _ := range data {
+		self.addresses.Update(k, ethereum.HexToAddress(address))
 	}
 }
 
 func (self *Bitfinex) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	liveAddress, _ := self.interf.GetDepositAddress(token.ID)
+	if liveAddress.Address != "" {
+		self.addresses.Update(token.ID, ethereum.HexToAddress(liveAddress.Address))
+	} else {
+		self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+	}
+}
+
+func (self *Bitfinex) UpdatePrecisionLimit(pair common.TokenPair, symbols BitfExchangeInfo) {
+	pairName := BitfinexSymbol(pair.Base.ID, pair.Quote.ID)
+	for _, symbol := range symbols {
+		if symbol.Pair == pairName {
+			exchangePrecisionLimit := common.ExchangePrecisionLimit{}
+			exchangePrecisionLimit.Precision.Amount = BITFINEX_AMOUNT_PRECISION
+			// Bitfinex price precision is a number of significant digits
+			// instead of decimals, prices are rounded to it by Trade
+			minQuantity, _ := strconv.ParseFloat(symbol.MinimumOrderSize, 64)
+			exchangePrecisionLimit.AmountLimit.Min = minQuantity
+			maxQuantity, _ := strconv.ParseFloat(symbol.MaximumOrderSize, 64)
+			exchangePrecisionLimit.AmountLimit.Max = maxQuantity
+			self.exchangeInfo.Update(pair.PairID(), exchangePrecisionLimit)
+			break
+		}
+	}
+}
+
+func (self *Bitfinex) UpdatePairsPrecision() {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		log.Printf("Get exchange info failed: %s\n", err)
+	} else {
+		for _, pair := range self.pairs {
+			self.UpdatePrecisionLimit(pair, exchangeInfo)
+		}
+	}
+}
+
+func (self *Bitfinex) GetInfo() (common.ExchangeInfo, error) {
+	return *self.exchangeInfo, nil
+}
+
+func (self *Bitfinex) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	data, err := self.exchangeInfo.Get(pair)
+	return data, err
+}
+
+func (self *Bitfinex) GetFee() common.ExchangeFees {
+	return self.fees
+}
+
+func (self *Bitfinex) GetMinDeposit() common.ExchangesMinDeposit {
+	return self.minDeposit
 }
 
 func (self *Bitfinex) ID() common.ExchangeID {
@@ -45,23 +166,117 @@ func (self *Bitfinex) Name() string {
 	return "bitfinex"
 }
 
-func (self *Bitfinex) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
-	return self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
+func orderProgress(order Bitforder) (done float64, remaining float64, finished bool) {
+	done, _ = strconv.ParseFloat(order.ExecutedAmount, 64)
+	remaining, _ = strconv.ParseFloat(order.RemainingAmount, 64)
+	return done, remaining, !order.IsLive || remaining < BITFINEX_EPSILON
 }
 
-func (self *Bitfinex) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) error {
-	return self.interf.Withdraw(token, amount, address, timepoint)
+// bitfinexAmount floors amount to BITFINEX_AMOUNT_PRECISION decimals so
+// orders never exceed the balance they are planned from
+func bitfinexAmount(amount float64) float64 {
+	scale := math.Pow10(BITFINEX_AMOUNT_PRECISION)
+	return math.Floor(amount*scale*(1+1e-12)) / scale
+}
+
+// bitfinexPrice rounds rate to BITFINEX_PRICE_SIGNIFICANT_DIGITS
+// significant digits, buy rates are rounded down and sell rates up so
+// orders are never placed at a worse rate than planned
+func bitfinexPrice(tradeType string, rate float64) float64 {
+	if rate <= 0 {
+		return rate
+	}
+	scale := math.Pow10(BITFINEX_PRICE_SIGNIFICANT_DIGITS - 1 - int(math.Floor(math.Log10(rate))))
+	if tradeType == "sell" {
+		return math.Ceil(rate*scale*(1-1e-12)) / scale
+	}
+	return math.Floor(rate*scale*(1+1e-12)) / scale
+}
+
+func (self *Bitfinex) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	amount = bitfinexAmount(amount)
+	if amount <= 0 {
+		return "", 0, 0, false, fmt.Errorf("Trade amount is under bitfinex amount precision %d", BITFINEX_AMOUNT_PRECISION)
+	}
+	result, err := self.interf.Trade(tradeType, base, quote, bitfinexPrice(tradeType, rate), amount)
+	if err != nil {
+		return "", 0, 0, false, err
+	}
+	done, remaining, finished = orderProgress(result)
+	return strconv.FormatUint(result.ID, 10), done, remaining, finished, nil
+}
+
+func (self *Bitfinex) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	return self.interf.Withdraw(token, amount, address)
+}
+
+func (self *Bitfinex) CancelOrder(id string, base, quote string) error {
+	idNo, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	_, err = self.interf.CancelOrder(idNo)
+	return err
+}
+
+func (self *Bitfinex) FetchOnePairData(
+	wg *sync.WaitGroup,
+	pair common.TokenPair,
+	data *sync.Map,
+	timepoint uint64) {
+
+	defer wg.Done()
+	result := common.ExchangePrice{}
+
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	resp_data, err := self.interf.GetDepthOnePair(pair)
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		for _, buy := range resp_data.Bids {
+			quantity, _ := strconv.ParseFloat(buy.Amount, 64)
+			rate, _ := strconv.ParseFloat(buy.Price, 64)
+			result.Bids = append(
+				result.Bids,
+				common.PriceEntry{
+					Quantity: quantity,
+					Rate:     rate,
+				},
+			)
+		}
+		for _, sell := range resp_data.Asks {
+			quantity, _ := strconv.ParseFloat(sell.Amount, 64)
+			rate, _ := strconv.ParseFloat(sell.Price, 64)
+			result.Asks = append(
+				result.Asks,
+				common.PriceEntry{
+					Quantity: quantity,
+					Rate:     rate,
+				},
+			)
+		}
+	}
+	data.Store(pair.PairID(), result)
 }
 
 func (self *Bitfinex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
 	pairs := self.pairs
-	for _, pair := range pairs {
-		wait.Add(1)
-		go self.interf.FetchOnePairData(&wait, pair, &data, timepoint)
+	var i int = 0
+	var x int = 0
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+BATCH_SIZE; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.FetchOnePairData(&wait, pair, &data, timepoint)
+		}
+		wait.Wait()
+		i = x
 	}
-	wait.Wait()
 	result := map[common.TokenPairID]common.ExchangePrice{}
 	data.Range(func(key, value interface{}) bool {
 		result[key.(common.TokenPairID)] = value.(common.ExchangePrice)
@@ -70,30 +285,196 @@ func (self *Bitfinex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]c
 	return result, nil
 }
 
-func (self *Bitfinex) FetchEBalanceData() (common.EBalanceEntry, error) {
+func (self *Bitfinex) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
+	result := common.OrderEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Data = []common.Order{}
+	resp_data, err := self.interf.ActiveOrders()
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		return result, nil
+	}
+	for _, order := range resp_data {
+		for _, pair := range self.pairs {
+			if BitfinexSymbol(pair.Base.ID, pair.Quote.ID) != strings.ToLower(order.Symbol) {
+				continue
+			}
+			price, _ := strconv.ParseFloat(order.Price, 64)
+			origQty, _ := strconv.ParseFloat(order.OriginalAmount, 64)
+			executedQty, _ := strconv.ParseFloat(order.ExecutedAmount, 64)
+			// bitfinex timestamp is in seconds with fractions
+			timestamp, _ := strconv.ParseFloat(order.Timestamp, 64)
+			result.Data = append(result.Data, common.Order{
+				ID:          fmt.Sprintf("%d_%s%s", order.ID, pair.Base.ID, pair.Quote.ID),
+				Base:        pair.Base.ID,
+				Quote:       pair.Quote.ID,
+				OrderId:     strconv.FormatUint(order.ID, 10),
+				Price:       price,
+				OrigQty:     origQty,
+				ExecutedQty: executedQty,
+				TimeInForce: "GTC",
+				Type:        "LIMIT",
+				Side:        order.Side,
+				Time:        uint64(timestamp * 1000),
+			})
+		}
+	}
+	return result, nil
+}
+
+// FetchEBalanceData only takes the exchange wallet into account as it is
+// the only wallet we trade with
+func (self *Bitfinex) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Error = ""
+	resp_data, err := self.interf.GetInfo()
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		result.Status = false
+	} else {
+		result.AvailableBalance = map[string]float64{}
+		result.LockedBalance = map[string]float64{}
+		result.DepositBalance = map[string]float64{}
+		result.Status = true
+		for _, b := range resp_data {
+			if b.Type != "exchange" {
+				continue
+			}
+			tokenID := bitfinexTokenID(b.Currency)
+			_, err := common.GetInternalToken(tokenID)
+			if err == nil {
+				total, _ := strconv.ParseFloat(b.Amount, 64)
+				avai, _ := strconv.ParseFloat(b.Available, 64)
+				result.AvailableBalance[tokenID] = avai
+				result.LockedBalance[tokenID] = total - avai
+				result.DepositBalance[tokenID] = 0
+			}
+		}
+	}
+	return result, nil
+}
+
+func (self *Bitfinex) FetchOnePairTradeHistory(
+	wait *sync.WaitGroup,
+	data *sync.Map,
+	pair common.TokenPair,
+	timepoint uint64) {
+
+	defer wait.Done()
+	result := []common.TradeHistory{}
+	resp, err := self.interf.GetAccountTradeHistory(pair.Base, pair.Quote, 0)
+	if err != nil {
+		log.Printf("Cannot fetch data for pair %s%s: %s", pair.Base.ID, pair.Quote.ID, err.Error())
+	}
+	for _, trade := range resp {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Amount, 64)
+		timestamp, _ := strconv.ParseFloat(trade.Timestamp, 64)
+		tradeHistory := common.TradeHistory{
+			ID:        strconv.FormatUint(trade.TID, 10),
+			Price:     price,
+			Qty:       quantity,
+			Type:      strings.ToLower(trade.Type),
+			Timestamp: uint64(timestamp * 1000),
+		}
+		result = append(result, tradeHistory)
+	}
+	data.Store(pair.PairID(), result)
+}
+
+func (self *Bitfinex) FetchTradeHistory(timepoint uint64) (map[common.TokenPairID][]common.TradeHistory, error) {
+	result := map[common.TokenPairID][]common.TradeHistory{}
+	data := sync.Map{}
+	pairs := self.pairs
+	wait := sync.WaitGroup{}
+	var i int = 0
+	var x int = 0
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+BATCH_SIZE; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.FetchOnePairTradeHistory(&wait, &data, pair, timepoint)
+		}
+		i = x
+		wait.Wait()
+	}
+	data.Range(func(key, value interface{}) bool {
+		result[key.(common.TokenPairID)] = value.([]common.TradeHistory)
+		return true
+	})
 	return result, nil
 }
 
-func NewBitfinex(interf BitfinexInterface) *Bitfinex {
+func movementStatus(movement Bitfmovement) string {
+	switch movement.Status {
+	case "COMPLETED":
+		return "done"
+	case "CANCELED", "UNCONFIRMED":
+		return "failed"
+	}
+	return ""
+}
+
+func (self *Bitfinex) DepositStatus(id common.ActivityID, txHash, currency string, amount float64, timepoint uint64) (string, error) {
+	movements, err := self.interf.MovementHistory(currency, timepoint-86400000, timepoint)
+	if err != nil {
+		return "", err
+	}
+	for _, movement := range movements {
+		if movement.Type == "DEPOSIT" && strings.ToLower(movement.TxID) == strings.ToLower(txHash) {
+			return movementStatus(movement), nil
+		}
+	}
+	log.Printf("Deposit is not found in movement list returned from Bitfinex. This might cause by wrong start/end time, please check again.")
+	return "", nil
+}
+
+func (self *Bitfinex) WithdrawStatus(id, currency string, amount float64, timepoint uint64) (string, string, error) {
+	movements, err := self.interf.MovementHistory(currency, timepoint-86400000, timepoint)
+	if err != nil {
+		return "", "", err
+	}
+	for _, movement := range movements {
+		if movement.Type == "WITHDRAWAL" && strconv.FormatUint(movement.ID, 10) == id {
+			return movementStatus(movement), movement.TxID, nil
+		}
+	}
+	log.Printf("Withdrawal doesn't exist. This shouldn't happen unless tx returned from withdrawal from bitfinex and activity ID are not consistently designed")
+	return "", "", nil
+}
+
+func (self *Bitfinex) OrderStatus(id string, base, quote string) (string, error) {
+	orderID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	order, err := self.interf.OrderStatus(orderID)
+	if err != nil {
+		return "", err
+	}
+	if order.IsLive {
+		return "", nil
+	}
+	return "done", nil
+}
+
+func NewBitfinex(addressConfig map[string]string, feeConfig common.ExchangeFees, interf BitfinexInterface,
+	minDepositConfig common.ExchangesMinDeposit) *Bitfinex {
+	tokens, pairs, fees, minDeposit := getExchangePairsAndFeesFromConfig(addressConfig, feeConfig, minDepositConfig, "bitfinex")
 	return &Bitfinex{
 		interf,
-		[]common.TokenPair{
-			common.MustCreateTokenPair("OMG", "ETH"),
-			common.MustCreateTokenPair("EOS", "ETH"),
-		},
-		map[string]ethereum.Address{
-			"ETH": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"OMG": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"DGD": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"CVC": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"MCO": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"GNT": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"ADX": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"EOS": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"PAY": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"BAT": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"KNC": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-		},
+		pairs,
+		tokens,
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		fees,
+		minDeposit,
 	}
 }
@@ -9,235 +9,354 @@ import (
 	"io/ioutil"
 	"log"
 	"math/big"
+	"math/rand"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/exchange"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"sync"
 )
 
 type BitfinexEndpoint struct {
 	signer Signer
 	interf Interface
+	// authenticated requests are serialized because Bitfinex rejects
+	// any nonce that is not bigger than the last one it has seen
+	mu        sync.Mutex
+	lastNonce int64
 }
 
-func nonce() string {
-	epsilon := 30 * time.Millisecond
-	anchor := int64(50299954901)
-	timestamp := time.Now().UnixNano()/int64(epsilon) - anchor
-	return strconv.Itoa(int(timestamp))
+func (self *BitfinexEndpoint) nonce() string {
+	nonce := time.Now().UnixNano()
+	if nonce <= self.lastNonce {
+		nonce = self.lastNonce + 1
+	}
+	self.lastNonce = nonce
+	return strconv.FormatInt(nonce, 10)
 }
 
-func (self *BitfinexEndpoint) fillRequest(req *http.Request, signNeeded bool, timepoint uint64) {
-	if req.Method == "POST" || req.Method == "PUT" {
-		req.Header.Add("Content-Type", "application/json;charset=utf-8")
-	}
+// fillRequest signs the request as described in Bitfinex v1 authenticated
+// endpoints: the payload is the json body containing the request path and a
+// nonce, it is sent base64 encoded and signed with HMAC-SHA384.
+func (self *BitfinexEndpoint) fillRequest(req *http.Request, payload []byte) {
+	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
-	if signNeeded == true {
-		payload := map[string]interface{}{
-			"request": req.URL.Path,
-			"nonce":   fmt.Sprintf("%v", timepoint),
-		}
-		payloadJson, _ := json.Marshal(payload)
-		payloadEnc := base64.StdEncoding.EncodeToString(payloadJson)
-		req.Header.Add("X-BFX-APIKEY", self.signer.GetKey())
-		req.Header.Add("X-BFX-PAYLOAD", payloadEnc)
-		req.Header.Add("X-BFX-SIGNATURE", self.signer.Sign(req.URL.String()))
-	}
-}
-
-func (self *BitfinexEndpoint) FetchOnePairData(
-	wg *sync.WaitGroup,
-	pair common.TokenPair,
-	data *sync.Map,
-	timepoint uint64) {
-
-	defer wg.Done()
-	result := common.ExchangePrice{}
-
-	client := &http.Client{}
-	url := self.interf.PublicEndpoint() + fmt.Sprintf(
-		"/book/%s%s",
-		strings.ToLower(pair.Base.ID),
-		strings.ToLower(pair.Quote.ID))
-	req, _ := http.NewRequest("GET", url, nil)
-	q := req.URL.Query()
-	q.Set("group", "1")
-	q.Set("limit_bids", "50")
-	q.Set("limit_asks", "50")
-	req.URL.RawQuery = q.Encode()
-	self.fillRequest(req, false, timepoint)
+	payloadEnc := base64.StdEncoding.EncodeToString(payload)
+	req.Header.Add("X-BFX-APIKEY", self.signer.GetKey())
+	req.Header.Add("X-BFX-PAYLOAD", payloadEnc)
+	req.Header.Add("X-BFX-SIGNATURE", self.signer.Sign(payloadEnc))
+}
+
+func (self *BitfinexEndpoint) GetResponse(
+	method string, url string,
+	params map[string]interface{}, signNeeded bool) ([]byte, error) {
 
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+	var req *http.Request
+	var err error
+	if signNeeded {
+		self.mu.Lock()
+		defer self.mu.Unlock()
+		req, err = http.NewRequest(method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		payload := map[string]interface{}{}
+		for k, v := range params {
+			payload[k] = v
+		}
+		payload["request"] = req.URL.Path
+		payload["nonce"] = self.nonce()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequest(method, url, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+		self.fillRequest(req, body)
+	} else {
+		req, err = http.NewRequest(method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Accept", "application/json")
+		q := req.URL.Query()
+		for k, v := range params {
+			q.Add(k, fmt.Sprintf("%v", v))
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	var resp_body []byte
+	log.Printf("request to bitfinex: %s\n", req.URL)
 	resp, err := client.Do(req)
-	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
-	result.Valid = true
 	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
+		return resp_body, err
 	} else {
 		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		returnTime := common.GetTimestamp()
-		result.ReturnTime = returnTime
-		if err != nil {
-			result.Valid = false
-			result.Error = err.Error()
-		} else {
-			resp_data := exchange.Bitfresp{}
-			json.Unmarshal(resp_body, &resp_data)
-			if len(resp_data.Asks) == 0 && len(resp_data.Bids) == 0 {
-				result.Valid = false
-			} else {
-				for _, buy := range resp_data.Bids {
-					quantity, _ := strconv.ParseFloat(buy["amount"], 64)
-					rate, _ := strconv.ParseFloat(buy["price"], 64)
-					result.Bids = append(
-						result.Bids,
-						common.PriceEntry{
-							quantity,
-							rate,
-						},
-					)
-				}
-				for _, sell := range resp_data.Asks {
-					quantity, _ := strconv.ParseFloat(sell["amount"], 64)
-					rate, _ := strconv.ParseFloat(sell["price"], 64)
-					result.Asks = append(
-						result.Asks,
-						common.PriceEntry{
-							quantity,
-							rate,
-						},
-					)
-				}
+		switch resp.StatusCode {
+		case 429:
+			err = errors.New("breaking a request rate limit.")
+		case 500:
+			err = errors.New("500 from Bitfinex, its fault.")
+		case 401:
+			err = errors.New("API key not valid.")
+		case 400:
+			message := struct {
+				Message string `json:"message"`
+			}{}
+			resp_body, err = ioutil.ReadAll(resp.Body)
+			if err == nil {
+				json.Unmarshal(resp_body, &message)
+				err = errors.New(fmt.Sprintf("Bitfinex rejected the request: %s", message.Message))
 			}
+		case 200:
+			resp_body, err = ioutil.ReadAll(resp.Body)
+		default:
+			err = errors.New(fmt.Sprintf("Bitfinex return with code: %d", resp.StatusCode))
+		}
+		if err != nil || len(resp_body) == 0 || rand.Int()%10 == 0 {
+			log.Printf("request to %s, got response from bitfinex (error or throttled to 10%%): %s, err: %v", req.URL, common.TruncStr(resp_body), err)
 		}
+		return resp_body, err
 	}
-	data.Store(pair.PairID(), result)
 }
 
-func (self *BitfinexEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64) (done float64, remaining float64, finished bool, err error) {
-	result := exchange.Bitftrade{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
-	data := url.Values{}
-	data.Set("method", "Trade")
-	data.Set("pair", fmt.Sprintf("%s_%s", strings.ToLower(base.ID), strings.ToLower(quote.ID)))
-	data.Set("type", tradeType)
-	data.Set("rate", strconv.FormatFloat(rate, 'f', -1, 64))
-	data.Set("amount", strconv.FormatFloat(amount, 'f', -1, 64))
-	params := data.Encode()
-	req, _ := http.NewRequest(
+func (self *BitfinexEndpoint) GetDepthOnePair(pair common.TokenPair) (exchange.Bitfresp, error) {
+	resp_data := exchange.Bitfresp{}
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.PublicEndpoint()+"/v1/book/"+exchange.BitfinexSymbol(pair.Base.ID, pair.Quote.ID),
+		map[string]interface{}{
+			"group":      "1",
+			"limit_bids": "50",
+			"limit_asks": "50",
+		},
+		false,
+	)
+	if err != nil {
+		return resp_data, err
+	}
+	err = json.Unmarshal(resp_body, &resp_data)
+	if err != nil {
+		log.Printf("failed to unmarshal response from bitfinex: %s, Response: %s", err, resp_body)
+		return resp_data, err
+	}
+	if resp_data.Message != "" {
+		return resp_data, errors.New(fmt.Sprintf("Getting depth from Bitfinex failed: %s", resp_data.Message))
+	}
+	return resp_data, nil
+}
+
+// In this version, we only support exchange limit order which means only
+// buy/sell with acceptable price on the exchange wallet, the order will be
+// active until it's implicitly canceled
+func (self *BitfinexEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64) (exchange.Bitforder, error) {
+	result := exchange.Bitforder{}
+	resp_body, err := self.GetResponse(
 		"POST",
-		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		self.interf.AuthenticatedEndpoint()+"/v1/order/new",
+		map[string]interface{}{
+			"symbol":   exchange.BitfinexSymbol(base.ID, quote.ID),
+			"amount":   strconv.FormatFloat(amount, 'f', -1, 64),
+			"price":    strconv.FormatFloat(rate, 'f', -1, 64),
+			"exchange": "bitfinex",
+			"side":     strings.ToLower(tradeType),
+			"type":     "exchange limit",
+		},
+		true,
 	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
 	}
-	return
+	return result, err
 }
 
-func (self *BitfinexEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address) error {
-	// ignoring timepoint because it's only relevant in simulation
-	result := exchange.Bitfwithdraw{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
+func (self *BitfinexEndpoint) CancelOrder(id uint64) (exchange.Bitforder, error) {
+	result := exchange.Bitforder{}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/order/cancel",
+		map[string]interface{}{
+			"order_id": id,
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
 	}
-	data := url.Values{}
-	data.Set("method", "WithdrawCoin")
-	data.Set("coinName", token.ID)
-	data.Set("amount", strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64))
-	data.Set("address", address.Hex())
-	params := data.Encode()
-	req, _ := http.NewRequest(
+	return result, err
+}
+
+func (self *BitfinexEndpoint) OrderStatus(id uint64) (exchange.Bitforder, error) {
+	result := exchange.Bitforder{}
+	resp_body, err := self.GetResponse(
 		"POST",
-		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		self.interf.AuthenticatedEndpoint()+"/v1/order/status",
+		map[string]interface{}{
+			"order_id": id,
+		},
+		true,
 	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-		if err != nil {
-			return err
-		}
-		if result.Error != "" {
-			return errors.New(result.Error)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) GetAccountTradeHistory(
+	base, quote common.Token,
+	since uint64) (exchange.BitfAccountTradeHistory, error) {
+
+	result := exchange.BitfAccountTradeHistory{}
+	params := map[string]interface{}{
+		"symbol":       exchange.BitfinexSymbol(base.ID, quote.ID),
+		"limit_trades": 500,
+	}
+	if since != 0 {
+		params["timestamp"] = strconv.FormatUint(since/1000, 10)
+	}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/mytrades",
+		params,
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+// MovementHistory returns deposits and withdrawals of a token between since
+// and until, both are in millisecond
+func (self *BitfinexEndpoint) MovementHistory(tokenID string, since, until uint64) (exchange.Bitfmovements, error) {
+	result := exchange.Bitfmovements{}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/history/movements",
+		map[string]interface{}{
+			"currency": strings.ToUpper(exchange.BitfinexCurrency(tokenID)),
+			"since":    strconv.FormatUint(since/1000, 10),
+			"until":    strconv.FormatUint(until/1000, 10),
+			"limit":    500,
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address) (string, error) {
+	result := exchange.Bitfwithdraws{}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/withdraw",
+		map[string]interface{}{
+			"withdraw_type":  exchange.BitfinexMethod(token.ID),
+			"walletselected": "exchange",
+			"amount":         strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64),
+			"address":        address.Hex(),
+		},
+		true,
+	)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("withdraw rejected by Bitfinex: %v", err))
+	}
+	err = json.Unmarshal(resp_body, &result)
+	if err != nil {
+		return "", err
+	}
+	if len(result) == 0 || result[0].Status != "success" {
+		message := "empty response"
+		if len(result) > 0 {
+			message = result[0].Message
 		}
-		return nil
-	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
-		return errors.New("withdraw rejected by Bitfinex")
+		return "", errors.New(fmt.Sprintf("withdraw rejected by Bitfinex: %s", message))
 	}
+	return strconv.FormatUint(result[0].WithdrawalID, 10), nil
+}
+
+func (self *BitfinexEndpoint) ActiveOrders() (exchange.Bitforders, error) {
+	result := exchange.Bitforders{}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/orders",
+		map[string]interface{}{},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
 }
 
 func (self *BitfinexEndpoint) GetInfo() (exchange.Bitfinfo, error) {
 	result := exchange.Bitfinfo{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
-	data := url.Values{}
-	data.Set("method", "getInfo")
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	log.Printf("endpoint: %v\n", self.interf.AuthenticatedEndpoint())
-	req, _ := http.NewRequest(
+	resp_body, err := self.GetResponse(
 		"POST",
-		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		self.interf.AuthenticatedEndpoint()+"/v1/balances",
+		map[string]interface{}{},
+		true,
 	)
-	log.Printf("params: %v\n", params)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
 	if err == nil {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			json.Unmarshal(resp_body, &result)
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) GetDepositAddress(tokenID string) (exchange.Bitfdepositaddress, error) {
+	result := exchange.Bitfdepositaddress{}
+	resp_body, err := self.GetResponse(
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/deposit/new",
+		map[string]interface{}{
+			"method":      exchange.BitfinexMethod(tokenID),
+			"wallet_name": "exchange",
+			"renew":       0,
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Result != "success" {
+			err = errors.New(fmt.Sprintf("Getting deposit address of %s from Bitfinex failed: %s", tokenID, result.Result))
 		}
 	}
 	return result, err
 }
 
+func (self *BitfinexEndpoint) GetExchangeInfo() (exchange.BitfExchangeInfo, error) {
+	result := exchange.BitfExchangeInfo{}
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.PublicEndpoint()+"/v1/symbols_details",
+		map[string]interface{}{},
+		false,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
 func NewBitfinexEndpoint(signer Signer, interf Interface) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, interf}
+	return &BitfinexEndpoint{signer: signer, interf: interf}
 }
 
 func NewRealBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, NewRealInterface()}
+	return NewBitfinexEndpoint(signer, NewRealInterface())
 }
 
-func NewSimulatedBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, NewSimulatedInterface()}
+func NewSimulatedBitfinexEndpoint(signer Signer, flagVariable string) *BitfinexEndpoint {
+	return NewBitfinexEndpoint(signer, NewSimulatedInterface(flagVariable))
 }
@@ -1,9 +1,5 @@
 package bitfinex
 
-import (
-	"os"
-)
-
 type Interface interface {
 	PublicEndpoint() string
 	AuthenticatedEndpoint() string
@@ -11,36 +7,97 @@ type Interface interface {
 
 type RealInterface struct{}
 
+func getOrSetDefaultURL(base_url string) string {
+	if len(base_url) > 1 {
+		return base_url + ":5400"
+	} else {
+		return "http://127.0.0.1:5400"
+	}
+
+}
+
 func (self *RealInterface) PublicEndpoint() string {
-	return "https://api.bitfinex.com/v1"
+	return "https://api.bitfinex.com"
 }
 
 func (self *RealInterface) AuthenticatedEndpoint() string {
-	return "https://api.bitfinex.com/v1"
+	return "https://api.bitfinex.com"
 }
 
 func NewRealInterface() *RealInterface {
 	return &RealInterface{}
 }
 
-type SimulatedInterface struct{}
+type SimulatedInterface struct {
+	base_url string
+}
 
 func (self *SimulatedInterface) baseurl() string {
-	baseurl := "http://127.0.0.1:8000"
-	if len(os.Args) > 1 {
-		baseurl = os.Args[1]
-	}
-	return baseurl
+	return getOrSetDefaultURL(self.base_url)
 }
 
 func (self *SimulatedInterface) PublicEndpoint() string {
-	return "https://api.bitfinex.com/v1"
+	return self.baseurl()
 }
 
 func (self *SimulatedInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
-func NewSimulatedInterface() *SimulatedInterface {
-	return &SimulatedInterface{}
+func NewSimulatedInterface(flagVariable string) *SimulatedInterface {
+	return &SimulatedInterface{base_url: flagVariable}
+}
+
+type RopstenInterface struct {
+	base_url string
+}
+
+func (self *RopstenInterface) baseurl() string {
+	return getOrSetDefaultURL(self.base_url)
+}
+
+func (self *RopstenInterface) PublicEndpoint() string {
+	return "https://api.bitfinex.com"
+}
+
+func (self *RopstenInterface) AuthenticatedEndpoint() string {
+	return self.baseurl()
+}
+
+func NewRopstenInterface(flagVariable string) *RopstenInterface {
+	return &RopstenInterface{base_url: flagVariable}
+}
+
+type KovanInterface struct {
+	base_url string
+}
+
+func (self *KovanInterface) baseurl() string {
+	return getOrSetDefaultURL(self.base_url)
+}
+
+func (self *KovanInterface) PublicEndpoint() string {
+	return "https://api.bitfinex.com"
+}
+
+func (self *KovanInterface) AuthenticatedEndpoint() string {
+	return self.baseurl()
+}
+
+func NewKovanInterface(flagVariable string) *KovanInterface {
+	return &KovanInterface{base_url: flagVariable}
+}
+
+type DevInterface struct{}
+
+func (self *DevInterface) PublicEndpoint() string {
+	return "https://api.bitfinex.com"
+}
+
+func (self *DevInterface) AuthenticatedEndpoint() string {
+	return "https://api.bitfinex.com"
+}
+
+func NewDevInterface() *DevInterface {
+	return &DevInterface{}
 }
@@ -1,35 +1,110 @@
 package exchange
 
+type Bitfprice struct {
+	Price     string `json:"price"`
+	Amount    string `json:"amount"`
+	Timestamp string `json:"timestamp"`
+}
+
 type Bitfresp struct {
-	Asks []map[string]string `json:"asks"`
-	Bids []map[string]string `json:"bids"`
+	Message string      `json:"message"`
+	Asks    []Bitfprice `json:"asks"`
+	Bids    []Bitfprice `json:"bids"`
 }
 
-type Bitfinfo struct {
-	Success int `json:"success"`
-	Return  map[string]map[string]float64
-	Error   string `json:"error"`
+// [
+//
+//	{
+//		"type": "exchange",
+//		"currency": "eth",
+//		"amount": "1.5",
+//		"available": "1.0"
+//	}
+//
+// ]
+type Bitfbalance struct {
+	Type      string `json:"type"`
+	Currency  string `json:"currency"`
+	Amount    string `json:"amount"`
+	Available string `json:"available"`
 }
 
-type BitExchangeInfo struct {
-	Pairs []struct {
-		Pair           string `json:"pair"`
-		PricePrecision int    `json:"price_precision"`
-	}
+type Bitfinfo []Bitfbalance
+
+type BitfSymbolDetail struct {
+	Pair             string `json:"pair"`
+	PricePrecision   int    `json:"price_precision"`
+	MaximumOrderSize string `json:"maximum_order_size"`
+	MinimumOrderSize string `json:"minimum_order_size"`
 }
 
+type BitfExchangeInfo []BitfSymbolDetail
+
 type Bitfwithdraw struct {
-	Success int `json:"success"`
-	Return  map[string]interface{}
-	Error   string `json:"error"`
-}
-
-type Bitftrade struct {
-	Success int `json:"success"`
-	Return  struct {
-		Done      float64 `json:"received"`
-		Remaining float64 `json:"remains"`
-		OrderID   int64   `json:"order_id"`
-	}
-	Error string `json:"error"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	WithdrawalID uint64 `json:"withdrawal_id"`
+}
+
+type Bitfwithdraws []Bitfwithdraw
+
+// Bitforder is returned by new order, cancel order and order status apis
+type Bitforders []Bitforder
+
+type Bitforder struct {
+	ID                uint64 `json:"id"`
+	Symbol            string `json:"symbol"`
+	Price             string `json:"price"`
+	AvgExecutionPrice string `json:"avg_execution_price"`
+	Side              string `json:"side"`
+	Type              string `json:"type"`
+	Timestamp         string `json:"timestamp"`
+	IsLive            bool   `json:"is_live"`
+	IsCancelled       bool   `json:"is_cancelled"`
+	OriginalAmount    string `json:"original_amount"`
+	RemainingAmount   string `json:"remaining_amount"`
+	ExecutedAmount    string `json:"executed_amount"`
+}
+
+//	{
+//		"id": 581183,
+//		"txid": "0x2b4ab1b9b2e36b7c2ba0e4e9a3bb6e0e29c28fdb5f6ad37ef7d1d3e3c9f0e23a",
+//		"currency": "ETH",
+//		"method": "ETHEREUM",
+//		"type": "WITHDRAWAL",
+//		"amount": ".01",
+//		"description": "0x2b4ab1b9...",
+//		"address": "0x39f8c41eaccd208549968701278dcb33a430ac19",
+//		"status": "COMPLETED",
+//		"timestamp": "1443833327.0",
+//		"fee": "0.01"
+//	}
+type Bitfmovement struct {
+	ID        uint64 `json:"id"`
+	TxID      string `json:"txid"`
+	Currency  string `json:"currency"`
+	Method    string `json:"method"`
+	Type      string `json:"type"`
+	Amount    string `json:"amount"`
+	Address   string `json:"address"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+type Bitfmovements []Bitfmovement
+
+type BitfAccountTradeHistory []struct {
+	TID       uint64 `json:"tid"`
+	OrderID   uint64 `json:"order_id"`
+	Price     string `json:"price"`
+	Amount    string `json:"amount"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+}
+
+type Bitfdepositaddress struct {
+	Result   string `json:"result"`
+	Method   string `json:"method"`
+	Currency string `json:"currency"`
+	Address  string `json:"address"`
 }
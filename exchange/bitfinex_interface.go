@@ -5,28 +5,34 @@ import (
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"sync"
 )
 
 type BitfinexInterface interface {
+	GetDepthOnePair(pair common.TokenPair) (Bitfresp, error)
 
 	GetInfo() (Bitfinfo, error)
 
-	FetchOnePairData(
-		wg *sync.WaitGroup,
-		pair common.TokenPair,
-		data *sync.Map,
-		timepoint uint64)
+	GetExchangeInfo() (BitfExchangeInfo, error)
+
+	GetDepositAddress(tokenID string) (Bitfdepositaddress, error)
+
+	GetAccountTradeHistory(base, quote common.Token, since uint64) (BitfAccountTradeHistory, error)
 
 	Withdraw(
 		token common.Token,
 		amount *big.Int,
-		address ethereum.Address,
-		timepoint uint64) error
+		address ethereum.Address) (string, error)
 
 	Trade(
 		tradeType string,
 		base, quote common.Token,
-		rate, amount float64,
-		timepoint uint64) (done float64, remaining float64, finished bool, err error)
+		rate, amount float64) (Bitforder, error)
+
+	CancelOrder(id uint64) (Bitforder, error)
+
+	OrderStatus(id uint64) (Bitforder, error)
+
+	ActiveOrders() (Bitforders, error)
+
+	MovementHistory(tokenID string, since, until uint64) (Bitfmovements, error)
 }
@@ -0,0 +1,180 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type testBitfinexInterface struct {
+	BalancesMock  string
+	MovementsMock string
+}
+
+func (self testBitfinexInterface) GetDepthOnePair(pair common.TokenPair) (Bitfresp, error) {
+	return Bitfresp{}, nil
+}
+func (self testBitfinexInterface) GetInfo() (Bitfinfo, error) {
+	res := Bitfinfo{}
+	err := json.Unmarshal([]byte(self.BalancesMock), &res)
+	return res, err
+}
+func (self testBitfinexInterface) GetExchangeInfo() (BitfExchangeInfo, error) {
+	return BitfExchangeInfo{}, nil
+}
+func (self testBitfinexInterface) GetDepositAddress(tokenID string) (Bitfdepositaddress, error) {
+	return Bitfdepositaddress{}, nil
+}
+func (self testBitfinexInterface) GetAccountTradeHistory(base, quote common.Token, since uint64) (BitfAccountTradeHistory, error) {
+	return BitfAccountTradeHistory{}, nil
+}
+func (self testBitfinexInterface) Withdraw(
+	token common.Token,
+	amount *big.Int,
+	address ethereum.Address) (string, error) {
+	return "", nil
+}
+func (self testBitfinexInterface) Trade(
+	tradeType string,
+	base, quote common.Token,
+	rate, amount float64) (Bitforder, error) {
+	return Bitforder{}, nil
+}
+func (self testBitfinexInterface) CancelOrder(id uint64) (Bitforder, error) {
+	return Bitforder{}, nil
+}
+func (self testBitfinexInterface) OrderStatus(id uint64) (Bitforder, error) {
+	return Bitforder{}, nil
+}
+func (self testBitfinexInterface) ActiveOrders() (Bitforders, error) {
+	return Bitforders{}, nil
+}
+func (self testBitfinexInterface) MovementHistory(tokenID string, since, until uint64) (Bitfmovements, error) {
+	res := Bitfmovements{}
+	err := json.Unmarshal([]byte(self.MovementsMock), &res)
+	return res, err
+}
+
+// testBitfinexTradeInterface records amounts and rates of placed orders
+type testBitfinexTradeInterface struct {
+	testBitfinexInterface
+	amounts *[]float64
+	rates   *[]float64
+}
+
+func (self testBitfinexTradeInterface) Trade(
+	tradeType string,
+	base, quote common.Token,
+	rate, amount float64) (Bitforder, error) {
+	*self.amounts = append(*self.amounts, amount)
+	*self.rates = append(*self.rates, rate)
+	return Bitforder{ID: 1, IsLive: true, ExecutedAmount: "0", RemainingAmount: "1"}, nil
+}
+
+func getTestBitfinex(balances, movements string) *Bitfinex {
+	return &Bitfinex{
+		testBitfinexInterface{balances, movements},
+		[]common.TokenPair{},
+		[]common.Token{},
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		common.ExchangeFees{},
+		common.ExchangesMinDeposit{},
+	}
+}
+
+const testBitfinexMovements = `[
+	{"id":581183,"txid":"0x2b4ab1b9b2e36b7c2ba0e4e9a3bb6e0e29c28fdb5f6ad37ef7d1d3e3c9f0e23a","currency":"OMG","method":"OMISEGO","type":"WITHDRAWAL","amount":"5","status":"COMPLETED","timestamp":"1513328774.0"},
+	{"id":581184,"txid":"0x15ccaab008f161efeee0febc3e32242846cea1fc93995e5abc6fb88d94ae7d21","currency":"OMG","method":"OMISEGO","type":"DEPOSIT","amount":"5","status":"COMPLETED","timestamp":"1513328774.0"},
+	{"id":581185,"txid":"0x4e3c6c5e5c56ef2f65867e0dac874f3e2ec2f66e05793b7a52281549c02e68d9","currency":"OMG","method":"OMISEGO","type":"DEPOSIT","amount":"5","status":"PENDING","timestamp":"1513328774.0"}
+]`
+
+func TestBitfinexDepositStatus(t *testing.T) {
+	bitf := getTestBitfinex("[]", testBitfinexMovements)
+	activityID := common.NewActivityID(
+		1513328774800747341,
+		"0x15ccaab008f161efeee0febc3e32242846cea1fc93995e5abc6fb88d94ae7d21|OMG|5",
+	)
+	out, err := bitf.DepositStatus(activityID, "0x15ccaab008f161efeee0febc3e32242846cea1fc93995e5abc6fb88d94ae7d21", "OMG", 5, common.GetTimepoint())
+	if err != nil || out != "done" {
+		t.Fatalf("Expected done, got %v, error: %v", out, err)
+	}
+	out, err = bitf.DepositStatus(activityID, "0x4e3c6c5e5c56ef2f65867e0dac874f3e2ec2f66e05793b7a52281549c02e68d9", "OMG", 5, common.GetTimepoint())
+	if err != nil || out != "" {
+		t.Fatalf("Expected pending deposit to return \"\", got %v, error: %v", out, err)
+	}
+}
+
+func TestBitfinexWithdrawStatus(t *testing.T) {
+	bitf := getTestBitfinex("[]", testBitfinexMovements)
+	out, tx, err := bitf.WithdrawStatus("581183", "OMG", 5, common.GetTimepoint())
+	if err != nil || out != "done" {
+		t.Fatalf("Expected done, got %v, error: %v", out, err)
+	}
+	if tx != "0x2b4ab1b9b2e36b7c2ba0e4e9a3bb6e0e29c28fdb5f6ad37ef7d1d3e3c9f0e23a" {
+		t.Fatalf("Expected withdraw tx to be returned, got %v", tx)
+	}
+}
+
+func TestBitfinexEBalance(t *testing.T) {
+	common.RegisterInternalActiveToken(common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18})
+	common.RegisterInternalActiveToken(common.Token{ID: "MANA", Address: "0x2222222222222222222222222222222222222222", Decimal: 18})
+	bitf := getTestBitfinex(`[
+		{"type":"exchange","currency":"eth","amount":"1.5","available":"1"},
+		{"type":"exchange","currency":"mna","amount":"100","available":"100"},
+		{"type":"trading","currency":"eth","amount":"3","available":"3"},
+		{"type":"exchange","currency":"usd","amount":"10","available":"10"}
+	]`, "[]")
+	balances, err := bitf.FetchEBalanceData(common.GetTimepoint())
+	if err != nil || !balances.Valid {
+		t.Fatalf("Expected to fetch balances, got %+v, error: %v", balances, err)
+	}
+	if balances.AvailableBalance["ETH"] != 1 || balances.LockedBalance["ETH"] != 0.5 {
+		t.Fatalf("Expected balances of exchange wallet only, got %+v", balances)
+	}
+	if balances.AvailableBalance["MANA"] != 100 {
+		t.Fatalf("Expected mna to be converted to MANA, got %+v", balances)
+	}
+	if _, found := balances.AvailableBalance["USD"]; found {
+		t.Fatalf("Expected unsupported currencies to be ignored, got %+v", balances)
+	}
+}
+
+func TestBitfinexTradeAmountPrecision(t *testing.T) {
+	amounts := []float64{}
+	bitf := getTestBitfinex("[]", "[]")
+	bitf.interf = testBitfinexTradeInterface{amounts: &amounts, rates: &[]float64{}}
+	base := common.Token{ID: "OMG"}
+	quote := common.Token{ID: "ETH"}
+	for _, amount := range []float64{1.123456789123, 0.29, 0.000000009} {
+		bitf.Trade("buy", base, quote, 0.01, amount, 0)
+	}
+	if fmt.Sprint(amounts) != "[1.12345678 0.29]" {
+		t.Fatalf("Expected amounts floored to %d decimals and amounts under it not traded, got %v", BITFINEX_AMOUNT_PRECISION, amounts)
+	}
+}
+
+func TestBitfinexTradePricePrecision(t *testing.T) {
+	rates := []float64{}
+	bitf := getTestBitfinex("[]", "[]")
+	bitf.interf = testBitfinexTradeInterface{amounts: &[]float64{}, rates: &rates}
+	base := common.Token{ID: "OMG"}
+	quote := common.Token{ID: "ETH"}
+	bitf.Trade("buy", base, quote, 0.0012345678, 1, 0)
+	bitf.Trade("sell", base, quote, 0.0012345678, 1, 0)
+	bitf.Trade("buy", base, quote, 123.456789, 1, 0)
+	bitf.Trade("sell", base, quote, 0.012345, 1, 0)
+	if fmt.Sprint(rates) != "[0.0012345 0.0012346 123.45 0.012345]" {
+		t.Fatalf("Expected rates rounded to %d significant digits against the trader, got %v", BITFINEX_PRICE_SIGNIFICANT_DIGITS, rates)
+	}
+	pair := common.TokenPair{Base: base, Quote: quote}
+	bitf.UpdatePrecisionLimit(pair, BitfExchangeInfo{{Pair: "omgeth", PricePrecision: 5, MinimumOrderSize: "0.1", MaximumOrderSize: "1000"}})
+	info, err := bitf.GetExchangeInfo(pair.PairID())
+	if err != nil || info.Precision.Amount != BITFINEX_AMOUNT_PRECISION || info.Precision.Price != 0 {
+		t.Fatalf("Expected amount precision only as price precision isn't in decimals, got %+v, error: %v", info.Precision, err)
+	}
+}
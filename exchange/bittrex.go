@@ -358,6 +358,46 @@ func (self *Bittrex) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry,
 	return result, nil
 }
 
+func (self *Bittrex) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
+	result := common.OrderEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Data = []common.Order{}
+	resp_data, err := self.interf.GetOpenOrders()
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		return result, nil
+	}
+	for _, order := range resp_data.Result {
+		// bittrex market is quote and base joined by "-", eg. ETH-OMG
+		tokens := strings.Split(order.Exchange, "-")
+		if len(tokens) != 2 {
+			continue
+		}
+		side := "sell"
+		if order.OrderType == "LIMIT_BUY" {
+			side = "buy"
+		}
+		t, _ := time.Parse("2006-01-02T15:04:05", order.Opened)
+		result.Data = append(result.Data, common.Order{
+			ID:          order.OrderUuid,
+			Base:        strings.ToUpper(tokens[1]),
+			Quote:       strings.ToUpper(tokens[0]),
+			OrderId:     order.OrderUuid,
+			Price:       order.Limit,
+			OrigQty:     order.Quantity,
+			ExecutedQty: order.Quantity - order.QuantityRemaining,
+			TimeInForce: "GTC",
+			Type:        "LIMIT",
+			Side:        side,
+			Time:        common.TimeToTimepoint(t),
+		})
+	}
+	return result, nil
+}
+
 func (self *Bittrex) FetchOnePairTradeHistory(
 	wait *sync.WaitGroup,
 	data *sync.Map,
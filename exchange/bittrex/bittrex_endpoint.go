@@ -257,6 +257,22 @@ func (self *BittrexEndpoint) CancelOrder(uuid string) (exchange.Bittcancelorder,
 	}
 }
 
+func (self *BittrexEndpoint) GetOpenOrders() (exchange.Bittopenorders, error) {
+	result := exchange.Bittopenorders{}
+	resp_body, err := self.GetResponse(
+		addPath(self.interf.MarketEndpoint(), "getopenorders"),
+		map[string]string{},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && !result.Success {
+			err = errors.New(fmt.Sprintf("Cannot get open orders: %s", result.Error))
+		}
+	}
+	return result, err
+}
+
 func (self *BittrexEndpoint) GetAccountTradeHistory(base, quote common.Token) (exchange.BittTradeHistory, error) {
 	result := exchange.BittTradeHistory{}
 	params := map[string]string{}
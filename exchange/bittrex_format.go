@@ -82,6 +82,20 @@ type Bitttrade struct {
 	Result  map[string]string `json:"result"`
 }
 
+type Bittopenorders struct {
+	Success bool   `json:"success"`
+	Error   string `json:"message"`
+	Result  []struct {
+		OrderUuid         string
+		Exchange          string
+		OrderType         string
+		Quantity          float64
+		QuantityRemaining float64
+		Limit             float64
+		Opened            string
+	} `json:"result"`
+}
+
 type Bittcancelorder struct {
 	Success bool   `json:"success"`
 	Error   string `json:"message"`
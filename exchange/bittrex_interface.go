@@ -35,4 +35,6 @@ type BittrexInterface interface {
 	WithdrawHistory(currency string) (Bittwithdrawhistory, error)
 
 	OrderStatus(uuid string) (Bitttraderesult, error)
+
+	GetOpenOrders() (Bittopenorders, error)
 }
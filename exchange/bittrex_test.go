@@ -60,6 +60,10 @@ func (self testBittrexInterface) GetDepositAddress(currency string) (BittrexDepo
 	return BittrexDepositAddress{}, nil
 }
 
+func (self testBittrexInterface) GetOpenOrders() (Bittopenorders, error) {
+	return Bittopenorders{}, nil
+}
+
 type testBittrexStorage struct {
 	IsNew bool
 }
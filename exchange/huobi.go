@@ -33,6 +33,13 @@ type Huobi struct {
 	intermediatorAddr ethereum.Address
 	storage           HuobiStorage
 	minDeposit        common.ExchangesMinDeposit
+	stream            OrderbookStream
+}
+
+// SetOrderbookStream makes FetchPriceData serve orderbooks from stream,
+// pairs whose local orderbooks are out of sync are still polled
+func (self *Huobi) SetOrderbookStream(stream OrderbookStream) {
+	self.stream = stream
 }
 
 func (self *Huobi) MarshalText() (text []byte, err error) {
@@ -224,13 +231,13 @@ func (self *Huobi) FetchOnePairData(
 func (self *Huobi) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
-	pairs := self.pairs
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	pairs := fetchStreamPriceData(self.stream, self.pairs, result, timepoint)
 	for _, pair := range pairs {
 		wait.Add(1)
 		go self.FetchOnePairData(&wait, pair, &data, timepoint)
 	}
 	wait.Wait()
-	result := map[common.TokenPairID]common.ExchangePrice{}
 	data.Range(func(key, value interface{}) bool {
 		result[key.(common.TokenPairID)] = value.(common.ExchangePrice)
 		return true
@@ -244,11 +251,40 @@ func (self *Huobi) OpenOrdersForOnePair(
 	data *sync.Map,
 	timepoint uint64) {
 
-	// defer wg.Done()
-
-	// result, err := self.interf.OpenOrdersForOnePair(pair, timepoint)
+	defer wg.Done()
 
-	//TODO: complete open orders for one pair
+	result, err := self.interf.OpenOrdersForOnePair(pair)
+
+	if err == nil {
+		orders := []common.Order{}
+		for _, order := range result.Data {
+			price, _ := strconv.ParseFloat(order.Price, 64)
+			orgQty, _ := strconv.ParseFloat(order.OrigQty, 64)
+			executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+			// huobi order type is side and type joined by "-", eg. buy-limit
+			side, orderType := order.Type, ""
+			if parts := strings.SplitN(order.Type, "-", 2); len(parts) == 2 {
+				side, orderType = parts[0], strings.ToUpper(parts[1])
+			}
+			orders = append(orders, common.Order{
+				ID:          fmt.Sprintf("%d_%s%s", order.OrderID, strings.ToUpper(pair.Base.ID), strings.ToUpper(pair.Quote.ID)),
+				Base:        strings.ToUpper(pair.Base.ID),
+				Quote:       strings.ToUpper(pair.Quote.ID),
+				OrderId:     fmt.Sprintf("%d", order.OrderID),
+				Price:       price,
+				OrigQty:     orgQty,
+				ExecutedQty: executedQty,
+				TimeInForce: "GTC",
+				Type:        orderType,
+				Side:        side,
+				Time:        order.CreatedAt,
+			})
+		}
+		data.Store(pair.PairID(), orders)
+	} else {
+		log.Printf("Unsuccessful response from Huobi: %s", err)
+		data.Store(pair.PairID(), err)
+	}
 }
 
 func (self *Huobi) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
@@ -268,11 +304,21 @@ func (self *Huobi) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
 
 	result.ReturnTime = common.GetTimestamp()
 
+	// the snapshot misses open orders of pairs failed to be fetched
+	errs := []string{}
 	data.Range(func(key, value interface{}) bool {
-		orders := value.([]common.Order)
-		result.Data = append(result.Data, orders...)
+		switch value := value.(type) {
+		case error:
+			errs = append(errs, fmt.Sprintf("%s: %s", key, value))
+		case []common.Order:
+			result.Data = append(result.Data, value...)
+		}
 		return true
 	})
+	if len(errs) > 0 {
+		result.Valid = false
+		result.Error = strings.Join(errs, ", ")
+	}
 	return result, nil
 }
 
@@ -611,6 +657,7 @@ func NewHuobi(
 		signer.GetAddress(),
 		storage,
 		minDeposit,
+		nil,
 	}
 	huobiServer := huobihttp.NewHuobiHTTPServer(&huobiObj)
 	go huobiServer.Run()
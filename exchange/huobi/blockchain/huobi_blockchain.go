@@ -21,10 +21,14 @@ func (self *Blockchain) SendTokenFromAccountToExchange(amount *big.Int, exchange
 	}
 	tx, err := self.BuildSendERC20Tx(opts, amount, exchangeAddress, tokenAddress)
 	if err != nil {
+		self.ReleaseNonce(opts)
 		return nil, err
-	} else {
-		return self.SignAndBroadcast(tx, HUOBI_OP)
 	}
+	signedTx, err := self.SignAndBroadcast(tx, HUOBI_OP)
+	if signedTx == nil {
+		self.ReleaseNonce(opts)
+	}
+	return signedTx, err
 }
 
 func (self *Blockchain) SendETHFromAccountToExchange(amount *big.Int, exchangeAddress ethereum.Address) (*types.Transaction, error) {
@@ -34,10 +38,14 @@ func (self *Blockchain) SendETHFromAccountToExchange(amount *big.Int, exchangeAd
 	}
 	tx, err := self.BuildSendETHTx(opts, exchangeAddress)
 	if err != nil {
+		self.ReleaseNonce(opts)
 		return nil, err
-	} else {
-		return self.SignAndBroadcast(tx, HUOBI_OP)
 	}
+	signedTx, err := self.SignAndBroadcast(tx, HUOBI_OP)
+	if signedTx == nil {
+		self.ReleaseNonce(opts)
+	}
+	return signedTx, err
 }
 
 func NewBlockchain(
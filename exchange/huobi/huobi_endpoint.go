@@ -305,23 +305,24 @@ func (self *HuobiEndpoint) GetAccountTradeHistory(
 }
 
 func (self *HuobiEndpoint) OpenOrdersForOnePair(
-	pair common.TokenPair) (exchange.HuobiOrder, error) {
-	// TODO: check again if use
-	result := exchange.HuobiOrder{}
+	pair common.TokenPair) (exchange.HuobiOpenOrders, error) {
+	result := exchange.HuobiOpenOrders{}
 	resp_body, err := self.GetResponse(
 		"GET",
-		self.interf.AuthenticatedEndpoint()+"/", // TODO: check again if available
+		self.interf.AuthenticatedEndpoint()+"/v1/order/orders",
 		map[string]string{
-			"symbol": pair.Base.ID + pair.Quote.ID,
+			"symbol": strings.ToLower(pair.Base.ID + pair.Quote.ID),
+			"states": "pre-submitted,submitted,partial-filled",
 		},
 		true,
 	)
-	if err != nil {
-		return result, err
-	} else {
-		json.Unmarshal(resp_body, &result)
-		return result, nil
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New(fmt.Sprintf("Get open orders failed: %s", result.Reason))
+		}
 	}
+	return result, err
 }
 
 func (self *HuobiEndpoint) GetDepositAddress(asset string) (exchange.HuobiDepositAddress, error) {
@@ -0,0 +1,169 @@
+package huobi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// a connection without any message for this long is considered dead
+	STREAM_READ_TIMEOUT time.Duration = 60 * time.Second
+	// delay before reconnecting a dropped stream
+	STREAM_RECONNECT_DELAY time.Duration = 5 * time.Second
+)
+
+// message pushed by huobi market stream, it is either a ping, a
+// subscription response or a depth tick
+type huobiStreamMessage struct {
+	Ping    uint64 `json:"ping"`
+	Status  string `json:"status"`
+	Error   string `json:"err-msg"`
+	Channel string `json:"ch"`
+	Tick    struct {
+		Bids    [][]float64 `json:"bids"`
+		Asks    [][]float64 `json:"asks"`
+		Version int64       `json:"version"`
+	} `json:"tick"`
+}
+
+// HuobiStream maintains local orderbooks of huobi pairs from huobi market
+// depth stream. All pairs are subscribed on one connection, every depth
+// tick is a full snapshot so it replaces the local orderbook.
+type HuobiStream struct {
+	interf         Interface
+	pairs          []common.TokenPair
+	books          map[string]*exchange.Orderbook
+	ids            map[common.TokenPairID]string
+	reconnectDelay time.Duration
+}
+
+func depthChannel(pair common.TokenPair) string {
+	return fmt.Sprintf(
+		"market.%s%s.depth.step0",
+		strings.ToLower(pair.Base.ID),
+		strings.ToLower(pair.Quote.ID),
+	)
+}
+
+// Orderbook implements exchange.OrderbookStream
+func (self *HuobiStream) Orderbook(pair common.TokenPairID, timepoint uint64) (common.ExchangePrice, bool) {
+	channel, found := self.ids[pair]
+	if !found {
+		return common.ExchangePrice{}, false
+	}
+	return self.books[channel].ExchangePrice(timepoint)
+}
+
+// Start connects the stream in background, it is reconnected
+// automatically whenever it is dropped
+func (self *HuobiStream) Start() {
+	go self.run()
+}
+
+func (self *HuobiStream) run() {
+	for {
+		err := self.stream()
+		log.Printf("Huobi orderbook stream stopped: %s", err)
+		for _, book := range self.books {
+			book.Invalidate()
+		}
+		time.Sleep(self.reconnectDelay)
+	}
+}
+
+func (self *HuobiStream) stream() error {
+	conn, err := websocket.Dial(self.interf.StreamEndpoint(), "", "http://localhost/")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for i, pair := range self.pairs {
+		sub := map[string]string{
+			"sub": depthChannel(pair),
+			"id":  fmt.Sprintf("%d", i),
+		}
+		if err = websocket.JSON.Send(conn, sub); err != nil {
+			return err
+		}
+	}
+	for {
+		if err = conn.SetReadDeadline(time.Now().Add(STREAM_READ_TIMEOUT)); err != nil {
+			return err
+		}
+		msg, err := receiveMessage(conn)
+		if err != nil {
+			return err
+		}
+		if msg.Ping != 0 {
+			if err = websocket.JSON.Send(conn, map[string]uint64{"pong": msg.Ping}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Status == "error" {
+			return fmt.Errorf("Huobi stream error: %s", msg.Error)
+		}
+		book, found := self.books[msg.Channel]
+		if !found {
+			continue
+		}
+		book.Reset(
+			toPriceEntries(msg.Tick.Bids),
+			toPriceEntries(msg.Tick.Asks),
+			msg.Tick.Version,
+			common.GetTimepoint(),
+		)
+	}
+}
+
+// receiveMessage reads a gzip compressed message from conn
+func receiveMessage(conn *websocket.Conn) (huobiStreamMessage, error) {
+	msg := huobiStreamMessage{}
+	data := []byte{}
+	if err := websocket.Message.Receive(conn, &data); err != nil {
+		return msg, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return msg, err
+	}
+	defer reader.Close()
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(text, &msg)
+	return msg, err
+}
+
+func toPriceEntries(levels [][]float64) []common.PriceEntry {
+	result := []common.PriceEntry{}
+	for _, level := range levels {
+		if len(level) < 2 {
+			continue
+		}
+		result = append(result, common.PriceEntry{Quantity: level[1], Rate: level[0]})
+	}
+	return result
+}
+
+func NewHuobiStream(interf Interface, pairs []common.TokenPair) *HuobiStream {
+	books := map[string]*exchange.Orderbook{}
+	ids := map[common.TokenPairID]string{}
+	for _, pair := range pairs {
+		channel := depthChannel(pair)
+		books[channel] = exchange.NewOrderbook()
+		ids[pair.PairID()] = channel
+	}
+	return &HuobiStream{interf, pairs, books, ids, STREAM_RECONNECT_DELAY}
+}
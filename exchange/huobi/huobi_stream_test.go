@@ -0,0 +1,107 @@
+package huobi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"golang.org/x/net/websocket"
+)
+
+type testStreamInterface struct {
+	url string
+}
+
+func (self testStreamInterface) PublicEndpoint() string {
+	return self.url
+}
+
+func (self testStreamInterface) AuthenticatedEndpoint() string {
+	return self.url
+}
+
+func (self testStreamInterface) StreamEndpoint() string {
+	return strings.Replace(self.url, "http", "ws", 1) + "/ws"
+}
+
+func sendGzip(conn *websocket.Conn, msg string) error {
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return websocket.Message.Send(conn, buf.Bytes())
+}
+
+func TestHuobiStream(t *testing.T) {
+	subs := make(chan string, 10)
+	pongs := make(chan uint64, 10)
+	handler := websocket.Handler(func(conn *websocket.Conn) {
+		sub := map[string]string{}
+		if err := websocket.JSON.Receive(conn, &sub); err != nil {
+			return
+		}
+		subs <- sub["sub"]
+		if sendGzip(conn, `{"ping":1234}`) != nil {
+			return
+		}
+		pong := map[string]uint64{}
+		if err := websocket.JSON.Receive(conn, &pong); err != nil {
+			return
+		}
+		pongs <- pong["pong"]
+		sendGzip(conn, `{"id":"0","status":"ok","subbed":"market.ethbtc.depth.step0"}`)
+		sendGzip(conn, `{"ch":"market.ethbtc.depth.step0","tick":{"bids":[[0.05,10],[0.049,3]],"asks":[[0.06,5]],"version":1}}`)
+		// keep the connection open until the client closes it
+		websocket.Message.Receive(conn, new([]byte))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pair := common.TokenPair{Base: common.Token{ID: "ETH"}, Quote: common.Token{ID: "BTC"}}
+	stream := NewHuobiStream(testStreamInterface{server.URL}, []common.TokenPair{pair})
+	stream.Start()
+
+	select {
+	case sub := <-subs:
+		if sub != "market.ethbtc.depth.step0" {
+			t.Fatalf("Expected subscription to market.ethbtc.depth.step0, got %s", sub)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stream did not subscribe")
+	}
+	select {
+	case pong := <-pongs:
+		if pong != 1234 {
+			t.Fatalf("Expected pong 1234, got %d", pong)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stream did not answer ping")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		price, ok := stream.Orderbook(pair.PairID(), common.GetTimepoint())
+		if ok {
+			text, _ := json.Marshal(price.Bids)
+			if len(price.Bids) != 2 || len(price.Asks) != 1 ||
+				price.Bids[0].Rate != 0.05 || price.Bids[1].Rate != 0.049 ||
+				price.Asks[0].Rate != 0.06 || price.Asks[0].Quantity != 5 {
+				t.Fatalf("Orderbook is not as expected, bids: %s, asks: %+v", text, price.Asks)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Orderbook is not in sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
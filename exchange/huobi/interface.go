@@ -1,8 +1,11 @@
 package huobi
 
+import "strings"
+
 type Interface interface {
 	PublicEndpoint() string
 	AuthenticatedEndpoint() string
+	StreamEndpoint() string
 }
 
 func getOrSetDefaultURL(base_url string) string {
@@ -24,6 +27,10 @@ func (self *RealInterface) AuthenticatedEndpoint() string {
 	return "https://api.huobi.pro"
 }
 
+func (self *RealInterface) StreamEndpoint() string {
+	return "wss://api.huobi.pro/ws"
+}
+
 func NewRealInterface() *RealInterface {
 	return &RealInterface{}
 }
@@ -44,6 +51,10 @@ func (self *SimulatedInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
+func (self *SimulatedInterface) StreamEndpoint() string {
+	return strings.Replace(self.baseurl(), "http", "ws", 1) + "/ws"
+}
+
 func NewSimulatedInterface(flagVariable string) *SimulatedInterface {
 	return &SimulatedInterface{base_url: flagVariable}
 }
@@ -64,6 +75,10 @@ func (self *RopstenInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
+func (self *RopstenInterface) StreamEndpoint() string {
+	return "wss://api.huobi.pro/ws"
+}
+
 func NewRopstenInterface(flagVariable string) *RopstenInterface {
 	return &RopstenInterface{base_url: flagVariable}
 }
@@ -84,6 +99,10 @@ func (self *KovanInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
+func (self *KovanInterface) StreamEndpoint() string {
+	return "wss://api.huobi.pro/ws"
+}
+
 func NewKovanInterface(flagVariable string) *KovanInterface {
 	return &KovanInterface{base_url: flagVariable}
 }
@@ -102,6 +121,10 @@ func (self *DevInterface) AuthenticatedEndpoint() string {
 	// return "http://192.168.25.16:5100"
 }
 
+func (self *DevInterface) StreamEndpoint() string {
+	return "wss://api.huobi.pro/ws"
+}
+
 func NewDevInterface() *DevInterface {
 	return &DevInterface{}
 }
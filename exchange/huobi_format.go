@@ -102,6 +102,22 @@ type HuobiOrder struct {
 	Reason string `json:"err-msg"`
 }
 
+type HuobiOpenOrders struct {
+	Status string `json:"status"`
+	Data   []struct {
+		OrderID     uint64 `json:"id"`
+		Symbol      string `json:"symbol"`
+		AccountID   uint64 `json:"account-id"`
+		OrigQty     string `json:"amount"`
+		Price       string `json:"price"`
+		Type        string `json:"type"`
+		State       string `json:"state"`
+		ExecutedQty string `json:"field-amount"`
+		CreatedAt   uint64 `json:"created-at"`
+	} `json:"data"`
+	Reason string `json:"err-msg"`
+}
+
 type HuobiDepositAddress struct {
 	Msg        string `json:"msg"`
 	Address    string `json:"address"`
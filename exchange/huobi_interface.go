@@ -12,7 +12,7 @@ type HuobiInterface interface {
 		pair common.TokenPair) (HuobiDepth, error)
 
 	OpenOrdersForOnePair(
-		pair common.TokenPair) (HuobiOrder, error)
+		pair common.TokenPair) (HuobiOpenOrders, error)
 
 	GetInfo() (HuobiInfo, error)
 
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"github.com/satori/go.uuid"
 )
 
 const (
@@ -19,9 +20,17 @@ const (
 )
 
 type Liqui struct {
-	interf    LiquiInterface
-	pairs     []common.TokenPair
-	addresses map[string]ethereum.Address
+	interf       LiquiInterface
+	pairs        []common.TokenPair
+	tokens       []common.Token
+	addresses    *common.ExchangeAddresses
+	exchangeInfo *common.ExchangeInfo
+	fees         common.ExchangeFees
+	minDeposit   common.ExchangesMinDeposit
+}
+
+func (self *Liqui) TokenAddresses() map[string]ethereum.Address {
+	return self.addresses.GetData()
 }
 
 func (self *Liqui) MarshalText() (text []byte, err error) {
@@ -29,18 +38,64 @@ func (self *Liqui) MarshalText() (text []byte, err error) {
 }
 
 func (self *Liqui) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
+	addr, supported := self.addresses.Get(token.ID)
 	return addr, supported
 }
 
 func (self *Liqui) UpdateAllDepositAddresses(address string) {
-	for k := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
+	data := self.addresses.GetData()
+	for k := range data {
+		self.addresses.Update(k, ethereum.HexToAddress(address))
 	}
 }
 
 func (self *Liqui) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+func (self *Liqui) UpdatePrecisionLimit(pair common.TokenPair, pairs map[string]LiqPairInfo) {
+	pairName := fmt.Sprintf("%s_%s", strings.ToLower(pair.Base.ID), strings.ToLower(pair.Quote.ID))
+	info, found := pairs[pairName]
+	if !found {
+		return
+	}
+	exchangePrecisionLimit := common.ExchangePrecisionLimit{}
+	exchangePrecisionLimit.Precision.Amount = info.DecimalPlaces
+	exchangePrecisionLimit.Precision.Price = info.DecimalPlaces
+	exchangePrecisionLimit.AmountLimit.Min = info.MinAmount
+	exchangePrecisionLimit.AmountLimit.Max = info.MaxAmount
+	exchangePrecisionLimit.PriceLimit.Min = info.MinPrice
+	exchangePrecisionLimit.PriceLimit.Max = info.MaxPrice
+	exchangePrecisionLimit.MinNotional = info.MinTotal
+	self.exchangeInfo.Update(pair.PairID(), exchangePrecisionLimit)
+}
+
+func (self *Liqui) UpdatePairsPrecision() {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		log.Printf("Get exchange info failed: %s\n", err)
+	} else {
+		for _, pair := range self.pairs {
+			self.UpdatePrecisionLimit(pair, exchangeInfo.Pairs)
+		}
+	}
+}
+
+func (self *Liqui) GetInfo() (common.ExchangeInfo, error) {
+	return *self.exchangeInfo, nil
+}
+
+func (self *Liqui) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	data, err := self.exchangeInfo.Get(pair)
+	return data, err
+}
+
+func (self *Liqui) GetFee() common.ExchangeFees {
+	return self.fees
+}
+
+func (self *Liqui) GetMinDeposit() common.ExchangesMinDeposit {
+	return self.minDeposit
 }
 
 func (self *Liqui) ID() common.ExchangeID {
@@ -59,18 +114,24 @@ func (self *Liqui) Trade(tradeType string, base common.Token, quote common.Token
 	return self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
 }
 
+// Withdraw returns an id combining Liqui transaction id and the time the
+// withdrawal was submitted. Liqui doesn't provide withdrawal history so the
+// submitted time is the only thing WithdrawStatus can rely on.
 func (self *Liqui) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
-	err := self.interf.Withdraw(token, amount, address, timepoint)
-	return uuid.NewV4().String(), err
+	result, err := self.interf.Withdraw(token, amount, address, timepoint)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d_%d", result.Return.TID, timepoint), nil
 }
 
-func (self *Liqui) CancelOrder(id common.ActivityID) error {
-	result, err := self.interf.CancelOrder(id.EID)
+func (self *Liqui) CancelOrder(id string, base, quote string) error {
+	result, err := self.interf.CancelOrder(id)
 	if err != nil {
 		return err
 	}
 	if result.Success != 1 {
-		return errors.New("Couldn't cancel order id " + id.EID + " err: " + result.Error)
+		return errors.New("Couldn't cancel order id " + id + " err: " + result.Error)
 	}
 	return nil
 }
@@ -120,13 +181,15 @@ func (self *Liqui) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, er
 	if err != nil {
 		result.Valid = false
 		result.Error = err.Error()
+		result.Status = false
 	} else {
 		if resp_data.Success == 1 {
 			balances := resp_data.Return["funds"]
+			result.Status = true
 			result.AvailableBalance = map[string]float64{}
 			result.LockedBalance = map[string]float64{}
 			result.DepositBalance = map[string]float64{}
-			for _, token := range common.InternalTokens() {
+			for _, token := range self.tokens {
 				result.AvailableBalance[token.ID] = balances[strings.ToLower(token.ID)]
 				// TODO: need to take open order into account
 				result.LockedBalance[token.ID] = 0
@@ -135,6 +198,7 @@ func (self *Liqui) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, er
 		} else {
 			result.Valid = false
 			result.Error = resp_data.Error
+			result.Status = false
 		}
 	}
 	return result, nil
@@ -180,8 +244,8 @@ func (self *Liqui) FetchPriceData(timepoint uint64) (map[common.TokenPairID]comm
 				one_pair_result.Bids = append(
 					one_pair_result.Bids,
 					common.PriceEntry{
-						buy[1],
-						buy[0],
+						Quantity: buy[1],
+						Rate:     buy[0],
 					},
 				)
 			}
@@ -189,8 +253,8 @@ func (self *Liqui) FetchPriceData(timepoint uint64) (map[common.TokenPairID]comm
 				one_pair_result.Asks = append(
 					one_pair_result.Asks,
 					common.PriceEntry{
-						sell[1],
-						sell[0],
+						Quantity: sell[1],
+						Rate:     sell[0],
 					},
 				)
 			}
@@ -200,7 +264,57 @@ func (self *Liqui) FetchPriceData(timepoint uint64) (map[common.TokenPairID]comm
 	return result, err
 }
 
-func (self *Liqui) DepositStatus(id common.ActivityID, timepoint uint64) (string, error) {
+func (self *Liqui) FetchOnePairTradeHistory(
+	wait *sync.WaitGroup,
+	data *sync.Map,
+	pair common.TokenPair,
+	timepoint uint64) {
+
+	defer wait.Done()
+	result := []common.TradeHistory{}
+	pairName := fmt.Sprintf("%s_%s", strings.ToLower(pair.Base.ID), strings.ToLower(pair.Quote.ID))
+	resp, err := self.interf.TradeHistory(pairName, timepoint)
+	if err != nil {
+		log.Printf("Cannot fetch data for pair %s%s: %s", pair.Base.ID, pair.Quote.ID, err.Error())
+	}
+	for id, trade := range resp.Return {
+		result = append(result, common.TradeHistory{
+			ID:        id,
+			Price:     trade.Rate,
+			Qty:       trade.Amount,
+			Type:      trade.Type,
+			Timestamp: trade.Timestamp * 1000,
+		})
+	}
+	data.Store(pair.PairID(), result)
+}
+
+func (self *Liqui) FetchTradeHistory(timepoint uint64) (map[common.TokenPairID][]common.TradeHistory, error) {
+	result := map[common.TokenPairID][]common.TradeHistory{}
+	data := sync.Map{}
+	pairs := self.pairs
+	wait := sync.WaitGroup{}
+	var i int = 0
+	var x int = 0
+	for i < len(pairs) {
+		for x = i; x < len(pairs) && x < i+BATCH_SIZE; x++ {
+			wait.Add(1)
+			pair := pairs[x]
+			go self.FetchOnePairTradeHistory(&wait, &data, pair, timepoint)
+		}
+		i = x
+		wait.Wait()
+	}
+	data.Range(func(key, value interface{}) bool {
+		result[key.(common.TokenPairID)] = value.([]common.TradeHistory)
+		return true
+	})
+	return result, nil
+}
+
+// Liqui doesn't provide deposit history so a deposit is considered done
+// after DEPOSIT_WAITING_TIME since it was submitted
+func (self *Liqui) DepositStatus(id common.ActivityID, txHash, currency string, amount float64, timepoint uint64) (string, error) {
 	timestamp := id.Timepoint
 	if timepoint-timestamp/uint64(time.Millisecond) > DEPOSIT_WAITING_TIME {
 		return "done", nil
@@ -209,53 +323,52 @@ func (self *Liqui) DepositStatus(id common.ActivityID, timepoint uint64) (string
 }
 
 // Liqui should not work properly because of lack of txid
-func (self *Liqui) WithdrawStatus(id common.ActivityID, timepoint uint64) (string, string, error) {
-	timestamp := id.Timepoint
-	if timepoint-timestamp/uint64(time.Millisecond) > WITHDRAW_WAITING_TIME {
+func (self *Liqui) WithdrawStatus(id, currency string, amount float64, timepoint uint64) (string, string, error) {
+	parts := strings.Split(id, "_")
+	if len(parts) != 2 {
+		return "", "", errors.New(fmt.Sprintf("Malformed Liqui withdraw id: %s", id))
+	}
+	submitted, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", "", err
+	}
+	if timepoint > submitted && timepoint-submitted > WITHDRAW_WAITING_TIME {
 		return "done", "", nil
 	}
 	return "", "", nil
 }
 
-func (self *Liqui) OrderStatus(id common.ActivityID, timepoint uint64) (string, error) {
-	result, err := self.interf.OrderInfo(id.EID, timepoint)
+func (self *Liqui) OrderStatus(id string, base, quote string) (string, error) {
+	result, err := self.interf.OrderInfo(id, common.GetTimepoint())
 	if err != nil {
 		return "", err
-	} else {
-		if result.Success != 1 {
-			for _, v := range result.Return {
-				if v.Status == 0 {
-					return "", nil
-				} else if v.Status == 1 {
-					return "done", nil
-				} else if v.Status == 2 {
-					return "failed", nil
-				} else if v.Status == 3 {
-					return "failed", nil
-				}
-			}
-			panic("Malformed response from liqui")
-		} else {
-			return "", errors.New(result.Error)
+	}
+	if result.Success != 1 {
+		return "", errors.New(result.Error)
+	}
+	for _, v := range result.Return {
+		switch v.Status {
+		case 0:
+			return "", nil
+		case 1:
+			return "done", nil
+		case 2, 3:
+			return "failed", nil
 		}
 	}
+	return "", errors.New("Malformed response from liqui")
 }
 
-func NewLiqui(interf LiquiInterface) *Liqui {
+func NewLiqui(addressConfig map[string]string, feeConfig common.ExchangeFees, interf LiquiInterface,
+	minDepositConfig common.ExchangesMinDeposit) *Liqui {
+	tokens, pairs, fees, minDeposit := getExchangePairsAndFeesFromConfig(addressConfig, feeConfig, minDepositConfig, "liqui")
 	return &Liqui{
 		interf,
-		[]common.TokenPair{
-			common.MustCreateTokenPair("OMG", "ETH"),
-			common.MustCreateTokenPair("DGD", "ETH"),
-			common.MustCreateTokenPair("CVC", "ETH"),
-			common.MustCreateTokenPair("MCO", "ETH"),
-			common.MustCreateTokenPair("GNT", "ETH"),
-			common.MustCreateTokenPair("ADX", "ETH"),
-			common.MustCreateTokenPair("EOS", "ETH"),
-			common.MustCreateTokenPair("PAY", "ETH"),
-			common.MustCreateTokenPair("BAT", "ETH"),
-			common.MustCreateTokenPair("KNC", "ETH"),
-		},
-		map[string]ethereum.Address{},
+		pairs,
+		tokens,
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		fees,
+		minDeposit,
 	}
 }
@@ -2,7 +2,6 @@ package liqui
 
 import (
 	"fmt"
-	"os"
 )
 
 type Interface interface {
@@ -10,6 +9,14 @@ type Interface interface {
 	AuthenticatedEndpoint(timepoint uint64) string
 }
 
+func getOrSetDefaultURL(base_url string) string {
+	if len(base_url) > 1 {
+		return base_url + ":5000"
+	} else {
+		return "http://127.0.0.1:5000"
+	}
+}
+
 type RealInterface struct{}
 
 func (self *RealInterface) PublicEndpoint(timepoint uint64) string {
@@ -26,37 +33,52 @@ func NewRealInterface() *RealInterface {
 	return &RealInterface{}
 }
 
-type SimulatedInterface struct{}
+type SimulatedInterface struct {
+	base_url string
+}
 
 func (self *SimulatedInterface) baseurl() string {
-	baseurl := "127.0.0.1"
-	if len(os.Args) > 1 {
-		baseurl = os.Args[1]
-	}
-	return baseurl + ":5000"
+	return getOrSetDefaultURL(self.base_url)
 }
 
 func (self *SimulatedInterface) PublicEndpoint(timepoint uint64) string {
 	return fmt.Sprintf("%s?timestamp=%d", self.baseurl(), timepoint)
-	// return "https://api.liqui.io/api/3"
 }
 
 func (self *SimulatedInterface) AuthenticatedEndpoint(timepoint uint64) string {
 	return fmt.Sprintf("%s?timestamp=%d", self.baseurl(), timepoint)
 }
 
-func NewSimulatedInterface() *SimulatedInterface {
-	return &SimulatedInterface{}
+func NewSimulatedInterface(flagVariable string) *SimulatedInterface {
+	return &SimulatedInterface{base_url: flagVariable}
 }
 
-type KovanInterface struct{}
+type RopstenInterface struct {
+	base_url string
+}
+
+func (self *RopstenInterface) baseurl() string {
+	return getOrSetDefaultURL(self.base_url)
+}
+
+func (self *RopstenInterface) PublicEndpoint(timepoint uint64) string {
+	return "https://api.liqui.io/api/3"
+}
+
+func (self *RopstenInterface) AuthenticatedEndpoint(timepoint uint64) string {
+	return self.baseurl()
+}
+
+func NewRopstenInterface(flagVariable string) *RopstenInterface {
+	return &RopstenInterface{base_url: flagVariable}
+}
+
+type KovanInterface struct {
+	base_url string
+}
 
 func (self *KovanInterface) baseurl() string {
-	baseurl := "http://simulator"
-	if len(os.Args) > 1 {
-		baseurl = os.Args[1]
-	}
-	return baseurl + ":5000"
+	return getOrSetDefaultURL(self.base_url)
 }
 
 func (self *KovanInterface) PublicEndpoint(timepoint uint64) string {
@@ -67,8 +89,8 @@ func (self *KovanInterface) AuthenticatedEndpoint(timepoint uint64) string {
 	return self.baseurl()
 }
 
-func NewKovanInterface() *KovanInterface {
-	return &KovanInterface{}
+func NewKovanInterface(flagVariable string) *KovanInterface {
+	return &KovanInterface{base_url: flagVariable}
 }
 
 type DevInterface struct{}
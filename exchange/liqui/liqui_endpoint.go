@@ -32,51 +32,40 @@ func nonce() string {
 	return strconv.Itoa(int(timestamp))
 }
 
-func (self *LiquiEndpoint) Depth(tokens string, timepoint uint64) (exchange.Liqresp, error) {
-	result := exchange.Liqresp{}
+func (self *LiquiEndpoint) publicRequest(timepoint uint64, paths ...string) ([]byte, error) {
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
 	u, err := url.Parse(self.interf.PublicEndpoint(timepoint))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	q := u.Query()
 	q.Set("ignore_invalid", "1")
 	u.RawQuery = q.Encode()
-	u.Path = path.Join(
-		u.Path,
-		"depth",
-		tokens,
-	)
+	u.Path = path.Join(append([]string{u.Path}, paths...)...)
 	req, _ := http.NewRequest("GET", u.String(), nil)
 	req.Header.Add("Accept", "application/json")
 	resp, err := client.Do(req)
-	if err == nil {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			resp_body, err := ioutil.ReadAll(resp.Body)
-			if err == nil {
-				json.Unmarshal(resp_body, &result)
-			}
-		} else {
-			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
-		}
+	if err != nil {
+		return nil, err
 	}
-	return result, err
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
 }
 
-func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
-	result := exchange.Liqcancel{}
+// tradingRequest posts a signed request to Liqui trade api, the method and
+// its params are passed in data
+func (self *LiquiEndpoint) tradingRequest(data url.Values, timepoint uint64) ([]byte, error) {
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
-	data := url.Values{}
-	data.Set("method", "CancelOrder")
-	data.Set("order_id", id)
 	data.Add("nonce", nonce())
 	params := data.Encode()
 	req, _ := http.NewRequest(
 		"POST",
-		self.interf.AuthenticatedEndpoint(common.GetTimepoint()),
+		self.interf.AuthenticatedEndpoint(timepoint),
 		bytes.NewBufferString(params),
 	)
 	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
@@ -85,209 +74,140 @@ func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
 	req.Header.Add("Key", self.signer.GetKey())
 	req.Header.Add("Sign", self.signer.Sign(params))
 	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-		return result, err
-	} else {
-		return result, errors.New("Cancel rejected by Liqui")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
 	}
+	resp_body, err := ioutil.ReadAll(resp.Body)
+	log.Printf("Liqui %s response: %s", data.Get("method"), common.TruncStr(resp_body))
+	return resp_body, err
+}
+
+func (self *LiquiEndpoint) Depth(tokens string, timepoint uint64) (exchange.Liqresp, error) {
+	result := exchange.Liqresp{}
+	resp_body, err := self.publicRequest(timepoint, "depth", tokens)
+	if err == nil {
+		json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) GetExchangeInfo() (exchange.LiqExchangeInfo, error) {
+	result := exchange.LiqExchangeInfo{}
+	resp_body, err := self.publicRequest(common.GetTimepoint(), "info")
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
+	result := exchange.Liqcancel{}
+	data := url.Values{}
+	data.Set("method", "CancelOrder")
+	data.Set("order_id", id)
+	resp_body, err := self.tradingRequest(data, common.GetTimepoint())
+	if err != nil {
+		return result, errors.New(fmt.Sprintf("Cancel rejected by Liqui: %s", err))
+	}
+	err = json.Unmarshal(resp_body, &result)
+	return result, err
 }
 
 func (self *LiquiEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
 	result := exchange.Liqtrade{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
 	data := url.Values{}
 	data.Set("method", "Trade")
 	data.Set("pair", fmt.Sprintf("%s_%s", strings.ToLower(base.ID), strings.ToLower(quote.ID)))
 	data.Set("type", tradeType)
 	data.Set("rate", strconv.FormatFloat(rate, 'f', -1, 64))
 	data.Set("amount", strconv.FormatFloat(amount, 'f', -1, 64))
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
-		self.interf.AuthenticatedEndpoint(timepoint),
-		bytes.NewBufferString(params),
-	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-		if err != nil {
-			return "", 0, 0, false, err
-		}
-		if result.Error != "" {
-			return "", 0, 0, false, errors.New(result.Error)
-		}
-		return strconv.FormatUint(result.Return.OrderID, 10), result.Return.Done, result.Return.Remaining, result.Return.OrderID == 0, nil
-	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
+	resp_body, err := self.tradingRequest(data, timepoint)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
 		return "", 0, 0, false, errors.New("Trade rejected by Liqui")
 	}
+	err = json.Unmarshal(resp_body, &result)
+	if err != nil {
+		return "", 0, 0, false, err
+	}
+	if result.Error != "" {
+		return "", 0, 0, false, errors.New(result.Error)
+	}
+	return strconv.FormatUint(result.Return.OrderID, 10), result.Return.Done, result.Return.Remaining, result.Return.OrderID == 0, nil
 }
 
-func (self *LiquiEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) error {
-	// ignoring timepoint because it's only relevant in simulation
+func (self *LiquiEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.Liqwithdraw, error) {
 	result := exchange.Liqwithdraw{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
 	data := url.Values{}
 	data.Set("method", "WithdrawCoin")
 	data.Set("coinName", token.ID)
 	data.Set("amount", strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64))
 	data.Set("address", address.Hex())
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
-		self.interf.AuthenticatedEndpoint(timepoint),
-		bytes.NewBufferString(params),
-	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-		if err != nil {
-			return err
-		}
-		if result.Error != "" {
-			return errors.New(result.Error)
-		}
-		return nil
-	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
-		return errors.New("withdraw rejected by Liqui")
+	resp_body, err := self.tradingRequest(data, timepoint)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		return result, errors.New("withdraw rejected by Liqui")
 	}
+	err = json.Unmarshal(resp_body, &result)
+	if err != nil {
+		return result, err
+	}
+	if result.Error != "" {
+		return result, errors.New(result.Error)
+	}
+	return result, nil
 }
 
 func (self *LiquiEndpoint) GetInfo(timepoint uint64) (exchange.Liqinfo, error) {
 	result := exchange.Liqinfo{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
 	data := url.Values{}
 	data.Set("method", "getInfo")
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	log.Printf("endpoint: %v\n", self.interf.AuthenticatedEndpoint(timepoint))
-	req, _ := http.NewRequest(
-		"POST",
-		self.interf.AuthenticatedEndpoint(timepoint),
-		bytes.NewBufferString(params),
-	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
+	resp_body, err := self.tradingRequest(data, timepoint)
 	if err == nil {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			resp_body, err := ioutil.ReadAll(resp.Body)
-			log.Printf("Liqui GetInfo response: %s", string(resp_body))
-			if err == nil {
-				json.Unmarshal(resp_body, &result)
-			}
-			log.Printf("Liqui GetInfo data: %v", result)
-		} else {
-			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
-		}
+		json.Unmarshal(resp_body, &result)
 	}
 	return result, err
 }
 
 func (self *LiquiEndpoint) OrderInfo(orderID string, timepoint uint64) (exchange.Liqorderinfo, error) {
 	result := exchange.Liqorderinfo{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
 	data := url.Values{}
 	data.Set("method", "OrderInfo")
 	data.Set("order_id", orderID)
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
-		self.interf.AuthenticatedEndpoint(timepoint),
-		bytes.NewBufferString(params),
-	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
+	resp_body, err := self.tradingRequest(data, timepoint)
 	if err == nil {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			resp_body, err := ioutil.ReadAll(resp.Body)
-			log.Printf("Liqui Order info response: %s", string(resp_body))
-			if err == nil {
-				json.Unmarshal(resp_body, &result)
-			}
-			log.Printf("Liqui Order info data: %v", result)
-		} else {
-			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
-		}
+		json.Unmarshal(resp_body, &result)
 	}
 	return result, err
 }
 
 func (self *LiquiEndpoint) ActiveOrders(timepoint uint64) (exchange.Liqorders, error) {
 	result := exchange.Liqorders{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
 	data := url.Values{}
 	data.Set("method", "ActiveOrders")
 	data.Set("pair", "") // all pairs
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
-		self.interf.AuthenticatedEndpoint(timepoint),
-		bytes.NewBufferString(params),
-	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetKey())
-	req.Header.Add("Sign", self.signer.Sign(params))
-	resp, err := client.Do(req)
+	resp_body, err := self.tradingRequest(data, timepoint)
+	if err == nil {
+		json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) TradeHistory(pair string, timepoint uint64) (exchange.LiqTradeHistory, error) {
+	result := exchange.LiqTradeHistory{}
+	data := url.Values{}
+	data.Set("method", "TradeHistory")
+	data.Set("pair", pair)
+	data.Set("count", "500")
+	resp_body, err := self.tradingRequest(data, timepoint)
 	if err == nil {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			resp_body, err := ioutil.ReadAll(resp.Body)
-			log.Printf("Liqui ActiveOrders response: %s", string(resp_body))
-			if err == nil {
-				json.Unmarshal(resp_body, &result)
-			}
-			log.Printf("Liqui ActiveOrders data: %v", result)
-		} else {
-			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Success != 1 {
+			err = errors.New("Getting trade history from Liqui failed: " + result.Error)
 		}
 	}
 	return result, err
@@ -301,12 +221,12 @@ func NewRealLiquiEndpoint(signer Signer) *LiquiEndpoint {
 	return &LiquiEndpoint{signer, NewRealInterface()}
 }
 
-func NewSimulatedLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewSimulatedInterface()}
+func NewSimulatedLiquiEndpoint(signer Signer, flagVariable string) *LiquiEndpoint {
+	return &LiquiEndpoint{signer, NewSimulatedInterface(flagVariable)}
 }
 
-func NewKovanLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewKovanInterface()}
+func NewKovanLiquiEndpoint(signer Signer, flagVariable string) *LiquiEndpoint {
+	return &LiquiEndpoint{signer, NewKovanInterface(flagVariable)}
 }
 
 func NewDevLiquiEndpoint(signer Signer) *LiquiEndpoint {
@@ -10,9 +10,13 @@ type Liqinfo struct {
 }
 
 type Liqwithdraw struct {
-	Success int                    `json:"success"`
-	Return  map[string]interface{} `json:"return"`
-	Error   string                 `json:"error"`
+	Success int `json:"success"`
+	Return  struct {
+		TID        uint64             `json:"tId"`
+		AmountSent float64            `json:"amountSent"`
+		Funds      map[string]float64 `json:"funds"`
+	} `json:"return"`
+	Error string `json:"error"`
 }
 
 type Liqtrade struct {
@@ -59,3 +63,47 @@ type Liqcancel struct {
 	} `json:"return"`
 	Error string `json:"error"`
 }
+
+type LiqPairInfo struct {
+	DecimalPlaces int     `json:"decimal_places"`
+	MinPrice      float64 `json:"min_price"`
+	MaxPrice      float64 `json:"max_price"`
+	MinAmount     float64 `json:"min_amount"`
+	MaxAmount     float64 `json:"max_amount"`
+	MinTotal      float64 `json:"min_total"`
+	Hidden        int     `json:"hidden"`
+	Fee           float64 `json:"fee"`
+}
+
+type LiqExchangeInfo struct {
+	ServerTime uint64                 `json:"server_time"`
+	Pairs      map[string]LiqPairInfo `json:"pairs"`
+}
+
+//	{
+//		"success": 1,
+//		"return": {
+//			"166830": {
+//				"pair": "omg_eth",
+//				"type": "sell",
+//				"amount": 1,
+//				"rate": 0.0215,
+//				"order_id": 343148,
+//				"is_your_order": 1,
+//				"timestamp": 1513328774
+//			}
+//		}
+//	}
+type LiqTradeHistory struct {
+	Success int `json:"success"`
+	Return  map[string]struct {
+		Pair        string  `json:"pair"`
+		Type        string  `json:"type"`
+		Amount      float64 `json:"amount"`
+		Rate        float64 `json:"rate"`
+		OrderID     uint64  `json:"order_id"`
+		IsYourOrder int     `json:"is_your_order"`
+		Timestamp   uint64  `json:"timestamp"`
+	} `json:"return"`
+	Error string `json:"error"`
+}
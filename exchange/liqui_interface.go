@@ -12,15 +12,19 @@ type LiquiInterface interface {
 
 	GetInfo(timepoint uint64) (Liqinfo, error)
 
+	GetExchangeInfo() (LiqExchangeInfo, error)
+
 	ActiveOrders(timepoint uint64) (Liqorders, error)
 
 	OrderInfo(orderID string, timepoint uint64) (Liqorderinfo, error)
 
+	TradeHistory(pair string, timepoint uint64) (LiqTradeHistory, error)
+
 	Withdraw(
 		token common.Token,
 		amount *big.Int,
 		address ethereum.Address,
-		timepoint uint64) error
+		timepoint uint64) (Liqwithdraw, error)
 
 	Trade(
 		tradeType string,
@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type testLiquiInterface struct {
+	OrderInfoMock string
+}
+
+func (self testLiquiInterface) Depth(tokens string, timepoint uint64) (Liqresp, error) {
+	return Liqresp{}, nil
+}
+func (self testLiquiInterface) GetInfo(timepoint uint64) (Liqinfo, error) {
+	return Liqinfo{}, nil
+}
+func (self testLiquiInterface) GetExchangeInfo() (LiqExchangeInfo, error) {
+	return LiqExchangeInfo{}, nil
+}
+func (self testLiquiInterface) ActiveOrders(timepoint uint64) (Liqorders, error) {
+	return Liqorders{}, nil
+}
+func (self testLiquiInterface) OrderInfo(orderID string, timepoint uint64) (Liqorderinfo, error) {
+	res := Liqorderinfo{}
+	err := json.Unmarshal([]byte(self.OrderInfoMock), &res)
+	return res, err
+}
+func (self testLiquiInterface) TradeHistory(pair string, timepoint uint64) (LiqTradeHistory, error) {
+	return LiqTradeHistory{}, nil
+}
+func (self testLiquiInterface) Withdraw(
+	token common.Token,
+	amount *big.Int,
+	address ethereum.Address,
+	timepoint uint64) (Liqwithdraw, error) {
+	res := Liqwithdraw{}
+	res.Success = 1
+	res.Return.TID = 166830
+	return res, nil
+}
+func (self testLiquiInterface) Trade(
+	tradeType string,
+	base, quote common.Token,
+	rate, amount float64,
+	timepoint uint64) (string, float64, float64, bool, error) {
+	return "", 0, 0, false, nil
+}
+func (self testLiquiInterface) CancelOrder(id string) (Liqcancel, error) {
+	return Liqcancel{}, nil
+}
+
+func getTestLiqui(orderInfo string) *Liqui {
+	return &Liqui{
+		testLiquiInterface{orderInfo},
+		[]common.TokenPair{},
+		[]common.Token{},
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		common.ExchangeFees{},
+		common.ExchangesMinDeposit{},
+	}
+}
+
+func TestLiquiWithdrawStatus(t *testing.T) {
+	liq := getTestLiqui("")
+	token := common.Token{ID: "OMG", Address: "0x3333333333333333333333333333333333333333", Decimal: 18}
+	timepoint := common.GetTimepoint()
+	id, err := liq.Withdraw(token, big.NewInt(1), ethereum.Address{}, timepoint)
+	if err != nil {
+		t.Fatalf("Expected withdraw to succeed, got error: %v", err)
+	}
+	status, _, err := liq.WithdrawStatus(id, "OMG", 1, timepoint+1000)
+	if err != nil || status != "" {
+		t.Fatalf("Expected withdrawal to be pending right after submitted, got %v, error: %v", status, err)
+	}
+	status, _, err = liq.WithdrawStatus(id, "OMG", 1, timepoint+WITHDRAW_WAITING_TIME+1)
+	if err != nil || status != "done" {
+		t.Fatalf("Expected withdrawal to be done after waiting time, got %v, error: %v", status, err)
+	}
+	if _, _, err = liq.WithdrawStatus("166830", "OMG", 1, timepoint); err == nil {
+		t.Fatalf("Expected malformed withdraw id to return an error")
+	}
+}
+
+func TestLiquiOrderStatus(t *testing.T) {
+	liq := getTestLiqui(`{"success":1,"return":{"343152":{"pair":"omg_eth","type":"sell","start_amount":13.345,"amount":12.345,"rate":485,"timestamp_created":1418654530,"status":0}}}`)
+	status, err := liq.OrderStatus("343152", "OMG", "ETH")
+	if err != nil || status != "" {
+		t.Fatalf("Expected active order to be pending, got %v, error: %v", status, err)
+	}
+	liq = getTestLiqui(`{"success":1,"return":{"343152":{"pair":"omg_eth","type":"sell","start_amount":13.345,"amount":0,"rate":485,"timestamp_created":1418654530,"status":1}}}`)
+	status, err = liq.OrderStatus("343152", "OMG", "ETH")
+	if err != nil || status != "done" {
+		t.Fatalf("Expected executed order to be done, got %v, error: %v", status, err)
+	}
+}
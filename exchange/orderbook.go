@@ -0,0 +1,150 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	// number of price levels of each side returned from a local orderbook
+	ORDERBOOK_DEPTH int = 50
+	// a local orderbook which is not updated for longer than this
+	// duration (in millisecond) is considered out of sync
+	ORDERBOOK_STALE_TIME uint64 = 30000
+)
+
+// OrderbookStream maintains local orderbooks from an exchange depth feed.
+type OrderbookStream interface {
+	// Orderbook returns the local orderbook of the pair, ok is false
+	// when the local orderbook is not in sync with the exchange
+	Orderbook(pair common.TokenPairID, timepoint uint64) (price common.ExchangePrice, ok bool)
+}
+
+// Orderbook is a local orderbook of one pair, it is safe for concurrent
+// use.
+type Orderbook struct {
+	mu        sync.RWMutex
+	bids      map[float64]float64
+	asks      map[float64]float64
+	updateID  int64
+	synced    bool
+	updatedAt uint64
+}
+
+func NewOrderbook() *Orderbook {
+	return &Orderbook{
+		bids: map[float64]float64{},
+		asks: map[float64]float64{},
+	}
+}
+
+func applyLevels(side map[float64]float64, levels []common.PriceEntry) {
+	for _, level := range levels {
+		if level.Quantity == 0 {
+			delete(side, level.Rate)
+		} else {
+			side[level.Rate] = level.Quantity
+		}
+	}
+}
+
+// Reset replaces the whole orderbook with a snapshot
+func (self *Orderbook) Reset(bids, asks []common.PriceEntry, updateID int64, timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.bids = map[float64]float64{}
+	self.asks = map[float64]float64{}
+	applyLevels(self.bids, bids)
+	applyLevels(self.asks, asks)
+	self.updateID = updateID
+	self.synced = true
+	self.updatedAt = timepoint
+}
+
+// Update applies a diff to the orderbook, levels with zero quantity are
+// removed
+func (self *Orderbook) Update(bids, asks []common.PriceEntry, updateID int64, timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	applyLevels(self.bids, bids)
+	applyLevels(self.asks, asks)
+	self.updateID = updateID
+	self.updatedAt = timepoint
+}
+
+// Invalidate marks the orderbook as out of sync, it stays out of sync
+// until the next Reset
+func (self *Orderbook) Invalidate() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.synced = false
+}
+
+func (self *Orderbook) UpdateID() int64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.updateID
+}
+
+func sortedLevels(side map[float64]float64, descending bool, limit int) []common.PriceEntry {
+	rates := make([]float64, 0, len(side))
+	for rate := range side {
+		rates = append(rates, rate)
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.Float64Slice(rates)))
+	} else {
+		sort.Float64s(rates)
+	}
+	if len(rates) > limit {
+		rates = rates[:limit]
+	}
+	result := []common.PriceEntry{}
+	for _, rate := range rates {
+		result = append(result, common.PriceEntry{Quantity: side[rate], Rate: rate})
+	}
+	return result
+}
+
+// ExchangePrice returns the best ORDERBOOK_DEPTH levels of each side, ok is
+// false if the orderbook is out of sync or stale at timepoint
+func (self *Orderbook) ExchangePrice(timepoint uint64) (common.ExchangePrice, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	if !self.synced || timepoint > self.updatedAt+ORDERBOOK_STALE_TIME {
+		return common.ExchangePrice{}, false
+	}
+	return common.ExchangePrice{
+		Valid:      true,
+		Timestamp:  common.Timestamp(fmt.Sprintf("%d", timepoint)),
+		Bids:       sortedLevels(self.bids, true, ORDERBOOK_DEPTH),
+		Asks:       sortedLevels(self.asks, false, ORDERBOOK_DEPTH),
+		ReturnTime: common.GetTimestamp(),
+	}, true
+}
+
+// fetchStreamPriceData fills result with orderbooks from stream and returns
+// pairs whose local orderbooks are not in sync, they have to be polled
+func fetchStreamPriceData(
+	stream OrderbookStream,
+	pairs []common.TokenPair,
+	result map[common.TokenPairID]common.ExchangePrice,
+	timepoint uint64) []common.TokenPair {
+
+	if stream == nil {
+		return pairs
+	}
+	remaining := []common.TokenPair{}
+	for _, pair := range pairs {
+		price, ok := stream.Orderbook(pair.PairID(), timepoint)
+		if ok {
+			result[pair.PairID()] = price
+		} else {
+			remaining = append(remaining, pair)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testOrderbookStream struct {
+	book *Orderbook
+	pair common.TokenPairID
+}
+
+func (self testOrderbookStream) Orderbook(pair common.TokenPairID, timepoint uint64) (common.ExchangePrice, bool) {
+	if pair != self.pair {
+		return common.ExchangePrice{}, false
+	}
+	return self.book.ExchangePrice(timepoint)
+}
+
+func TestOrderbook(t *testing.T) {
+	book := NewOrderbook()
+	if _, ok := book.ExchangePrice(1000); ok {
+		t.Fatalf("Orderbook must not be in sync before reset")
+	}
+	book.Reset(
+		[]common.PriceEntry{{Quantity: 1, Rate: 0.04}, {Quantity: 2, Rate: 0.05}},
+		[]common.PriceEntry{{Quantity: 3, Rate: 0.07}, {Quantity: 4, Rate: 0.06}},
+		10, 1000,
+	)
+	book.Update(
+		[]common.PriceEntry{{Quantity: 0, Rate: 0.05}, {Quantity: 5, Rate: 0.045}},
+		[]common.PriceEntry{{Quantity: 6, Rate: 0.07}},
+		11, 2000,
+	)
+	price, ok := book.ExchangePrice(3000)
+	if !ok || !price.Valid {
+		t.Fatalf("Orderbook must be in sync")
+	}
+	if len(price.Bids) != 2 || price.Bids[0].Rate != 0.045 || price.Bids[0].Quantity != 5 || price.Bids[1].Rate != 0.04 {
+		t.Fatalf("Bids are not as expected: %+v", price.Bids)
+	}
+	if len(price.Asks) != 2 || price.Asks[0].Rate != 0.06 || price.Asks[1].Rate != 0.07 || price.Asks[1].Quantity != 6 {
+		t.Fatalf("Asks are not as expected: %+v", price.Asks)
+	}
+	if book.UpdateID() != 11 {
+		t.Fatalf("Expected update id 11, got %d", book.UpdateID())
+	}
+	if _, ok = book.ExchangePrice(2000 + ORDERBOOK_STALE_TIME + 1); ok {
+		t.Fatalf("Stale orderbook must not be in sync")
+	}
+	book.Invalidate()
+	if _, ok = book.ExchangePrice(3000); ok {
+		t.Fatalf("Invalidated orderbook must not be in sync")
+	}
+
+	book.Reset(price.Bids, price.Asks, 12, 3000)
+	streamed := common.TokenPair{Base: common.Token{ID: "ETH"}, Quote: common.Token{ID: "BTC"}}
+	polled := common.TokenPair{Base: common.Token{ID: "OMG"}, Quote: common.Token{ID: "ETH"}}
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	pairs := fetchStreamPriceData(
+		testOrderbookStream{book, streamed.PairID()},
+		[]common.TokenPair{streamed, polled},
+		result,
+		3000,
+	)
+	if len(pairs) != 1 || pairs[0].PairID() != polled.PairID() {
+		t.Fatalf("Expected only %s to be polled, got %+v", polled.PairID(), pairs)
+	}
+	if _, found := result[streamed.PairID()]; !found || len(result) != 1 {
+		t.Fatalf("Expected orderbook of %s from stream, got %+v", streamed.PairID(), result)
+	}
+}
@@ -3,10 +3,26 @@ package exchange
 import (
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"math/big"
+	"sync"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	stableexblockchain "github.com/KyberNetwork/reserve-data/exchange/stableex/blockchain"
 	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// DGX is backed by 1 gram of gold while the gold feed is quoted in
+	// troy ounce
+	GRAMS_PER_TROY_OUNCE float64 = 31.1034768
+	// quantity of each side of the orderbook constructed from stable
+	// token params
+	STABLE_EX_ORDERBOOK_QUANTITY float64 = 1000
 )
 
 type StableEx struct {
@@ -14,6 +30,10 @@ type StableEx struct {
 	exchangeInfo *common.ExchangeInfo
 	fees         common.ExchangeFees
 	mindeposit   common.ExchangesMinDeposit
+	blockchain   StableExBlockchain
+	storage      StableExStorage
+	mu           sync.Mutex
+	orders       map[string]common.StableExOrder
 }
 
 func (self *StableEx) TokenAddresses() map[string]ethereum.Address {
@@ -66,17 +86,74 @@ func (self *StableEx) Name() string {
 	return "stable token exchange"
 }
 
-func (self *StableEx) QueryOrder(symbol string, id uint64) (done float64, remaining float64, finished bool, err error) {
-	// TODO: see if trade order (a tx to dgx contract) is successful or not
-	// - successful: done = order amount, remaining = 0, finished = true, err = nil
-	// - failed: done = 0, remaining = order amount, finished = false, err = some error
-	// - pending: done = 0, remaining = order amount, finished = false, err = nil
-	return 0, 0, false, errors.New("not supported")
+// QueryOrder reports a trade order, which is a tx to the stable token
+// contract, as done once its tx is mined and the contract traded with it,
+// and as failed once the tx is failed or lost.
+func (self *StableEx) QueryOrder(id string, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
+	order, found := self.getOrder(id)
+	if !found {
+		return 0, 0, false, fmt.Errorf("order %s is not found", id)
+	}
+	status, done, err := self.orderStatus(id, order, timepoint)
+	switch status {
+	case "done":
+		self.removeOrder(id)
+		return done, math.Max(order.Amount-done, 0), true, nil
+	case "failed":
+		self.removeOrder(id)
+		return 0, order.Amount, false, err
+	default:
+		return 0, order.Amount, false, err
+	}
 }
 
 func (self *StableEx) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
-	// TODO: communicate with dgx connector to do the trade
-	return "not supported", 0, 0, false, errors.New("not supported")
+	if quote.ID != "ETH" {
+		return "", 0, 0, false, fmt.Errorf("stable exchange doesn't support %s as quote token", quote.ID)
+	}
+	ask, bid, err := self.prices(base.ID, timepoint)
+	if err != nil {
+		return "", 0, 0, false, err
+	}
+	var tx *types.Transaction
+	switch tradeType {
+	case "buy":
+		if rate < ask {
+			return "", 0, 0, false, fmt.Errorf("buy rate %f is lower than stable exchange ask price %f", rate, ask)
+		}
+		tx, err = self.blockchain.BuyFromStableEx(
+			ethereum.HexToAddress(base.Address),
+			common.FloatToBigInt(amount*rate, quote.Decimal),
+			common.FloatToBigInt(amount, base.Decimal),
+		)
+	case "sell":
+		if rate > bid {
+			return "", 0, 0, false, fmt.Errorf("sell rate %f is higher than stable exchange bid price %f", rate, bid)
+		}
+		tx, err = self.blockchain.SellToStableEx(
+			ethereum.HexToAddress(base.Address),
+			common.FloatToBigInt(amount, base.Decimal),
+			common.FloatToBigInt(amount*rate, quote.Decimal),
+		)
+	default:
+		return "", 0, 0, false, fmt.Errorf("unsupported trade type %s", tradeType)
+	}
+	if tx == nil {
+		return "", 0, 0, false, err
+	}
+	// a tx which no node accepted may still be mined so its order is
+	// tracked anyway
+	id = tx.Hash().Hex()
+	self.storeOrder(id, common.StableExOrder{
+		Base:      base.ID,
+		Quote:     quote.ID,
+		Type:      tradeType,
+		Rate:      rate,
+		Amount:    amount,
+		Timestamp: timepoint,
+	})
+	log.Printf("Stable exchange: %s %f %s at %f, tx: %s", tradeType, amount, base.ID, rate, id)
+	return id, 0, amount, false, err
 }
 
 func (self *StableEx) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
@@ -88,9 +165,57 @@ func (self *StableEx) CancelOrder(id, base, quote string) error {
 	return errors.New("Dgx doesn't support trade cancelling")
 }
 
+// FetchPriceData constructs one level orderbooks around the gold price of
+// the stable tokens, spreads are taken from the confirmed stable token
+// params.
 func (self *StableEx) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	result := map[common.TokenPairID]common.ExchangePrice{}
-	// TODO: Get price data from dgx connector and construct valid orderbooks
+	for _, pair := range self.pairs {
+		price := common.ExchangePrice{}
+		price.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+		price.Valid = true
+		ask, bid, err := self.prices(pair.Base.ID, timepoint)
+		price.ReturnTime = common.GetTimestamp()
+		if err != nil {
+			price.Valid = false
+			price.Error = err.Error()
+		} else {
+			price.Asks = []common.PriceEntry{
+				common.PriceEntry{Quantity: STABLE_EX_ORDERBOOK_QUANTITY, Rate: ask},
+			}
+			price.Bids = []common.PriceEntry{
+				common.PriceEntry{Quantity: STABLE_EX_ORDERBOOK_QUANTITY, Rate: bid},
+			}
+		}
+		result[pair.PairID()] = price
+	}
+	return result, nil
+}
+
+// FetchOrderData returns trade orders whose txs are not mined yet
+func (self *StableEx) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
+	result := common.OrderEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	result.Data = []common.Order{}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for id, order := range self.orders {
+		result.Data = append(result.Data, common.Order{
+			ID:          id,
+			Base:        order.Base,
+			Quote:       order.Quote,
+			OrderId:     id,
+			Price:       order.Rate,
+			OrigQty:     order.Amount,
+			ExecutedQty: 0,
+			TimeInForce: "GTC",
+			Type:        "LIMIT",
+			Side:        order.Type,
+			Time:        order.Timestamp,
+		})
+	}
+	result.ReturnTime = common.GetTimestamp()
 	return result, nil
 }
 
@@ -114,8 +239,7 @@ func (self *StableEx) FetchTradeHistory(timepoint uint64) (map[common.TokenPairI
 }
 
 func (self *StableEx) DepositStatus(id common.ActivityID, txHash, currency string, amount float64, timepoint uint64) (string, error) {
-	// TODO: checking txHash status
-	return "", errors.New("not supported")
+	return self.txStatus(txHash, id.Timepoint, timepoint)
 }
 
 func (self *StableEx) WithdrawStatus(id, currency string, amount float64, timepoint uint64) (string, string, error) {
@@ -124,20 +248,184 @@ func (self *StableEx) WithdrawStatus(id, currency string, amount float64, timepo
 }
 
 func (self *StableEx) OrderStatus(id string, base, quote string) (string, error) {
-	// TODO: checking id (id is the txhash) status
-	return "", errors.New("not supported")
+	order, found := self.getOrder(id)
+	if !found {
+		return "", fmt.Errorf("order %s is not found", id)
+	}
+	status, _, err := self.orderStatus(id, order, common.GetTimepoint())
+	if status != "" {
+		self.removeOrder(id)
+	}
+	return status, err
+}
+
+// orderStatus returns status of trade order id and the base amount it
+// traded. A mined order is only done if the Trade event of the stable
+// token contract shows the operator received at least what the order
+// asked for, otherwise it is failed.
+func (self *StableEx) orderStatus(id string, order common.StableExOrder, timepoint uint64) (string, float64, error) {
+	status, err := self.txStatus(id, order.Timestamp, timepoint)
+	if err != nil {
+		return "", 0, err
+	}
+	if status == "failed" {
+		return "failed", 0, fmt.Errorf("trade tx %s is failed", id)
+	}
+	if status != "done" {
+		return "", 0, nil
+	}
+	src, dest, found, err := self.blockchain.StableExTrade(ethereum.HexToHash(id))
+	if err != nil {
+		return "", 0, err
+	}
+	if !found {
+		return "failed", 0, fmt.Errorf("trade tx %s is mined but the stable token contract didn't trade", id)
+	}
+	pair, err := self.pair(order.Base, order.Quote)
+	if err != nil {
+		return "", 0, err
+	}
+	var expected *big.Int
+	var done float64
+	if order.Type == "buy" {
+		expected = common.FloatToBigInt(order.Amount, pair.Base.Decimal)
+		done = common.BigToFloat(dest, pair.Base.Decimal)
+	} else {
+		expected = common.FloatToBigInt(order.Amount*order.Rate, pair.Quote.Decimal)
+		done = common.BigToFloat(src, pair.Base.Decimal)
+	}
+	if dest.Cmp(expected) < 0 {
+		return "failed", 0, fmt.Errorf("trade tx %s received %s, expected at least %s", id, dest.Text(10), expected.Text(10))
+	}
+	return "done", done, nil
+}
+
+func (self *StableEx) pair(base, quote string) (common.TokenPair, error) {
+	for _, pair := range self.pairs {
+		if pair.Base.ID == base && pair.Quote.ID == quote {
+			return pair, nil
+		}
+	}
+	return common.TokenPair{}, fmt.Errorf("pair %s-%s is not supported", base, quote)
+}
+
+// txStatus maps mining status of a tx sent at timestamp to exchange status.
+// A lost tx is only considered failed after 15 minutes, if timestamp is
+// unknown (0) it is considered failed right away.
+func (self *StableEx) txStatus(txHash string, timestamp, timepoint uint64) (string, error) {
+	status, _, err := self.blockchain.TxStatus(ethereum.HexToHash(txHash))
+	if err != nil {
+		return "", err
+	}
+	switch status {
+	case "mined":
+		return "done", nil
+	case "failed":
+		return "failed", nil
+	case "lost":
+		if timepoint-timestamp > uint64(15*time.Minute/time.Millisecond) {
+			return "failed", nil
+		}
+		return "", nil
+	default:
+		return "", nil
+	}
+}
+
+// prices returns ask and bid prices of token in ETH. Reference price is
+// the median of the gold feed, AskSpread and BidSpread of the confirmed stable
+// token params are in basis points.
+func (self *StableEx) prices(tokenID string, timepoint uint64) (ask float64, bid float64, err error) {
+	allParams, err := self.storage.GetStableTokenParams()
+	if err != nil {
+		return 0, 0, err
+	}
+	params, ok := allParams[tokenID].(map[string]interface{})
+	if !ok {
+		return 0, 0, fmt.Errorf("stable token params of %s is not confirmed", tokenID)
+	}
+	askSpread, ok := params["AskSpread"].(float64)
+	if !ok {
+		return 0, 0, fmt.Errorf("AskSpread of %s is missing", tokenID)
+	}
+	bidSpread, ok := params["BidSpread"].(float64)
+	if !ok {
+		return 0, 0, fmt.Errorf("BidSpread of %s is missing", tokenID)
+	}
+	version, err := self.storage.CurrentGoldInfoVersion(timepoint)
+	if err != nil {
+		return 0, 0, err
+	}
+	gold, err := self.storage.GetGoldInfo(version)
+	if err != nil {
+		return 0, 0, err
+	}
+	if gold.Median <= 0 {
+		return 0, 0, fmt.Errorf("gold price is not available: no valid and fresh %s quote", common.GOLD_PAIR)
+	}
+	price := gold.Median / GRAMS_PER_TROY_OUNCE
+	return price * (1 + askSpread/10000), price * (1 - bidSpread/10000), nil
+}
+
+// storeOrder keeps the order in memory even if it can't be stored as its
+// tx is already sent
+func (self *StableEx) storeOrder(id string, order common.StableExOrder) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.orders[id] = order
+	if err := self.storage.StoreStableExOrder(id, order); err != nil {
+		log.Printf("Stable exchange: storing order %s failed: %s", id, err)
+	}
+}
+
+func (self *StableEx) getOrder(id string) (common.StableExOrder, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	order, found := self.orders[id]
+	return order, found
+}
+
+func (self *StableEx) removeOrder(id string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.orders, id)
+	if err := self.storage.RemoveStableExOrder(id); err != nil {
+		log.Printf("Stable exchange: removing order %s failed: %s", id, err)
+	}
 }
 
 func (self *StableEx) GetMinDeposit() common.ExchangesMinDeposit {
 	return self.mindeposit
 }
 
-func NewStableEx(addressConfig map[string]string, feeConfig common.ExchangeFees, minDepositConfig common.ExchangesMinDeposit) *StableEx {
+func NewStableEx(
+	addressConfig map[string]string,
+	feeConfig common.ExchangeFees,
+	blockchain *blockchain.BaseBlockchain,
+	contract ethereum.Address,
+	signer blockchain.Signer, nonce blockchain.NonceCorpus, storage StableExStorage,
+	minDepositConfig common.ExchangesMinDeposit) (*StableEx, error) {
+
 	_, pairs, fees, mindeposit := getExchangePairsAndFeesFromConfig(addressConfig, feeConfig, minDepositConfig, "stable_exchange")
-	return &StableEx{
-		pairs,
-		common.NewExchangeInfo(),
-		fees,
-		mindeposit,
+	if contract.Big().Cmp(ethereum.Big0) == 0 {
+		return nil, errors.New("stable contract address is not configured")
 	}
+	bc, err := stableexblockchain.NewBlockchain(blockchain, contract, signer, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("Cant create stable exchange's blockchain: %s", err)
+	}
+	// orders placed before a restart are still pending
+	orders, err := storage.GetStableExOrders()
+	if err != nil {
+		return nil, fmt.Errorf("Cant load stable exchange orders: %s", err)
+	}
+	return &StableEx{
+		pairs:        pairs,
+		exchangeInfo: common.NewExchangeInfo(),
+		fees:         fees,
+		mindeposit:   mindeposit,
+		blockchain:   bc,
+		storage:      storage,
+		orders:       orders,
+	}, nil
 }
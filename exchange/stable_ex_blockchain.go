@@ -0,0 +1,15 @@
+package exchange
+
+import (
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type StableExBlockchain interface {
+	BuyFromStableEx(token ethereum.Address, ethAmount *big.Int, minAmount *big.Int) (*types.Transaction, error)
+	SellToStableEx(token ethereum.Address, amount *big.Int, minETH *big.Int) (*types.Transaction, error)
+	StableExTrade(hash ethereum.Hash) (srcAmount *big.Int, destAmount *big.Int, found bool, err error)
+	TxStatus(hash ethereum.Hash) (string, uint64, error)
+}
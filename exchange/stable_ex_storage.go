@@ -0,0 +1,18 @@
+package exchange
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// StableExStorage gives stable exchange access to the confirmed stable
+// token params and the gold feed it uses to price the stable tokens, and
+// keeps its pending trade orders across restarts.
+type StableExStorage interface {
+	GetStableTokenParams() (map[string]interface{}, error)
+	CurrentGoldInfoVersion(timepoint uint64) (common.Version, error)
+	GetGoldInfo(version common.Version) (common.GoldData, error)
+
+	StoreStableExOrder(id string, order common.StableExOrder) error
+	GetStableExOrders() (map[string]common.StableExOrder, error)
+	RemoveStableExOrder(id string) error
+}
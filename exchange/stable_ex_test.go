@@ -0,0 +1,170 @@
+package exchange
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type testStableExBlockchain struct {
+	status string
+	// amounts of the Trade event of the stable token contract, nil if the
+	// contract didn't trade
+	src  *big.Int
+	dest *big.Int
+}
+
+func (self *testStableExBlockchain) BuyFromStableEx(token ethereum.Address, ethAmount *big.Int, minAmount *big.Int) (*types.Transaction, error) {
+	return types.NewTransaction(0, token, ethAmount, big.NewInt(200000), big.NewInt(1), nil), nil
+}
+
+func (self *testStableExBlockchain) SellToStableEx(token ethereum.Address, amount *big.Int, minETH *big.Int) (*types.Transaction, error) {
+	return types.NewTransaction(1, token, big.NewInt(0), big.NewInt(200000), big.NewInt(1), nil), nil
+}
+
+func (self *testStableExBlockchain) StableExTrade(hash ethereum.Hash) (*big.Int, *big.Int, bool, error) {
+	return self.src, self.dest, self.dest != nil, nil
+}
+
+func (self *testStableExBlockchain) TxStatus(hash ethereum.Hash) (string, uint64, error) {
+	return self.status, 0, nil
+}
+
+type testStableExStorage struct {
+	orders map[string]common.StableExOrder
+}
+
+func (self testStableExStorage) GetStableTokenParams() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"DGX": map[string]interface{}{
+			"AskSpread":            float64(50),
+			"BidSpread":            float64(50),
+			"PriceUpdateThreshold": 0.1,
+		},
+	}, nil
+}
+
+func (self testStableExStorage) CurrentGoldInfoVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(1), nil
+}
+
+func (self testStableExStorage) GetGoldInfo(version common.Version) (common.GoldData, error) {
+	return common.NewGoldData(1, map[string]common.GoldQuote{
+		"OneForge": {Pair: common.GOLD_PAIR, Rate: GRAMS_PER_TROY_OUNCE * 0.1, Valid: true},
+	}), nil
+}
+
+func (self testStableExStorage) StoreStableExOrder(id string, order common.StableExOrder) error {
+	self.orders[id] = order
+	return nil
+}
+
+func (self testStableExStorage) GetStableExOrders() (map[string]common.StableExOrder, error) {
+	result := map[string]common.StableExOrder{}
+	for id, order := range self.orders {
+		result[id] = order
+	}
+	return result, nil
+}
+
+func (self testStableExStorage) RemoveStableExOrder(id string) error {
+	delete(self.orders, id)
+	return nil
+}
+
+func getTestStableEx(bc *testStableExBlockchain) *StableEx {
+	dgx := common.Token{ID: "DGX", Address: "0x4f3afec4e5a3f2a6a1a411def7d7dfe50ee057bf", Decimal: 9}
+	eth := common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18}
+	return &StableEx{
+		pairs:        []common.TokenPair{{Base: dgx, Quote: eth}},
+		exchangeInfo: common.NewExchangeInfo(),
+		blockchain:   bc,
+		storage:      testStableExStorage{orders: map[string]common.StableExOrder{}},
+		orders:       map[string]common.StableExOrder{},
+	}
+}
+
+func TestStableExPriceData(t *testing.T) {
+	stableEx := getTestStableEx(&testStableExBlockchain{})
+	data, err := stableEx.FetchPriceData(common.GetTimepoint())
+	if err != nil {
+		t.Fatalf("Expected fetching price data to succeed, got error: %v", err)
+	}
+	price := data[common.NewTokenPairID("DGX", "ETH")]
+	if !price.Valid || len(price.Asks) != 1 || len(price.Bids) != 1 {
+		t.Fatalf("Expected a valid one level orderbook, got %+v", price)
+	}
+	if price.Asks[0].Rate <= 0.1 || price.Bids[0].Rate >= 0.1 {
+		t.Fatalf("Expected ask and bid to be spread around 0.1, got ask %f, bid %f", price.Asks[0].Rate, price.Bids[0].Rate)
+	}
+}
+
+func TestStableExTrade(t *testing.T) {
+	bc := &testStableExBlockchain{}
+	stableEx := getTestStableEx(bc)
+	pair := stableEx.pairs[0]
+	timepoint := common.GetTimepoint()
+	if _, _, _, _, err := stableEx.Trade("buy", pair.Base, pair.Quote, 0.1, 10, timepoint); err == nil {
+		t.Fatalf("Expected buying under the ask price to be rejected")
+	}
+	id, done, remaining, finished, err := stableEx.Trade("buy", pair.Base, pair.Quote, 0.101, 10, timepoint)
+	if err != nil || done != 0 || remaining != 10 || finished {
+		t.Fatalf("Expected trade to be pending, got done %f, remaining %f, finished %t, error: %v", done, remaining, finished, err)
+	}
+	status, err := stableEx.OrderStatus(id, "DGX", "ETH")
+	if err != nil || status != "" {
+		t.Fatalf("Expected order to be pending while its tx is not mined, got %s, error: %v", status, err)
+	}
+	storage := stableEx.storage.(testStableExStorage)
+	if order, stored := storage.orders[id]; !stored || order.Amount != 10 || order.Timestamp != timepoint {
+		t.Fatalf("Expected pending order to be stored, got %+v", storage.orders)
+	}
+	bc.status = "mined"
+	bc.src = common.FloatToBigInt(1.01, 18)
+	bc.dest = common.FloatToBigInt(10, 9)
+	done, remaining, finished, err = stableEx.QueryOrder(id, timepoint)
+	if err != nil || done != 10 || remaining != 0 || !finished {
+		t.Fatalf("Expected order to be finished once the contract traded, got done %f, remaining %f, finished %t, error: %v", done, remaining, finished, err)
+	}
+	if len(storage.orders) != 0 {
+		t.Fatalf("Expected finished order to be removed from storage, got %+v", storage.orders)
+	}
+}
+
+func TestStableExTradeNotFilled(t *testing.T) {
+	bc := &testStableExBlockchain{}
+	stableEx := getTestStableEx(bc)
+	pair := stableEx.pairs[0]
+	id, _, _, _, err := stableEx.Trade("sell", pair.Base, pair.Quote, 0.099, 10, common.GetTimepoint())
+	if err != nil {
+		t.Fatalf("Expected trade to be sent, got error: %v", err)
+	}
+	// 10 DGX are sold for 0.9 ETH instead of at least 0.99 ETH
+	bc.status = "mined"
+	bc.src = common.FloatToBigInt(10, 9)
+	bc.dest = common.FloatToBigInt(0.9, 18)
+	status, err := stableEx.OrderStatus(id, "DGX", "ETH")
+	if err == nil || status != "failed" {
+		t.Fatalf("Expected order to be failed when it received less than expected, got %s, error: %v", status, err)
+	}
+	if _, found := stableEx.getOrder(id); found {
+		t.Fatalf("Expected failed order to be removed")
+	}
+
+	// the tx is mined without trading with the contract
+	bc.status = ""
+	id, _, _, _, err = stableEx.Trade("sell", pair.Base, pair.Quote, 0.099, 10, common.GetTimepoint())
+	if err != nil {
+		t.Fatalf("Expected trade to be sent, got error: %v", err)
+	}
+	bc.status = "mined"
+	bc.src = nil
+	bc.dest = nil
+	status, err = stableEx.OrderStatus(id, "DGX", "ETH")
+	if err == nil || status != "failed" {
+		t.Fatalf("Expected order to be failed when the contract didn't trade, got %s, error: %v", status, err)
+	}
+}
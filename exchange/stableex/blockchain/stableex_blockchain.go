@@ -0,0 +1,140 @@
+package blockchain
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	STABLE_EX_OP string = "stable_ex_op"
+	// gas limit of trades with the stable token contract, it is not
+	// estimated as a sell is sent before its approval is mined
+	STABLE_EX_TRADE_GAS int64 = 200000
+)
+
+type Blockchain struct {
+	*blockchain.BaseBlockchain
+	contract   *blockchain.Contract
+	tradeEvent ethereum.Hash
+}
+
+// BuyFromStableEx sends ethAmount to the stable token contract to buy
+// token, the tx is reverted if less than minAmount of token is bought.
+func (self *Blockchain) BuyFromStableEx(token ethereum.Address, ethAmount *big.Int, minAmount *big.Int) (*types.Transaction, error) {
+	opts, err := self.GetTxOpts(STABLE_EX_OP, nil, nil, ethAmount)
+	if err != nil {
+		return nil, err
+	}
+	opts.GasLimit = big.NewInt(STABLE_EX_TRADE_GAS)
+	return self.trade(opts, "buy", token, minAmount)
+}
+
+// SellToStableEx sells amount of token to the stable token contract, the
+// contract is approved to take the token first if needed. The tx is
+// reverted if less than minETH is received.
+func (self *Blockchain) SellToStableEx(token ethereum.Address, amount *big.Int, minETH *big.Int) (*types.Transaction, error) {
+	if err := self.approve(token, amount); err != nil {
+		return nil, err
+	}
+	opts, err := self.GetTxOpts(STABLE_EX_OP, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts.GasLimit = big.NewInt(STABLE_EX_TRADE_GAS)
+	return self.trade(opts, "sell", token, amount, minETH)
+}
+
+func (self *Blockchain) trade(opts blockchain.TxOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := self.BuildTx(timeout, opts, self.contract, method, params...)
+	if err != nil {
+		self.ReleaseNonce(opts)
+		return nil, err
+	}
+	signedTx, err := self.SignAndBroadcast(tx, STABLE_EX_OP)
+	if signedTx == nil {
+		self.ReleaseNonce(opts)
+	}
+	return signedTx, err
+}
+
+// approve allows the stable token contract to take amount of token from
+// the operator unless the pending allowance is already enough
+func (self *Blockchain) approve(token ethereum.Address, amount *big.Int) error {
+	operator := self.GetOperator(STABLE_EX_OP).Address
+	allowance, err := self.ERC20Allowance(token, operator, self.contract.Address)
+	if err != nil {
+		return err
+	}
+	if allowance.Cmp(amount) >= 0 {
+		return nil
+	}
+	opts, err := self.GetTxOpts(STABLE_EX_OP, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	tx, err := self.BuildApproveERC20Tx(opts, amount, self.contract.Address, token)
+	if err != nil {
+		self.ReleaseNonce(opts)
+		return err
+	}
+	signedTx, err := self.SignAndBroadcast(tx, STABLE_EX_OP)
+	if signedTx == nil {
+		self.ReleaseNonce(opts)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Stable exchange: approving %s of token %s, tx: %s", amount.Text(10), token.Hex(), signedTx.Hash().Hex())
+	return nil
+}
+
+// StableExTrade returns the amounts the operator paid and received in the
+// Trade event of the stable token contract in mined tx hash, found is
+// false if the tx didn't trade with the contract.
+func (self *Blockchain) StableExTrade(hash ethereum.Hash) (srcAmount *big.Int, destAmount *big.Int, found bool, err error) {
+	receipt, err := self.TransactionReceipt(hash)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	trader := self.GetOperator(STABLE_EX_OP).Address
+	for _, l := range receipt.Logs {
+		if l.Address != self.contract.Address || len(l.Topics) < 2 || l.Topics[0] != self.tradeEvent {
+			continue
+		}
+		// data is src, srcAmount, dest and destAmount in 32 bytes words
+		if ethereum.BytesToAddress(l.Topics[1].Bytes()) != trader || len(l.Data) < 128 {
+			continue
+		}
+		return new(big.Int).SetBytes(l.Data[32:64]), new(big.Int).SetBytes(l.Data[96:128]), true, nil
+	}
+	return nil, nil, false, nil
+}
+
+func NewBlockchain(
+	base *blockchain.BaseBlockchain,
+	contractAddr ethereum.Address,
+	signer blockchain.Signer, nonce blockchain.NonceCorpus) (*Blockchain, error) {
+
+	log.Printf("stable contract address: %s", contractAddr.Hex())
+	contract := blockchain.NewContract(
+		contractAddr,
+		filepath.Join(common.CurrentDir(), "stable.abi"),
+	)
+	base.RegisterOperator(STABLE_EX_OP, blockchain.NewOperator(signer, nonce))
+
+	return &Blockchain{
+		BaseBlockchain: base,
+		contract:       contract,
+		tradeEvent:     contract.ABI.Events["Trade"].Id(),
+	}, nil
+}
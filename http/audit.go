@@ -0,0 +1,157 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AUDIT_CONTEXT_KEY string = "audit_record"
+	// longer param values are truncated in audit records
+	MAX_AUDIT_VALUE_SIZE int = 4096
+)
+
+// params with one of these words in their name are redacted in audit
+// records
+var auditSecretParams = []string{"secret", "passphrase", "password", "private", "signed"}
+
+// AuditStorage is an append only store of audit records
+type AuditStorage interface {
+	StoreAuditRecord(record common.AuditRecord) error
+	// GetAuditRecords returns records in [fromTime, toTime] millisecond
+	// ordered by time
+	GetAuditRecords(fromTime, toTime uint64) ([]common.AuditRecord, error)
+}
+
+func redactParams(params url.Values) map[string]string {
+	result := map[string]string{}
+	for name := range params {
+		value := params.Get(name)
+		lower := strings.ToLower(name)
+		for _, word := range auditSecretParams {
+			if strings.Contains(lower, word) {
+				value = "[redacted]"
+				break
+			}
+		}
+		if len(value) > MAX_AUDIT_VALUE_SIZE {
+			value = value[:MAX_AUDIT_VALUE_SIZE] + "...[truncated]"
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// auditRecord returns the audit record of an authenticated request, it is
+// stored after the response is written
+func auditRecord(c *gin.Context) *common.AuditRecord {
+	record := &common.AuditRecord{
+		Timestamp:    common.GetTimepoint(),
+		Method:       c.Request.Method,
+		Endpoint:     c.Request.URL.Path,
+		RequestedKey: c.GetHeader("apikey"),
+		Params:       map[string]string{},
+	}
+	c.Set(AUDIT_CONTEXT_KEY, record)
+	return record
+}
+
+// auditWriter reads the outcome of json responses of the apis, it is read
+// from the first write as gin writes json responses at once
+type auditWriter struct {
+	gin.ResponseWriter
+	written bool
+	outcome struct {
+		Success *bool  `json:"success"`
+		Reason  string `json:"reason"`
+	}
+}
+
+func (self *auditWriter) Write(data []byte) (int, error) {
+	if !self.written {
+		self.written = true
+		if strings.HasPrefix(self.Header().Get("Content-Type"), "application/json") {
+			if err := json.Unmarshal(data, &self.outcome); err != nil {
+				self.outcome.Success = nil
+			}
+		}
+	}
+	return self.ResponseWriter.Write(data)
+}
+
+// auditRequests stores audit records of authenticated requests with their
+// outcome, requests not calling Authenticated are not recorded
+func (self *HTTPServer) auditRequests(c *gin.Context) {
+	if self.auditStorage == nil {
+		c.Next()
+		return
+	}
+	writer := &auditWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	value, found := c.Get(AUDIT_CONTEXT_KEY)
+	if !found {
+		return
+	}
+	record := *value.(*common.AuditRecord)
+	record.Status = writer.Status()
+	if writer.outcome.Success != nil {
+		record.Success = *writer.outcome.Success
+		record.Reason = writer.outcome.Reason
+	} else {
+		record.Success = record.Status < http.StatusBadRequest
+	}
+	if err := self.auditStorage.StoreAuditRecord(record); err != nil {
+		log.Printf("Storing audit record of %s %s failed: %s", record.Method, record.Endpoint, err)
+	}
+}
+
+// GetAuditLogs returns audit records in [fromTime, toTime] millisecond,
+// they are returned as json lines if format param is "jsonl"
+func (self *HTTPServer) GetAuditLogs(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{KeyAdminPermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	fromTime, toTime, ok := self.ValidateTimeInput(c)
+	if !ok {
+		return
+	}
+	if self.auditStorage == nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": "There is no audit storage"},
+		)
+		return
+	}
+	records, err := self.auditStorage.GetAuditRecords(fromTime, toTime)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	if c.Query("format") == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for _, record := range records {
+			if err = encoder.Encode(record); err != nil {
+				log.Printf("Exporting audit records failed: %s", err)
+				return
+			}
+		}
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": records},
+	)
+}
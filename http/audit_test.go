@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactParams(t *testing.T) {
+	params := url.Values{}
+	params.Set("nonce", "1")
+	params.Set("passphrase", "hunter2")
+	params.Set("Private_Key", "0x1")
+	params.Set("data", strings.Repeat("a", MAX_AUDIT_VALUE_SIZE+1))
+	result := redactParams(params)
+	if result["nonce"] != "1" {
+		t.Fatalf("Expected nonce to be kept, got %s", result["nonce"])
+	}
+	if result["passphrase"] != "[redacted]" || result["Private_Key"] != "[redacted]" {
+		t.Fatalf("Expected secrets to be redacted, got %+v", result)
+	}
+	if len(result["data"]) != MAX_AUDIT_VALUE_SIZE+len("...[truncated]") {
+		t.Fatalf("Expected long values to be truncated, got %d bytes", len(result["data"]))
+	}
+}
+
+func TestCosignedAudit(t *testing.T) {
+	auth := KNAuthentication{
+		KNSecret:      "secret",
+		KNConfirmConf: "confirm",
+		storage:       testKeyStorage{},
+	}
+	confirmKey, err := auth.CreateKey([]common.APIKeyScope{{Permission: ConfirmConfPermission.String()}}, 0)
+	if err != nil {
+		t.Fatalf("Couldn't create key: %v", err)
+	}
+	server := HTTPServer{authEnabled: true, auth: auth}
+	message := "amount=1&nonce=1"
+	cosigned := func(apikey, signed, cosignerKey, cosignature string) (bool, *common.AuditRecord) {
+		request := httptest.NewRequest(http.MethodPost, "/deposit/binance", strings.NewReader(message))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("apikey", apikey)
+		request.Header.Set("signed", signed)
+		request.Header.Set("cosigner_apikey", cosignerKey)
+		request.Header.Set("cosigned", cosignature)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = request
+		record := auditRecord(c)
+		if err := c.Request.ParseForm(); err != nil {
+			t.Fatalf("Couldn't parse form: %v", err)
+		}
+		return server.Cosigned(c, "binance", []string{"KNC"}), record
+	}
+
+	ok, record := cosigned("", auth.KNSign(message), confirmKey.ID, sign(confirmKey.Secret, message))
+	if !ok || record.CosignerKey != confirmKey.ID {
+		t.Fatalf("Expected request co-signed by %s, got %t, cosigner %q", confirmKey.ID, ok, record.CosignerKey)
+	}
+	// the same key signing with its api key and without it
+	if ok, record = cosigned(confirmKey.ID, sign(confirmKey.Secret, message), confirmKey.ID, sign(confirmKey.Secret, message)); ok || record.CosignerKey != "" {
+		t.Fatalf("Expected a key not to co-sign its own request")
+	}
+	if ok, _ = cosigned("", auth.KNConfirmConfSign(message), "", auth.KNConfirmConfSign(message)); ok {
+		t.Fatalf("Expected a config key not to co-sign its own request")
+	}
+	if ok, _ = cosigned("", auth.KNSign(message), "", auth.KNSign(message)+"00"); ok {
+		t.Fatalf("Expected an invalid co-signature to be rejected")
+	}
+	if ok, _ = cosigned("", auth.KNConfirmConfSign(message), "", auth.KNSign(message)); ok {
+		t.Fatalf("Expected a co-signer without confirm configuration permission to be rejected")
+	}
+}
@@ -2,10 +2,15 @@ package http
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 
+	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
@@ -14,17 +19,41 @@ type Authentication interface {
 	KNReadonlySign(message string) string
 	KNConfigurationSign(message string) string
 	KNConfirmConfSign(message string) string
-	GetPermission(signed string, message string) []Permission
+	// GetScopes returns scopes of the key keyID if message is signed by
+	// the key. Messages without key id are checked against all secrets
+	// of the config file.
+	GetScopes(keyID string, signed string, message string) []common.APIKeyScope
+	// Signer returns the id of the key signing message, keyID is used as
+	// in GetScopes. It returns "" if no valid key signs the message.
+	Signer(keyID string, signed string, message string) string
+	CreateKey(scopes []common.APIKeyScope, expiresAt uint64) (common.APIKey, error)
+	RevokeKey(id string) error
+	// GetKeys returns all keys without their secrets
+	GetKeys() ([]common.APIKey, error)
 }
 
+// KeyStorage persists api keys created by the key admin apis
+type KeyStorage interface {
+	StoreAPIKey(key common.APIKey) error
+	GetAPIKey(id string) (common.APIKey, error)
+	GetAPIKeys() ([]common.APIKey, error)
+}
+
+// KNAuthentication authenticates requests with the keys of its storage
+// and the secrets of the config file. Secrets of the config file are keys
+// named after their config fields, they never expire and can't be revoked.
 type KNAuthentication struct {
 	KNSecret        string `json:"kn_secret"`
 	KNReadOnly      string `json:"kn_readonly"`
 	KNConfiguration string `json:"kn_configuration"`
 	KNConfirmConf   string `json:"kn_confirm_configuration"`
+	KNKeyAdmin      string `json:"kn_key_admin"`
+	storage         KeyStorage
 }
 
-func NewKNAuthenticationFromFile(path string) KNAuthentication {
+// NewKNAuthenticationFromFile loads secrets from the config file at path,
+// storage can be nil and then only those secrets are accepted.
+func NewKNAuthenticationFromFile(path string, storage KeyStorage) KNAuthentication {
 	raw, err := ioutil.ReadFile(path)
 	if err != nil {
 		panic(err)
@@ -34,50 +63,179 @@ func NewKNAuthenticationFromFile(path string) KNAuthentication {
 	if err != nil {
 		panic(err)
 	}
+	result.storage = storage
 	return result
 }
 
-func (self KNAuthentication) KNSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNSecret))
+func sign(secret, msg string) string {
+	mac := hmac.New(sha512.New, []byte(secret))
 	mac.Write([]byte(msg))
 	return ethereum.Bytes2Hex(mac.Sum(nil))
 }
 
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (self KNAuthentication) KNSign(msg string) string {
+	return sign(self.KNSecret, msg)
+}
+
 func (self KNAuthentication) KNReadonlySign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNReadOnly))
-	mac.Write([]byte(msg))
-	return ethereum.Bytes2Hex(mac.Sum(nil))
+	return sign(self.KNReadOnly, msg)
 }
 
 func (self KNAuthentication) KNConfigurationSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNConfiguration))
-	mac.Write([]byte(msg))
-	return ethereum.Bytes2Hex(mac.Sum(nil))
+	return sign(self.KNConfiguration, msg)
 }
 
 func (self KNAuthentication) KNConfirmConfSign(msg string) string {
-	mac := hmac.New(sha512.New, []byte(self.KNConfirmConf))
-	mac.Write([]byte(msg))
-	return ethereum.Bytes2Hex(mac.Sum(nil))
+	return sign(self.KNConfirmConf, msg)
+}
+
+// configKeys returns keys of the secrets set in the config file
+func (self KNAuthentication) configKeys() []common.APIKey {
+	secrets := []struct {
+		id         string
+		secret     string
+		permission Permission
+	}{
+		{"kn_secret", self.KNSecret, RebalancePermission},
+		{"kn_readonly", self.KNReadOnly, ReadOnlyPermission},
+		{"kn_configuration", self.KNConfiguration, ConfigurePermission},
+		{"kn_confirm_configuration", self.KNConfirmConf, ConfirmConfPermission},
+		{"kn_key_admin", self.KNKeyAdmin, KeyAdminPermission},
+	}
+	result := []common.APIKey{}
+	for _, s := range secrets {
+		if s.secret == "" {
+			continue
+		}
+		result = append(result, common.APIKey{
+			ID:     s.id,
+			Secret: s.secret,
+			Scopes: []common.APIKeyScope{{Permission: s.permission.String()}},
+		})
+	}
+	return result
 }
 
-func (self KNAuthentication) GetPermission(signed string, message string) []Permission {
-	result := []Permission{}
-	rebalanceSigned := self.KNSign(message)
-	if signed == rebalanceSigned {
-		result = append(result, RebalancePermission)
+func (self KNAuthentication) getKey(id string) (common.APIKey, error) {
+	for _, key := range self.configKeys() {
+		if key.ID == id {
+			return key, nil
+		}
 	}
-	readonlySigned := self.KNReadonlySign(message)
-	if signed == readonlySigned {
-		result = append(result, ReadOnlyPermission)
+	if self.storage == nil {
+		return common.APIKey{}, fmt.Errorf("Key %s is not found", id)
 	}
-	configureSigned := self.KNConfigurationSign(message)
-	if signed == configureSigned {
-		result = append(result, ConfigurePermission)
+	return self.storage.GetAPIKey(id)
+}
+
+func isSignedBy(key common.APIKey, signed, message string) bool {
+	return hmac.Equal([]byte(signed), []byte(sign(key.Secret, message)))
+}
+
+func (self KNAuthentication) GetScopes(keyID string, signed string, message string) []common.APIKeyScope {
+	result := []common.APIKeyScope{}
+	if keyID == "" {
+		for _, key := range self.configKeys() {
+			if isSignedBy(key, signed, message) {
+				result = append(result, key.Scopes...)
+			}
+		}
+		return result
+	}
+	key, err := self.getKey(keyID)
+	if err != nil {
+		return result
 	}
-	confirmConfSigned := self.KNConfirmConfSign(message)
-	if signed == confirmConfSigned {
-		result = append(result, ConfirmConfPermission)
+	if key.Revoked || (key.ExpiresAt != 0 && key.ExpiresAt < common.GetTimepoint()) {
+		return result
+	}
+	if isSignedBy(key, signed, message) {
+		result = key.Scopes
 	}
 	return result
 }
+
+func (self KNAuthentication) Signer(keyID string, signed string, message string) string {
+	if keyID == "" {
+		for _, key := range self.configKeys() {
+			if isSignedBy(key, signed, message) {
+				return key.ID
+			}
+		}
+		return ""
+	}
+	if len(self.GetScopes(keyID, signed, message)) == 0 {
+		return ""
+	}
+	return keyID
+}
+
+func (self KNAuthentication) CreateKey(scopes []common.APIKeyScope, expiresAt uint64) (common.APIKey, error) {
+	if self.storage == nil {
+		return common.APIKey{}, errors.New("There is no storage for api keys")
+	}
+	if len(scopes) == 0 {
+		return common.APIKey{}, errors.New("Key must have at least one scope")
+	}
+	for _, scope := range scopes {
+		if _, err := ParsePermission(scope.Permission); err != nil {
+			return common.APIKey{}, err
+		}
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return common.APIKey{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return common.APIKey{}, err
+	}
+	key := common.APIKey{
+		ID:        id,
+		Secret:    secret,
+		Scopes:    scopes,
+		CreatedAt: common.GetTimepoint(),
+		ExpiresAt: expiresAt,
+	}
+	return key, self.storage.StoreAPIKey(key)
+}
+
+func (self KNAuthentication) RevokeKey(id string) error {
+	for _, key := range self.configKeys() {
+		if key.ID == id {
+			return fmt.Errorf("Key %s is set in the config file, it can't be revoked", id)
+		}
+	}
+	if self.storage == nil {
+		return fmt.Errorf("Key %s is not found", id)
+	}
+	key, err := self.storage.GetAPIKey(id)
+	if err != nil {
+		return err
+	}
+	key.Revoked = true
+	return self.storage.StoreAPIKey(key)
+}
+
+func (self KNAuthentication) GetKeys() ([]common.APIKey, error) {
+	result := self.configKeys()
+	if self.storage != nil {
+		keys, err := self.storage.GetAPIKeys()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, keys...)
+	}
+	for i := range result {
+		result[i].Secret = ""
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testKeyStorage map[string]common.APIKey
+
+func (self testKeyStorage) StoreAPIKey(key common.APIKey) error {
+	self[key.ID] = key
+	return nil
+}
+
+func (self testKeyStorage) GetAPIKey(id string) (common.APIKey, error) {
+	key, found := self[id]
+	if !found {
+		return key, fmt.Errorf("Key %s is not found", id)
+	}
+	return key, nil
+}
+
+func (self testKeyStorage) GetAPIKeys() ([]common.APIKey, error) {
+	result := []common.APIKey{}
+	for _, key := range self {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+func TestKNAuthenticationKeys(t *testing.T) {
+	auth := KNAuthentication{
+		KNSecret:   "secret",
+		KNReadOnly: "readonly",
+		storage:    testKeyStorage{},
+	}
+	message := "nonce=1"
+
+	scopes := auth.GetScopes("", auth.KNReadonlySign(message), message)
+	if len(scopes) != 1 || scopes[0].Permission != ReadOnlyPermission.String() {
+		t.Fatalf("Expected readonly scope of the config secret, got %+v", scopes)
+	}
+	if scopes = auth.GetScopes("", sign("", message), message); len(scopes) != 0 {
+		t.Fatalf("Unset config secrets must not be accepted, got %+v", scopes)
+	}
+
+	if _, err := auth.CreateKey([]common.APIKeyScope{{Permission: "everything"}}, 0); err == nil {
+		t.Fatalf("Expected error creating a key with an unknown permission")
+	}
+	tradeScopes := []common.APIKeyScope{{Permission: TradePermission.String(), Exchanges: []string{"binance"}}}
+	key, err := auth.CreateKey(tradeScopes, 0)
+	if err != nil {
+		t.Fatalf("Couldn't create key: %v", err)
+	}
+	signed := sign(key.Secret, message)
+	if scopes = auth.GetScopes(key.ID, signed, message); len(scopes) != 1 {
+		t.Fatalf("Expected scopes of the new key, got %+v", scopes)
+	}
+	if scopes = auth.GetScopes("", signed, message); len(scopes) != 0 {
+		t.Fatalf("Keys of the storage must be used with their id, got %+v", scopes)
+	}
+	if scopes = auth.GetScopes(key.ID, auth.KNSign(message), message); len(scopes) != 0 {
+		t.Fatalf("Expected no scope for a message signed by another key, got %+v", scopes)
+	}
+
+	keys, err := auth.GetKeys()
+	if err != nil || len(keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %+v, error: %v", keys, err)
+	}
+	for _, k := range keys {
+		if k.Secret != "" {
+			t.Fatalf("Secret of key %s must not be listed", k.ID)
+		}
+	}
+
+	if err = auth.RevokeKey("kn_secret"); err == nil {
+		t.Fatalf("Expected error revoking a config secret")
+	}
+	if err = auth.RevokeKey(key.ID); err != nil {
+		t.Fatalf("Couldn't revoke key: %v", err)
+	}
+	if scopes = auth.GetScopes(key.ID, signed, message); len(scopes) != 0 {
+		t.Fatalf("Expected no scope for a revoked key, got %+v", scopes)
+	}
+
+	expired, err := auth.CreateKey(tradeScopes, 1)
+	if err != nil {
+		t.Fatalf("Couldn't create key: %v", err)
+	}
+	if scopes = auth.GetScopes(expired.ID, sign(expired.Secret, message), message); len(scopes) != 0 {
+		t.Fatalf("Expected no scope for an expired key, got %+v", scopes)
+	}
+}
+
+func TestEligibleScopes(t *testing.T) {
+	scopes := []common.APIKeyScope{
+		{Permission: TradePermission.String(), Exchanges: []string{"binance"}},
+		{Permission: WithdrawPermission.String(), Tokens: []string{"KNC", "ETH"}},
+	}
+	testCases := []struct {
+		perms    []Permission
+		exchange string
+		tokens   []string
+		expected bool
+	}{
+		{[]Permission{TradePermission}, "binance", []string{"KNC", "ETH"}, true},
+		{[]Permission{TradePermission}, "huobi", []string{"KNC", "ETH"}, false},
+		{[]Permission{RebalancePermission, WithdrawPermission}, "huobi", []string{"KNC"}, true},
+		{[]Permission{WithdrawPermission}, "huobi", []string{"OMG"}, false},
+		{[]Permission{WithdrawPermission}, "huobi", []string{}, false},
+		{[]Permission{DepositPermission}, "binance", []string{"KNC"}, false},
+		{[]Permission{ReadOnlyPermission}, "", []string{}, false},
+	}
+	for _, tc := range testCases {
+		if result := eligible(scopes, tc.perms, tc.exchange, tc.tokens); result != tc.expected {
+			t.Fatalf("Expected eligible %t for %+v, got %t", tc.expected, tc, result)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package http
 
+import (
+	"fmt"
+)
+
 type Permission int
 
 const (
@@ -7,4 +11,32 @@ const (
 	RebalancePermission                     // can do everything except configure setting
 	ConfigurePermission                     // can read data and configure setting, cannot set rates, deposit, withdraw, trade, cancel activities
 	ConfirmConfPermission                   // can read data and confirm configuration proposal
+	TradePermission                         // can only trade and cancel orders
+	WithdrawPermission                      // can only withdraw from exchanges
+	DepositPermission                       // can only deposit to exchanges
+	KeyAdminPermission                      // can only create, list and revoke api keys
 )
+
+var permissionNames = map[Permission]string{
+	ReadOnlyPermission:    "readonly",
+	RebalancePermission:   "rebalance",
+	ConfigurePermission:   "configure",
+	ConfirmConfPermission: "confirm_configuration",
+	TradePermission:       "trade",
+	WithdrawPermission:    "withdraw",
+	DepositPermission:     "deposit",
+	KeyAdminPermission:    "key_admin",
+}
+
+func (self Permission) String() string {
+	return permissionNames[self]
+}
+
+func ParsePermission(name string) (Permission, error) {
+	for perm, permName := range permissionNames {
+		if permName == name {
+			return perm, nil
+		}
+	}
+	return 0, fmt.Errorf("Permission %s is not supported", name)
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+	"github.com/gin-gonic/gin"
+)
+
+// signer returns the id of the key signing an authenticated request. When
+// authentication is disabled it is the "apikey" header or anonymous if the
+// header is empty.
+func (self *HTTPServer) signer(c *gin.Context, anonymous string) string {
+	if !self.authEnabled {
+		if key := c.GetHeader("apikey"); key != "" {
+			return key
+		}
+		return anonymous
+	}
+	return self.auth.Signer(c.GetHeader("apikey"), c.GetHeader("signed"), c.Request.Form.Encode())
+}
+
+func proposalID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": fmt.Sprintf("Proposal id %s is invalid", c.Param("id"))},
+		)
+		return 0, false
+	}
+	return id, true
+}
+
+func proposalResponse(c *gin.Context, proposal metric.Proposal, err error) {
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": proposal},
+	)
+}
+
+// GetProposals returns proposals filtered by "type" and "status" query
+// params, they are ordered by id
+func (self *HTTPServer) GetProposals(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	proposals, err := self.proposals.GetProposals(c.Query("type"), c.Query("status"), common.GetTimepoint())
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": proposals},
+	)
+}
+
+func (self *HTTPServer) GetProposal(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	id, ok := proposalID(c)
+	if !ok {
+		return
+	}
+	proposal, err := self.proposals.GetProposal(id, common.GetTimepoint())
+	proposalResponse(c, proposal, err)
+}
+
+// Propose stores a pending proposal of "type" with "data", "params" is an
+// optional json object of string params of the type
+func (self *HTTPServer) Propose(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"type", "data"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	data := postForm.Get("data")
+	if len(data) > MAX_DATA_SIZE {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": "the data size must be less than 1 MB"},
+		)
+		return
+	}
+	params := map[string]string{}
+	if postForm.Get("params") != "" {
+		if err := json.Unmarshal([]byte(postForm.Get("params")), &params); err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	proposal, err := self.proposals.Propose(
+		postForm.Get("type"), data, params,
+		self.signer(c, "anonymous proposer"), common.GetTimepoint())
+	proposalResponse(c, proposal, err)
+}
+
+// ApproveProposal approves the proposal of "id" path param, "data" must be
+// the proposed data
+func (self *HTTPServer) ApproveProposal(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"data"}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	id, ok := proposalID(c)
+	if !ok {
+		return
+	}
+	proposal, err := self.proposals.Approve(
+		id, postForm.Get("data"),
+		self.signer(c, "anonymous approver"), common.GetTimepoint())
+	proposalResponse(c, proposal, err)
+}
+
+// RejectProposal rejects the proposal of "id" path param with an optional
+// "note"
+func (self *HTTPServer) RejectProposal(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{}, []Permission{ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	id, ok := proposalID(c)
+	if !ok {
+		return
+	}
+	proposal, err := self.proposals.Reject(
+		id, self.signer(c, "anonymous approver"),
+		postForm.Get("note"), common.GetTimepoint())
+	proposalResponse(c, proposal, err)
+}
+
+// approvePending approves the pending proposal of proposalType for the
+// confirm apis of each configuration
+func (self *HTTPServer) approvePending(c *gin.Context, proposalType, data string) (metric.Proposal, error) {
+	timepoint := common.GetTimepoint()
+	proposal, err := self.proposals.GetPending(proposalType, timepoint)
+	if err != nil {
+		return proposal, err
+	}
+	return self.proposals.Approve(proposal.ID, data, self.signer(c, "anonymous approver"), timepoint)
+}
+
+// rejectPending rejects the pending proposal of proposalType for the
+// reject apis of each configuration
+func (self *HTTPServer) rejectPending(c *gin.Context, proposalType string) (metric.Proposal, error) {
+	timepoint := common.GetTimepoint()
+	proposal, err := self.proposals.GetPending(proposalType, timepoint)
+	if err != nil {
+		return proposal, err
+	}
+	return self.proposals.Reject(proposal.ID, self.signer(c, "anonymous approver"), "", timepoint)
+}
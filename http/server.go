@@ -1,7 +1,10 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"net/http"
@@ -22,14 +25,16 @@ import (
 )
 
 type HTTPServer struct {
-	app         reserve.ReserveData
-	core        reserve.ReserveCore
-	stat        reserve.ReserveStats
-	metric      metric.MetricStorage
-	host        string
-	authEnabled bool
-	auth        Authentication
-	r           *gin.Engine
+	app          reserve.ReserveData
+	core         reserve.ReserveCore
+	stat         reserve.ReserveStats
+	metric       metric.MetricStorage
+	proposals    *metric.ProposalManager
+	host         string
+	authEnabled  bool
+	auth         Authentication
+	auditStorage AuditStorage
+	r            *gin.Engine
 }
 
 const (
@@ -37,6 +42,8 @@ const (
 	MAX_DATA_SIZE  int    = 1000000 //1 Megabyte in byte
 	START_TIMEZONE int64  = -11
 	END_TIMEZONE   int64  = 14
+	// interval of heartbeats sent to idle subscriptions
+	SUBSCRIPTION_HEARTBEAT time.Duration = 30 * time.Second
 )
 
 func getTimePoint(c *gin.Context, useDefault bool) uint64 {
@@ -78,23 +85,111 @@ func IsIntime(nonce string) bool {
 	return true
 }
 
-func eligible(ups, allowedPerms []Permission) bool {
-	for _, up := range ups {
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// eligible returns true if one of the scopes grants one of allowedPerms
+// on exchange and tokens. Scopes restricted to some exchanges or tokens
+// are only eligible for requests acting on them.
+func eligible(scopes []common.APIKeyScope, allowedPerms []Permission, exchange string, tokens []string) bool {
+	_, ok := eligiblePermission(scopes, allowedPerms, exchange, tokens)
+	return ok
+}
+
+// eligiblePermission returns the permission of the first eligible scope
+func eligiblePermission(scopes []common.APIKeyScope, allowedPerms []Permission, exchange string, tokens []string) (Permission, bool) {
+	for _, scope := range scopes {
+		perm, err := ParsePermission(scope.Permission)
+		if err != nil {
+			continue
+		}
+		allowed := false
 		for _, ap := range allowedPerms {
-			if up == ap {
-				return true
+			if perm == ap {
+				allowed = true
+			}
+		}
+		if !allowed {
+			continue
+		}
+		if len(scope.Exchanges) > 0 && !contains(scope.Exchanges, exchange) {
+			continue
+		}
+		if len(scope.Tokens) > 0 {
+			if len(tokens) == 0 {
+				continue
+			}
+			for _, token := range tokens {
+				if !contains(scope.Tokens, token) {
+					allowed = false
+				}
 			}
 		}
+		if allowed {
+			return perm, true
+		}
 	}
-	return false
+	return Permission(0), false
 }
 
 // signed message (message = url encoded both query params and post params, keys are sorted) in "signed" header
 // using HMAC512
 // params must contain "nonce" which is the unixtime in millisecond. The nonce will be invalid
 // if it differs from server time more than 10s
+// the key signing the message is given in "apikey" header, messages without
+// it are checked against all secrets of the config file
 func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, perms []Permission) (url.Values, bool) {
+	return self.AuthenticatedScope(c, requiredParams, perms, "", []string{})
+}
+
+// Cosigned returns true if the request is also signed in "cosigned" header
+// by a key with confirm configuration permission on exchange and tokens,
+// the key is given in "cosigner_apikey" header and must not be the key
+// signing the request. Requests must be authenticated before, the cosigner
+// key is recorded in their audit record. Cosigned deposits and withdrawals
+// may exceed transfer limits.
+func (self *HTTPServer) Cosigned(c *gin.Context, exchange string, tokens []string) bool {
+	cosigned := c.GetHeader("cosigned")
+	if cosigned == "" {
+		return false
+	}
+	record := &common.AuditRecord{}
+	if value, found := c.Get(AUDIT_CONTEXT_KEY); found {
+		record = value.(*common.AuditRecord)
+	}
+	if !self.authEnabled {
+		record.CosignerKey = c.GetHeader("cosigner_apikey")
+		return true
+	}
+	message := c.Request.Form.Encode()
+	cosigner := self.auth.Signer(c.GetHeader("cosigner_apikey"), cosigned, message)
+	requester := self.auth.Signer(c.GetHeader("apikey"), c.GetHeader("signed"), message)
+	if cosigner == "" || cosigner == requester {
+		// a key can't co-sign its own request
+		return false
+	}
+	scopes := self.auth.GetScopes(c.GetHeader("cosigner_apikey"), cosigned, message)
+	if !eligible(scopes, []Permission{ConfirmConfPermission}, exchange, tokens) {
+		return false
+	}
+	record.CosignerKey = cosigner
+	return true
+}
+
+// AuthenticatedScope authenticates requests acting on exchange and tokens
+// of tokenParams, keys restricted to some exchanges or tokens must allow
+// them. Requests are recorded in the audit log with the key and the
+// permission used.
+func (self *HTTPServer) AuthenticatedScope(c *gin.Context, requiredParams []string, perms []Permission, exchange string, tokenParams []string) (url.Values, bool) {
+	record := auditRecord(c)
 	err := c.Request.ParseForm()
+	record.Params = redactParams(c.Request.Form)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -107,6 +202,7 @@ func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, p
 	}
 
 	if !self.authEnabled {
+		record.KeyID = record.RequestedKey
 		return c.Request.Form, true
 	}
 
@@ -138,11 +234,17 @@ func (self *HTTPServer) Authenticated(c *gin.Context, requiredParams []string, p
 
 	signed := c.GetHeader("signed")
 	message := c.Request.Form.Encode()
-	userPerms := self.auth.GetPermission(signed, message)
-	if eligible(userPerms, perms) {
+	scopes := self.auth.GetScopes(c.GetHeader("apikey"), signed, message)
+	tokens := []string{}
+	for _, p := range tokenParams {
+		tokens = append(tokens, params.Get(p))
+	}
+	record.KeyID = self.auth.Signer(c.GetHeader("apikey"), signed, message)
+	if perm, ok := eligiblePermission(scopes, perms, exchange, tokens); ok {
+		record.Permission = perm.String()
 		return params, true
 	} else {
-		if len(userPerms) == 0 {
+		if len(scopes) == 0 {
 			c.JSON(
 				http.StatusOK,
 				gin.H{
@@ -418,8 +520,127 @@ func (self *HTTPServer) SetRate(c *gin.Context) {
 	}
 }
 
+// decodeRates decodes hex rates separated by "-"
+func decodeRates(rates string) ([]*big.Int, error) {
+	result := []*big.Int{}
+	for _, rate := range strings.Split(rates, "-") {
+		r, err := hexutil.DecodeBig(rate)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// PlanRates is a dry run of SetRate, it returns whether base rates would be
+// set, the compact bulks and the estimated gas without sending anything
+func (self *HTTPServer) PlanRates(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{"tokens", "buys", "sells"}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	tokens := []common.Token{}
+	for _, tok := range strings.Split(c.Query("tokens"), "-") {
+		token, err := common.GetInternalToken(tok)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+		tokens = append(tokens, token)
+	}
+	buys, err := decodeRates(c.Query("buys"))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	sells, err := decodeRates(c.Query("sells"))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	plan, err := self.core.PlanRates(tokens, buys, sells)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": plan},
+	)
+}
+
+// SimulateRate returns the rates of the pricing contract for "quantities"
+// of "token" on "side" (buy or sell) at "block", the current block if it
+// is not set. Quantities are separated by "-", they are in ETH for buys
+// and in token for sells.
+func (self *HTTPServer) SimulateRate(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{"token", "side", "quantities"}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	token, err := common.GetInternalToken(c.Query("token"))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	quantities := []float64{}
+	for _, value := range strings.Split(c.Query("quantities"), "-") {
+		quantity, err := strconv.ParseFloat(value, 64)
+		if err != nil || quantity <= 0 {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": fmt.Sprintf("Quantity %s is invalid", value)},
+			)
+			return
+		}
+		quantities = append(quantities, quantity)
+	}
+	var block uint64
+	if c.Query("block") != "" {
+		block, err = strconv.ParseUint(c.Query("block"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	data, err := self.core.SimulateRates(token, c.Query("side"), quantities, block)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": data},
+	)
+}
+
 func (self *HTTPServer) Trade(c *gin.Context) {
-	postForm, ok := self.Authenticated(c, []string{"base", "quote", "amount", "rate", "type"}, []Permission{RebalancePermission})
+	postForm, ok := self.AuthenticatedScope(
+		c, []string{"base", "quote", "amount", "rate", "type"},
+		[]Permission{RebalancePermission, TradePermission},
+		c.Param("exchangeid"), []string{"base", "quote"})
 	if !ok {
 		return
 	}
@@ -501,7 +722,10 @@ func (self *HTTPServer) Trade(c *gin.Context) {
 }
 
 func (self *HTTPServer) CancelOrder(c *gin.Context) {
-	postForm, ok := self.Authenticated(c, []string{"order_id"}, []Permission{RebalancePermission})
+	postForm, ok := self.AuthenticatedScope(
+		c, []string{"order_id"},
+		[]Permission{RebalancePermission, TradePermission},
+		c.Param("exchangeid"), []string{})
 	if !ok {
 		return
 	}
@@ -543,7 +767,10 @@ func (self *HTTPServer) CancelOrder(c *gin.Context) {
 }
 
 func (self *HTTPServer) Withdraw(c *gin.Context) {
-	postForm, ok := self.Authenticated(c, []string{"token", "amount"}, []Permission{RebalancePermission})
+	postForm, ok := self.AuthenticatedScope(
+		c, []string{"token", "amount"},
+		[]Permission{RebalancePermission, WithdrawPermission},
+		c.Param("exchangeid"), []string{"token"})
 	if !ok {
 		return
 	}
@@ -577,7 +804,8 @@ func (self *HTTPServer) Withdraw(c *gin.Context) {
 		return
 	}
 	log.Printf("Withdraw %s %s from %s\n", amount.Text(10), token.ID, exchange.ID())
-	id, err := self.core.Withdraw(exchange, token, amount, getTimePoint(c, false))
+	cosigned := self.Cosigned(c, exchangeParam, []string{tokenParam})
+	id, err := self.core.Withdraw(exchange, token, amount, getTimePoint(c, false), cosigned)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -595,7 +823,10 @@ func (self *HTTPServer) Withdraw(c *gin.Context) {
 }
 
 func (self *HTTPServer) Deposit(c *gin.Context) {
-	postForm, ok := self.Authenticated(c, []string{"amount", "token"}, []Permission{RebalancePermission})
+	postForm, ok := self.AuthenticatedScope(
+		c, []string{"amount", "token"},
+		[]Permission{RebalancePermission, DepositPermission},
+		c.Param("exchangeid"), []string{"token"})
 	if !ok {
 		return
 	}
@@ -629,7 +860,8 @@ func (self *HTTPServer) Deposit(c *gin.Context) {
 		return
 	}
 	log.Printf("Depositing %s %s to %s\n", amount.Text(10), token.ID, exchange.ID())
-	id, err := self.core.Deposit(exchange, token, amount, getTimePoint(c, false))
+	cosigned := self.Cosigned(c, exchangeParam, []string{tokenParam})
+	id, err := self.core.Deposit(exchange, token, amount, getTimePoint(c, false), cosigned)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1056,7 +1288,7 @@ func (self *HTTPServer) GetPendingTargetQty(c *gin.Context) {
 	if !ok {
 		return
 	}
-	data, err := self.metric.GetPendingTargetQty()
+	proposal, err := self.proposals.GetPending(metric.TARGET_QTY_PROPOSAL, common.GetTimepoint())
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1066,11 +1298,24 @@ func (self *HTTPServer) GetPendingTargetQty(c *gin.Context) {
 	}
 	c.JSON(
 		http.StatusOK,
-		gin.H{"success": true, "data": data},
+		gin.H{"success": true, "data": pendingTargetQty(proposal)},
 	)
 	return
 }
 
+// pendingTargetQty returns a pending target quantity proposal in the format
+// of the pending target quantity apis
+func pendingTargetQty(proposal metric.Proposal) metric.TokenTargetQty {
+	result := metric.TokenTargetQty{
+		ID:        proposal.ID,
+		Timestamp: proposal.CreatedAt,
+		Data:      proposal.Data,
+		Status:    "unconfirmed",
+	}
+	result.Type, _ = strconv.ParseInt(proposal.Params["type"], 10, 64)
+	return result
+}
+
 // func targetQtySanityCheck(total, reserve, rebalanceThresold, transferThresold float64) error {
 // 	if total <= reserve {
 // 		return errors.New("Total quantity must bigger than reserver quantity")
@@ -1089,7 +1334,13 @@ func (self *HTTPServer) ConfirmTargetQty(c *gin.Context) {
 	}
 	data := postForm.Get("data")
 	id := postForm.Get("id")
-	err := self.metric.StoreTokenTargetQty(id, data)
+	pending, err := self.proposals.GetPending(metric.TARGET_QTY_PROPOSAL, common.GetTimepoint())
+	if err == nil && strconv.FormatUint(pending.ID, 10) != id {
+		err = errors.New("Pending target quantity ID does not match")
+	}
+	if err == nil {
+		_, err = self.proposals.Approve(pending.ID, data, self.signer(c, "anonymous approver"), common.GetTimepoint())
+	}
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1110,7 +1361,7 @@ func (self *HTTPServer) CancelTargetQty(c *gin.Context) {
 	if !ok {
 		return
 	}
-	err := self.metric.RemovePendingTargetQty()
+	_, err := self.rejectPending(c, metric.TARGET_QTY_PROPOSAL)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1134,40 +1385,9 @@ func (self *HTTPServer) SetTargetQty(c *gin.Context) {
 	data := postForm.Get("data")
 	dataType := postForm.Get("type")
 	log.Println("Setting target qty")
-	var err error
-	for _, dataConfig := range strings.Split(data, "|") {
-		dataParts := strings.Split(dataConfig, "_")
-		if dataType == "" || (dataType == "1" && len(dataParts) != 5) {
-			c.JSON(
-				http.StatusOK,
-				gin.H{"success": false, "reason": "Data submitted not enough information"},
-			)
-			return
-		}
-		token := dataParts[0]
-		// total, _ := strconv.ParseFloat(dataParts[1], 64)
-		// reserve, _ := strconv.ParseFloat(dataParts[2], 64)
-		// rebalanceThresold, _ := strconv.ParseFloat(dataParts[3], 64)
-		// transferThresold, _ := strconv.ParseFloat(dataParts[4], 64)
-		_, err = common.GetInternalToken(token)
-		if err != nil {
-			c.JSON(
-				http.StatusOK,
-				gin.H{"success": false, "reason": err.Error()},
-			)
-			return
-		}
-	}
-	err = self.metric.StorePendingTargetQty(data, dataType)
-	if err != nil {
-		c.JSON(
-			http.StatusOK,
-			gin.H{"success": false, "reason": err.Error()},
-		)
-		return
-	}
-
-	pendingData, err := self.metric.GetPendingTargetQty()
+	proposal, err := self.proposals.Propose(
+		metric.TARGET_QTY_PROPOSAL, data, map[string]string{"type": dataType},
+		self.signer(c, "anonymous proposer"), common.GetTimepoint())
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1177,7 +1397,7 @@ func (self *HTTPServer) SetTargetQty(c *gin.Context) {
 	}
 	c.JSON(
 		http.StatusOK,
-		gin.H{"success": true, "data": pendingData},
+		gin.H{"success": true, "data": pendingTargetQty(proposal)},
 	)
 	return
 }
@@ -1190,6 +1410,27 @@ func (self *HTTPServer) GetAddress(c *gin.Context) {
 	return
 }
 
+// GetNonces returns nonce states of blockchain operators: the next nonce,
+// allocated nonces which are not mined and gaps left by dropped txs
+func (self *HTTPServer) GetNonces(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{KeyAdminPermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	data, err := self.core.GetNonceStates()
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": data},
+	)
+}
+
 func (self *HTTPServer) GetTradeHistory(c *gin.Context) {
 	timepoint := common.GetTimepoint()
 	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
@@ -1217,6 +1458,105 @@ func (self *HTTPServer) GetTradeHistory(c *gin.Context) {
 	)
 }
 
+func (self *HTTPServer) GetOpenOrders(c *gin.Context) {
+	log.Printf("Getting open orders")
+	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+
+	data, err := self.app.GetOpenOrders(getTimePoint(c, true))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success":   true,
+				"version":   data.Version,
+				"timestamp": data.Timestamp,
+				"data":      data.Data,
+			},
+		)
+	}
+}
+
+func (self *HTTPServer) updateData(update common.DataUpdate) (gin.H, error) {
+	timepoint := uint64(update.Version)
+	switch update.Topic {
+	case common.PRICE_UPDATE:
+		data, err := self.app.GetAllPrices(timepoint)
+		return gin.H{"version": data.Version, "timestamp": data.Timestamp, "data": data.Data}, err
+	case common.RATE_UPDATE:
+		data, err := self.app.GetRate(timepoint)
+		return gin.H{"version": data.Version, "timestamp": data.Timestamp, "data": data.Data}, err
+	case common.AUTH_DATA_UPDATE:
+		data, err := self.app.GetAuthData(timepoint)
+		return gin.H{"version": data.Version, "timestamp": data.Timestamp, "data": data.Data}, err
+	}
+	return nil, fmt.Errorf("Topic %s is not supported", update.Topic)
+}
+
+// Subscribe pushes new prices, rates and auth data versions as server sent
+// events as soon as they are stored. Topics are given in "topics" param
+// separated by comma, all topics are subscribed if it is omitted.
+func (self *HTTPServer) Subscribe(c *gin.Context) {
+	log.Printf("Subscribing to data updates")
+	params, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, RebalancePermission, ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	topics := map[string]bool{}
+	topicsParam := params.Get("topics")
+	if topicsParam == "" {
+		topicsParam = strings.Join([]string{common.PRICE_UPDATE, common.RATE_UPDATE, common.AUTH_DATA_UPDATE}, ",")
+	}
+	for _, topic := range strings.Split(topicsParam, ",") {
+		switch topic {
+		case common.PRICE_UPDATE, common.RATE_UPDATE, common.AUTH_DATA_UPDATE:
+			topics[topic] = true
+		default:
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": fmt.Sprintf("Topic %s is not supported", topic)},
+			)
+			return
+		}
+	}
+
+	updates := self.app.SubscribeUpdates()
+	defer self.app.UnsubscribeUpdates(updates)
+	heartbeat := time.NewTicker(SUBSCRIPTION_HEARTBEAT)
+	defer heartbeat.Stop()
+	clientGone := c.Writer.CloseNotify()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-clientGone:
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", common.GetTimepoint())
+			return true
+		case update, ok := <-updates:
+			if !ok {
+				return false
+			}
+			if !topics[update.Topic] {
+				return true
+			}
+			data, err := self.updateData(update)
+			if err != nil {
+				log.Printf("Getting %s version %d failed: %s", update.Topic, update.Version, err)
+				return true
+			}
+			c.SSEvent(update.Topic, data)
+			return true
+		}
+	})
+}
+
 func (self *HTTPServer) GetGoldData(c *gin.Context) {
 	log.Printf("Getting gold data")
 
@@ -1411,7 +1751,7 @@ func (self *HTTPServer) GetPendingPWIEquation(c *gin.Context) {
 	if !ok {
 		return
 	}
-	data, err := self.metric.GetPendingPWIEquation()
+	proposal, err := self.proposals.GetPending(metric.PWI_EQUATION_PROPOSAL, common.GetTimepoint())
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1426,7 +1766,7 @@ func (self *HTTPServer) GetPendingPWIEquation(c *gin.Context) {
 		http.StatusOK,
 		gin.H{
 			"success": true,
-			"data":    data,
+			"data":    metric.PWIEquation{ID: proposal.ID, Data: proposal.Data},
 		},
 	)
 }
@@ -1467,32 +1807,14 @@ func (self *HTTPServer) GetBurnFee(c *gin.Context) {
 }
 
 func (self *HTTPServer) SetPWIEquation(c *gin.Context) {
-	var err error
 	postForm, ok := self.Authenticated(c, []string{}, []Permission{ConfigurePermission})
 	if !ok {
 		return
 	}
 	data := postForm.Get("data")
-	for _, dataConfig := range strings.Split(data, "|") {
-		dataParts := strings.Split(dataConfig, "_")
-		if len(dataParts) != 4 {
-			c.JSON(
-				http.StatusOK,
-				gin.H{"success": false, "reason": "The input data is not correct"},
-			)
-			return
-		}
-		token := dataParts[0]
-		_, err = common.GetInternalToken(token)
-		if err != nil {
-			c.JSON(
-				http.StatusOK,
-				gin.H{"success": false, "reason": err.Error()},
-			)
-			return
-		}
-	}
-	err = self.metric.StorePendingPWIEquation(data)
+	_, err := self.proposals.Propose(
+		metric.PWI_EQUATION_PROPOSAL, data, nil,
+		self.signer(c, "anonymous proposer"), common.GetTimepoint())
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1543,7 +1865,7 @@ func (self *HTTPServer) ConfirmPWIEquation(c *gin.Context) {
 		return
 	}
 	postData := postForm.Get("data")
-	err := self.metric.StorePWIEquation(postData)
+	_, err := self.approvePending(c, metric.PWI_EQUATION_PROPOSAL, postData)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -1727,8 +2049,7 @@ func (self *HTTPServer) RejectPWIEquation(c *gin.Context) {
 	if !ok {
 		return
 	}
-	// postData := postForm.Get("data")
-	err := self.metric.RemovePendingPWIEquation()
+	_, err := self.rejectPending(c, metric.PWI_EQUATION_PROPOSAL)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -2203,6 +2524,43 @@ func (self *HTTPServer) UpdatePriceAnalyticData(c *gin.Context) {
 		},
 	)
 }
+func (self *HTTPServer) ReplayStatAggregation(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"aggregation", "from_time"}, []Permission{ConfigurePermission})
+	if !ok {
+		return
+	}
+	fromTime, err := strconv.ParseUint(postForm.Get("from_time"), 10, 64)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": false,
+				"reason":  fmt.Sprintf("from_time param is invalid: %s", err),
+			},
+		)
+		return
+	}
+	toTime, _ := strconv.ParseUint(postForm.Get("to_time"), 10, 64)
+	replayed, err := self.stat.ReplayAggregation(postForm.Get("aggregation"), fromTime, toTime)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": false,
+				"reason":  err.Error(),
+			},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    replayed,
+		},
+	)
+}
+
 func (self *HTTPServer) GetPriceAnalyticData(c *gin.Context) {
 	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
 	if !ok {
@@ -2392,6 +2750,75 @@ func (self *HTTPServer) GetReserveVolume(c *gin.Context) {
 	)
 }
 
+func (self *HTTPServer) GetReserves(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    self.stat.GetReserves(),
+		},
+	)
+}
+
+func (self *HTTPServer) GetReserveStats(c *gin.Context) {
+	fromTime, _ := strconv.ParseUint(c.Query("fromTime"), 10, 64)
+	toTime, _ := strconv.ParseUint(c.Query("toTime"), 10, 64)
+	freq := c.Query("freq")
+	reserveAddr := c.Query("reserveAddr")
+	if reserveAddr == "" {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": false,
+				"reason":  "reserveAddr is required",
+			},
+		)
+		return
+	}
+	data, err := self.stat.GetReserveStats(fromTime, toTime, freq, reserveAddr)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": false,
+				"reason":  err.Error(),
+			},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    data,
+		},
+	)
+}
+
+func (self *HTTPServer) GetReserveMarketShare(c *gin.Context) {
+	fromTime, _ := strconv.ParseUint(c.Query("fromTime"), 10, 64)
+	toTime, _ := strconv.ParseUint(c.Query("toTime"), 10, 64)
+	freq := c.Query("freq")
+	data, err := self.stat.GetReserveMarketShare(fromTime, toTime, freq)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": false,
+				"reason":  err.Error(),
+			},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    data,
+		},
+	)
+}
+
 func (self *HTTPServer) SetStableTokenParams(c *gin.Context) {
 	postForm, ok := self.Authenticated(c, []string{}, []Permission{ConfigurePermission})
 	if !ok {
@@ -2408,7 +2835,9 @@ func (self *HTTPServer) SetStableTokenParams(c *gin.Context) {
 		)
 		return
 	}
-	err := self.metric.SetStableTokenParams(value)
+	_, err := self.proposals.Propose(
+		metric.STABLE_TOKEN_PARAMS_PROPOSAL, string(value), nil,
+		self.signer(c, "anonymous proposer"), common.GetTimepoint())
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -2443,7 +2872,7 @@ func (self *HTTPServer) ConfirmStableTokenParams(c *gin.Context) {
 		)
 		return
 	}
-	err := self.metric.ConfirmStableTokenParams(value)
+	_, err := self.approvePending(c, metric.STABLE_TOKEN_PARAMS_PROPOSAL, string(value))
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -2467,7 +2896,7 @@ func (self *HTTPServer) RejectStableTokenParams(c *gin.Context) {
 	if !ok {
 		return
 	}
-	err := self.metric.RemovePendingStableTokenParams()
+	_, err := self.rejectPending(c, metric.STABLE_TOKEN_PARAMS_PROPOSAL)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -2487,13 +2916,19 @@ func (self *HTTPServer) RejectStableTokenParams(c *gin.Context) {
 
 }
 
+// GetPendingStableTokenParams returns params of the pending proposal, it is
+// empty if there is no pending proposal
 func (self *HTTPServer) GetPendingStableTokenParams(c *gin.Context) {
 	_, ok := self.Authenticated(c, []string{}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
 	if !ok {
 		return
 	}
 
-	data, err := self.metric.GetPendingStableTokenParams()
+	data := make(map[string]interface{})
+	proposals, err := self.proposals.GetProposals(metric.STABLE_TOKEN_PARAMS_PROPOSAL, metric.PROPOSAL_PENDING, common.GetTimepoint())
+	if err == nil && len(proposals) > 0 {
+		err = json.Unmarshal([]byte(proposals[0].Data), &data)
+	}
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -2577,6 +3012,92 @@ func (self *HTTPServer) GetTokenHeatmap(c *gin.Context) {
 	)
 }
 
+func (self *HTTPServer) GetAPIKeys(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{}, []Permission{KeyAdminPermission})
+	if !ok {
+		return
+	}
+	keys, err := self.auth.GetKeys()
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    keys,
+		},
+	)
+}
+
+// CreateAPIKey returns the new key with its secret, the secret is not
+// returned by any other api
+func (self *HTTPServer) CreateAPIKey(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"scopes"}, []Permission{KeyAdminPermission})
+	if !ok {
+		return
+	}
+	scopes := []common.APIKeyScope{}
+	if err := json.Unmarshal([]byte(postForm.Get("scopes")), &scopes); err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	var expiresAt uint64
+	if postForm.Get("expires_at") != "" {
+		var err error
+		expiresAt, err = strconv.ParseUint(postForm.Get("expires_at"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	key, err := self.auth.CreateKey(scopes, expiresAt)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    key,
+		},
+	)
+}
+
+func (self *HTTPServer) RevokeAPIKey(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"id"}, []Permission{KeyAdminPermission})
+	if !ok {
+		return
+	}
+	if err := self.auth.RevokeKey(postForm.Get("id")); err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+		},
+	)
+}
+
 func (self *HTTPServer) Run() {
 	if self.core != nil && self.app != nil {
 		self.r.GET("/prices-version", self.AllPricesVersion)
@@ -2597,6 +3118,8 @@ func (self *HTTPServer) Run() {
 		self.r.POST("/withdraw/:exchangeid", self.Withdraw)
 		self.r.POST("/trade/:exchangeid", self.Trade)
 		self.r.POST("/setrates", self.SetRate)
+		self.r.GET("/plan-rates", self.PlanRates)
+		self.r.GET("/simulate-rate", self.SimulateRate)
 		self.r.GET("/exchangeinfo", self.GetExchangeInfo)
 		self.r.GET("/exchangeinfo/:exchangeid/:base/:quote", self.GetPairInfo)
 		self.r.GET("/exchangefees", self.GetFee)
@@ -2604,6 +3127,8 @@ func (self *HTTPServer) Run() {
 		self.r.GET("/exchangefees/:exchangeid", self.GetExchangeFee)
 		self.r.GET("/core/addresses", self.GetAddress)
 		self.r.GET("/tradehistory", self.GetTradeHistory)
+		self.r.GET("/open-orders", self.GetOpenOrders)
+		self.r.GET("/subscribe", self.Subscribe)
 
 		self.r.GET("/targetqty", self.GetTargetQty)
 		self.r.GET("/pendingtargetqty", self.GetPendingTargetQty)
@@ -2639,7 +3164,23 @@ func (self *HTTPServer) Run() {
 		self.r.GET("/pending-stable-token-params", self.GetPendingStableTokenParams)
 		self.r.GET("/stable-token-params", self.GetStableTokenParams)
 
+		self.r.POST("/submit-step-function", self.SubmitStepFunction)
+		self.r.GET("/step-functions", self.GetStepFunctions)
+
 		self.r.GET("/gold-feed", self.GetGoldData)
+
+		self.r.GET("/proposals", self.GetProposals)
+		self.r.GET("/proposals/:id", self.GetProposal)
+		self.r.POST("/proposals", self.Propose)
+		self.r.POST("/proposals/:id/approve", self.ApproveProposal)
+		self.r.POST("/proposals/:id/reject", self.RejectProposal)
+
+		self.r.GET("/audit-logs", self.GetAuditLogs)
+		self.r.GET("/nonces", self.GetNonces)
+
+		self.r.GET("/api-keys", self.GetAPIKeys)
+		self.r.POST("/create-api-key", self.CreateAPIKey)
+		self.r.POST("/revoke-api-key", self.RevokeAPIKey)
 	}
 
 	if self.stat != nil {
@@ -2664,7 +3205,11 @@ func (self *HTTPServer) Run() {
 		self.r.GET("/get-countries", self.GetCountries)
 		self.r.POST("/update-price-analytic-data", self.UpdatePriceAnalyticData)
 		self.r.GET("/get-price-analytic-data", self.GetPriceAnalyticData)
+		self.r.POST("/replay-stat-aggregation", self.ReplayStatAggregation)
 		self.r.GET("/get-reserve-volume", self.GetReserveVolume)
+		self.r.GET("/get-reserves", self.GetReserves)
+		self.r.GET("/get-reserve-stats", self.GetReserveStats)
+		self.r.GET("/get-reserve-market-share", self.GetReserveMarketShare)
 		self.r.GET("/get-user-list", self.GetUserList)
 		self.r.GET("/get-token-heatmap", self.GetTokenHeatmap)
 	}
@@ -2677,9 +3222,11 @@ func NewHTTPServer(
 	core reserve.ReserveCore,
 	stat reserve.ReserveStats,
 	metric metric.MetricStorage,
+	proposals *metric.ProposalManager,
 	host string,
 	enableAuth bool,
 	authEngine Authentication,
+	auditStorage AuditStorage,
 	env string) *HTTPServer {
 
 	r := gin.Default()
@@ -2697,12 +3244,14 @@ func NewHTTPServer(
 		false,
 	))
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AddAllowHeaders("signed")
+	corsConfig.AddAllowHeaders("signed", "apikey", "cosigned", "cosigner_apikey")
 	corsConfig.AllowAllOrigins = true
 	corsConfig.MaxAge = 5 * time.Minute
 	r.Use(cors.New(corsConfig))
 
-	return &HTTPServer{
-		app, core, stat, metric, host, enableAuth, authEngine, r,
+	server := &HTTPServer{
+		app, core, stat, metric, proposals, host, enableAuth, authEngine, auditStorage, r,
 	}
+	r.Use(server.auditRequests)
+	return server
 }
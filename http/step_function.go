@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/gin-gonic/gin"
+)
+
+// SubmitStepFunction sends the step functions of "token" in the latest
+// applied step_function proposal to the pricing contract, "type" (qty or imbalance) limits the submission to one
+// of them. It returns the activity id of each submitted step function.
+func (self *HTTPServer) SubmitStepFunction(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"token"}, []Permission{ConfigurePermission, ConfirmConfPermission})
+	if !ok {
+		return
+	}
+	token, err := common.GetInternalToken(postForm.Get("token"))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	kind := postForm.Get("type")
+	if kind != "" && kind != common.QTY_STEP_FUNCTION && kind != common.IMBALANCE_STEP_FUNCTION {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": "Step function type must be qty or imbalance"},
+		)
+		return
+	}
+	stepFunctions, proposalID, err := self.proposals.ConfirmedStepFunctions(token.ID, common.GetTimepoint())
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	functions := map[string]*common.StepFunction{
+		common.QTY_STEP_FUNCTION:       stepFunctions.Qty,
+		common.IMBALANCE_STEP_FUNCTION: stepFunctions.Imbalance,
+	}
+	ids := map[string]common.ActivityID{}
+	for _, name := range []string{common.QTY_STEP_FUNCTION, common.IMBALANCE_STEP_FUNCTION} {
+		if functions[name] == nil || (kind != "" && kind != name) {
+			continue
+		}
+		id, err := self.core.SetStepFunction(token, name, *functions[name])
+		ids[name] = id
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error(), "ids": ids, "proposal": proposalID},
+			)
+			return
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": "There is no confirmed step function of this type"},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "ids": ids, "proposal": proposalID},
+	)
+}
+
+// GetStepFunctions returns the current qty and imbalance step functions of
+// "token" in the pricing contract
+func (self *HTTPServer) GetStepFunctions(c *gin.Context) {
+	_, ok := self.Authenticated(c, []string{"token"}, []Permission{ReadOnlyPermission, ConfigurePermission, ConfirmConfPermission, RebalancePermission})
+	if !ok {
+		return
+	}
+	token, err := common.GetInternalToken(c.Query("token"))
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	data, err := self.core.GetStepFunctions(token)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": data},
+	)
+}
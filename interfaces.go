@@ -18,6 +18,10 @@ type ReserveStats interface {
 	GetUserVolume(fromTime, toTime uint64, freq, userAddr string) (common.StatTicks, error)
 	GetUsersVolume(fromTime, toTime uint64, freq string, userAddrs []string) (common.UsersVolume, error)
 	GetReserveVolume(fromTime, toTime uint64, freq, reserveAddr, token string) (common.StatTicks, error)
+	// GetReserves returns our reserve and the configured third party reserves
+	GetReserves() []string
+	GetReserveStats(fromTime, toTime uint64, freq, reserveAddr string) (common.StatTicks, error)
+	GetReserveMarketShare(fromTime, toTime uint64, freq string) (common.StatTicks, error)
 	GetTradeSummary(fromTime, toTime uint64, timezone int64) (common.StatTicks, error)
 
 	GetCapByUser(userID string) (*common.UserCap, error)
@@ -37,6 +41,11 @@ type ReserveStats interface {
 
 	GetUserList(fromTime, toTime uint64, timezone int64) (common.UserListResponse, error)
 
+	// ReplayAggregation rebuilds stats of aggregation from fromTime to
+	// toTime in millisecond from the stored trade logs while the stat
+	// fetcher is held, it returns the number of replayed trade logs
+	ReplayAggregation(aggregation string, fromTime, toTime uint64) (int, error)
+
 	RunDBController() error
 	Run() error
 	Stop() error
@@ -58,6 +67,15 @@ type ReserveData interface {
 	GetRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error)
 	GetPendingActivities() ([]common.ActivityRecord, error)
 
+	CurrentOrderVersion(timestamp uint64) (common.Version, error)
+	GetOpenOrders(timestamp uint64) (common.AllOrderResponse, error)
+
+	// SubscribeUpdates notifies every new price, rate and auth data version
+	// as soon as it is stored, the channel must be released with
+	// UnsubscribeUpdates
+	SubscribeUpdates() <-chan common.DataUpdate
+	UnsubscribeUpdates(ch <-chan common.DataUpdate)
+
 	GetTradeHistory(timepoint uint64) (common.AllTradeHistory, error)
 
 	GetGoldData(timepoint uint64) (common.GoldData, error)
@@ -83,22 +101,41 @@ type ReserveCore interface {
 		amount float64,
 		timestamp uint64) (id common.ActivityID, done float64, remaining float64, finished bool, err error)
 
+	// Deposit bypasses transfer limits if cosigned is true
 	Deposit(
 		exchange common.Exchange,
 		token common.Token,
 		amount *big.Int,
-		timestamp uint64) (common.ActivityID, error)
+		timestamp uint64,
+		cosigned bool) (common.ActivityID, error)
 
+	// Withdraw bypasses transfer limits if cosigned is true
 	Withdraw(
 		exchange common.Exchange,
 		token common.Token,
 		amount *big.Int,
-		timestamp uint64) (common.ActivityID, error)
+		timestamp uint64,
+		cosigned bool) (common.ActivityID, error)
 
 	CancelOrder(id common.ActivityID, exchange common.Exchange) error
 
 	// blockchain related action
 	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int) (common.ActivityID, error)
 
+	// PlanRates previews how SetRates would update rates on chain
+	PlanRates(tokens []common.Token, buys, sells []*big.Int) (common.CompactRatePlan, error)
+
+	// SetStepFunction sends the qty or imbalance step function of token
+	SetStepFunction(token common.Token, kind string, stepFunction common.StepFunction) (common.ActivityID, error)
+
+	GetStepFunctions(token common.Token) (common.TokenStepFunctions, error)
+
+	// SimulateRates returns the rates of the pricing contract for quantities
+	// of token at block, the current block is used if block is 0
+	SimulateRates(token common.Token, side string, quantities []float64, block uint64) (common.RateSimulation, error)
+
+	// GetNonceStates returns nonce states of blockchain operators by name
+	GetNonceStates() (map[string]common.NonceState, error)
+
 	GetAddresses() *common.Addresses
 }
@@ -0,0 +1,455 @@
+package metric
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	TARGET_QTY_PROPOSAL          string = "target_qty"
+	PWI_EQUATION_PROPOSAL        string = "pwi_equation"
+	STABLE_TOKEN_PARAMS_PROPOSAL string = "stable_token_params"
+	STEP_FUNCTION_PROPOSAL       string = "step_function"
+
+	PROPOSAL_PENDING  string = "pending"
+	PROPOSAL_APPLIED  string = "applied"
+	PROPOSAL_REJECTED string = "rejected"
+	PROPOSAL_EXPIRED  string = "expired"
+)
+
+// ProposalEvent is an entry of the history of a proposal, Action is one of
+// propose, approve, apply, reject and expire
+type ProposalEvent struct {
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	Timestamp uint64 `json:"timestamp"`
+	Note      string `json:"note,omitempty"`
+}
+
+// Proposal is a configuration change waiting for approvals. It is applied
+// when RequiredApprovals keys other than the proposer approve it before
+// ExpiresAt (millisecond, 0 means never).
+type Proposal struct {
+	ID                uint64            `json:"id"`
+	Type              string            `json:"type"`
+	Data              string            `json:"data"`
+	Params            map[string]string `json:"params,omitempty"`
+	Proposer          string            `json:"proposer"`
+	Approvers         []string          `json:"approvers"`
+	RequiredApprovals int               `json:"required_approvals"`
+	CreatedAt         uint64            `json:"created_at"`
+	ExpiresAt         uint64            `json:"expires_at"`
+	Status            string            `json:"status"`
+	History           []ProposalEvent   `json:"history"`
+}
+
+func (self *Proposal) addEvent(action, actor, note string, timepoint uint64) {
+	self.History = append(self.History, ProposalEvent{action, actor, timepoint, note})
+}
+
+// ProposalType validates and applies proposals of a configuration type.
+// Matches compares the data of an approval with the proposed data, string
+// equality is used if it is nil.
+type ProposalType struct {
+	Name     string
+	Validate func(data string, params map[string]string) error
+	Matches  func(proposed, approved string) bool
+	Apply    func(proposal Proposal) error
+}
+
+// ProposalStorage persists proposals by id
+type ProposalStorage interface {
+	StoreProposal(proposal Proposal) error
+	GetProposal(id uint64) (Proposal, error)
+	GetProposals() ([]Proposal, error)
+}
+
+// ProposalManager runs the two-person approval workflow of configuration
+// changes: a key proposes a change, other keys approve or reject it. There
+// is at most one pending proposal of each type.
+type ProposalManager struct {
+	mu                sync.Mutex
+	storage           MetricStorage
+	types             map[string]ProposalType
+	requiredApprovals int
+	ttl               uint64
+	lastID            uint64
+}
+
+// NewProposalManager returns a manager of target quantity, PWI equation,
+// stable token params and step function proposals. Proposals need requiredApprovals approvals
+// and expire after ttl milliseconds, 0 ttl means they never expire.
+func NewProposalManager(storage MetricStorage, requiredApprovals int, ttl uint64) *ProposalManager {
+	if requiredApprovals < 1 {
+		requiredApprovals = 1
+	}
+	result := &ProposalManager{
+		storage:           storage,
+		types:             map[string]ProposalType{},
+		requiredApprovals: requiredApprovals,
+		ttl:               ttl,
+	}
+	result.RegisterType(targetQtyProposalType(storage))
+	result.RegisterType(pwiEquationProposalType(storage))
+	result.RegisterType(stableTokenParamsProposalType(storage))
+	result.RegisterType(stepFunctionProposalType())
+	return result
+}
+
+// RegisterType makes proposals of proposalType.Name available, it replaces
+// the registered type of the same name
+func (self *ProposalManager) RegisterType(proposalType ProposalType) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.types[proposalType.Name] = proposalType
+}
+
+func (self *ProposalManager) getType(name string) (ProposalType, error) {
+	proposalType, found := self.types[name]
+	if !found {
+		return proposalType, fmt.Errorf("Proposal type %s is not supported", name)
+	}
+	return proposalType, nil
+}
+
+// expire marks proposal expired if it is pending after its expiry
+func (self *ProposalManager) expire(proposal *Proposal, timepoint uint64) error {
+	if proposal.Status != PROPOSAL_PENDING || proposal.ExpiresAt == 0 || timepoint < proposal.ExpiresAt {
+		return nil
+	}
+	proposal.Status = PROPOSAL_EXPIRED
+	proposal.addEvent("expire", "", "", timepoint)
+	return self.storage.StoreProposal(*proposal)
+}
+
+// GetProposals returns proposals of proposalType with status, empty
+// filters match all proposals
+func (self *ProposalManager) GetProposals(proposalType, status string, timepoint uint64) ([]Proposal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	proposals, err := self.storage.GetProposals()
+	if err != nil {
+		return nil, err
+	}
+	result := []Proposal{}
+	for i := range proposals {
+		if err = self.expire(&proposals[i], timepoint); err != nil {
+			return nil, err
+		}
+		if (proposalType == "" || proposals[i].Type == proposalType) &&
+			(status == "" || proposals[i].Status == status) {
+			result = append(result, proposals[i])
+		}
+	}
+	return result, nil
+}
+
+func (self *ProposalManager) GetProposal(id uint64, timepoint uint64) (Proposal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	proposal, err := self.storage.GetProposal(id)
+	if err != nil {
+		return proposal, err
+	}
+	err = self.expire(&proposal, timepoint)
+	return proposal, err
+}
+
+// GetPending returns the pending proposal of proposalType
+func (self *ProposalManager) GetPending(proposalType string, timepoint uint64) (Proposal, error) {
+	proposals, err := self.GetProposals(proposalType, PROPOSAL_PENDING, timepoint)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if len(proposals) == 0 {
+		return Proposal{}, fmt.Errorf("There is no pending %s proposal", proposalType)
+	}
+	return proposals[0], nil
+}
+
+func (self *ProposalManager) pending(proposalType string, timepoint uint64) (bool, error) {
+	proposals, err := self.storage.GetProposals()
+	if err != nil {
+		return false, err
+	}
+	for i := range proposals {
+		if proposals[i].Type != proposalType {
+			continue
+		}
+		if err = self.expire(&proposals[i], timepoint); err != nil {
+			return false, err
+		}
+		if proposals[i].Status == PROPOSAL_PENDING {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Propose stores a pending proposal of proposalType by proposer, it fails
+// if data is invalid or another proposal of the type is pending
+func (self *ProposalManager) Propose(proposalType, data string, params map[string]string, proposer string, timepoint uint64) (Proposal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	pType, err := self.getType(proposalType)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if pType.Validate != nil {
+		if err = pType.Validate(data, params); err != nil {
+			return Proposal{}, err
+		}
+	}
+	found, err := self.pending(proposalType, timepoint)
+	if err != nil {
+		return Proposal{}, err
+	}
+	if found {
+		return Proposal{}, fmt.Errorf("There is another pending %s proposal, please approve or reject it before proposing a new one", proposalType)
+	}
+	id := timepoint
+	if id <= self.lastID {
+		id = self.lastID + 1
+	}
+	self.lastID = id
+	proposal := Proposal{
+		ID:                id,
+		Type:              proposalType,
+		Data:              data,
+		Params:            params,
+		Proposer:          proposer,
+		Approvers:         []string{},
+		RequiredApprovals: self.requiredApprovals,
+		CreatedAt:         timepoint,
+		Status:            PROPOSAL_PENDING,
+	}
+	if self.ttl > 0 {
+		proposal.ExpiresAt = timepoint + self.ttl
+	}
+	proposal.addEvent("propose", proposer, "", timepoint)
+	return proposal, self.storage.StoreProposal(proposal)
+}
+
+func (self *ProposalManager) getPendingProposal(id uint64, timepoint uint64) (Proposal, error) {
+	proposal, err := self.storage.GetProposal(id)
+	if err != nil {
+		return proposal, err
+	}
+	if err = self.expire(&proposal, timepoint); err != nil {
+		return proposal, err
+	}
+	if proposal.Status != PROPOSAL_PENDING {
+		return proposal, fmt.Errorf("Proposal %d is %s", id, proposal.Status)
+	}
+	return proposal, nil
+}
+
+// Approve approves the pending proposal id by approver, data must match
+// the proposed data. The proposal is applied when it has enough approvals,
+// it stays pending if applying fails.
+func (self *ProposalManager) Approve(id uint64, data string, approver string, timepoint uint64) (Proposal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	proposal, err := self.getPendingProposal(id, timepoint)
+	if err != nil {
+		return proposal, err
+	}
+	pType, err := self.getType(proposal.Type)
+	if err != nil {
+		return proposal, err
+	}
+	matches := pType.Matches
+	if matches == nil {
+		matches = func(proposed, approved string) bool { return proposed == approved }
+	}
+	if !matches(proposal.Data, data) {
+		return proposal, errors.New("Approved data does not match proposed data")
+	}
+	if approver == proposal.Proposer {
+		return proposal, errors.New("Proposals can't be approved by their proposer")
+	}
+	for _, existing := range proposal.Approvers {
+		if existing == approver {
+			return proposal, fmt.Errorf("Proposal %d is already approved by %s", id, approver)
+		}
+	}
+	proposal.Approvers = append(proposal.Approvers, approver)
+	proposal.addEvent("approve", approver, "", timepoint)
+	if len(proposal.Approvers) >= proposal.RequiredApprovals {
+		if err = pType.Apply(proposal); err != nil {
+			return proposal, err
+		}
+		proposal.Status = PROPOSAL_APPLIED
+		proposal.addEvent("apply", approver, "", timepoint)
+	}
+	return proposal, self.storage.StoreProposal(proposal)
+}
+
+// Reject closes the pending proposal id without applying it
+func (self *ProposalManager) Reject(id uint64, actor, note string, timepoint uint64) (Proposal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	proposal, err := self.getPendingProposal(id, timepoint)
+	if err != nil {
+		return proposal, err
+	}
+	proposal.Status = PROPOSAL_REJECTED
+	proposal.addEvent("reject", actor, note, timepoint)
+	return proposal, self.storage.StoreProposal(proposal)
+}
+
+// validateTokenConfig checks data of token configs separated by "|" with
+// parts separated by "_", the first part is an internal token. The number
+// of parts is not checked if parts is 0.
+func validateTokenConfig(data string, parts int) error {
+	for _, dataConfig := range strings.Split(data, "|") {
+		dataParts := strings.Split(dataConfig, "_")
+		if parts != 0 && len(dataParts) != parts {
+			return fmt.Errorf("Token config (%s) must have %d parts", dataConfig, parts)
+		}
+		if _, err := common.GetInternalToken(dataParts[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targetQtyProposalType applies TokenTargetQty data, the "type" param is
+// the type of the target quantity
+func targetQtyProposalType(storage MetricStorage) ProposalType {
+	return ProposalType{
+		Name: TARGET_QTY_PROPOSAL,
+		Validate: func(data string, params map[string]string) error {
+			if params["type"] == "" {
+				return errors.New("Target quantity proposals need a type param")
+			}
+			if params["type"] == "1" {
+				return validateTokenConfig(data, 5)
+			}
+			return validateTokenConfig(data, 0)
+		},
+		Apply: func(proposal Proposal) error {
+			targetQty := TokenTargetQty{
+				ID:        proposal.ID,
+				Timestamp: common.GetTimepoint(),
+				Data:      proposal.Data,
+				Status:    "confirmed",
+			}
+			targetQty.Type, _ = strconv.ParseInt(proposal.Params["type"], 10, 64)
+			return storage.StoreTokenTargetQty(targetQty)
+		},
+	}
+}
+
+func pwiEquationProposalType(storage MetricStorage) ProposalType {
+	return ProposalType{
+		Name: PWI_EQUATION_PROPOSAL,
+		Validate: func(data string, params map[string]string) error {
+			return validateTokenConfig(data, 4)
+		},
+		Apply: func(proposal Proposal) error {
+			return storage.StorePWIEquation(PWIEquation{proposal.ID, proposal.Data})
+		},
+	}
+}
+
+// stableTokenParamsProposalType applies json objects of stable token
+// params, approvals may encode the same object differently
+func stableTokenParamsProposalType(storage MetricStorage) ProposalType {
+	return ProposalType{
+		Name: STABLE_TOKEN_PARAMS_PROPOSAL,
+		Validate: func(data string, params map[string]string) error {
+			temp := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(data), &temp); err != nil {
+				return fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err)
+			}
+			return nil
+		},
+		Matches: func(proposed, approved string) bool {
+			proposedParams := map[string]interface{}{}
+			approvedParams := map[string]interface{}{}
+			if json.Unmarshal([]byte(proposed), &proposedParams) != nil ||
+				json.Unmarshal([]byte(approved), &approvedParams) != nil {
+				return false
+			}
+			return reflect.DeepEqual(proposedParams, approvedParams)
+		},
+		Apply: func(proposal Proposal) error {
+			return storage.StoreStableTokenParams([]byte(proposal.Data))
+		},
+	}
+}
+
+// parseStepFunctions parses step functions of internal tokens by token id
+func parseStepFunctions(data string) (map[string]common.TokenStepFunctions, error) {
+	result := map[string]common.TokenStepFunctions{}
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return result, fmt.Errorf("Rejected: Data could not be unmarshalled to defined format: %s", err)
+	}
+	if len(result) == 0 {
+		return result, errors.New("Step function proposals need at least one token")
+	}
+	for tokenID, stepFunctions := range result {
+		if _, err := common.GetInternalToken(tokenID); err != nil {
+			return result, err
+		}
+		if err := stepFunctions.Validate(); err != nil {
+			return result, fmt.Errorf("%s %s", tokenID, err)
+		}
+	}
+	return result, nil
+}
+
+// ConfirmedStepFunctions returns the step functions of tokenID of the
+// latest applied step function proposal setting them
+func (self *ProposalManager) ConfirmedStepFunctions(tokenID string, timepoint uint64) (common.TokenStepFunctions, uint64, error) {
+	proposals, err := self.GetProposals(STEP_FUNCTION_PROPOSAL, PROPOSAL_APPLIED, timepoint)
+	if err != nil {
+		return common.TokenStepFunctions{}, 0, err
+	}
+	for i := len(proposals) - 1; i >= 0; i-- {
+		stepFunctions, err := parseStepFunctions(proposals[i].Data)
+		if err != nil {
+			return common.TokenStepFunctions{}, 0, err
+		}
+		if result, found := stepFunctions[tokenID]; found {
+			return result, proposals[i].ID, nil
+		}
+	}
+	return common.TokenStepFunctions{}, 0, fmt.Errorf("There is no confirmed step function of %s", tokenID)
+}
+
+// stepFunctionProposalType confirms json objects of step functions by
+// token. Applied proposals are the confirmed step functions, they are sent
+// to the pricing contract by the submit api.
+func stepFunctionProposalType() ProposalType {
+	return ProposalType{
+		Name: STEP_FUNCTION_PROPOSAL,
+		Validate: func(data string, params map[string]string) error {
+			_, err := parseStepFunctions(data)
+			return err
+		},
+		Matches: func(proposed, approved string) bool {
+			proposedFunctions := map[string]common.TokenStepFunctions{}
+			approvedFunctions := map[string]common.TokenStepFunctions{}
+			if json.Unmarshal([]byte(proposed), &proposedFunctions) != nil ||
+				json.Unmarshal([]byte(approved), &approvedFunctions) != nil {
+				return false
+			}
+			// big ints are compared by their encoding
+			proposedJSON, _ := json.Marshal(proposedFunctions)
+			approvedJSON, _ := json.Marshal(approvedFunctions)
+			return string(proposedJSON) == string(approvedJSON)
+		},
+		Apply: func(proposal Proposal) error {
+			return nil
+		},
+	}
+}
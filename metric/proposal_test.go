@@ -0,0 +1,154 @@
+package metric
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testProposalStorage struct {
+	MetricStorage
+	proposals    map[uint64]Proposal
+	stableParams string
+}
+
+func (self *testProposalStorage) StoreProposal(proposal Proposal) error {
+	self.proposals[proposal.ID] = proposal
+	return nil
+}
+
+func (self *testProposalStorage) GetProposal(id uint64) (Proposal, error) {
+	proposal, found := self.proposals[id]
+	if !found {
+		return proposal, fmt.Errorf("Proposal %d is not found", id)
+	}
+	return proposal, nil
+}
+
+func (self *testProposalStorage) GetProposals() ([]Proposal, error) {
+	result := []Proposal{}
+	for _, proposal := range self.proposals {
+		result = append(result, proposal)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (self *testProposalStorage) StoreStableTokenParams(value []byte) error {
+	self.stableParams = string(value)
+	return nil
+}
+
+func TestProposalApproval(t *testing.T) {
+	storage := &testProposalStorage{proposals: map[uint64]Proposal{}}
+	manager := NewProposalManager(storage, 2, 1000)
+	params := `{"DGX":{"AskSpread":10}}`
+
+	if _, err := manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, `{"DGX":`, nil, "alice", 100); err == nil {
+		t.Fatalf("Expected malformed stable token params to be rejected")
+	}
+	proposal, err := manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, params, nil, "alice", 100)
+	if err != nil {
+		t.Fatalf("Couldn't propose stable token params: %s", err)
+	}
+	if _, err = manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, params, nil, "alice", 101); err == nil {
+		t.Fatalf("Expected a second pending proposal of the same type to fail")
+	}
+	if _, err = manager.Approve(proposal.ID, params, "alice", 200); err == nil {
+		t.Fatalf("Expected the proposer not to be able to approve")
+	}
+	if _, err = manager.Approve(proposal.ID, `{"DGX":{"AskSpread":20}}`, "bob", 200); err == nil {
+		t.Fatalf("Expected approving different data to fail")
+	}
+	if proposal, err = manager.Approve(proposal.ID, `{ "DGX": {"AskSpread": 10} }`, "bob", 200); err != nil || proposal.Status != PROPOSAL_PENDING {
+		t.Fatalf("Expected proposal to wait for a second approval, got %+v, error: %v", proposal, err)
+	}
+	if _, err = manager.Approve(proposal.ID, params, "bob", 300); err == nil {
+		t.Fatalf("Expected a second approval of the same key to fail")
+	}
+	if storage.stableParams != "" {
+		t.Fatalf("Expected params not to be applied before enough approvals")
+	}
+	if proposal, err = manager.Approve(proposal.ID, params, "carol", 300); err != nil || proposal.Status != PROPOSAL_APPLIED {
+		t.Fatalf("Expected proposal to be applied, got %+v, error: %v", proposal, err)
+	}
+	if storage.stableParams != params {
+		t.Fatalf("Expected params to be applied, got %s", storage.stableParams)
+	}
+	actions := []string{}
+	for _, event := range proposal.History {
+		actions = append(actions, event.Action)
+	}
+	if fmt.Sprint(actions) != "[propose approve approve apply]" {
+		t.Fatalf("Unexpected proposal history %v", actions)
+	}
+}
+
+func TestProposalRejectAndExpiry(t *testing.T) {
+	storage := &testProposalStorage{proposals: map[uint64]Proposal{}}
+	manager := NewProposalManager(storage, 1, 1000)
+	params := `{"DGX":{"AskSpread":10}}`
+
+	proposal, err := manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, params, nil, "alice", 100)
+	if err != nil {
+		t.Fatalf("Couldn't propose stable token params: %s", err)
+	}
+	if proposal, err = manager.Reject(proposal.ID, "bob", "wrong spread", 200); err != nil || proposal.Status != PROPOSAL_REJECTED {
+		t.Fatalf("Expected proposal to be rejected, got %+v, error: %v", proposal, err)
+	}
+	if _, err = manager.Approve(proposal.ID, params, "bob", 200); err == nil {
+		t.Fatalf("Expected approving a rejected proposal to fail")
+	}
+
+	if proposal, err = manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, params, nil, "alice", 300); err != nil {
+		t.Fatalf("Couldn't propose after rejection: %s", err)
+	}
+	if _, err = manager.Approve(proposal.ID, params, "bob", 1300); err == nil {
+		t.Fatalf("Expected approving an expired proposal to fail")
+	}
+	if proposal, err = manager.GetProposal(proposal.ID, 1300); err != nil || proposal.Status != PROPOSAL_EXPIRED {
+		t.Fatalf("Expected proposal to be expired, got %+v, error: %v", proposal, err)
+	}
+	if _, err = manager.Propose(STABLE_TOKEN_PARAMS_PROPOSAL, params, nil, "alice", 1400); err != nil {
+		t.Fatalf("Couldn't propose after expiry: %s", err)
+	}
+	if pending, err := manager.GetProposals(STABLE_TOKEN_PARAMS_PROPOSAL, PROPOSAL_PENDING, 1400); err != nil || len(pending) != 1 {
+		t.Fatalf("Expected one pending proposal, got %+v, error: %v", pending, err)
+	}
+	if _, err = manager.Propose("unknown", params, nil, "alice", 1400); err == nil {
+		t.Fatalf("Expected proposals of unknown type to fail")
+	}
+}
+
+func TestStepFunctionProposals(t *testing.T) {
+	common.RegisterInternalActiveToken(common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18})
+	storage := &testProposalStorage{proposals: map[uint64]Proposal{}}
+	manager := NewProposalManager(storage, 1, 0)
+	qty := `{"x_buy":[0,1000],"y_buy":[0,-10],"x_sell":[1000],"y_sell":[-20]}`
+
+	if _, err := manager.Propose(STEP_FUNCTION_PROPOSAL, `{"KNC":{"qty":{"x_buy":[1000,0],"y_buy":[0,-10],"x_sell":[1000],"y_sell":[-20]}}}`, nil, "alice", 100); err == nil {
+		t.Fatalf("Expected decreasing x to be rejected")
+	}
+	if _, err := manager.Propose(STEP_FUNCTION_PROPOSAL, `{"XYZ":{"qty":`+qty+`}}`, nil, "alice", 100); err == nil {
+		t.Fatalf("Expected step functions of unsupported tokens to be rejected")
+	}
+	if _, _, err := manager.ConfirmedStepFunctions("KNC", 100); err == nil {
+		t.Fatalf("Expected no confirmed step functions before approval")
+	}
+	proposal, err := manager.Propose(STEP_FUNCTION_PROPOSAL, `{"KNC":{"qty":`+qty+`}}`, nil, "alice", 100)
+	if err != nil {
+		t.Fatalf("Couldn't propose step functions: %s", err)
+	}
+	if _, err = manager.Approve(proposal.ID, `{"KNC": {"qty": {"x_buy": [0, 1000], "y_buy": [0, -10], "x_sell": [1000], "y_sell": [-20]}}}`, "bob", 200); err != nil {
+		t.Fatalf("Couldn't approve step functions: %s", err)
+	}
+	stepFunctions, id, err := manager.ConfirmedStepFunctions("KNC", 300)
+	if err != nil || id != proposal.ID || stepFunctions.Qty == nil || stepFunctions.Imbalance != nil {
+		t.Fatalf("Expected confirmed qty step function of proposal %d, got %+v of %d, error: %v", proposal.ID, stepFunctions, id, err)
+	}
+	if stepFunctions.Qty.YSell[0].Int64() != -20 {
+		t.Fatalf("Unexpected confirmed step function %+v", stepFunctions.Qty)
+	}
+}
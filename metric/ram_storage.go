@@ -1,9 +1,7 @@
 package metric
 
 import (
-	"errors"
 	"log"
-	"strconv"
 	"sync"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -12,18 +10,16 @@ import (
 const MAX_CAPACITY int = 1000
 
 type RamMetricStorage struct {
-	mu               sync.RWMutex
-	data             []*MetricEntry
-	pendingTargetQty TokenTargetQty
-	tokenTargetQty   TokenTargetQty
+	mu             sync.RWMutex
+	data           []*MetricEntry
+	tokenTargetQty TokenTargetQty
 }
 
 func NewRamMetricStorage() *RamMetricStorage {
 	return &RamMetricStorage{
-		mu:               sync.RWMutex{},
-		data:             []*MetricEntry{},
-		pendingTargetQty: TokenTargetQty{},
-		tokenTargetQty:   TokenTargetQty{},
+		mu:             sync.RWMutex{},
+		data:           []*MetricEntry{},
+		tokenTargetQty: TokenTargetQty{},
 	}
 }
 
@@ -75,42 +71,10 @@ func (self *RamMetricStorage) GetMetric(tokens []common.Token, fromTime, toTime
 	return result, nil
 }
 
-func (self *RamMetricStorage) StorePendingTargetQty(data, dataType string) error {
+func (self *RamMetricStorage) StoreTokenTargetQty(targetQty TokenTargetQty) error {
 	self.mu.Lock()
 	defer self.mu.Unlock()
-	if self.pendingTargetQty.ID != 0 {
-		return errors.New("There is one pending target quantity, please confirm or cancel it before adding a new one")
-	}
-	self.pendingTargetQty.Type, _ = strconv.ParseInt(dataType, 10, 64)
-	self.pendingTargetQty.Data = data
-	self.pendingTargetQty.Status = "unconfirmed"
-	self.pendingTargetQty.ID = common.GetTimepoint()
-	return nil
-}
-
-func (self *RamMetricStorage) RemovePendingTargetQty() error {
-	self.mu.Lock()
-	defer self.mu.Unlock()
-	self.pendingTargetQty = TokenTargetQty{}
-	return nil
-}
-
-func (self *RamMetricStorage) GetPendingTargetQty() (TokenTargetQty, error) {
-	self.mu.RLock()
-	defer self.mu.RUnlock()
-	result := self.pendingTargetQty
-	return result, nil
-}
-
-func (self *RamMetricStorage) StoreTokenTargetQty(id, data string) error {
-	self.mu.Lock()
-	defer self.mu.Unlock()
-	if self.pendingTargetQty.ID == 0 {
-		return errors.New("There is not pending data. Please set before confirm")
-	}
-	self.tokenTargetQty = self.pendingTargetQty
-	self.tokenTargetQty.Status = "confirmed"
-	self.RemovePendingTargetQty()
+	self.tokenTargetQty = targetQty
 	return nil
 }
 
@@ -142,22 +106,10 @@ func (self *RamMetricStorage) StoreSetrateControl(status bool) error {
 	return nil
 }
 
-func (self *RamMetricStorage) StorePendingPWIEquation(data string) error {
-	return nil
-}
-
-func (self *RamMetricStorage) GetPendingPWIEquation() (PWIEquation, error) {
-	return PWIEquation{}, nil
-}
-
-func (self *RamMetricStorage) StorePWIEquation(data string) error {
+func (self *RamMetricStorage) StorePWIEquation(equation PWIEquation) error {
 	return nil
 }
 
 func (self *RamMetricStorage) GetPWIEquation() (PWIEquation, error) {
 	return PWIEquation{}, nil
 }
-
-func (self *RamMetricStorage) RemovePendingPWIEquation() error {
-	return nil
-}
@@ -5,28 +5,19 @@ import (
 )
 
 type MetricStorage interface {
+	ProposalStorage
+
 	StoreMetric(data *MetricEntry, timepoint uint64) error
-	StoreTokenTargetQty(id, data string) error
-	StorePendingTargetQty(data, dataType string) error
+	StoreTokenTargetQty(targetQty TokenTargetQty) error
 	StoreRebalanceControl(status bool) error
 	StoreSetrateControl(status bool) error
-	StorePendingPWIEquation(data string) error
-	StorePWIEquation(data string) error
+	StorePWIEquation(equation PWIEquation) error
+	StoreStableTokenParams(value []byte) error
 
 	GetMetric(tokens []common.Token, fromTime, toTime uint64) (map[string]MetricList, error)
 	GetTokenTargetQty() (TokenTargetQty, error)
-	GetPendingTargetQty() (TokenTargetQty, error)
 	GetRebalanceControl() (RebalanceControl, error)
 	GetSetrateControl() (SetrateControl, error)
-	GetPendingPWIEquation() (PWIEquation, error)
 	GetPWIEquation() (PWIEquation, error)
-
-	RemovePendingTargetQty() error
-	RemovePendingPWIEquation() error
-
-	SetStableTokenParams(value []byte) error
-	ConfirmStableTokenParams(value []byte) error
-	RemovePendingStableTokenParams() error
-	GetPendingStableTokenParams() (map[string]interface{}, error)
 	GetStableTokenParams() (map[string]interface{}, error)
 }
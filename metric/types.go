@@ -1,5 +1,11 @@
 package metric
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type TokenMetric struct {
 	AfpMid float64
 	Spread float64
@@ -46,3 +52,41 @@ type RebalanceControl struct {
 type SetrateControl struct {
 	Status bool `json:"status"`
 }
+
+// TokenTarget is the parsed target of one token in a confirmed
+// TokenTargetQty, data format is token_total_reserve_rebalanceThreshold_transferThreshold
+type TokenTarget struct {
+	Total              float64
+	Reserve            float64
+	RebalanceThreshold float64
+	TransferThreshold  float64
+}
+
+// Targets parses the data of the target quantity into targets per token
+func (self TokenTargetQty) Targets() (map[string]TokenTarget, error) {
+	result := map[string]TokenTarget{}
+	if self.Data == "" {
+		return result, nil
+	}
+	for _, dataConfig := range strings.Split(self.Data, "|") {
+		dataParts := strings.Split(dataConfig, "_")
+		if len(dataParts) != 5 {
+			return result, fmt.Errorf("Target quantity config (%s) is malformed", dataConfig)
+		}
+		values := []float64{}
+		for _, part := range dataParts[1:] {
+			value, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return result, err
+			}
+			values = append(values, value)
+		}
+		result[dataParts[0]] = TokenTarget{
+			Total:              values[0],
+			Reserve:            values[1],
+			RebalanceThreshold: values[2],
+			TransferThreshold:  values[3],
+		}
+	}
+	return result, nil
+}
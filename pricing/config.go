@@ -0,0 +1,28 @@
+package pricing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+type TokenPricingConfig struct {
+	// Spread is the relative distance between ask and bid around the mid price
+	Spread float64 `json:"spread"`
+	// Threshold is the relative mid price change which triggers a new set rates
+	Threshold float64 `json:"threshold"`
+}
+
+type Config struct {
+	Tokens map[string]TokenPricingConfig `json:"tokens"`
+}
+
+func GetConfigFromFile(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	} else {
+		result := Config{}
+		err := json.Unmarshal(data, &result)
+		return result, err
+	}
+}
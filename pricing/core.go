@@ -0,0 +1,12 @@
+package pricing
+
+import (
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Core is the part of reserve core used to submit rates
+type Core interface {
+	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int) (common.ActivityID, error)
+}
@@ -0,0 +1,204 @@
+package pricing
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// TokenRate is the rate derived for one token, all prices are in ETH per token
+type TokenRate struct {
+	Token  common.Token
+	Mid    float64
+	Bid    float64
+	Ask    float64
+	Buy    *big.Int
+	Sell   *big.Int
+	AfpMid *big.Int
+}
+
+// Engine derives buy and sell rates from the aggregated exchange orderbooks
+// and submits them to the reserve when mid prices move beyond the configured
+// thresholds.
+type Engine struct {
+	storage       Storage
+	metricStorage MetricStorage
+	core          Core
+	config        Config
+	runner        Runner
+	mu            sync.Mutex
+	lastMids      map[string]float64
+}
+
+func NewEngine(
+	storage Storage,
+	metricStorage MetricStorage,
+	core Core,
+	config Config,
+	runner Runner) *Engine {
+	return &Engine{
+		storage:       storage,
+		metricStorage: metricStorage,
+		core:          core,
+		config:        config,
+		runner:        runner,
+		mu:            sync.Mutex{},
+		lastMids:      map[string]float64{},
+	}
+}
+
+func (self *Engine) Stop() error {
+	return self.runner.Stop()
+}
+
+func (self *Engine) Run() error {
+	log.Printf("Pricing engine runner is starting...")
+	self.runner.Start()
+	go self.RunPricing()
+	log.Printf("Pricing engine runner is running...")
+	return nil
+}
+
+func (self *Engine) RunPricing() {
+	for {
+		log.Printf("waiting for signal from runner pricing channel")
+		t := <-self.runner.GetPricingTicker()
+		log.Printf("got signal in pricing channel with timestamp %d", common.TimeToTimepoint(t))
+		if err := self.UpdateRates(common.TimeToTimepoint(t)); err != nil {
+			log.Printf("Updating rates failed: %s", err)
+		}
+	}
+}
+
+// ethToWei converts an amount of ETH to wei without going through
+// FloatToBigInt which loses precision for very small prices
+func ethToWei(amount float64) *big.Int {
+	result, _ := new(big.Float).Mul(
+		big.NewFloat(amount),
+		new(big.Float).SetInt(big.NewInt(1000000000000000000)),
+	).Int(nil)
+	return result
+}
+
+// bestBidAsk returns the highest bid and lowest ask across all exchanges
+func bestBidAsk(onePrice common.OnePrice) (float64, float64) {
+	var bid, ask float64
+	for _, exchangePrice := range onePrice {
+		if !exchangePrice.Valid {
+			continue
+		}
+		if len(exchangePrice.Bids) > 0 && exchangePrice.Bids[0].Rate > bid {
+			bid = exchangePrice.Bids[0].Rate
+		}
+		if len(exchangePrice.Asks) > 0 && (ask == 0 || exchangePrice.Asks[0].Rate < ask) {
+			ask = exchangePrice.Asks[0].Rate
+		}
+	}
+	return bid, ask
+}
+
+// DeriveRate derives the rate of a token from its orderbooks. Buy rate is
+// the amount of token per ETH the reserve gives, sell rate is the amount of
+// ETH per token the reserve gives, both are scaled by 10^18.
+func DeriveRate(token common.Token, onePrice common.OnePrice, config TokenPricingConfig) (TokenRate, error) {
+	bid, ask := bestBidAsk(onePrice)
+	if bid <= 0 || ask <= 0 || bid > ask {
+		return TokenRate{}, errors.New("Orderbooks don't have a valid best bid and ask")
+	}
+	if config.Spread <= 0 || config.Spread >= 2 {
+		return TokenRate{}, errors.New("Spread must be bigger than 0 and smaller than 2")
+	}
+	mid := (bid + ask) / 2
+	result := TokenRate{
+		Token: token,
+		Mid:   mid,
+		Bid:   mid * (1 - config.Spread/2),
+		Ask:   mid * (1 + config.Spread/2),
+	}
+	result.Buy = ethToWei(1 / result.Ask)
+	result.Sell = ethToWei(result.Bid)
+	result.AfpMid = ethToWei(mid)
+	return result, nil
+}
+
+func moved(last, current, threshold float64) bool {
+	if last == 0 {
+		return true
+	}
+	return math.Abs(current-last)/last > threshold
+}
+
+// UpdateRates derives rates of all configured tokens from the latest prices
+// and submits all of them when any token's mid price has moved beyond its
+// threshold since the last submission. It does nothing when set rate is on hold.
+func (self *Engine) UpdateRates(timepoint uint64) error {
+	control, err := self.metricStorage.GetSetrateControl()
+	if err != nil {
+		return err
+	}
+	if !control.Status {
+		log.Printf("Set rate is on hold, skip updating rates")
+		return nil
+	}
+	version, err := self.storage.CurrentPriceVersion(timepoint)
+	if err != nil {
+		return err
+	}
+	prices, err := self.storage.GetAllPrices(version)
+	if err != nil {
+		return err
+	}
+	tokenIDs := []string{}
+	for tokenID := range self.config.Tokens {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Strings(tokenIDs)
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	rates := []TokenRate{}
+	changed := false
+	for _, tokenID := range tokenIDs {
+		token, err := common.GetInternalToken(tokenID)
+		if err != nil || token.IsETH() {
+			log.Printf("Pricing: ignore token %s: it is not an internal token", tokenID)
+			continue
+		}
+		config := self.config.Tokens[tokenID]
+		rate, err := DeriveRate(token, prices.Data[common.NewTokenPairID(tokenID, "ETH")], config)
+		if err != nil {
+			log.Printf("Pricing: cannot derive rate for %s: %s", tokenID, err)
+			continue
+		}
+		if moved(self.lastMids[tokenID], rate.Mid, config.Threshold) {
+			changed = true
+		}
+		rates = append(rates, rate)
+	}
+	if !changed {
+		return nil
+	}
+	tokens := []common.Token{}
+	buys := []*big.Int{}
+	sells := []*big.Int{}
+	afpMids := []*big.Int{}
+	for _, rate := range rates {
+		tokens = append(tokens, rate.Token)
+		buys = append(buys, rate.Buy)
+		sells = append(sells, rate.Sell)
+		afpMids = append(afpMids, rate.AfpMid)
+	}
+	_, err = self.core.SetRates(tokens, buys, sells, big.NewInt(int64(prices.Block)), afpMids)
+	if err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		self.lastMids[rate.Token.ID] = rate.Mid
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package pricing
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+type testStorage struct {
+	prices common.AllPriceEntry
+}
+
+func (self *testStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return 1, nil
+}
+
+func (self *testStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	return self.prices, nil
+}
+
+type testMetricStorage struct {
+	status bool
+}
+
+func (self testMetricStorage) GetSetrateControl() (metric.SetrateControl, error) {
+	return metric.SetrateControl{Status: self.status}, nil
+}
+
+type testCore struct {
+	calls int
+}
+
+func (self *testCore) SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int, afpMid []*big.Int) (common.ActivityID, error) {
+	self.calls++
+	return common.ActivityID{}, nil
+}
+
+func testOnePrice(bid, ask float64) common.OnePrice {
+	return common.OnePrice{
+		"binance": {
+			Valid: true,
+			Bids:  []common.PriceEntry{{Quantity: 10, Rate: bid}},
+			Asks:  []common.PriceEntry{{Quantity: 10, Rate: ask}},
+		},
+		"huobi": {
+			Valid: true,
+			Bids:  []common.PriceEntry{{Quantity: 10, Rate: bid - 0.0001}},
+			Asks:  []common.PriceEntry{{Quantity: 10, Rate: ask + 0.0001}},
+		},
+	}
+}
+
+func TestDeriveRate(t *testing.T) {
+	token := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	rate, err := DeriveRate(token, testOnePrice(0.0019, 0.0021), TokenPricingConfig{Spread: 0.02})
+	if err != nil {
+		t.Fatalf("Expected to derive rate, got error: %s", err)
+	}
+	if math.Abs(rate.Mid-0.002) > 1e-12 {
+		t.Fatalf("Expected mid to be computed from best bid and ask, got %f", rate.Mid)
+	}
+	eth := new(big.Float).SetInt(big.NewInt(1000000000000000000))
+	ask := new(big.Float).Quo(eth, new(big.Float).SetInt(rate.Buy))
+	bid := new(big.Float).Quo(new(big.Float).SetInt(rate.Sell), eth)
+	mid := new(big.Float).Quo(new(big.Float).SetInt(rate.AfpMid), eth)
+	if ask.Cmp(bid) <= 0 || ask.Cmp(mid) <= 0 {
+		t.Fatalf("Expected ask (%s) to be bigger than bid (%s) and mid (%s)", ask.String(), bid.String(), mid.String())
+	}
+	if _, err = DeriveRate(token, common.OnePrice{}, TokenPricingConfig{Spread: 0.02}); err == nil {
+		t.Fatalf("Expected to return an error when there is no orderbook")
+	}
+}
+
+func TestUpdateRatesThreshold(t *testing.T) {
+	common.RegisterInternalActiveToken(common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18})
+	storage := &testStorage{
+		prices: common.AllPriceEntry{
+			Block: 100,
+			Data: map[common.TokenPairID]common.OnePrice{
+				"KNC-ETH": testOnePrice(0.0019, 0.0021),
+			},
+		},
+	}
+	core := &testCore{}
+	config := Config{Tokens: map[string]TokenPricingConfig{"KNC": {Spread: 0.02, Threshold: 0.01}}}
+	engine := NewEngine(storage, testMetricStorage{false}, core, config, NewTickerRunner(0))
+	if err := engine.UpdateRates(common.GetTimepoint()); err != nil || core.calls != 0 {
+		t.Fatalf("Expected not to set rates while set rate is on hold, got %d calls, error: %v", core.calls, err)
+	}
+	engine.metricStorage = testMetricStorage{true}
+	if err := engine.UpdateRates(common.GetTimepoint()); err != nil || core.calls != 1 {
+		t.Fatalf("Expected to set rates for the first time, got %d calls, error: %v", core.calls, err)
+	}
+	storage.prices.Data["KNC-ETH"] = testOnePrice(0.00191, 0.00211)
+	if err := engine.UpdateRates(common.GetTimepoint()); err != nil || core.calls != 1 {
+		t.Fatalf("Expected not to set rates when price moves within threshold, got %d calls, error: %v", core.calls, err)
+	}
+	storage.prices.Data["KNC-ETH"] = testOnePrice(0.0021, 0.0023)
+	if err := engine.UpdateRates(common.GetTimepoint()); err != nil || core.calls != 2 {
+		t.Fatalf("Expected to set rates when price moves beyond threshold, got %d calls, error: %v", core.calls, err)
+	}
+}
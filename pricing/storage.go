@@ -0,0 +1,15 @@
+package pricing
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+type Storage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(common.Version) (common.AllPriceEntry, error)
+}
+
+type MetricStorage interface {
+	GetSetrateControl() (metric.SetrateControl, error)
+}
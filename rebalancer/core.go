@@ -0,0 +1,33 @@
+package rebalancer
+
+import (
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Core is the part of reserve core used to move inventory around
+type Core interface {
+	Trade(
+		exchange common.Exchange,
+		tradeType string,
+		base common.Token,
+		quote common.Token,
+		rate float64,
+		amount float64,
+		timestamp uint64) (id common.ActivityID, done float64, remaining float64, finished bool, err error)
+
+	Deposit(
+		exchange common.Exchange,
+		token common.Token,
+		amount *big.Int,
+		timestamp uint64,
+		cosigned bool) (common.ActivityID, error)
+
+	Withdraw(
+		exchange common.Exchange,
+		token common.Token,
+		amount *big.Int,
+		timestamp uint64,
+		cosigned bool) (common.ActivityID, error)
+}
@@ -0,0 +1,245 @@
+package rebalancer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+const (
+	DEPOSIT  string = "deposit"
+	WITHDRAW string = "withdraw"
+	BUY      string = "buy"
+	SELL     string = "sell"
+)
+
+// Decision is one action the rebalancer wants to take to move a token
+// back toward its target quantity
+type Decision struct {
+	Action   string
+	Token    common.Token
+	Exchange common.Exchange
+	Amount   float64
+	// Rate is only used for buy and sell decisions
+	Rate    float64
+	Current float64
+	Target  float64
+	Reason  string
+}
+
+// hasPendingActivity returns true if there is any pending deposit, withdraw
+// or trade involving the token. Balances of such a token are not reliable
+// so we don't rebalance it until those activities are done.
+func hasPendingActivity(tokenID string, pendings []common.ActivityRecord) bool {
+	for _, activity := range pendings {
+		switch activity.Action {
+		case "deposit", "withdraw":
+			if token, ok := activity.Params["token"].(string); ok && token == tokenID {
+				return true
+			}
+		case "trade":
+			base, _ := activity.Params["base"].(string)
+			quote, _ := activity.Params["quote"].(string)
+			if base == tokenID || quote == tokenID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func exchangeBalance(auth common.AuthDataSnapshot, exchange common.Exchange, tokenID string) (float64, bool) {
+	balances, found := auth.ExchangeBalances[exchange.ID()]
+	if !found || !balances.Valid {
+		return 0, false
+	}
+	return balances.AvailableBalance[tokenID], true
+}
+
+// bestPrice returns best bid (for sell) or best ask (for buy) of the token
+// against ETH on the exchange
+func bestPrice(prices common.AllPriceEntry, exchange common.Exchange, tokenID string, tradeType string) (float64, bool) {
+	onePrice, found := prices.Data[common.NewTokenPairID(tokenID, "ETH")]
+	if !found {
+		return 0, false
+	}
+	exchangePrice, found := onePrice[exchange.ID()]
+	if !found || !exchangePrice.Valid {
+		return 0, false
+	}
+	if tradeType == SELL && len(exchangePrice.Bids) > 0 {
+		return exchangePrice.Bids[0].Rate, true
+	}
+	if tradeType == BUY && len(exchangePrice.Asks) > 0 {
+		return exchangePrice.Asks[0].Rate, true
+	}
+	return 0, false
+}
+
+// tradeAmount floors amount to the amount precision of the token-ETH pair
+// on the exchange, it is 0 if the floored amount is below the amount
+// minimum or its value is below the min notional of the exchange
+func tradeAmount(exchange common.Exchange, tokenID string, amount, rate float64) float64 {
+	info, err := exchange.GetExchangeInfo(common.NewTokenPairID(tokenID, "ETH"))
+	if err != nil {
+		return 0
+	}
+	// exchanges without precision info have both precisions zero
+	if info.Precision.Amount != 0 || info.Precision.Price != 0 {
+		scale := math.Pow10(info.Precision.Amount)
+		amount = math.Floor(amount*scale*(1+1e-12)) / scale
+	}
+	if amount < info.AmountLimit.Min || (info.MinNotional != 0 && amount*rate < info.MinNotional) {
+		return 0
+	}
+	return amount
+}
+
+func planTrade(
+	token common.Token, target metric.TokenTarget, total float64,
+	exchanges []common.Exchange,
+	auth common.AuthDataSnapshot,
+	prices common.AllPriceEntry) (Decision, bool) {
+
+	diff := total - target.Total
+	if token.IsETH() || target.Total <= 0 || math.Abs(diff) <= target.RebalanceThreshold*target.Total {
+		return Decision{}, false
+	}
+	result := Decision{Token: token, Current: total, Target: target.Total}
+	for _, exchange := range exchanges {
+		if _, supported := exchange.Address(token); !supported {
+			continue
+		}
+		if diff > 0 {
+			available, ok := exchangeBalance(auth, exchange, token.ID)
+			rate, hasPrice := bestPrice(prices, exchange, token.ID, SELL)
+			if !ok || !hasPrice || rate <= 0 {
+				continue
+			}
+			amount := tradeAmount(exchange, token.ID, math.Min(diff, available), rate)
+			if amount > result.Amount {
+				result.Action = SELL
+				result.Exchange = exchange
+				result.Amount = amount
+				result.Rate = rate
+			}
+		} else {
+			available, ok := exchangeBalance(auth, exchange, "ETH")
+			rate, hasPrice := bestPrice(prices, exchange, token.ID, BUY)
+			if !ok || !hasPrice || rate <= 0 {
+				continue
+			}
+			amount := tradeAmount(exchange, token.ID, math.Min(-diff, available/rate), rate)
+			if amount > result.Amount {
+				result.Action = BUY
+				result.Exchange = exchange
+				result.Amount = amount
+				result.Rate = rate
+			}
+		}
+	}
+	if result.Amount <= 0 {
+		return Decision{}, false
+	}
+	result.Reason = fmt.Sprintf(
+		"Total %s quantity (%f) deviates from target (%f) more than rebalance threshold (%f)",
+		token.ID, total, target.Total, target.RebalanceThreshold)
+	return result, true
+}
+
+func planTransfer(
+	token common.Token, target metric.TokenTarget, reserveBalance float64,
+	exchanges []common.Exchange,
+	auth common.AuthDataSnapshot) (Decision, bool) {
+
+	diff := reserveBalance - target.Reserve
+	if target.Reserve <= 0 || math.Abs(diff) <= target.TransferThreshold*target.Reserve {
+		return Decision{}, false
+	}
+	result := Decision{Token: token, Current: reserveBalance, Target: target.Reserve}
+	// deposit to the exchange holding the least of the token,
+	// withdraw from the exchange holding the most of it
+	lowest := math.MaxFloat64
+	for _, exchange := range exchanges {
+		if _, supported := exchange.Address(token); !supported {
+			continue
+		}
+		available, ok := exchangeBalance(auth, exchange, token.ID)
+		if !ok {
+			continue
+		}
+		if diff > 0 {
+			if available < lowest {
+				lowest = available
+				result.Action = DEPOSIT
+				result.Exchange = exchange
+				result.Amount = diff
+			}
+		} else {
+			amount := math.Min(-diff, available)
+			if amount > result.Amount {
+				result.Action = WITHDRAW
+				result.Exchange = exchange
+				result.Amount = amount
+			}
+		}
+	}
+	if result.Amount <= 0 {
+		return Decision{}, false
+	}
+	result.Reason = fmt.Sprintf(
+		"Reserve %s quantity (%f) deviates from target (%f) more than transfer threshold (%f)",
+		token.ID, reserveBalance, target.Reserve, target.TransferThreshold)
+	return result, true
+}
+
+// Plan decides at most one action per token to move reserve and exchange
+// inventory toward the confirmed targets. Trading is considered first as it
+// changes the total quantity, transferring between the reserve and exchanges
+// is considered after that.
+func Plan(
+	targets map[string]metric.TokenTarget,
+	tokens map[string]common.Token,
+	exchanges []common.Exchange,
+	auth common.AuthDataSnapshot,
+	prices common.AllPriceEntry) []Decision {
+
+	result := []Decision{}
+	tokenIDs := []string{}
+	for tokenID := range targets {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Strings(tokenIDs)
+	for _, tokenID := range tokenIDs {
+		token, found := tokens[tokenID]
+		if !found {
+			continue
+		}
+		if hasPendingActivity(tokenID, auth.PendingActivities) {
+			continue
+		}
+		balance, found := auth.ReserveBalances[tokenID]
+		if !found || !balance.Valid {
+			continue
+		}
+		reserveBalance := balance.Balance.ToFloat(token.Decimal)
+		total := reserveBalance
+		for _, exchange := range exchanges {
+			if _, supported := exchange.Address(token); !supported {
+				continue
+			}
+			available, _ := exchangeBalance(auth, exchange, tokenID)
+			total += available
+		}
+		target := targets[tokenID]
+		if decision, ok := planTrade(token, target, total, exchanges, auth, prices); ok {
+			result = append(result, decision)
+		} else if decision, ok := planTransfer(token, target, reserveBalance, exchanges, auth); ok {
+			result = append(result, decision)
+		}
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package rebalancer
+
+import (
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/core"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+func testAuthData(reserveKNC, exchangeKNC, exchangeETH float64) common.AuthDataSnapshot {
+	balance := common.RawBalance(*common.FloatToBigInt(reserveKNC, 18))
+	return common.AuthDataSnapshot{
+		Valid: true,
+		ExchangeBalances: map[common.ExchangeID]common.EBalanceEntry{
+			"binance": {
+				Valid: true,
+				AvailableBalance: map[string]float64{
+					"KNC": exchangeKNC,
+					"ETH": exchangeETH,
+				},
+			},
+		},
+		ReserveBalances: map[string]common.BalanceEntry{
+			"KNC": {Valid: true, Balance: balance},
+		},
+		PendingActivities: []common.ActivityRecord{},
+	}
+}
+
+func testPrices() common.AllPriceEntry {
+	return common.AllPriceEntry{
+		Data: map[common.TokenPairID]common.OnePrice{
+			"KNC-ETH": {
+				"binance": {
+					Valid: true,
+					Bids:  []common.PriceEntry{{Quantity: 100, Rate: 0.001}},
+					Asks:  []common.PriceEntry{{Quantity: 100, Rate: 0.002}},
+				},
+			},
+		},
+	}
+}
+
+func testPlan(t *testing.T, auth common.AuthDataSnapshot) []Decision {
+	targetQty := metric.TokenTargetQty{Data: "KNC_1000_500_0.1_0.2"}
+	targets, err := targetQty.Targets()
+	if err != nil {
+		t.Fatalf("Expected to parse target quantity, got error: %s", err)
+	}
+	tokens := map[string]common.Token{
+		"KNC": {ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18},
+	}
+	return Plan(targets, tokens, []common.Exchange{common.TestExchange{}}, auth, testPrices())
+}
+
+func TestPlanInsideThresholds(t *testing.T) {
+	decisions := testPlan(t, testAuthData(500, 550, 1))
+	if len(decisions) != 0 {
+		t.Fatalf("Expected no decision, got %+v", decisions)
+	}
+}
+
+func TestPlanSellSurplus(t *testing.T) {
+	decisions := testPlan(t, testAuthData(500, 800, 1))
+	if len(decisions) != 1 || decisions[0].Action != SELL {
+		t.Fatalf("Expected one sell decision, got %+v", decisions)
+	}
+	if decisions[0].Amount != 300 || decisions[0].Rate != 0.001 {
+		t.Fatalf("Expected to sell 300 KNC at best bid, got %+v", decisions[0])
+	}
+}
+
+func TestPlanBuyLimitedByETH(t *testing.T) {
+	decisions := testPlan(t, testAuthData(500, 100, 0.2))
+	if len(decisions) != 1 || decisions[0].Action != BUY {
+		t.Fatalf("Expected one buy decision, got %+v", decisions)
+	}
+	if decisions[0].Amount != 100 {
+		t.Fatalf("Expected buy amount to be limited by ETH balance, got %+v", decisions[0])
+	}
+}
+
+func TestPlanTransfer(t *testing.T) {
+	decisions := testPlan(t, testAuthData(300, 700, 1))
+	if len(decisions) != 1 || decisions[0].Action != WITHDRAW || decisions[0].Amount != 200 {
+		t.Fatalf("Expected to withdraw 200 KNC, got %+v", decisions)
+	}
+	decisions = testPlan(t, testAuthData(700, 300, 1))
+	if len(decisions) != 1 || decisions[0].Action != DEPOSIT || decisions[0].Amount != 200 {
+		t.Fatalf("Expected to deposit 200 KNC, got %+v", decisions)
+	}
+}
+
+func TestPlanSkipPendingToken(t *testing.T) {
+	auth := testAuthData(300, 700, 1)
+	auth.PendingActivities = []common.ActivityRecord{
+		{
+			Action: "deposit",
+			Params: map[string]interface{}{"token": "KNC", "amount": "1"},
+		},
+	}
+	decisions := testPlan(t, auth)
+	if len(decisions) != 0 {
+		t.Fatalf("Expected no decision while there is pending activity, got %+v", decisions)
+	}
+}
+
+// testPrecisionExchange trades KNC-ETH with 2 amount decimals, 6 price
+// decimals, 1 KNC min amount and 0.01 ETH min notional
+type testPrecisionExchange struct {
+	common.TestExchange
+}
+
+func (self testPrecisionExchange) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	return common.ExchangePrecisionLimit{
+		Precision:   common.TokenPairPrecision{Amount: 2, Price: 6},
+		AmountLimit: common.TokenPairAmountLimit{Min: 1, Max: 100000},
+		MinNotional: 0.01,
+	}, nil
+}
+
+type testRiskStorage struct {
+	auth common.AuthDataSnapshot
+}
+
+func (self testRiskStorage) GetAllRecords(fromTime, toTime uint64) ([]common.ActivityRecord, error) {
+	return []common.ActivityRecord{}, nil
+}
+
+func (self testRiskStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(1), nil
+}
+
+func (self testRiskStorage) GetOnePrice(pair common.TokenPairID, version common.Version) (common.OnePrice, error) {
+	return testPrices().Data[pair], nil
+}
+
+func (self testRiskStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(1), nil
+}
+
+func (self testRiskStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	return self.auth, nil
+}
+
+func TestPlanTradePassesRiskCheck(t *testing.T) {
+	targetQty := metric.TokenTargetQty{Data: "KNC_1000_500_0.1_0.2"}
+	targets, err := targetQty.Targets()
+	if err != nil {
+		t.Fatalf("Expected to parse target quantity, got error: %s", err)
+	}
+	knc := common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18}
+	eth := common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18}
+	exchanges := []common.Exchange{testPrecisionExchange{}}
+	for _, auth := range []common.AuthDataSnapshot{
+		testAuthData(500, 800.123456, 1),
+		testAuthData(500, 100, 0.123456789),
+	} {
+		decisions := Plan(targets, map[string]common.Token{"KNC": knc}, exchanges, auth, testPrices())
+		if len(decisions) != 1 || (decisions[0].Action != SELL && decisions[0].Action != BUY) {
+			t.Fatalf("Expected one trade decision, got %+v", decisions)
+		}
+		decision := decisions[0]
+		checker := core.NewRiskChecker(testRiskStorage{auth}, core.RiskConfig{})
+		if rejections := checker.CheckTrade(decision.Exchange, decision.Action, knc, eth, decision.Rate, decision.Amount, 0); len(rejections) != 0 {
+			t.Fatalf("Expected %s of %f KNC to pass risk checks, got %+v", decision.Action, decision.Amount, rejections)
+		}
+	}
+
+	// 0.0015 ETH only buys 0.75 KNC, below the 1 KNC min amount
+	if decisions := Plan(targets, map[string]common.Token{"KNC": knc}, exchanges, testAuthData(500, 100, 0.0015), testPrices()); len(decisions) != 0 {
+		t.Fatalf("Expected no trade under the exchange minimums, got %+v", decisions)
+	}
+}
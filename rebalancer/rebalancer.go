@@ -0,0 +1,176 @@
+package rebalancer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Rebalancer reads the confirmed target quantities and the latest auth data
+// and moves inventory between the reserve and exchanges toward the targets.
+type Rebalancer struct {
+	storage         Storage
+	metricStorage   MetricStorage
+	activityStorage ActivityStorage
+	core            Core
+	exchanges       []common.Exchange
+	runner          Runner
+}
+
+func NewRebalancer(
+	storage Storage,
+	metricStorage MetricStorage,
+	activityStorage ActivityStorage,
+	core Core,
+	exchanges []common.Exchange,
+	runner Runner) *Rebalancer {
+	return &Rebalancer{
+		storage:         storage,
+		metricStorage:   metricStorage,
+		activityStorage: activityStorage,
+		core:            core,
+		exchanges:       exchanges,
+		runner:          runner,
+	}
+}
+
+func (self *Rebalancer) Stop() error {
+	return self.runner.Stop()
+}
+
+func (self *Rebalancer) Run() error {
+	log.Printf("Rebalancer runner is starting...")
+	self.runner.Start()
+	go self.RunRebalancer()
+	log.Printf("Rebalancer runner is running...")
+	return nil
+}
+
+func (self *Rebalancer) RunRebalancer() {
+	for {
+		log.Printf("waiting for signal from runner rebalance channel")
+		t := <-self.runner.GetRebalanceTicker()
+		log.Printf("got signal in rebalance channel with timestamp %d", common.TimeToTimepoint(t))
+		if err := self.Rebalance(common.TimeToTimepoint(t)); err != nil {
+			log.Printf("Rebalancing failed: %s", err)
+		}
+	}
+}
+
+// Rebalance plans and executes one round of rebalancing. It does nothing
+// when rebalance is on hold or there is no confirmed target quantity.
+func (self *Rebalancer) Rebalance(timepoint uint64) error {
+	control, err := self.metricStorage.GetRebalanceControl()
+	if err != nil {
+		return err
+	}
+	if !control.Status {
+		log.Printf("Rebalance is on hold, skip rebalancing")
+		return nil
+	}
+	targetQty, err := self.metricStorage.GetTokenTargetQty()
+	if err != nil {
+		return err
+	}
+	if targetQty.Status != "confirmed" {
+		return errors.New("There is no confirmed target quantity")
+	}
+	targets, err := targetQty.Targets()
+	if err != nil {
+		return err
+	}
+	authVersion, err := self.storage.CurrentAuthDataVersion(timepoint)
+	if err != nil {
+		return err
+	}
+	auth, err := self.storage.GetAuthData(authVersion)
+	if err != nil {
+		return err
+	}
+	if !auth.Valid {
+		return fmt.Errorf("Latest auth data is invalid: %s", auth.Error)
+	}
+	priceVersion, err := self.storage.CurrentPriceVersion(timepoint)
+	if err != nil {
+		return err
+	}
+	prices, err := self.storage.GetAllPrices(priceVersion)
+	if err != nil {
+		return err
+	}
+	tokens := map[string]common.Token{}
+	for tokenID := range targets {
+		token, err := common.GetInternalToken(tokenID)
+		if err != nil {
+			log.Printf("Rebalancer: ignore target of %s: %s", tokenID, err)
+			continue
+		}
+		tokens[tokenID] = token
+	}
+	for _, decision := range Plan(targets, tokens, self.exchanges, auth, prices) {
+		self.execute(decision, timepoint)
+	}
+	return nil
+}
+
+func (self *Rebalancer) execute(decision Decision, timepoint uint64) {
+	var id common.ActivityID
+	var err error
+	switch decision.Action {
+	case DEPOSIT:
+		id, err = self.core.Deposit(
+			decision.Exchange, decision.Token,
+			common.FloatToBigInt(decision.Amount, decision.Token.Decimal),
+			timepoint, false)
+	case WITHDRAW:
+		id, err = self.core.Withdraw(
+			decision.Exchange, decision.Token,
+			common.FloatToBigInt(decision.Amount, decision.Token.Decimal),
+			timepoint, false)
+	case BUY, SELL:
+		var eth common.Token
+		eth, err = common.GetInternalToken("ETH")
+		if err == nil {
+			id, _, _, _, err = self.core.Trade(
+				decision.Exchange, decision.Action, decision.Token, eth,
+				decision.Rate, decision.Amount, timepoint)
+		}
+	default:
+		err = fmt.Errorf("Rebalance action %s is not supported", decision.Action)
+	}
+	status := "done"
+	if err != nil {
+		status = "failed"
+	}
+	amount := strconv.FormatFloat(decision.Amount, 'f', -1, 64)
+	self.activityStorage.Record(
+		"rebalance",
+		common.NewActivityID(uint64(time.Now().UnixNano()), decision.Token.ID+"|"+decision.Action),
+		string(decision.Exchange.ID()),
+		map[string]interface{}{
+			"action":    decision.Action,
+			"exchange":  decision.Exchange,
+			"token":     decision.Token,
+			"amount":    amount,
+			"rate":      decision.Rate,
+			"current":   decision.Current,
+			"target":    decision.Target,
+			"reason":    decision.Reason,
+			"timepoint": timepoint,
+		}, map[string]interface{}{
+			"id":    id,
+			"error": common.ErrorToString(err),
+		},
+		status,
+		"",
+		timepoint,
+	)
+	log.Printf(
+		"Rebalancer ----------> %s %s %s on %s: %s ==> Result: id: %s, error: %v",
+		decision.Action, amount, decision.Token.ID, decision.Exchange.ID(), decision.Reason, id, err,
+	)
+}
@@ -0,0 +1,47 @@
+package rebalancer
+
+import (
+	"time"
+)
+
+// Runner to trigger rebalancer
+type Runner interface {
+	GetRebalanceTicker() <-chan time.Time
+	// Start must be non-blocking and must only return after runner
+	// gets to ready state before GetRebalanceTicker() gets called
+	Start() error
+	// Stop should only be invoked when the runner is already running
+	Stop() error
+}
+
+type TickerRunner struct {
+	duration time.Duration
+	clock    *time.Ticker
+	signal   chan bool
+}
+
+func (self *TickerRunner) GetRebalanceTicker() <-chan time.Time {
+	if self.clock == nil {
+		<-self.signal
+	}
+	return self.clock.C
+}
+
+func (self *TickerRunner) Start() error {
+	self.clock = time.NewTicker(self.duration)
+	self.signal <- true
+	return nil
+}
+
+func (self *TickerRunner) Stop() error {
+	self.clock.Stop()
+	return nil
+}
+
+func NewTickerRunner(duration time.Duration) *TickerRunner {
+	return &TickerRunner{
+		duration,
+		nil,
+		make(chan bool, 1),
+	}
+}
@@ -0,0 +1,31 @@
+package rebalancer
+
+import (
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+type Storage interface {
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(common.Version) (common.AllPriceEntry, error)
+
+	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
+	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
+}
+
+type MetricStorage interface {
+	GetTokenTargetQty() (metric.TokenTargetQty, error)
+	GetRebalanceControl() (metric.RebalanceControl, error)
+}
+
+type ActivityStorage interface {
+	Record(
+		action string,
+		id common.ActivityID,
+		destination string,
+		params map[string]interface{},
+		result map[string]interface{},
+		estatus string,
+		mstatus string,
+		timepoint uint64) error
+}
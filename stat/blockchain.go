@@ -7,6 +7,7 @@ import (
 
 type Blockchain interface {
 	CurrentBlock() (uint64, error)
+	GetBlockHash(block uint64) (ethereum.Hash, error)
 	GetLogs(fromBlock uint64, toBlock uint64) ([]common.KNLog, error)
 	GetReserveRates(atBlock, currentBlock uint64, reserveAddress ethereum.Address, tokens []common.Token) (common.ReserveRates, error)
 }
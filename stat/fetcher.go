@@ -31,6 +31,7 @@ const (
 	BURNFEE_AGGREGATION        string = "burn_fee_aggregation"
 	USER_INFO_AGGREGATION      string = "user_info_aggregation"
 	RESERVE_VOLUME_AGGREGATION string = "reserve_volume_aggregation"
+	RESERVE_STAT_AGGREGATION   string = "reserve_stat_aggregation"
 )
 
 type Fetcher struct {
@@ -45,6 +46,8 @@ type Fetcher struct {
 	deployBlock            uint64
 	reserveAddress         ethereum.Address
 	thirdPartyReserves     []ethereum.Address
+	// processMu serializes log processing and reorg rollbacks
+	processMu sync.Mutex
 }
 
 func NewFetcher(
@@ -93,68 +96,76 @@ func (self *Fetcher) Run() error {
 func (self *Fetcher) RunCatLogProcessor() {
 	for {
 		t := <-self.runner.GetCatLogProcessorTicker()
-		// get trade log from db
-		fromTime, err := self.userStorage.GetLastProcessedCatLogTimepoint()
+		self.ProcessCatLogs(t)
+	}
+}
+
+// ProcessCatLogs updates address categories from cat logs fetched after the
+// last processed one
+func (self *Fetcher) ProcessCatLogs(t time.Time) {
+	self.processMu.Lock()
+	defer self.processMu.Unlock()
+	// get trade log from db
+	fromTime, err := self.userStorage.GetLastProcessedCatLogTimepoint()
+	if err != nil {
+		log.Printf("get last processor state from db failed: %v", err)
+		return
+	}
+	fromTime += 1
+	if fromTime == 1 {
+		// there is no cat log being processed before
+		// load the first log we have and set the fromTime to it's timestamp
+		l, err := self.logStorage.GetFirstCatLog()
 		if err != nil {
-			log.Printf("get last processor state from db failed: %v", err)
-			continue
+			log.Printf("can't get first cat log: err(%s)", err)
+			return
+		} else {
+			fromTime = l.Timestamp - 1
 		}
-		fromTime += 1
-		if fromTime == 1 {
-			// there is no cat log being processed before
-			// load the first log we have and set the fromTime to it's timestamp
-			l, err := self.logStorage.GetFirstCatLog()
+	}
+	toTime := common.TimeToTimepoint(t) * 1000000
+	maxRange := self.logStorage.MaxRange()
+	if toTime-fromTime > maxRange {
+		toTime = fromTime + maxRange
+	}
+	catLogs, err := self.logStorage.GetCatLogs(fromTime, toTime)
+	if err != nil {
+		log.Printf("get cat log from db failed: %v", err)
+		return
+	}
+	log.Printf("PROCESS %d cat logs from %d to %d", len(catLogs), fromTime, toTime)
+	if len(catLogs) > 0 {
+		var last uint64
+		for _, l := range catLogs {
+			err := self.userStorage.UpdateAddressCategory(
+				l.Address,
+				l.Category,
+			)
 			if err != nil {
-				log.Printf("can't get first cat log: err(%s)", err)
-				continue
+				log.Printf("updating address and category failed: err(%s)", err)
 			} else {
-				fromTime = l.Timestamp - 1
+				if l.Timestamp > last {
+					last = l.Timestamp
+				}
 			}
 		}
-		toTime := common.TimeToTimepoint(t) * 1000000
-		maxRange := self.logStorage.MaxRange()
-		if toTime-fromTime > maxRange {
-			toTime = fromTime + maxRange
-		}
-		catLogs, err := self.logStorage.GetCatLogs(fromTime, toTime)
+		self.userStorage.SetLastProcessedCatLogTimepoint(last)
+	} else {
+		l, err := self.logStorage.GetLastCatLog()
 		if err != nil {
-			log.Printf("get cat log from db failed: %v", err)
-			continue
-		}
-		log.Printf("PROCESS %d cat logs from %d to %d", len(catLogs), fromTime, toTime)
-		if len(catLogs) > 0 {
-			var last uint64
-			for _, l := range catLogs {
-				err := self.userStorage.UpdateAddressCategory(
-					l.Address,
-					l.Category,
-				)
-				if err != nil {
-					log.Printf("updating address and category failed: err(%s)", err)
-				} else {
-					if l.Timestamp > last {
-						last = l.Timestamp
-					}
-				}
-			}
-			self.userStorage.SetLastProcessedCatLogTimepoint(last)
+			log.Printf("LogFetcher - can't get last cat log: err(%s)", err)
+			return
 		} else {
-			l, err := self.logStorage.GetLastCatLog()
-			if err != nil {
-				log.Printf("LogFetcher - can't get last cat log: err(%s)", err)
-				continue
-			} else {
-				// log.Printf("LogFetcher - got last cat log: %+v", l)
-				if toTime < l.Timestamp {
-					// if we are querying on past logs, store toTime as the last
-					// processed trade log timepoint
-					self.userStorage.SetLastProcessedCatLogTimepoint(toTime)
-				}
+			// log.Printf("LogFetcher - got last cat log: %+v", l)
+			if toTime < l.Timestamp {
+				// if we are querying on past logs, store toTime as the last
+				// processed trade log timepoint
+				self.userStorage.SetLastProcessedCatLogTimepoint(toTime)
 			}
 		}
-
-		log.Println("processed cat logs")
 	}
+
+	log.Println("processed cat logs")
 }
 
 func (self *Fetcher) GetTradeLogTimeRange(fromTime uint64, t time.Time) (uint64, uint64) {
@@ -390,6 +401,43 @@ func (self *Fetcher) RunVolumeStatAggregation(t time.Time) {
 	return
 }
 
+func (self *Fetcher) RunReserveStatAggregation(t time.Time) {
+	// get trade log from db
+	fromTime, err := self.statStorage.GetLastProcessedTradeLogTimepoint(RESERVE_STAT_AGGREGATION)
+	if err != nil {
+		log.Printf("get trade log processor state from db failed: %v", err)
+		return
+	}
+	fromTime, toTime := self.GetTradeLogTimeRange(fromTime, t)
+	tradeLogs, err := self.logStorage.GetTradeLogs(fromTime, toTime)
+	if err != nil {
+		log.Printf("get trade log from db failed: %v", err)
+		return
+	}
+	if len(tradeLogs) > 0 {
+		var last uint64
+
+		reserveStats := map[string]common.ReserveTradeStatsFreq{}
+		for _, trade := range tradeLogs {
+			self.aggregateReserveStats(trade, reserveStats)
+			if trade.Timestamp > last {
+				last = trade.Timestamp
+			}
+		}
+		self.statStorage.SetReserveStats(reserveStats, last)
+	} else {
+		l, err := self.logStorage.GetLastTradeLog()
+		if err != nil {
+			log.Printf("can't get last trade log: err(%s)", err)
+			return
+		} else {
+			if toTime < l.Timestamp {
+				self.statStorage.SetLastProcessedTradeLogTimepoint(RESERVE_STAT_AGGREGATION, toTime)
+			}
+		}
+	}
+}
+
 // func (self *Fetcher) RunUserAggregation(t time.Time) {
 // 	// get trade log from db
 // 	fromTime, err := self.statStorage.GetLastProcessedTradeLogTimepoint(USER_AGGREGATION)
@@ -474,6 +522,7 @@ func runAggregationInParallel(wg *sync.WaitGroup, t time.Time, f func(t time.Tim
 func (self *Fetcher) RunTradeLogProcessor() {
 	for {
 		t := <-self.runner.GetTradeLogProcessorTicker()
+		self.processMu.Lock()
 		// self.RunUserAggregation(t)
 		wg := sync.WaitGroup{}
 		wg.Add(1)
@@ -488,7 +537,10 @@ func (self *Fetcher) RunTradeLogProcessor() {
 		go runAggregationInParallel(&wg, t, self.RunCountryStatAggregation)
 		wg.Add(1)
 		go runAggregationInParallel(&wg, t, self.RunUserInfoAggregation)
+		wg.Add(1)
+		go runAggregationInParallel(&wg, t, self.RunReserveStatAggregation)
 		wg.Wait()
+		self.processMu.Unlock()
 	}
 }
 
@@ -534,7 +586,7 @@ func (self *Fetcher) ReserveSupportedTokens(reserve ethereum.Address) []common.T
 
 func (self *Fetcher) FetchReserveRates(timepoint uint64) {
 	log.Printf("Fetching reserve and sanity rate from blockchain")
-	supportedReserves := append(self.thirdPartyReserves, self.reserveAddress)
+	supportedReserves := self.SupportedReserves()
 	data := sync.Map{}
 	wg := sync.WaitGroup{}
 	// get current block to use to fetch all reserve rates.
@@ -568,6 +620,19 @@ func (self *Fetcher) RunLogFetcher() {
 			lastBlock = self.deployBlock
 		}
 		if err == nil {
+			forkBlock, reorged, err := self.CheckReorg()
+			if err != nil {
+				log.Printf("LogFetcher - failed to check chain reorganisation, err: %+v", err)
+				continue
+			}
+			if reorged {
+				log.Printf("LogFetcher - chain is reorganised after block %d, rolling back logs", forkBlock)
+				if err = self.RollbackLogs(forkBlock); err != nil {
+					log.Printf("LogFetcher - failed to roll back logs, err: %+v", err)
+					continue
+				}
+				lastBlock = forkBlock
+			}
 			toBlock := lastBlock + 1 + 1440 // 1440 is considered as 6 hours
 			if toBlock > self.currentBlock-REORG_BLOCK_SAFE {
 				toBlock = self.currentBlock - REORG_BLOCK_SAFE
@@ -575,6 +640,13 @@ func (self *Fetcher) RunLogFetcher() {
 			if lastBlock+1 > toBlock {
 				continue
 			}
+			// the hash is taken before the logs so a reorg in between is
+			// detected on the next fetch
+			toBlockHash, err := self.blockchain.GetBlockHash(toBlock)
+			if err != nil {
+				log.Printf("LogFetcher - failed to get hash of block %d, err: %+v", toBlock, err)
+				continue
+			}
 			nextBlock, err := self.FetchLogs(lastBlock+1, toBlock, timepoint)
 			if err != nil {
 				// in case there is error, we roll back and try it again.
@@ -591,6 +663,9 @@ func (self *Fetcher) RunLogFetcher() {
 				}
 				log.Printf("LogFetcher - update log block: %d", nextBlock)
 				self.logStorage.UpdateLogBlock(nextBlock, timepoint)
+				if err = self.logStorage.StoreBlockHash(toBlock, toBlockHash); err != nil {
+					log.Printf("LogFetcher - failed to store hash of block %d, err: %+v", toBlock, err)
+				}
 			}
 		} else {
 			log.Printf("LogFetcher - failed to get last fetched log block, err: %+v", err)
@@ -652,7 +727,16 @@ func (self *Fetcher) FetchLogs(fromBlock uint64, toBlock uint64, timepoint uint6
 	} else {
 		if len(logs) > 0 {
 			var maxBlock uint64 = 0
+			hashed := map[uint64]bool{}
 			for _, il := range logs {
+				// hashes of blocks with logs are recorded before the logs so
+				// logs of orphaned blocks are found by CheckReorg
+				if !hashed[il.BlockNo()] {
+					if err = self.storeLogBlockHash(il); err != nil {
+						log.Printf("LogFetcher - storing hash of block %d failed, err: %+v", il.BlockNo(), err)
+					}
+					hashed[il.BlockNo()] = true
+				}
 				if il.Type() == "TradeLog" {
 					l := il.(common.TradeLog)
 					txHash := il.TxHash()
@@ -683,6 +767,20 @@ func (self *Fetcher) FetchLogs(fromBlock uint64, toBlock uint64, timepoint uint6
 	}
 }
 
+func (self *Fetcher) storeLogBlockHash(il common.KNLog) error {
+	var hash ethereum.Hash
+	switch l := il.(type) {
+	case common.TradeLog:
+		hash = l.BlockHash
+	case common.SetCatLog:
+		hash = l.BlockHash
+	}
+	if hash == (ethereum.Hash{}) {
+		return nil
+	}
+	return self.logStorage.StoreBlockHash(il.BlockNo(), hash)
+}
+
 func checkWalletAddress(walletAddr ethereum.Address) bool {
 	cap := big.NewInt(0)
 	cap.Exp(big.NewInt(2), big.NewInt(128), big.NewInt(0))
@@ -852,6 +950,54 @@ func (self *Fetcher) aggregateBurnFeeStats(trade common.TradeLog, burnFeeStats m
 	return nil
 }
 
+// SupportedReserves returns our reserve and the configured third party
+// reserves
+func (self *Fetcher) SupportedReserves() []ethereum.Address {
+	result := []ethereum.Address{self.reserveAddress}
+	return append(result, self.thirdPartyReserves...)
+}
+
+// aggregateReserveStats aggregates trades of the supported reserves, trades
+// of other reserves are ignored
+func (self *Fetcher) aggregateReserveStats(trade common.TradeLog, reserveStats map[string]common.ReserveTradeStatsFreq) {
+	supported := false
+	for _, reserve := range self.SupportedReserves() {
+		if reserve == trade.ReserveAddress {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return
+	}
+	reserveAddr := common.AddrToString(trade.ReserveAddress)
+	_, _, ethAmount, burnFee := self.getTradeInfo(trade)
+	var walletFee float64
+	if trade.WalletFee != nil {
+		walletFee = common.BigToFloat(trade.WalletFee, common.ETHToken().Decimal)
+	}
+	for _, freq := range []string{"M", "H", "D"} {
+		timestamp := getTimestampFromTimeZone(trade.Timestamp, freq)
+		currentStats, exist := reserveStats[reserveAddr]
+		if !exist {
+			currentStats = common.ReserveTradeStatsFreq{}
+		}
+		dataFreq, exist := currentStats[freq]
+		if !exist {
+			dataFreq = map[uint64]common.ReserveTradeStats{}
+		}
+		data := dataFreq[timestamp]
+		data.TradeCount++
+		data.ETHVolume += ethAmount
+		data.USDVolume += trade.FiatAmount
+		data.BurnFee += burnFee
+		data.WalletFee += walletFee
+		dataFreq[timestamp] = data
+		currentStats[freq] = dataFreq
+		reserveStats[reserveAddr] = currentStats
+	}
+}
+
 func (self *Fetcher) aggregateUserInfo(trade common.TradeLog, userInfos map[string]common.UserInfoTimezone) {
 	userAddr := common.AddrToString(trade.UserAddress)
 	srcAddr := common.AddrToString(trade.SrcAddress)
@@ -972,8 +1118,7 @@ func (self *Fetcher) aggregateMetricStat(trade common.TradeLog, statKey string,
 	metricStats map[string]common.MetricStatsTimeZone,
 	kycEd bool,
 	allFirstTradeEver map[ethereum.Address]uint64) {
-	userAddr := trade.UserAddress
-
+	snapshot := self.firstTradeSnapshot(trade, allFirstTradeEver)
 	for i := START_TIMEZONE; i <= END_TIMEZONE; i++ {
 		freq := fmt.Sprintf("%s%d", TIMEZONE_BUCKET_PREFIX, i)
 		timestamp := getTimestampFromTimeZone(trade.Timestamp, freq)
@@ -989,23 +1134,16 @@ func (self *Fetcher) aggregateMetricStat(trade common.TradeLog, statKey string,
 		if !exist {
 			data = common.MetricStats{}
 		}
-		timeFirstTrade := allFirstTradeEver[trade.UserAddress]
-		if timeFirstTrade == trade.Timestamp {
+		if snapshot.Ever {
 			data.NewUniqueAddresses++
 			data.UniqueAddr++
 			if kycEd {
 				data.KYCEd++
 			}
-		} else {
-			firstTradeInday, err := self.statStorage.GetFirstTradeInDay(userAddr, trade.Timestamp, i)
-			if err != nil {
-				log.Printf("ERROR: get first traede in day fail. %v", err)
-			}
-			if firstTradeInday == trade.Timestamp {
-				data.UniqueAddr++
-				if kycEd {
-					data.KYCEd++
-				}
+		} else if snapshot.InDay[i] {
+			data.UniqueAddr++
+			if kycEd {
+				data.KYCEd++
 			}
 		}
 
@@ -1020,6 +1158,39 @@ func (self *Fetcher) aggregateMetricStat(trade common.TradeLog, statKey string,
 	return
 }
 
+// firstTradeSnapshot returns the first trade state of trade when it was
+// first aggregated, it is taken from allFirstTradeEver and the first trades
+// in day and stored on the first aggregation. Every aggregation and the
+// rollback of trade count its unique addresses from the same snapshot.
+func (self *Fetcher) firstTradeSnapshot(trade common.TradeLog, allFirstTradeEver map[ethereum.Address]uint64) common.FirstTradeSnapshot {
+	snapshot, found, err := self.statStorage.GetFirstTradeSnapshot(trade)
+	if err != nil {
+		log.Printf("ERROR: get first trade snapshot fail. %v", err)
+	}
+	if found {
+		return snapshot
+	}
+	snapshot = common.FirstTradeSnapshot{
+		Ever:  allFirstTradeEver[trade.UserAddress] == trade.Timestamp,
+		InDay: map[int64]bool{},
+	}
+	if !snapshot.Ever {
+		for i := START_TIMEZONE; i <= END_TIMEZONE; i++ {
+			firstTradeInday, err := self.statStorage.GetFirstTradeInDay(trade.UserAddress, trade.Timestamp, i)
+			if err != nil {
+				log.Printf("ERROR: get first traede in day fail. %v", err)
+			}
+			if firstTradeInday == trade.Timestamp {
+				snapshot.InDay[i] = true
+			}
+		}
+	}
+	if err = self.statStorage.StoreFirstTradeSnapshot(trade, snapshot); err != nil {
+		log.Printf("ERROR: store first trade snapshot fail. %v", err)
+	}
+	return snapshot
+}
+
 func (self *Fetcher) FetchCurrentBlock() {
 	block, err := self.blockchain.CurrentBlock()
 	if err != nil {
@@ -2,6 +2,7 @@ package stat
 
 import (
 	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 type LogStorage interface {
@@ -18,4 +19,13 @@ type LogStorage interface {
 	UpdateLogBlock(block uint64, timepoint uint64) error
 	MaxRange() uint64
 	LastBlock() (uint64, error)
+
+	// StoreBlockHash records hash of a fetched block, blocks of fetched
+	// logs and the last block of each fetched range are recorded and only
+	// the latest hashes are kept
+	StoreBlockHash(block uint64, hash ethereum.Hash) error
+	GetBlockHashes() (map[uint64]ethereum.Hash, error)
+	// RemoveLogsAfter removes trade logs, cat logs and block hashes of
+	// blocks after block, it returns the removed trade logs
+	RemoveLogsAfter(block uint64) ([]common.TradeLog, error)
 }
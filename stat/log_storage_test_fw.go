@@ -2,6 +2,7 @@ package stat
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -134,3 +135,50 @@ func (self *LogStorageTest) TestUtil() error {
 	return err
 
 }
+
+func (self *LogStorageTest) TestRemoveLogsAfter() error {
+	var err error
+	for i, block := range []uint64{100, 101, 102} {
+		err = self.storage.StoreTradeLog(common.TradeLog{
+			Timestamp:   uint64(i + 1),
+			BlockNumber: block,
+			Index:       1,
+		}, uint64(i+1))
+		if err != nil {
+			return err
+		}
+		err = self.storage.StoreBlockHash(block, ethereum.BigToHash(big.NewInt(int64(block))))
+		if err != nil {
+			return err
+		}
+	}
+	err = self.storage.StoreCatLog(common.SetCatLog{Timestamp: 4, BlockNumber: 102, Index: 2})
+	if err != nil {
+		return err
+	}
+	removed, err := self.storage.RemoveLogsAfter(100)
+	if err != nil {
+		return err
+	}
+	if len(removed) != 2 {
+		return fmt.Errorf("RemoveLogsAfter return wrong number of trade logs, expected 2, got %d", len(removed))
+	}
+	record, err := self.storage.GetLastTradeLog()
+	if err != nil {
+		return err
+	}
+	if record.BlockNumber != 100 {
+		return fmt.Errorf("GetLastTradeLog return wrong record, expect BlockNumber 100, got %d", record.BlockNumber)
+	}
+	if _, err = self.storage.GetLastCatLog(); err == nil {
+		return fmt.Errorf("Cat log of removed block is not removed")
+	}
+	hashes, err := self.storage.GetBlockHashes()
+	if err != nil {
+		return err
+	}
+	if len(hashes) != 1 || hashes[100] != ethereum.BigToHash(big.NewInt(100)) {
+		return fmt.Errorf("GetBlockHashes return wrong hashes, expect hash of block 100 only, got %v", hashes)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package stat
+
+import (
+	"log"
+	"sort"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// CheckReorg compares recorded block hashes, of the blocks of fetched logs
+// and the last block of each fetched range, with the canonical chain from
+// the latest one backward. It returns the latest recorded block which is
+// still in the chain and true if blocks after it are reorganised.
+func (self *Fetcher) CheckReorg() (uint64, bool, error) {
+	hashes, err := self.logStorage.GetBlockHashes()
+	if err != nil {
+		return 0, false, err
+	}
+	blocks := []uint64{}
+	for block := range hashes {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] > blocks[j] })
+	for i, block := range blocks {
+		hash, err := self.blockchain.GetBlockHash(block)
+		if err != nil {
+			return 0, false, err
+		}
+		if hash == hashes[block] {
+			return block, i > 0, nil
+		}
+		log.Printf("LogFetcher - block %d is reorganised, recorded hash %s, chain hash %s", block, hashes[block].Hex(), hash.Hex())
+	}
+	if len(blocks) == 0 {
+		return 0, false, nil
+	}
+	// the reorg is deeper than recorded hashes, roll back all of them
+	oldest := blocks[len(blocks)-1]
+	if oldest > 0 {
+		oldest--
+	}
+	return oldest, true, nil
+}
+
+// RollbackLogs removes logs of blocks after forkBlock and rolls back
+// aggregations of the removed trade logs so logs of the new chain are
+// fetched and aggregated again. Address categories set by removed cat logs
+// are kept, they are overwritten by cat logs of the new chain.
+func (self *Fetcher) RollbackLogs(forkBlock uint64) error {
+	self.processMu.Lock()
+	defer self.processMu.Unlock()
+	removed, err := self.logStorage.RemoveLogsAfter(forkBlock)
+	if err != nil {
+		return err
+	}
+	if err = self.logStorage.UpdateLogBlock(forkBlock, common.GetTimepoint()); err != nil {
+		return err
+	}
+	log.Printf("LogFetcher - removed %d trade logs after block %d", len(removed), forkBlock)
+
+	// logs of the new chain are after all remaining logs
+	var tradeBoundary, catBoundary uint64
+	if l, err := self.logStorage.GetLastTradeLog(); err == nil {
+		tradeBoundary = l.Timestamp
+	}
+	if l, err := self.logStorage.GetLastCatLog(); err == nil {
+		catBoundary = l.Timestamp
+	}
+	if err = self.rollbackAggregations(removed, tradeBoundary); err != nil {
+		return err
+	}
+	if err = self.statStorage.RemoveFirstTrades(&removed); err != nil {
+		return err
+	}
+	lastCat, err := self.userStorage.GetLastProcessedCatLogTimepoint()
+	if err != nil {
+		return err
+	}
+	if lastCat > catBoundary {
+		return self.userStorage.SetLastProcessedCatLogTimepoint(catBoundary)
+	}
+	return nil
+}
+
+// rollbackAggregations subtracts removed trade logs from the aggregations
+// which already processed them and moves their last processed timepoints
+// back to boundary. Unique addresses are subtracted from the first trade
+// snapshots taken when the trades were aggregated.
+func (self *Fetcher) rollbackAggregations(removed []common.TradeLog, boundary uint64) error {
+	allFirstTradeEver, _ := self.statStorage.GetAllFirstTradeEver()
+	kycEdUsers, _ := self.userStorage.GetKycUsers()
+	aggregations := []string{
+		BURNFEE_AGGREGATION,
+		VOLUME_STAT_AGGREGATION,
+		TRADE_SUMMARY_AGGREGATION,
+		WALLET_AGGREGATION,
+		COUNTRY_AGGREGATION,
+		USER_INFO_AGGREGATION,
+		RESERVE_STAT_AGGREGATION,
+	}
+	for _, aggregation := range aggregations {
+		lastProcessed, err := self.statStorage.GetLastProcessedTradeLogTimepoint(aggregation)
+		if err != nil {
+			return err
+		}
+		if lastProcessed <= boundary {
+			continue
+		}
+		processed := []common.TradeLog{}
+		for _, trade := range removed {
+			if trade.Timestamp <= lastProcessed {
+				processed = append(processed, trade)
+			}
+		}
+		switch aggregation {
+		case BURNFEE_AGGREGATION:
+			stats := map[string]common.BurnFeeStatsTimeZone{}
+			for _, trade := range processed {
+				self.aggregateBurnFeeStats(trade, stats)
+			}
+			negateBurnFeeStats(stats)
+			err = self.statStorage.SetBurnFeeStat(stats, boundary)
+		case VOLUME_STAT_AGGREGATION:
+			stats := map[string]common.VolumeStatsTimeZone{}
+			for _, trade := range processed {
+				self.aggregateVolumeStats(trade, stats)
+			}
+			negateVolumeStats(stats)
+			err = self.statStorage.SetVolumeStat(stats, boundary)
+		case TRADE_SUMMARY_AGGREGATION:
+			stats := map[string]common.MetricStatsTimeZone{}
+			for _, trade := range processed {
+				self.aggregateTradeSumary(trade, stats, allFirstTradeEver, kycEdUsers)
+			}
+			negateMetricStats(stats)
+			err = self.statStorage.SetTradeSummary(stats, boundary)
+		case WALLET_AGGREGATION:
+			stats := map[string]common.MetricStatsTimeZone{}
+			for _, trade := range processed {
+				self.aggregateWalletStats(trade, stats, allFirstTradeEver, kycEdUsers)
+			}
+			negateMetricStats(stats)
+			err = self.statStorage.SetWalletStat(stats, boundary)
+		case COUNTRY_AGGREGATION:
+			stats := map[string]common.MetricStatsTimeZone{}
+			for _, trade := range processed {
+				self.aggregateCountryStats(trade, stats, allFirstTradeEver, kycEdUsers)
+			}
+			negateMetricStats(stats)
+			err = self.statStorage.SetCountryStat(stats, boundary)
+		case USER_INFO_AGGREGATION:
+			userInfos := map[string]common.UserInfoTimezone{}
+			for _, trade := range processed {
+				self.aggregateUserInfo(trade, userInfos)
+			}
+			negateUserInfos(userInfos)
+			err = self.statStorage.SetUserList(userInfos, boundary)
+		case RESERVE_STAT_AGGREGATION:
+			stats := map[string]common.ReserveTradeStatsFreq{}
+			for _, trade := range processed {
+				self.aggregateReserveStats(trade, stats)
+			}
+			negateReserveStats(stats)
+			err = self.statStorage.SetReserveStats(stats, boundary)
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("LogFetcher - rolled back %d trade logs of %s", len(processed), aggregation)
+	}
+	return nil
+}
+
+func negateBurnFeeStats(stats map[string]common.BurnFeeStatsTimeZone) {
+	for _, timezoneData := range stats {
+		for _, data := range timezoneData {
+			for timepoint, stat := range data {
+				stat.TotalBurnFee = -stat.TotalBurnFee
+				data[timepoint] = stat
+			}
+		}
+	}
+}
+
+func negateVolumeStats(stats map[string]common.VolumeStatsTimeZone) {
+	for _, timezoneData := range stats {
+		for _, data := range timezoneData {
+			for timepoint, stat := range data {
+				stat.ETHVolume = -stat.ETHVolume
+				stat.USDAmount = -stat.USDAmount
+				stat.Volume = -stat.Volume
+				data[timepoint] = stat
+			}
+		}
+	}
+}
+
+func negateMetricStats(stats map[string]common.MetricStatsTimeZone) {
+	for _, timezoneData := range stats {
+		for _, data := range timezoneData {
+			for timepoint, stat := range data {
+				stat.ETHVolume = -stat.ETHVolume
+				stat.USDVolume = -stat.USDVolume
+				stat.BurnFee = -stat.BurnFee
+				stat.TradeCount = -stat.TradeCount
+				stat.UniqueAddr = -stat.UniqueAddr
+				stat.KYCEd = -stat.KYCEd
+				stat.NewUniqueAddresses = -stat.NewUniqueAddresses
+				data[timepoint] = stat
+			}
+		}
+	}
+}
+
+func negateUserInfos(userInfos map[string]common.UserInfoTimezone) {
+	for _, timezoneData := range userInfos {
+		for _, data := range timezoneData {
+			for timepoint, info := range data {
+				info.ETHVolume = -info.ETHVolume
+				info.USDVolume = -info.USDVolume
+				data[timepoint] = info
+			}
+		}
+	}
+}
+
+func negateReserveStats(stats map[string]common.ReserveTradeStatsFreq) {
+	for _, freqData := range stats {
+		for _, data := range freqData {
+			for timepoint, stat := range data {
+				stat.TradeCount = -stat.TradeCount
+				stat.ETHVolume = -stat.ETHVolume
+				stat.USDVolume = -stat.USDVolume
+				stat.BurnFee = -stat.BurnFee
+				stat.WalletFee = -stat.WalletFee
+				data[timepoint] = stat
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// ReplayAggregation rebuilds stats of aggregation in the buckets covering
+// fromTime to toTime from stored trade logs. Stats of every key in those
+// buckets are removed and trade logs are aggregated again in batches of
+// batchRange, other buckets and other aggregations are untouched. Trade
+// logs after the last processed timepoint of the aggregation are left to
+// the trade log processor, which is held while replaying. progress is
+// called after each batch.
+func (self *Fetcher) ReplayAggregation(aggregation string, fromTime, toTime, batchRange uint64, progress func(batchTo, replayTo uint64, tradeCount int)) error {
+	self.processMu.Lock()
+	defer self.processMu.Unlock()
+	lastProcessed, err := self.statStorage.GetLastProcessedTradeLogTimepoint(aggregation)
+	if err != nil {
+		return err
+	}
+	if toTime > lastProcessed {
+		toTime = lastProcessed
+	}
+	if fromTime > toTime {
+		return fmt.Errorf("Nothing to replay, %s is processed up to %d", aggregation, lastProcessed)
+	}
+	// buckets covering the window start or end at most one day out of it,
+	// trade logs of that day are replayed only into those buckets
+	day := uint64(24 * time.Hour)
+	var replayFrom uint64
+	if fromTime > day {
+		replayFrom = fromTime - day
+	}
+	replayTo := toTime + day
+	if replayTo > lastProcessed {
+		replayTo = lastProcessed
+	}
+	if maxRange := self.logStorage.MaxRange(); batchRange == 0 || batchRange > maxRange {
+		batchRange = maxRange
+	}
+	inWindow := func(timepoint uint64, freq string) bool {
+		return timepoint >= getTimestampFromTimeZone(fromTime, freq) &&
+			timepoint <= getTimestampFromTimeZone(toTime, freq)
+	}
+	if err = self.statStorage.ResetAggregation(aggregation, fromTime, toTime); err != nil {
+		return err
+	}
+
+	for batchFrom := replayFrom; batchFrom <= replayTo; {
+		batchTo := batchFrom + batchRange - 1
+		if batchTo > replayTo {
+			batchTo = replayTo
+		}
+		tradeLogs, err := self.logStorage.GetTradeLogs(batchFrom, batchTo)
+		if err != nil {
+			return err
+		}
+		if err = self.replayTradeLogs(aggregation, tradeLogs, lastProcessed, inWindow); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(batchTo, replayTo, len(tradeLogs))
+		}
+		batchFrom = batchTo + 1
+	}
+	return nil
+}
+
+// replayTradeLogs aggregates tradeLogs into the buckets in window
+func (self *Fetcher) replayTradeLogs(
+	aggregation string,
+	tradeLogs []common.TradeLog,
+	lastProcessed uint64,
+	inWindow func(timepoint uint64, freq string) bool) error {
+	switch aggregation {
+	case BURNFEE_AGGREGATION:
+		stats := map[string]common.BurnFeeStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			self.aggregateBurnFeeStats(trade, stats)
+		}
+		filterBurnFeeStats(stats, inWindow)
+		return self.statStorage.SetBurnFeeStat(stats, lastProcessed)
+	case VOLUME_STAT_AGGREGATION:
+		stats := map[string]common.VolumeStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			self.aggregateVolumeStats(trade, stats)
+		}
+		filterVolumeStats(stats, inWindow)
+		return self.statStorage.SetVolumeStat(stats, lastProcessed)
+	case RESERVE_STAT_AGGREGATION:
+		stats := map[string]common.ReserveTradeStatsFreq{}
+		for _, trade := range tradeLogs {
+			self.aggregateReserveStats(trade, stats)
+		}
+		filterReserveStats(stats, inWindow)
+		return self.statStorage.SetReserveStats(stats, lastProcessed)
+	case USER_INFO_AGGREGATION:
+		userInfos := map[string]common.UserInfoTimezone{}
+		for _, trade := range tradeLogs {
+			self.aggregateUserInfo(trade, userInfos)
+		}
+		filterUserInfos(userInfos, inWindow)
+		return self.statStorage.SetUserList(userInfos, lastProcessed)
+	case TRADE_SUMMARY_AGGREGATION, WALLET_AGGREGATION, COUNTRY_AGGREGATION:
+		if len(tradeLogs) > 0 {
+			self.statStorage.SetFirstTradeEver(&tradeLogs)
+			self.statStorage.SetFirstTradeInDay(&tradeLogs)
+		}
+		allFirstTradeEver, _ := self.statStorage.GetAllFirstTradeEver()
+		kycEdUsers, _ := self.userStorage.GetKycUsers()
+		stats := map[string]common.MetricStatsTimeZone{}
+		for _, trade := range tradeLogs {
+			switch aggregation {
+			case TRADE_SUMMARY_AGGREGATION:
+				self.aggregateTradeSumary(trade, stats, allFirstTradeEver, kycEdUsers)
+			case WALLET_AGGREGATION:
+				self.aggregateWalletStats(trade, stats, allFirstTradeEver, kycEdUsers)
+			case COUNTRY_AGGREGATION:
+				self.aggregateCountryStats(trade, stats, allFirstTradeEver, kycEdUsers)
+			}
+		}
+		filterMetricStats(stats, inWindow)
+		switch aggregation {
+		case TRADE_SUMMARY_AGGREGATION:
+			return self.statStorage.SetTradeSummary(stats, lastProcessed)
+		case WALLET_AGGREGATION:
+			return self.statStorage.SetWalletStat(stats, lastProcessed)
+		default:
+			return self.statStorage.SetCountryStat(stats, lastProcessed)
+		}
+	}
+	return fmt.Errorf("Aggregation %s can't be replayed", aggregation)
+}
+
+// filterBurnFeeStats removes stats out of window
+func filterBurnFeeStats(stats map[string]common.BurnFeeStatsTimeZone, inWindow func(uint64, string) bool) {
+	for _, freqData := range stats {
+		for freq, data := range freqData {
+			for timepoint := range data {
+				if !inWindow(timepoint, freq) {
+					delete(data, timepoint)
+				}
+			}
+		}
+	}
+}
+
+func filterVolumeStats(stats map[string]common.VolumeStatsTimeZone, inWindow func(uint64, string) bool) {
+	for _, freqData := range stats {
+		for freq, data := range freqData {
+			for timepoint := range data {
+				if !inWindow(timepoint, freq) {
+					delete(data, timepoint)
+				}
+			}
+		}
+	}
+}
+
+func filterReserveStats(stats map[string]common.ReserveTradeStatsFreq, inWindow func(uint64, string) bool) {
+	for _, freqData := range stats {
+		for freq, data := range freqData {
+			for timepoint := range data {
+				if !inWindow(timepoint, freq) {
+					delete(data, timepoint)
+				}
+			}
+		}
+	}
+}
+
+func filterMetricStats(stats map[string]common.MetricStatsTimeZone, inWindow func(uint64, string) bool) {
+	for _, timezoneData := range stats {
+		for timezone, data := range timezoneData {
+			freq := fmt.Sprintf("%s%d", TIMEZONE_BUCKET_PREFIX, timezone)
+			for timepoint := range data {
+				if !inWindow(timepoint, freq) {
+					delete(data, timepoint)
+				}
+			}
+		}
+	}
+}
+
+func filterUserInfos(userInfos map[string]common.UserInfoTimezone, inWindow func(uint64, string) bool) {
+	for _, timezoneData := range userInfos {
+		for timezone, data := range timezoneData {
+			freq := fmt.Sprintf("%s%d", TIMEZONE_BUCKET_PREFIX, timezone)
+			for timepoint := range data {
+				if !inWindow(timepoint, freq) {
+					delete(data, timepoint)
+				}
+			}
+		}
+	}
+}
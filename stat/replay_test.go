@@ -0,0 +1,115 @@
+package stat_test
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/stat"
+	statstorage "github.com/KyberNetwork/reserve-data/stat/storage"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+func TestReplayAggregation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "test_replay")
+	if err != nil {
+		t.Fatalf("Couldn't create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	statStorage, err := statstorage.NewBoltStatStorage(filepath.Join(tmpDir, "stat.db"))
+	if err != nil {
+		t.Fatalf("Couldn't init stat storage: %s", err)
+	}
+	logStorage, err := statstorage.NewBoltLogStorage(filepath.Join(tmpDir, "log.db"))
+	if err != nil {
+		t.Fatalf("Couldn't init log storage: %s", err)
+	}
+	common.RegisterInternalActiveToken(common.Token{ID: "ETH", Address: "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", Decimal: 18})
+	common.RegisterInternalActiveToken(common.Token{ID: "KNC", Address: "0x1111111111111111111111111111111111111111", Decimal: 18})
+	reserve := ethereum.HexToAddress("0x2222222222222222222222222222222222222222")
+	day := uint64(24 * time.Hour)
+	hour := uint64(time.Hour)
+
+	// the trade of the fourth day is not processed yet
+	for i, timestamp := range []uint64{day + hour, 2*day + hour, 2*day + 2*hour, 3*day + hour, 4*day + hour} {
+		err = logStorage.StoreTradeLog(common.TradeLog{
+			Timestamp:      timestamp,
+			BlockNumber:    uint64(i + 1),
+			SrcAddress:     ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"),
+			DestAddress:    ethereum.HexToAddress("0x1111111111111111111111111111111111111111"),
+			SrcAmount:      big.NewInt(1000000000000000000),
+			DestAmount:     big.NewInt(1000000000000000000),
+			ReserveAddress: reserve,
+			BurnFee:        big.NewInt(1000000000000000000),
+		}, timestamp)
+		if err != nil {
+			t.Fatalf("Couldn't store trade log: %s", err)
+		}
+	}
+	// burn fees of the second day are aggregated twice and wallet fees of
+	// the second day are aggregated to a wrong wallet
+	lastProcessed := 3*day + hour
+	wrongWallet := ethereum.HexToAddress("0x3333333333333333333333333333333333333333")
+	err = statStorage.SetBurnFeeStat(map[string]common.BurnFeeStatsTimeZone{
+		common.AddrToString(reserve): {
+			"D": {
+				day:     {TotalBurnFee: 1},
+				2 * day: {TotalBurnFee: 4},
+				3 * day: {TotalBurnFee: 1},
+			},
+		},
+		common.AddrToString(reserve) + "_" + common.AddrToString(wrongWallet): {
+			"D": {
+				day:     {TotalBurnFee: 1},
+				2 * day: {TotalBurnFee: 2},
+			},
+		},
+	}, lastProcessed)
+	if err != nil {
+		t.Fatalf("Couldn't store burn fee stats: %s", err)
+	}
+
+	fetcher := stat.NewFetcher(statStorage, logStorage, nil, nil, nil, 0, reserve, nil)
+	trades := 0
+	err = fetcher.ReplayAggregation(stat.BURNFEE_AGGREGATION, 2*day, 3*day-1, hour, func(batchTo, replayTo uint64, tradeCount int) {
+		trades += tradeCount
+	})
+	if err != nil {
+		t.Fatalf("Expected replay to succeed, got error: %s", err)
+	}
+	if trades != 4 {
+		t.Fatalf("Expected the processed trades of the window and the days around it to be replayed, got %d", trades)
+	}
+	fees, err := statStorage.GetBurnFee(0, 5*day, "D", reserve)
+	if err != nil {
+		t.Fatalf("Couldn't get burn fee stats: %s", err)
+	}
+	expected := map[uint64]float64{day / 1000000: 1, 2 * day / 1000000: 2, 3 * day / 1000000: 1}
+	if len(fees) != len(expected) {
+		t.Fatalf("Expected burn fees %v, got %v", expected, fees)
+	}
+	for timepoint, fee := range expected {
+		if fees[timepoint] != fee {
+			t.Fatalf("Expected burn fees %v, got %v", expected, fees)
+		}
+	}
+	walletFees, err := statStorage.GetWalletFee(0, 5*day, "D", reserve, wrongWallet)
+	if err != nil {
+		t.Fatalf("Couldn't get wallet fee stats: %s", err)
+	}
+	if len(walletFees) != 1 || walletFees[day/1000000] != float64(1) {
+		t.Fatalf("Expected wallet fees of the wrong wallet in the window to be removed, got %v", walletFees)
+	}
+	timepoint, err := statStorage.GetLastProcessedTradeLogTimepoint(stat.BURNFEE_AGGREGATION)
+	if err != nil || timepoint != lastProcessed {
+		t.Fatalf("Expected last processed timepoint to stay %d, got %d, error: %v", lastProcessed, timepoint, err)
+	}
+
+	if err = fetcher.ReplayAggregation(stat.BURNFEE_AGGREGATION, 4*day, 5*day, hour, nil); err == nil {
+		t.Fatalf("Expected replaying trades after the last processed timepoint to fail")
+	}
+}
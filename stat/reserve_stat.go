@@ -154,6 +154,64 @@ func (self ReserveStats) GetReserveVolume(fromTime, toTime uint64, freq, reserve
 	return data, err
 }
 
+func (self ReserveStats) GetReserves() []string {
+	result := []string{}
+	for _, reserve := range self.fetcher.SupportedReserves() {
+		result = append(result, common.AddrToString(reserve))
+	}
+	return result
+}
+
+func (self ReserveStats) GetReserveStats(fromTime, toTime uint64, freq, reserveAddr string) (common.StatTicks, error) {
+	data := common.StatTicks{}
+
+	fromTime, toTime, err := validateTimeWindow(fromTime, toTime, freq)
+	if err != nil {
+		return data, err
+	}
+	return self.statStorage.GetReserveStats(fromTime, toTime, freq, ethereum.HexToAddress(reserveAddr))
+}
+
+// GetReserveMarketShare returns shares of the supported reserves in their
+// total ETH volume of each time frame
+func (self ReserveStats) GetReserveMarketShare(fromTime, toTime uint64, freq string) (common.StatTicks, error) {
+	result := common.StatTicks{}
+
+	fromTime, toTime, err := validateTimeWindow(fromTime, toTime, freq)
+	if err != nil {
+		return result, err
+	}
+	volumes := map[uint64]map[string]float64{}
+	for _, reserve := range self.fetcher.SupportedReserves() {
+		stats, err := self.statStorage.GetReserveStats(fromTime, toTime, freq, reserve)
+		if err != nil {
+			return result, err
+		}
+		for timepoint, stat := range stats {
+			if _, exist := volumes[timepoint]; !exist {
+				volumes[timepoint] = map[string]float64{}
+			}
+			volumes[timepoint][common.AddrToString(reserve)] = stat.(common.ReserveTradeStats).ETHVolume
+		}
+	}
+	for timepoint, reserveVolumes := range volumes {
+		var total float64
+		for _, volume := range reserveVolumes {
+			total += volume
+		}
+		shares := map[string]common.ReserveMarketShare{}
+		for reserve, volume := range reserveVolumes {
+			share := common.ReserveMarketShare{ETHVolume: volume}
+			if total > 0 {
+				share.Share = volume / total
+			}
+			shares[reserve] = share
+		}
+		result[timepoint] = shares
+	}
+	return result, nil
+}
+
 func (self ReserveStats) GetTradeSummary(fromTime, toTime uint64, timezone int64) (common.StatTicks, error) {
 	data := common.StatTicks{}
 
@@ -329,6 +387,18 @@ func (self ReserveStats) RunAnalyticStorageController() {
 	}
 }
 
+func (self ReserveStats) ReplayAggregation(aggregation string, fromTime, toTime uint64) (int, error) {
+	if toTime == 0 {
+		toTime = common.GetTimepoint()
+	}
+	replayed := 0
+	err := self.fetcher.ReplayAggregation(aggregation, fromTime*1000000, toTime*1000000, 0, func(batchTo, replayTo uint64, tradeCount int) {
+		replayed += tradeCount
+		log.Printf("%s: replayed %d trade logs up to %d/%d", aggregation, replayed, batchTo/1000000, replayTo/1000000)
+	})
+	return replayed, err
+}
+
 func (self ReserveStats) RunDBController() error {
 	err := self.controllerRunner.Start()
 	if err != nil {
@@ -20,6 +20,10 @@ type StatStorage interface {
 
 	SetBurnFeeStat(burnFeeStat map[string]common.BurnFeeStatsTimeZone, lastProcessedTimepoint uint64) error
 
+	// SetReserveStats adds trade stats of reserves keyed by reserve address
+	SetReserveStats(reserveStats map[string]common.ReserveTradeStatsFreq, lastProcessedTimepoint uint64) error
+	GetReserveStats(fromTime, toTime uint64, freq string, reserveAddr ethereum.Address) (common.StatTicks, error)
+
 	SetWalletAddress(walletAddr ethereum.Address) error
 	GetWalletAddress() ([]string, error)
 
@@ -34,10 +38,24 @@ type StatStorage interface {
 	GetAllFirstTradeEver() (map[ethereum.Address]uint64, error)
 	SetFirstTradeInDay(tradeLogs *[]common.TradeLog) error
 	GetFirstTradeInDay(userAddr ethereum.Address, timepoint uint64, timezone int64) (uint64, error)
+	// StoreFirstTradeSnapshot records the first trade state of trade when
+	// it is first aggregated
+	StoreFirstTradeSnapshot(trade common.TradeLog, snapshot common.FirstTradeSnapshot) error
+	// GetFirstTradeSnapshot returns the recorded first trade state of
+	// trade, it is false if there is none
+	GetFirstTradeSnapshot(trade common.TradeLog) (common.FirstTradeSnapshot, bool, error)
+	// RemoveFirstTrades removes first trade ever and first trade in day
+	// records made by tradeLogs and their first trade snapshots
+	RemoveFirstTrades(tradeLogs *[]common.TradeLog) error
 
 	SetUserList(userInfos map[string]common.UserInfoTimezone, lastProcessedTimepoint uint64) error
 	GetUserList(fromTime, toTime uint64, timezone int64) (map[string]common.UserInfo, error)
 
 	SetTradeSummary(stats map[string]common.MetricStatsTimeZone, lastProcessedTimepoint uint64) error
 	GetTradeSummary(fromTime, toTime uint64, timezone int64) (common.StatTicks, error)
+
+	// ResetAggregation removes stats of every key of aggregation in the
+	// buckets covering fromTime to toTime, last processed timepoint of the
+	// aggregation is kept
+	ResetAggregation(aggregation string, fromTime, toTime uint64) error
 }
@@ -455,3 +455,160 @@ func (self *StatStorageTest) TestFirstTradeInDay() error {
 	}
 	return err
 }
+
+func (self *StatStorageTest) TestRemoveFirstTrades() error {
+	var err error
+	first := common.TradeLog{
+		Timestamp:   45678,
+		UserAddress: ethereum.HexToAddress(TESTUSERADDR),
+	}
+	tradeLogs := []common.TradeLog{first}
+	if err = self.storage.SetFirstTradeEver(&tradeLogs); err != nil {
+		return err
+	}
+	snapshot := common.FirstTradeSnapshot{Ever: false, InDay: map[int64]bool{7: true}}
+	if err = self.storage.StoreFirstTradeSnapshot(first, snapshot); err != nil {
+		return err
+	}
+	stored, found, err := self.storage.GetFirstTradeSnapshot(first)
+	if err != nil {
+		return err
+	}
+	if !found || stored.Ever || !stored.InDay[7] || len(stored.InDay) != 1 {
+		return fmt.Errorf("GetFirstTradeSnapshot return wrong snapshot, expect %+v, got %+v (found %t)", snapshot, stored, found)
+	}
+	// a later trade of the user is not its first trade, nothing is removed
+	later := []common.TradeLog{{
+		Timestamp:   45679,
+		UserAddress: ethereum.HexToAddress(TESTUSERADDR),
+	}}
+	if err = self.storage.RemoveFirstTrades(&later); err != nil {
+		return err
+	}
+	timepoint, err := self.storage.GetFirstTradeEver(first.UserAddress)
+	if err != nil {
+		return err
+	}
+	if timepoint != first.Timestamp {
+		return fmt.Errorf("first trade ever is removed by a later trade, expect timepoint %d, got %d", first.Timestamp, timepoint)
+	}
+	if err = self.storage.RemoveFirstTrades(&tradeLogs); err != nil {
+		return err
+	}
+	timepoint, err = self.storage.GetFirstTradeEver(first.UserAddress)
+	if err != nil {
+		return err
+	}
+	if timepoint != 0 {
+		return fmt.Errorf("first trade ever of the removed trade is not removed, got timepoint %d", timepoint)
+	}
+	if _, found, err = self.storage.GetFirstTradeSnapshot(first); err != nil || found {
+		return fmt.Errorf("first trade snapshot of the removed trade is not removed, error: %v", err)
+	}
+	return nil
+}
+
+func (self *StatStorageTest) TestResetAggregation() error {
+	var err error
+	asset := ethereum.HexToAddress(TESTASSETADDR)
+	day := uint64(86400000000000)
+	stats := map[string]common.VolumeStatsTimeZone{
+		common.AddrToString(asset): {
+			"D": {
+				day:     {Volume: 1},
+				2 * day: {Volume: 2},
+			},
+		},
+	}
+	other := ethereum.HexToAddress("0x3333333333333333333333333333333333333333")
+	stats[common.AddrToString(other)] = common.VolumeStatsTimeZone{
+		"D": {2 * day: {Volume: 3}},
+	}
+	if err = self.storage.SetVolumeStat(stats, 2*day); err != nil {
+		return err
+	}
+	// burn fees are kept in top level buckets of their keys as volumes
+	reserve := ethereum.HexToAddress("0x4444444444444444444444444444444444444444")
+	burnFees := map[string]common.BurnFeeStatsTimeZone{
+		common.AddrToString(reserve): {
+			"D": {2 * day: {TotalBurnFee: 1}},
+		},
+	}
+	if err = self.storage.SetBurnFeeStat(burnFees, 2*day); err != nil {
+		return err
+	}
+	// reset stats of the second day only
+	if err = self.storage.ResetAggregation(VOLUME_STAT_AGGREGATION, 2*day+1, 2*day+2); err != nil {
+		return err
+	}
+	volumes, err := self.storage.GetAssetVolume(0, 3*day, "D", asset)
+	if err != nil {
+		return err
+	}
+	if len(volumes) != 1 {
+		return fmt.Errorf("expect volume of the first day only, got %v", volumes)
+	}
+	if _, found := volumes[day/1000000]; !found {
+		return fmt.Errorf("volume of the first day is removed, got %v", volumes)
+	}
+	if volumes, err = self.storage.GetAssetVolume(0, 3*day, "D", other); err != nil {
+		return err
+	}
+	if len(volumes) != 0 {
+		return fmt.Errorf("expect volume of every key to be reset, got %v", volumes)
+	}
+	fees, err := self.storage.GetBurnFee(0, 3*day, "D", reserve)
+	if err != nil {
+		return err
+	}
+	if fees[2*day/1000000] != float64(1) {
+		return fmt.Errorf("burn fee of the second day is removed, got %v", fees)
+	}
+	lastProcessed, err := self.storage.GetLastProcessedTradeLogTimepoint(VOLUME_STAT_AGGREGATION)
+	if err != nil {
+		return err
+	}
+	if lastProcessed != 2*day {
+		return fmt.Errorf("last processed timepoint is changed, expect %d, got %d", 2*day, lastProcessed)
+	}
+	return nil
+}
+
+func (self *StatStorageTest) TestReserveStats() error {
+	var err error
+	reserve := ethereum.HexToAddress(TESTASSETADDR)
+	hour := uint64(3600000000000)
+	stats := map[string]common.ReserveTradeStatsFreq{
+		common.AddrToString(reserve): {
+			"H": {
+				hour: {TradeCount: 1, ETHVolume: 2, BurnFee: 0.1},
+			},
+		},
+	}
+	// stats are added to the stored ones
+	if err = self.storage.SetReserveStats(stats, hour); err != nil {
+		return err
+	}
+	if err = self.storage.SetReserveStats(stats, hour); err != nil {
+		return err
+	}
+	result, err := self.storage.GetReserveStats(0, 2*hour, "H", reserve)
+	if err != nil {
+		return err
+	}
+	stat, ok := result[hour/1000000].(common.ReserveTradeStats)
+	if !ok {
+		return fmt.Errorf("expect reserve stats at %d, got %v", hour/1000000, result)
+	}
+	if stat.TradeCount != 2 || stat.ETHVolume != 4 {
+		return fmt.Errorf("expect 2 trades and 4 ETH volume, got %+v", stat)
+	}
+	lastProcessed, err := self.storage.GetLastProcessedTradeLogTimepoint(RESERVE_STAT_AGGREGATION)
+	if err != nil {
+		return err
+	}
+	if lastProcessed != hour {
+		return fmt.Errorf("expect last processed timepoint %d, got %d", hour, lastProcessed)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/boltdb/bolt"
+)
+
+const (
+	ETH_USD_RATE_BUCKET          string = "eth_usd_rates"
+	ETH_USD_REALTIME_RATE_BUCKET string = "eth_usd_realtime_rates"
+)
+
+// BoltEthUSDRateStorage keeps ETH-USD rates history and realtime rates in
+// separate buckets keyed by timepoint in millisecond
+type BoltEthUSDRateStorage struct {
+	db *bolt.DB
+}
+
+func NewBoltEthUSDRateStorage(path string) (*BoltEthUSDRateStorage, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(ETH_USD_RATE_BUCKET)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists([]byte(ETH_USD_REALTIME_RATE_BUCKET))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltEthUSDRateStorage{db}, nil
+}
+
+func float64ToBytes(f float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(f))
+	return b
+}
+
+func bytesToFloat64(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+func (self *BoltEthUSDRateStorage) StoreUSDRates(rates []common.USDRate) error {
+	return self.storeRates(ETH_USD_RATE_BUCKET, rates)
+}
+
+// GetUSDRatesAround returns the latest history rate at or before timepoint
+// and the earliest one after it, a missing rate has zero timepoint
+func (self *BoltEthUSDRateStorage) GetUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error) {
+	return self.ratesAround(ETH_USD_RATE_BUCKET, timepoint)
+}
+
+func (self *BoltEthUSDRateStorage) StoreRealtimeUSDRate(rate common.USDRate) error {
+	return self.storeRates(ETH_USD_REALTIME_RATE_BUCKET, []common.USDRate{rate})
+}
+
+func (self *BoltEthUSDRateStorage) GetRealtimeUSDRatesAround(timepoint uint64) (common.USDRate, common.USDRate, error) {
+	return self.ratesAround(ETH_USD_REALTIME_RATE_BUCKET, timepoint)
+}
+
+func (self *BoltEthUSDRateStorage) storeRates(bucket string, rates []common.USDRate) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		for _, rate := range rates {
+			if err := b.Put(uint64ToBytes(rate.Timepoint), float64ToBytes(rate.Rate)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (self *BoltEthUSDRateStorage) ratesAround(bucket string, timepoint uint64) (common.USDRate, common.USDRate, error) {
+	var before, after common.USDRate
+	err := self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(bucket)).Cursor()
+		k, v := c.Seek(uint64ToBytes(timepoint + 1))
+		if k != nil {
+			after = common.USDRate{Timepoint: bytesToUint64(k), Rate: bytesToFloat64(v)}
+			k, v = c.Prev()
+		} else {
+			k, v = c.Last()
+		}
+		if k != nil {
+			before = common.USDRate{Timepoint: bytesToUint64(k), Rate: bytesToFloat64(v)}
+		}
+		return nil
+	})
+	return before, after, err
+}
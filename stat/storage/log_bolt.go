@@ -9,12 +9,15 @@ import (
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/boltdb/bolt"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 const (
 	MAX_GET_LOG_PERIOD uint64 = 86400000000000 //1 days in nanosecond
 	TRADELOG_BUCKET    string = "logs"
 	CATLOG_BUCKET      string = "cat_logs"
+	BLOCK_HASH_BUCKET  string = "block_hashes"
+	MAX_BLOCK_HASHES   int    = 1000
 )
 
 type BoltLogStorage struct {
@@ -38,6 +41,9 @@ func NewBoltLogStorage(path string) (*BoltLogStorage, error) {
 		if _, uErr := tx.CreateBucketIfNotExists([]byte(TRADELOG_BUCKET)); uErr != nil {
 			return uErr
 		}
+		if _, uErr := tx.CreateBucketIfNotExists([]byte(BLOCK_HASH_BUCKET)); uErr != nil {
+			return uErr
+		}
 		_, uErr := tx.CreateBucketIfNotExists([]byte(CATLOG_BUCKET))
 		return uErr
 	})
@@ -292,3 +298,90 @@ func (self *BoltLogStorage) LastBlock() (uint64, error) {
 	defer self.mu.RUnlock()
 	return self.block, nil
 }
+
+func (self *BoltLogStorage) StoreBlockHash(block uint64, hash ethereum.Hash) error {
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HASH_BUCKET))
+		if uErr := b.Put(uint64ToBytes(block), hash.Bytes()); uErr != nil {
+			return uErr
+		}
+		c := b.Cursor()
+		for n := b.Stats().KeyN; n > MAX_BLOCK_HASHES; n-- {
+			k, _ := c.First()
+			if k == nil {
+				break
+			}
+			if uErr := b.Delete(k); uErr != nil {
+				return uErr
+			}
+		}
+		return nil
+	})
+}
+
+func (self *BoltLogStorage) GetBlockHashes() (map[uint64]ethereum.Hash, error) {
+	result := map[uint64]ethereum.Hash{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(BLOCK_HASH_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			result[bytesToUint64(k)] = ethereum.BytesToHash(v)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// removeAfter deletes records of blocks after block from bucket, records
+// of the bucket must be ordered by block, blockOf returns block of a record
+func removeAfter(tx *bolt.Tx, bucket string, block uint64, blockOf func(k, v []byte) (uint64, error)) error {
+	b := tx.Bucket([]byte(bucket))
+	c := b.Cursor()
+	keys := [][]byte{}
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		blockNumber, err := blockOf(k, v)
+		if err != nil {
+			return err
+		}
+		if blockNumber <= block {
+			break
+		}
+		keys = append(keys, k)
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *BoltLogStorage) RemoveLogsAfter(block uint64) ([]common.TradeLog, error) {
+	result := []common.TradeLog{}
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		err := removeAfter(tx, TRADELOG_BUCKET, block, func(k, v []byte) (uint64, error) {
+			record := common.TradeLog{}
+			if vErr := json.Unmarshal(v, &record); vErr != nil {
+				return 0, vErr
+			}
+			if record.BlockNumber > block {
+				result = append(result, record)
+			}
+			return record.BlockNumber, nil
+		})
+		if err != nil {
+			return err
+		}
+		err = removeAfter(tx, CATLOG_BUCKET, block, func(k, v []byte) (uint64, error) {
+			record := common.SetCatLog{}
+			vErr := json.Unmarshal(v, &record)
+			return record.BlockNumber, vErr
+		})
+		if err != nil {
+			return err
+		}
+		return removeAfter(tx, BLOCK_HASH_BUCKET, block, func(k, v []byte) (uint64, error) {
+			return bytesToUint64(k), nil
+		})
+	})
+	return result, err
+}
@@ -38,6 +38,8 @@ const (
 	USER_STAT_BUCKET            string = "user_stat_bucket"
 	VOLUME_STAT_BUCKET          string = "volume_stat_bucket"
 	USER_LIST_BUCKET            string = "user_list"
+	RESERVE_STAT_BUCKET         string = "reserve_stat_bucket"
+	FIRST_TRADE_SNAPSHOT_BUCKET string = "first_trade_snapshot"
 
 	TRADE_SUMMARY_AGGREGATION string = "trade_summary_aggregation"
 	WALLET_AGGREGATION        string = "wallet_aggregation"
@@ -46,6 +48,7 @@ const (
 	VOLUME_STAT_AGGREGATION   string = "volume_stat_aggregation"
 	BURNFEE_AGGREGATION       string = "burn_fee_aggregation"
 	USER_INFO_AGGREGATION     string = "user_info_aggregation"
+	RESERVE_STAT_AGGREGATION  string = "reserve_stat_aggregation"
 )
 
 type BoltStatStorage struct {
@@ -87,6 +90,10 @@ func NewBoltStatStorage(path string) (*BoltStatStorage, error) {
 		if err != nil {
 			return err
 		}
+		_, err = tx.CreateBucketIfNotExists([]byte(FIRST_TRADE_SNAPSHOT_BUCKET))
+		if err != nil {
+			return err
+		}
 
 		return err
 	})
@@ -281,6 +288,92 @@ func (self *BoltStatStorage) SetBurnFeeStat(burnFeeStats map[string]common.BurnF
 	return err
 }
 
+func (self *BoltStatStorage) SetReserveStats(reserveStats map[string]common.ReserveTradeStatsFreq, lastProcessTimePoint uint64) error {
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		reserveStatBk, err := tx.CreateBucketIfNotExists([]byte(RESERVE_STAT_BUCKET))
+		if err != nil {
+			return err
+		}
+		for reserve, freqData := range reserveStats {
+			reserveBk, err := reserveStatBk.CreateBucketIfNotExists([]byte(strings.ToLower(reserve)))
+			if err != nil {
+				return err
+			}
+			for _, freq := range []string{"M", "H", "D"} {
+				stats := freqData[freq]
+				freqBkName, _ := getBucketNameByFreq(freq)
+				freqBk, err := reserveBk.CreateBucketIfNotExists([]byte(freqBkName))
+				if err != nil {
+					return err
+				}
+				for timepoint, stat := range stats {
+					timestamp := uint64ToBytes(timepoint)
+					currentData := common.ReserveTradeStats{}
+					v := freqBk.Get(timestamp)
+					if v != nil {
+						json.Unmarshal(v, &currentData)
+					}
+					currentData.TradeCount += stat.TradeCount
+					currentData.ETHVolume += stat.ETHVolume
+					currentData.USDVolume += stat.USDVolume
+					currentData.BurnFee += stat.BurnFee
+					currentData.WalletFee += stat.WalletFee
+					dataJSON, err := json.Marshal(currentData)
+					if err != nil {
+						return err
+					}
+					if err = freqBk.Put(timestamp, dataJSON); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		lastProcessBk := tx.Bucket([]byte(TRADELOG_PROCESSOR_STATE))
+		if lastProcessBk != nil {
+			dataJSON := uint64ToBytes(lastProcessTimePoint)
+			lastProcessBk.Put([]byte(RESERVE_STAT_AGGREGATION), dataJSON)
+		}
+		return nil
+	})
+	return err
+}
+
+func (self *BoltStatStorage) GetReserveStats(fromTime, toTime uint64, freq string, ethReserveAddr ethereum.Address) (common.StatTicks, error) {
+	result := common.StatTicks{}
+	reserveAddr := common.AddrToString(ethReserveAddr)
+	freqBkName, err := getBucketNameByFreq(freq)
+	if err != nil {
+		return result, err
+	}
+	err = self.db.View(func(tx *bolt.Tx) error {
+		reserveStatBk := tx.Bucket([]byte(RESERVE_STAT_BUCKET))
+		if reserveStatBk == nil {
+			return nil
+		}
+		reserveBk := reserveStatBk.Bucket([]byte(reserveAddr))
+		if reserveBk == nil {
+			return nil
+		}
+		freqBk := reserveBk.Bucket([]byte(freqBkName))
+		if freqBk == nil {
+			return nil
+		}
+		min := uint64ToBytes(fromTime)
+		max := uint64ToBytes(toTime)
+		c := freqBk.Cursor()
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+			value := common.ReserveTradeStats{}
+			if err := json.Unmarshal(v, &value); err != nil {
+				return err
+			}
+			key := bytesToUint64(k) / 1000000
+			result[key] = value
+		}
+		return nil
+	})
+	return result, err
+}
+
 func (self *BoltStatStorage) SetVolumeStat(volumeStats map[string]common.VolumeStatsTimeZone, lastProcessTimePoint uint64) error {
 	err := self.db.Update(func(tx *bolt.Tx) error {
 		for asset, freqData := range volumeStats {
@@ -604,6 +697,221 @@ func (self *BoltStatStorage) SetFirstTradeInDay(tradeLogs *[]common.TradeLog) er
 	return err
 }
 
+// tradeKey identifies a trade by its tx hash and log index
+func tradeKey(trade common.TradeLog) []byte {
+	return []byte(fmt.Sprintf("%s_%d", trade.TransactionHash.Hex(), trade.Index))
+}
+
+func (self *BoltStatStorage) StoreFirstTradeSnapshot(trade common.TradeLog, snapshot common.FirstTradeSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FIRST_TRADE_SNAPSHOT_BUCKET))
+		return b.Put(tradeKey(trade), data)
+	})
+}
+
+func (self *BoltStatStorage) GetFirstTradeSnapshot(trade common.TradeLog) (common.FirstTradeSnapshot, bool, error) {
+	result := common.FirstTradeSnapshot{}
+	found := false
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FIRST_TRADE_SNAPSHOT_BUCKET))
+		v := b.Get(tradeKey(trade))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &result)
+	})
+	return result, found, err
+}
+
+func (self *BoltStatStorage) RemoveFirstTrades(tradeLogs *[]common.TradeLog) error {
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		firstTradeBk, err := tx.CreateBucketIfNotExists([]byte(USER_FIRST_TRADE_EVER))
+		if err != nil {
+			return err
+		}
+		snapshotBk := tx.Bucket([]byte(FIRST_TRADE_SNAPSHOT_BUCKET))
+		userStatBk, err := tx.CreateBucketIfNotExists([]byte(USER_STAT_BUCKET))
+		if err != nil {
+			return err
+		}
+		for _, trade := range *tradeLogs {
+			if err = snapshotBk.Delete(tradeKey(trade)); err != nil {
+				return err
+			}
+			userAddr := []byte(common.AddrToString(trade.UserAddress))
+			if v := firstTradeBk.Get(userAddr); v != nil && bytesToUint64(v) == trade.Timestamp {
+				if err = firstTradeBk.Delete(userAddr); err != nil {
+					return err
+				}
+			}
+			for timezone := START_TIMEZONE; timezone <= END_TIMEZONE; timezone++ {
+				timezoneBk := userStatBk.Bucket(uint64ToBytes(uint64(timezone)))
+				if timezoneBk == nil {
+					continue
+				}
+				freq := fmt.Sprintf("%s%d", TIMEZONE_BUCKET_PREFIX, timezone)
+				userDailyBucket := timezoneBk.Bucket(getTimestampByFreq(trade.Timestamp, freq))
+				if userDailyBucket == nil {
+					continue
+				}
+				if v := userDailyBucket.Get(userAddr); v != nil && bytesToUint64(v) == trade.Timestamp {
+					if err = userDailyBucket.Delete(userAddr); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// deleteRange deletes records of bucket b with keys from min to max for
+// which isStat returns true, nested buckets are kept
+func deleteRange(b *bolt.Bucket, min, max []byte, isStat func(v []byte) bool) error {
+	keys := [][]byte{}
+	c := b.Cursor()
+	for k, v := c.Seek(min); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+		if v != nil && isStat(v) {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasField returns whether a stat record is encoded with field. Burn fee
+// and volume stats are both kept in frequency buckets of top level buckets
+// named after their keys so they are told apart by their records.
+func hasField(field string) func(v []byte) bool {
+	return func(v []byte) bool {
+		record := map[string]json.RawMessage{}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return false
+		}
+		_, found := record[field]
+		return found
+	}
+}
+
+// metricAggregation returns the aggregation of the top level bucket name
+// holding stats by timezone: the trade summary, a wallet or a country
+func metricAggregation(name string) string {
+	switch {
+	case name == "trade_summary":
+		return TRADE_SUMMARY_AGGREGATION
+	case strings.HasPrefix(name, "0x"):
+		return WALLET_AGGREGATION
+	default:
+		return COUNTRY_AGGREGATION
+	}
+}
+
+// resetBuckets deletes stats in frequency buckets freqs of buckets from
+// fromTime to toTime
+func resetBuckets(buckets []*bolt.Bucket, freqs []string, fromTime, toTime uint64, isStat func(v []byte) bool) error {
+	for _, b := range buckets {
+		for _, freq := range freqs {
+			freqBkName, err := getBucketNameByFreq(freq)
+			if err != nil {
+				return err
+			}
+			freqBk := b.Bucket([]byte(freqBkName))
+			if freqBk == nil {
+				continue
+			}
+			if err = deleteRange(freqBk, getTimestampByFreq(fromTime, freq), getTimestampByFreq(toTime, freq), isStat); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ResetAggregation removes stats of every key of aggregation in the buckets
+// covering fromTime to toTime, so keys which won't be aggregated again are
+// cleared too. Last processed timepoint of the aggregation is kept.
+func (self *BoltStatStorage) ResetAggregation(aggregation string, fromTime, toTime uint64) error {
+	freqs := []string{"M", "H", "D"}
+	timezones := []string{}
+	for i := START_TIMEZONE; i <= END_TIMEZONE; i++ {
+		timezones = append(timezones, fmt.Sprintf("%s%d", TIMEZONE_BUCKET_PREFIX, i))
+	}
+	anyRecord := func(v []byte) bool { return true }
+	return self.db.Update(func(tx *bolt.Tx) error {
+		buckets := []*bolt.Bucket{}
+		switch aggregation {
+		case BURNFEE_AGGREGATION, VOLUME_STAT_AGGREGATION:
+			tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				buckets = append(buckets, b)
+				return nil
+			})
+			isStat := hasField("volume")
+			if aggregation == BURNFEE_AGGREGATION {
+				isStat = hasField("TotalBurnFee")
+			}
+			return resetBuckets(buckets, freqs, fromTime, toTime, isStat)
+		case TRADE_SUMMARY_AGGREGATION, WALLET_AGGREGATION, COUNTRY_AGGREGATION:
+			tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				if string(name) != USER_LIST_BUCKET && metricAggregation(string(name)) == aggregation {
+					buckets = append(buckets, b)
+				}
+				return nil
+			})
+			return resetBuckets(buckets, timezones, fromTime, toTime, anyRecord)
+		case RESERVE_STAT_AGGREGATION:
+			reserveStatBk := tx.Bucket([]byte(RESERVE_STAT_BUCKET))
+			if reserveStatBk == nil {
+				return nil
+			}
+			reserveStatBk.ForEach(func(k, v []byte) error {
+				if v == nil {
+					buckets = append(buckets, reserveStatBk.Bucket(k))
+				}
+				return nil
+			})
+			return resetBuckets(buckets, freqs, fromTime, toTime, anyRecord)
+		case USER_INFO_AGGREGATION:
+			// user infos are grouped by timestamp then user address, the
+			// whole timestamp buckets are removed
+			userListBk := tx.Bucket([]byte(USER_LIST_BUCKET))
+			if userListBk == nil {
+				return nil
+			}
+			for _, freq := range timezones {
+				timezoneBk := userListBk.Bucket([]byte(freq))
+				if timezoneBk == nil {
+					continue
+				}
+				timestamps := [][]byte{}
+				max := getTimestampByFreq(toTime, freq)
+				c := timezoneBk.Cursor()
+				for k, v := c.Seek(getTimestampByFreq(fromTime, freq)); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+					if v == nil {
+						timestamps = append(timestamps, k)
+					}
+				}
+				for _, k := range timestamps {
+					if err := timezoneBk.DeleteBucket(k); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("Aggregation %s can't be reset", aggregation)
+	})
+}
+
 func (self *BoltStatStorage) SetUserList(userInfos map[string]common.UserInfoTimezone, lastProcessTimePoint uint64) error {
 	err := self.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(USER_LIST_BUCKET))
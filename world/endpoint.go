@@ -6,12 +6,12 @@ import (
 )
 
 type Endpoint interface {
-	GoldDataEndpoint() string
-	BackupGoldDataEndpoint() string
+	GlobalDataSources() []GlobalDataSourceConfig
 }
 
 type RealEndpoint struct {
-	OneForgeKey string `json:"oneforge"`
+	OneForgeKey string                   `json:"oneforge"`
+	GoldSources []GlobalDataSourceConfig `json:"gold_sources"`
 }
 
 func (self RealEndpoint) GoldDataEndpoint() string {
@@ -22,6 +22,18 @@ func (self RealEndpoint) BackupGoldDataEndpoint() string {
 	return "https://forex.1forge.com/1.0.3/convert?from=XAU&to=ETH&quantity=1&api_key=" + self.OneForgeKey
 }
 
+// GlobalDataSources returns sources in "gold_sources" of the config file,
+// Digix and 1Forge are used if it is empty
+func (self RealEndpoint) GlobalDataSources() []GlobalDataSourceConfig {
+	if len(self.GoldSources) > 0 {
+		return self.GoldSources
+	}
+	return []GlobalDataSourceConfig{
+		{Name: "DGX", Type: "dgx", URL: self.GoldDataEndpoint()},
+		{Name: "OneForge", Type: "oneforge", URL: self.BackupGoldDataEndpoint()},
+	}
+}
+
 func NewRealEndpointFromFile(path string) (*RealEndpoint, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -42,3 +54,10 @@ func (self SimulatedEndpoint) GoldDataEndpoint() string {
 func (self SimulatedEndpoint) BackupGoldDataEndpoint() string {
 	return "http://simulator:5500/1.0.3/convert?from=XAU&to=ETH&quantity=1&api_key="
 }
+
+func (self SimulatedEndpoint) GlobalDataSources() []GlobalDataSourceConfig {
+	return []GlobalDataSourceConfig{
+		{Name: "DGX", Type: "dgx", URL: self.GoldDataEndpoint()},
+		{Name: "OneForge", Type: "oneforge", URL: self.BackupGoldDataEndpoint()},
+	}
+}
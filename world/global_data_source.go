@@ -0,0 +1,249 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// quotes older than DEFAULT_MAX_QUOTE_AGE seconds are stale unless a source
+// sets its own max_age
+const DEFAULT_MAX_QUOTE_AGE uint64 = 10 * 60
+
+// GlobalDataSource is a feed of a global price such as XAU-ETH or a fiat
+// FX rate
+type GlobalDataSource interface {
+	Name() string
+	GetQuote() common.GoldQuote
+}
+
+// GlobalDataSourceConfig is an entry of "gold_sources" in the config file.
+// Type selects the registered source implementation, Params are passed to
+// it as they are.
+type GlobalDataSourceConfig struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	URL    string            `json:"url"`
+	Pair   string            `json:"pair"`
+	MaxAge uint64            `json:"max_age"`
+	Params map[string]string `json:"params"`
+}
+
+type GlobalDataSourceFactory func(config GlobalDataSourceConfig) (GlobalDataSource, error)
+
+var globalDataSourceFactories = map[string]GlobalDataSourceFactory{
+	"dgx":      NewDGXSource,
+	"oneforge": NewOneForgeSource,
+	"json":     NewJSONSource,
+}
+
+// RegisterGlobalDataSource makes a source type available to "gold_sources"
+// config entries, it replaces the factory of a registered type
+func RegisterGlobalDataSource(sourceType string, factory GlobalDataSourceFactory) {
+	globalDataSourceFactories[sourceType] = factory
+}
+
+func NewGlobalDataSource(config GlobalDataSourceConfig) (GlobalDataSource, error) {
+	factory, found := globalDataSourceFactories[config.Type]
+	if !found {
+		return nil, fmt.Errorf("Global data source type %s is not supported", config.Type)
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("Global data source of type %s has no name", config.Type)
+	}
+	if config.Pair == "" {
+		config.Pair = common.GOLD_PAIR
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = DEFAULT_MAX_QUOTE_AGE
+	}
+	return factory(config)
+}
+
+func getJSON(url string, result interface{}) error {
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Add("Accept", "application/json")
+	log.Printf("request to gold feed endpoint: %s", req.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Gold feed returned with code: %d", resp.StatusCode)
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	log.Printf("request to %s, got response from gold feed %s", req.URL, respBody)
+	return json.Unmarshal(respBody, result)
+}
+
+// newQuote returns a valid quote, it is stale if timestamp is older than
+// maxAge seconds. Sources without quote time use the current time.
+func newQuote(config GlobalDataSourceConfig, rate float64, timestamp uint64) common.GoldQuote {
+	now := common.GetTimepoint()
+	if timestamp == 0 {
+		timestamp = now
+	}
+	quote := common.GoldQuote{
+		Pair:      config.Pair,
+		Rate:      rate,
+		Timestamp: timestamp,
+		Valid:     rate > 0,
+		Stale:     now > timestamp && now-timestamp > config.MaxAge*1000,
+	}
+	if !quote.Valid {
+		quote.Error = fmt.Sprintf("Invalid rate %f", rate)
+	}
+	return quote
+}
+
+func errorQuote(config GlobalDataSourceConfig, err error) common.GoldQuote {
+	return common.GoldQuote{
+		Pair:  config.Pair,
+		Valid: false,
+		Error: err.Error(),
+	}
+}
+
+// DGXSource prices XAU-ETH from XAUUSD and ETHUSD of the Digix tick feed
+type DGXSource struct {
+	config GlobalDataSourceConfig
+}
+
+func NewDGXSource(config GlobalDataSourceConfig) (GlobalDataSource, error) {
+	return &DGXSource{config}, nil
+}
+
+func (self *DGXSource) Name() string {
+	return self.config.Name
+}
+
+func (self *DGXSource) GetQuote() common.GoldQuote {
+	result := common.DGXGoldData{}
+	if err := getJSON(self.config.URL, &result); err != nil {
+		return errorQuote(self.config, err)
+	}
+	prices := map[string]common.GoldRate{}
+	for _, rate := range result.Data {
+		prices[rate.Symbol] = rate
+	}
+	xau, hasXAU := prices["XAUUSD"]
+	eth, hasETH := prices["ETHUSD"]
+	if !hasXAU || !hasETH || eth.Price <= 0 {
+		return errorQuote(self.config, fmt.Errorf("XAUUSD or ETHUSD is missing in Digix feed"))
+	}
+	timestamp := xau.Time
+	if eth.Time < timestamp {
+		timestamp = eth.Time
+	}
+	return newQuote(self.config, xau.Price/eth.Price, timestamp*1000)
+}
+
+// OneForgeSource gets XAU-ETH from 1Forge convert api
+type OneForgeSource struct {
+	config GlobalDataSourceConfig
+}
+
+func NewOneForgeSource(config GlobalDataSourceConfig) (GlobalDataSource, error) {
+	return &OneForgeSource{config}, nil
+}
+
+func (self *OneForgeSource) Name() string {
+	return self.config.Name
+}
+
+func (self *OneForgeSource) GetQuote() common.GoldQuote {
+	result := common.OneForgeGoldData{}
+	if err := getJSON(self.config.URL, &result); err != nil {
+		return errorQuote(self.config, err)
+	}
+	if result.Error {
+		return errorQuote(self.config, fmt.Errorf("1Forge returned error: %s", result.Message))
+	}
+	return newQuote(self.config, result.Value, result.Timestamp*1000)
+}
+
+// JSONSource reads a rate from any json api. Params:
+//   - rate_field: dot separated path of the rate, it may be a number or a string
+//   - time_field: optional dot separated path of the quote time in second
+//   - invert: "true" if the api returns the inverse of the pair rate
+type JSONSource struct {
+	config    GlobalDataSourceConfig
+	rateField []string
+	timeField []string
+	invert    bool
+}
+
+func NewJSONSource(config GlobalDataSourceConfig) (GlobalDataSource, error) {
+	if config.Params["rate_field"] == "" {
+		return nil, fmt.Errorf("Global data source %s has no rate_field param", config.Name)
+	}
+	source := &JSONSource{
+		config:    config,
+		rateField: strings.Split(config.Params["rate_field"], "."),
+		invert:    config.Params["invert"] == "true",
+	}
+	if config.Params["time_field"] != "" {
+		source.timeField = strings.Split(config.Params["time_field"], ".")
+	}
+	return source, nil
+}
+
+func (self *JSONSource) Name() string {
+	return self.config.Name
+}
+
+func jsonNumber(data interface{}, path []string) (float64, error) {
+	for _, key := range path {
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("%s is not an object", key)
+		}
+		if data, ok = object[key]; !ok {
+			return 0, fmt.Errorf("%s is missing", key)
+		}
+	}
+	switch value := data.(type) {
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	}
+	return 0, fmt.Errorf("%s is not a number", strings.Join(path, "."))
+}
+
+func (self *JSONSource) GetQuote() common.GoldQuote {
+	var result interface{}
+	if err := getJSON(self.config.URL, &result); err != nil {
+		return errorQuote(self.config, err)
+	}
+	rate, err := jsonNumber(result, self.rateField)
+	if err != nil {
+		return errorQuote(self.config, err)
+	}
+	if self.invert && rate != 0 {
+		rate = 1 / rate
+	}
+	var timestamp uint64
+	if self.timeField != nil {
+		seconds, err := jsonNumber(result, self.timeField)
+		if err != nil {
+			return errorQuote(self.config, err)
+		}
+		timestamp = uint64(seconds * 1000)
+	}
+	return newQuote(self.config, rate, timestamp)
+}
@@ -1,114 +1,51 @@
 package world
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"time"
+	"sync"
 
 	"github.com/KyberNetwork/reserve-data/common"
 )
 
 type TheWorld struct {
-	endpoint Endpoint
+	sources []GlobalDataSource
 }
 
-func (self *TheWorld) getOneForgeGoldInfo() common.OneForgeGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
+// GetGoldInfo queries all sources concurrently, failing sources are kept
+// as invalid quotes
+func (self *TheWorld) GetGoldInfo() (common.GoldData, error) {
+	quotes := make([]common.GoldQuote, len(self.sources))
+	wait := sync.WaitGroup{}
+	for i, source := range self.sources {
+		wait.Add(1)
+		go func(i int, source GlobalDataSource) {
+			defer wait.Done()
+			quotes[i] = source.GetQuote()
+		}(i, source)
 	}
-	url := self.endpoint.BackupGoldDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var resp_body []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.OneForgeGoldData{
-			Error:   true,
-			Message: err.Error(),
-		}
-	} else {
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			err = errors.New(fmt.Sprintf("Gold feed returned with code: %d", resp.StatusCode))
-			return common.OneForgeGoldData{
-				Error:   true,
-				Message: err.Error(),
-			}
-		}
-		resp_body, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return common.OneForgeGoldData{
-				Error:   true,
-				Message: err.Error(),
-			}
-		}
-		log.Printf("request to %s, got response from gold feed %s", req.URL, resp_body)
-		result := common.OneForgeGoldData{}
-		err = json.Unmarshal(resp_body, &result)
-		if err != nil {
-			result.Error = true
-			result.Message = err.Error()
-		}
-		return result
+	wait.Wait()
+	result := map[string]common.GoldQuote{}
+	for i, source := range self.sources {
+		result[source.Name()] = quotes[i]
 	}
+	return common.NewGoldData(common.GetTimepoint(), result), nil
 }
 
-func (self *TheWorld) getDGXGoldInfo() common.DGXGoldData {
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
-	}
-	url := self.endpoint.GoldDataEndpoint()
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("Accept", "application/json")
-	var err error
-	var resp_body []byte
-	log.Printf("request to gold feed endpoint: %s", req.URL)
-	resp, err := client.Do(req)
-	if err != nil {
-		return common.DGXGoldData{
-			Valid: false,
-			Error: err.Error(),
-		}
-	} else {
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			err = errors.New(fmt.Sprintf("Gold feed returned with code: %d", resp.StatusCode))
-			return common.DGXGoldData{
-				Valid: false,
-				Error: err.Error(),
-			}
-		}
-		resp_body, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return common.DGXGoldData{
-				Valid: false,
-				Error: err.Error(),
-			}
-		}
-		log.Printf("request to %s, got response from gold feed %s", req.URL, resp_body)
-		result := common.DGXGoldData{
-			Valid: true,
-		}
-		err = json.Unmarshal(resp_body, &result)
+func NewTheWorldFromEndpoint(endpoint Endpoint) (*TheWorld, error) {
+	sources := []GlobalDataSource{}
+	names := map[string]bool{}
+	for _, config := range endpoint.GlobalDataSources() {
+		if names[config.Name] {
+			return nil, fmt.Errorf("Global data source %s is duplicated", config.Name)
+		}
+		names[config.Name] = true
+		source, err := NewGlobalDataSource(config)
 		if err != nil {
-			result.Valid = false
-			result.Error = err.Error()
+			return nil, err
 		}
-		return result
+		sources = append(sources, source)
 	}
-}
-
-func (self *TheWorld) GetGoldInfo() (common.GoldData, error) {
-	return common.GoldData{
-		DGX:      self.getDGXGoldInfo(),
-		OneForge: self.getOneForgeGoldInfo(),
-	}, nil
+	return &TheWorld{sources}, nil
 }
 
 func NewTheWorld(env string, keyfile string) (*TheWorld, error) {
@@ -118,9 +55,9 @@ func NewTheWorld(env string, keyfile string) (*TheWorld, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &TheWorld{endpoint}, nil
+		return NewTheWorldFromEndpoint(endpoint)
 	case "simulation":
-		return &TheWorld{SimulatedEndpoint{}}, nil
+		return NewTheWorldFromEndpoint(SimulatedEndpoint{})
 	}
 	panic("unsupported environment")
 }
@@ -0,0 +1,89 @@
+package world
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testEndpoint struct {
+	sources []GlobalDataSourceConfig
+}
+
+func (self testEndpoint) GlobalDataSources() []GlobalDataSourceConfig {
+	return self.sources
+}
+
+func TestGetGoldInfo(t *testing.T) {
+	now := common.GetTimepoint() / 1000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tick":
+			fmt.Fprintf(w, `{"success":"true","data":[{"symbol":"ETHUSD","price":500,"time":%d},{"symbol":"XAUUSD","price":1000,"time":%d}]}`, now, now)
+		case "/oneforge":
+			fmt.Fprintf(w, `{"value":2.2,"text":"1 XAU is worth 2.2 ETH","timestamp":%d}`, now)
+		case "/ethxau":
+			fmt.Fprintf(w, `{"value":0.5,"text":"1 ETH is worth 0.5 XAU","timestamp":%d}`, now)
+		case "/stale":
+			fmt.Fprintf(w, `{"value":9,"timestamp":%d}`, now-3600)
+		case "/fx":
+			fmt.Fprint(w, `{"quote":{"price":"0.8"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	theWorld, err := NewTheWorldFromEndpoint(testEndpoint{[]GlobalDataSourceConfig{
+		{Name: "DGX", Type: "dgx", URL: server.URL + "/tick"},
+		{Name: "OneForge", Type: "oneforge", URL: server.URL + "/oneforge"},
+		{Name: "other", Type: "json", URL: server.URL + "/ethxau", Params: map[string]string{"rate_field": "value", "time_field": "timestamp", "invert": "true"}},
+		{Name: "stale", Type: "json", URL: server.URL + "/stale", Params: map[string]string{"rate_field": "value", "time_field": "timestamp"}},
+		{Name: "down", Type: "oneforge", URL: server.URL + "/down"},
+		{Name: "EURUSD", Type: "json", URL: server.URL + "/fx", Pair: "EURUSD", Params: map[string]string{"rate_field": "quote.price", "invert": "true"}},
+	}})
+	if err != nil {
+		t.Fatalf("Creating the world failed: %s", err)
+	}
+	data, err := theWorld.GetGoldInfo()
+	if err != nil {
+		t.Fatalf("Getting gold info failed: %s", err)
+	}
+	if len(data.Sources) != 6 {
+		t.Fatalf("Expected quotes of 6 sources, got %+v", data.Sources)
+	}
+	if quote := data.Sources["DGX"]; !quote.Valid || quote.Rate != 2 || quote.Pair != common.GOLD_PAIR {
+		t.Fatalf("Expected DGX rate 2, got %+v", quote)
+	}
+	if quote := data.Sources["other"]; !quote.Valid || quote.Rate != 2 || quote.Pair != common.GOLD_PAIR {
+		t.Fatalf("Expected inverted ETHXAU rate 2, got %+v", quote)
+	}
+	if quote := data.Sources["stale"]; !quote.Valid || !quote.Stale {
+		t.Fatalf("Expected stale quote, got %+v", quote)
+	}
+	if quote := data.Sources["down"]; quote.Valid || quote.Error == "" {
+		t.Fatalf("Expected invalid quote of a failing source, got %+v", quote)
+	}
+	if quote := data.Sources["EURUSD"]; !quote.Valid || quote.Rate != 1.25 {
+		t.Fatalf("Expected EURUSD rate 1.25, got %+v", quote)
+	}
+	// median of DGX (2), OneForge (2.2) and inverted other (1/0.5)
+	if data.Median != 2 {
+		t.Fatalf("Expected median 2, got %f", data.Median)
+	}
+}
+
+func TestNewTheWorldFromEndpoint(t *testing.T) {
+	if _, err := NewTheWorldFromEndpoint(testEndpoint{[]GlobalDataSourceConfig{{Name: "x", Type: "unknown"}}}); err == nil {
+		t.Fatalf("Expected error for unsupported source type")
+	}
+	if _, err := NewTheWorldFromEndpoint(testEndpoint{[]GlobalDataSourceConfig{{Name: "x", Type: "json"}}}); err == nil {
+		t.Fatalf("Expected error for json source without rate_field")
+	}
+	duplicated := []GlobalDataSourceConfig{{Name: "x", Type: "dgx"}, {Name: "x", Type: "oneforge"}}
+	if _, err := NewTheWorldFromEndpoint(testEndpoint{duplicated}); err == nil {
+		t.Fatalf("Expected error for duplicated source names")
+	}
+}